@@ -1,6 +1,50 @@
 package sabakan
 
-import "time"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxAssetUploadParts is the maximum number of parts of an AssetUpload.
+const MaxAssetUploadParts = 10000
+
+// AssetOptionTTL is the name of the asset option that specifies the time to
+// live of an asset as a duration string such as "72h".  Assets are removed
+// automatically when their TTL has passed since they were uploaded.
+const AssetOptionTTL = "ttl"
+
+// Asset options to restrict downloads of an asset to some machines.
+// If either is set, only machines that match both of them can download the asset.
+const (
+	// AssetOptionAllowRoles is a comma-separated list of machine roles.
+	AssetOptionAllowRoles = "allow-roles"
+
+	// AssetOptionAllowLabels is a label selector such as "datacenter=dc1,!spare".
+	AssetOptionAllowLabels = "allow-labels"
+)
+
+// MaxAssetSize is the maximum size of an asset.
+const MaxAssetSize = 2 << 31
+
+// HeaderMachineToken is the HTTP header name for a machine to identify itself
+// by a token issued with MachineModel.IssueToken.
+const HeaderMachineToken = "X-Sabakan-Machine-Token"
+
+var (
+	// ErrChecksumMismatch is returned when uploaded data does not match its checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrIncompleteUpload is returned when parts of an upload do not make up the asset.
+	ErrIncompleteUpload = errors.New("incomplete upload")
+
+	// ErrQuotaExceeded is returned when the total size of assets would exceed the quota.
+	ErrQuotaExceeded = errors.New("asset quota exceeded")
+)
 
 // Asset represents an asset.
 type Asset struct {
@@ -13,6 +57,87 @@ type Asset struct {
 	URLs        []string          `json:"urls"`
 	Exists      bool              `json:"exists"`
 	Options     map[string]string `json:"options"`
+
+	// History is a list of IDs of the previous versions kept for rollback.
+	// The newest one comes first.
+	History []int `json:"history,omitempty"`
+}
+
+// Expires returns the time when the asset expires.
+// ok is false if the asset has no valid TTL.
+func (a *Asset) Expires() (t time.Time, ok bool) {
+	v, ok := a.Options[AssetOptionTTL]
+	if !ok {
+		return time.Time{}, false
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return time.Time{}, false
+	}
+	return a.Date.Add(ttl), true
+}
+
+// IsRestricted returns true if downloads of the asset are restricted to some machines.
+func (a *Asset) IsRestricted() bool {
+	_, roles := a.Options[AssetOptionAllowRoles]
+	_, labels := a.Options[AssetOptionAllowLabels]
+	return roles || labels
+}
+
+// AllowsMachine returns true if m is allowed to download the asset.
+func (a *Asset) AllowsMachine(m *Machine) bool {
+	q := Query{
+		"role":   a.Options[AssetOptionAllowRoles],
+		"labels": a.Options[AssetOptionAllowLabels],
+	}
+	ok, err := q.Match(m)
+	return err == nil && ok
+}
+
+// AssetRequester identifies a client that downloads an asset.
+type AssetRequester struct {
+	// IP is the source address of the request.
+	IP net.IP
+
+	// Token is the machine token sent by the client, if any.
+	Token string
+
+	// Trusted is true if the client can download any asset,
+	// e.g. an administrator permitted by allow-ips.
+	Trusted bool
+}
+
+// ValidateAssetOptions validates options of an asset.
+func ValidateAssetOptions(options map[string]string) error {
+	for k, v := range options {
+		if !IsValidLabelName(k) {
+			return errors.New("invalid option key: " + k)
+		}
+		switch k {
+		case AssetOptionAllowRoles:
+			for _, role := range strings.Split(v, ",") {
+				if !IsValidRole(role) {
+					return errors.New("invalid role in allow-roles: " + role)
+				}
+			}
+		case AssetOptionAllowLabels:
+			sel, err := ParseLabelSelector(v)
+			if err != nil || len(sel) == 0 {
+				return errors.New("invalid allow-labels: " + v)
+			}
+		default:
+			if !IsValidLabelValue(v) {
+				return errors.New("invalid option value: " + v)
+			}
+		}
+	}
+	if v, ok := options[AssetOptionTTL]; ok {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return errors.New("invalid ttl: " + v)
+		}
+	}
+	return nil
 }
 
 // AssetStatus is the status of an asset.
@@ -20,3 +145,87 @@ type AssetStatus struct {
 	Status int `json:"status"`
 	ID     int `json:"id,string"`
 }
+
+// AssetUpload represents a resumable upload of an asset.
+//
+// The contents are uploaded as numbered parts, then committed to be
+// the current version of the asset.
+type AssetUpload struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	ContentType string            `json:"content-type"`
+	Size        int64             `json:"size"`
+	Sha256      string            `json:"sha256"`
+	Options     map[string]string `json:"options,omitempty"`
+	Date        time.Time         `json:"date"`
+	Parts       []AssetUploadPart `json:"parts"`
+}
+
+// AssetUploadPart is an uploaded part of AssetUpload.
+type AssetUploadPart struct {
+	Number int    `json:"number"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Validate validates the declared attributes of the upload.
+func (u *AssetUpload) Validate() error {
+	if len(u.ContentType) == 0 {
+		return errors.New("content-type is required")
+	}
+	if u.Size < 0 {
+		return errors.New("invalid size")
+	}
+	csum, err := hex.DecodeString(u.Sha256)
+	if err != nil || len(csum) != 32 {
+		return errors.New("invalid sha256: " + u.Sha256)
+	}
+	return ValidateAssetOptions(u.Options)
+}
+
+// VerifyParts returns ErrIncompleteUpload if the parts are not numbered
+// from 1 consecutively, or their total size differs from the declared size.
+// Parts must be sorted by their numbers.
+func (u *AssetUpload) VerifyParts() error {
+	var size int64
+	for i, p := range u.Parts {
+		if p.Number != i+1 {
+			return fmt.Errorf("%w: part %d is missing", ErrIncompleteUpload, i+1)
+		}
+		size += p.Size
+	}
+	if size != u.Size {
+		return fmt.Errorf("%w: uploaded %d bytes of %d bytes", ErrIncompleteUpload, size, u.Size)
+	}
+	return nil
+}
+
+// AssetGCReport is a report of assets that no ignition template references.
+type AssetGCReport struct {
+	// Unreferenced is a list of the unreferenced assets.
+	Unreferenced []*Asset `json:"unreferenced"`
+
+	// Size is the total size of the unreferenced assets.
+	Size int64 `json:"size"`
+
+	// Removed is true if the unreferenced assets have been removed.
+	Removed bool `json:"removed"`
+}
+
+// NewAssetGCReport returns a report of assets not in refs.
+func NewAssetGCReport(assets []*Asset, refs map[string]bool) *AssetGCReport {
+	report := &AssetGCReport{
+		Unreferenced: []*Asset{},
+	}
+	for _, a := range assets {
+		if refs[a.Name] {
+			continue
+		}
+		report.Unreferenced = append(report.Unreferenced, a)
+		report.Size += a.Size
+	}
+	sort.Slice(report.Unreferenced, func(i, j int) bool {
+		return report.Unreferenced[i].Name < report.Unreferenced[j].Name
+	})
+	return report
+}
@@ -0,0 +1,90 @@
+package sabakan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssetExpires(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		ttl      string
+		expires  time.Time
+		hasValue bool
+	}{
+		{"", time.Time{}, false},
+		{"72h", date.Add(72 * time.Hour), true},
+		{"0s", time.Time{}, false},
+		{"foo", time.Time{}, false},
+	}
+	for _, c := range cases {
+		a := &Asset{Date: date, Options: map[string]string{}}
+		if c.ttl != "" {
+			a.Options[AssetOptionTTL] = c.ttl
+		}
+		expires, ok := a.Expires()
+		if ok != c.hasValue || !expires.Equal(c.expires) {
+			t.Errorf("ttl %q: unexpected expiration %v, %v", c.ttl, expires, ok)
+		}
+	}
+}
+
+func TestValidateAssetOptions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		options map[string]string
+		valid   bool
+	}{
+		{nil, true},
+		{map[string]string{"version": "1.0.0", "ttl": "1h30m"}, true},
+		{map[string]string{"version?": "1.0.0"}, false},
+		{map[string]string{"ttl": "-1h"}, false},
+		{map[string]string{"ttl": "1d"}, false},
+		{map[string]string{"allow-roles": "boot,worker", "allow-labels": "datacenter in (dc1,dc2),!spare"}, true},
+		{map[string]string{"allow-roles": "boot,"}, false},
+		{map[string]string{"allow-labels": ""}, false},
+		{map[string]string{"allow-labels": "datacenter=dc1,datacenter=(dc2"}, false},
+		{map[string]string{"version": "1,0"}, false},
+	}
+	for _, c := range cases {
+		err := ValidateAssetOptions(c.options)
+		if (err == nil) != c.valid {
+			t.Errorf("%v: unexpected result: %v", c.options, err)
+		}
+	}
+}
+
+func TestAssetAllowsMachine(t *testing.T) {
+	t.Parallel()
+
+	m := NewMachine(MachineSpec{
+		Serial: "1234",
+		Role:   "worker",
+		Labels: map[string]string{"datacenter": "dc1"},
+	})
+
+	cases := []struct {
+		options    map[string]string
+		restricted bool
+		allowed    bool
+	}{
+		{nil, false, true},
+		{map[string]string{"allow-roles": "boot,worker"}, true, true},
+		{map[string]string{"allow-roles": "boot"}, true, false},
+		{map[string]string{"allow-labels": "datacenter=dc1"}, true, true},
+		{map[string]string{"allow-labels": "datacenter!=dc1"}, true, false},
+		{map[string]string{"allow-roles": "worker", "allow-labels": "spare"}, true, false},
+	}
+	for _, c := range cases {
+		a := &Asset{Name: "foo", Options: c.options}
+		if a.IsRestricted() != c.restricted {
+			t.Errorf("%v: IsRestricted() should be %v", c.options, c.restricted)
+		}
+		if c.restricted && a.AllowsMachine(m) != c.allowed {
+			t.Errorf("%v: AllowsMachine() should be %v", c.options, c.allowed)
+		}
+	}
+}
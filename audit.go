@@ -10,14 +10,16 @@ type AuditCategory string
 
 // Audit categories.
 const (
-	AuditAssets   = AuditCategory("assets")
-	AuditCrypts   = AuditCategory("crypts")
-	AuditDHCP     = AuditCategory("dhcp")
-	AuditIgnition = AuditCategory("ignition")
-	AuditImage    = AuditCategory("image")
-	AuditIPAM     = AuditCategory("ipam")
-	AuditIPXE     = AuditCategory("ipxe")
-	AuditMachines = AuditCategory("machines")
+	AuditAssets    = AuditCategory("assets")
+	AuditCrypts    = AuditCategory("crypts")
+	AuditDHCP      = AuditCategory("dhcp")
+	AuditIgnition  = AuditCategory("ignition")
+	AuditImage     = AuditCategory("image")
+	AuditInventory = AuditCategory("inventory")
+	AuditIPAM      = AuditCategory("ipam")
+	AuditIPXE      = AuditCategory("ipxe")
+	AuditMachines  = AuditCategory("machines")
+	AuditSecrets   = AuditCategory("secrets")
 )
 
 // AuditLog represents an audit log entry.
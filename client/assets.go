@@ -2,13 +2,17 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
@@ -95,7 +99,269 @@ func (c *Client) AssetsUpload(ctx context.Context, name, filename string, meta m
 	return &result, nil
 }
 
+// AssetsUploads retrieves resumable uploads of an asset in progress
+func (c *Client) AssetsUploads(ctx context.Context, name string) ([]*sabakan.AssetUpload, error) {
+	var uploads []*sabakan.AssetUpload
+	err := c.getJSON(ctx, path.Join("assets", name, "uploads"), nil, &uploads)
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// AssetsUploadAbort aborts a resumable upload of an asset
+func (c *Client) AssetsUploadAbort(ctx context.Context, name, id string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("assets", name, "uploads", id), nil)
+}
+
+// AssetsUploadResumable stores a file as an asset by uploading it in parts
+// of partSize bytes.
+//
+// If an upload of the same contents is in progress, the parts that have
+// already been uploaded are skipped.  This allows resuming an interrupted
+// upload by calling this again.
+func (c *Client) AssetsUploadResumable(ctx context.Context, name, filename string, meta map[string]string, partSize int64) (*sabakan.AssetStatus, error) {
+	if partSize <= 0 {
+		return nil, errors.New("invalid part size")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	contentType, err := detectContentTypeFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return nil, err
+	}
+	if (size+partSize-1)/partSize > sabakan.MaxAssetUploadParts {
+		return nil, fmt.Errorf("too many parts; part size should be larger than %d bytes", size/sabakan.MaxAssetUploadParts)
+	}
+	csum := hex.EncodeToString(h.Sum(nil))
+
+	uploads, err := c.AssetsUploads(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var upload *sabakan.AssetUpload
+	for _, u := range uploads {
+		if u.Sha256 == csum && u.Size == size && u.ContentType == contentType && equalOptions(u.Options, meta) {
+			upload = u
+		}
+	}
+	if upload == nil {
+		upload = &sabakan.AssetUpload{
+			ContentType: contentType,
+			Size:        size,
+			Sha256:      csum,
+			Options:     meta,
+		}
+		if len(meta) == 0 {
+			upload.Options = nil
+		}
+		upload, err = c.assetsUploadCreate(ctx, name, upload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uploaded := make(map[int]sabakan.AssetUploadPart)
+	for _, p := range upload.Parts {
+		uploaded[p.Number] = p
+	}
+	for n := 1; int64(n-1)*partSize < size || n == 1; n++ {
+		offset := int64(n-1) * partSize
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		r := io.NewSectionReader(file, offset, length)
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		if err != nil {
+			return nil, err
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		if p, ok := uploaded[n]; ok && p.Size == length && p.Sha256 == sum {
+			continue
+		}
+
+		err = c.assetsUploadPart(ctx, name, upload.ID, n, sum, io.NewSectionReader(file, offset, length), length)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var status sabakan.AssetStatus
+	err = c.sendRequestWithJSONResult(ctx, "POST", path.Join("assets", name, "uploads", upload.ID, "commit"), nil, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func equalOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) assetsUploadCreate(ctx context.Context, name string, upload *sabakan.AssetUpload) (*sabakan.AssetUpload, error) {
+	var result sabakan.AssetUpload
+	err := c.sendRequestWithJSONResult(ctx, "POST", path.Join("assets", name, "uploads"), nil, upload, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) assetsUploadPart(ctx context.Context, name, id string, number int, sum string, r io.Reader, size int64) error {
+	req := c.newRequest(ctx, "PUT", path.Join("assets", name, "uploads", id, strconv.Itoa(number)), r)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Sabakan-Part-SHA256", sum)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// AssetsDownload downloads an asset to filename.
+//
+// The contents are written to "<filename>.<SHA256>.part" at first, and the file
+// is renamed to filename after its checksum is verified.  If the download is
+// interrupted, calling this again resumes from the end of the partial file
+// unless the asset has been updated.
+func (c *Client) AssetsDownload(ctx context.Context, name, filename string) error {
+	asset, err := c.AssetsInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	part := filename + "." + asset.Sha256 + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > asset.Size {
+		offset = 0
+	}
+
+	csum := asset.Sha256
+	if offset < asset.Size {
+		req := c.newRequest(ctx, "GET", path.Join("assets", name), nil)
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", strconv.Quote(asset.Sha256))
+		}
+		resp, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusPartialContent {
+			// the whole contents are returned
+			offset = 0
+		}
+		if v := resp.Header.Get("X-Sabakan-Asset-SHA256"); v != "" {
+			csum = v
+		}
+
+		err = f.Truncate(offset)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(offset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, resp.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != csum {
+		os.Remove(part)
+		return fmt.Errorf("checksum mismatch for asset %s", name)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(part, filename)
+}
+
 // AssetsDelete deletes an asset
 func (c *Client) AssetsDelete(ctx context.Context, name string) error {
 	return c.sendRequest(ctx, "DELETE", path.Join("assets", name), nil)
 }
+
+// AssetsHistory retrieves meta data of the versions of an asset kept for rollback
+func (c *Client) AssetsHistory(ctx context.Context, name string) ([]*sabakan.Asset, error) {
+	var history []*sabakan.Asset
+	err := c.getJSON(ctx, path.Join("assets", name, "history"), nil, &history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// AssetsRollback makes the version of id the current version of an asset
+func (c *Client) AssetsRollback(ctx context.Context, name string, id int) (*sabakan.AssetStatus, error) {
+	var status sabakan.AssetStatus
+	params := map[string]string{"id": strconv.Itoa(id)}
+	err := c.sendRequestWithJSONResult(ctx, "POST", path.Join("assets", name, "rollback"), params, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// AssetsGC removes assets that no ignition template references.
+// If dryRun is true, this only reports the assets.
+func (c *Client) AssetsGC(ctx context.Context, dryRun bool) (*sabakan.AssetGCReport, error) {
+	var report sabakan.AssetGCReport
+	params := make(map[string]string)
+	if dryRun {
+		params["dry-run"] = "true"
+	}
+	err := c.sendRequestWithJSONResult(ctx, "POST", "gc/assets", params, nil, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+	"github.com/cybozu-go/sabakan/v3/web"
+)
+
+func newTestAssetServer(t *testing.T, content string) (*Client, *[]string) {
+	m := mock.NewModel()
+	_, err := m.Asset.Put(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("http://localhost:10080")
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	handler := web.NewServer(m, "", "", u, u, []*net.IPNet{loopback}, false, nil, false)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/assets/foo" {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, &ranges
+}
+
+func testAssetsDownloadResume(t *testing.T) {
+	t.Parallel()
+
+	c, ranges := newTestAssetServer(t, "0123456789")
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "foo")
+
+	asset, err := c.AssetsInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := filename + "." + asset.Sha256 + ".part"
+	err = os.WriteFile(part, []byte("0123"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.AssetsDownload(ctx, "foo", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Error("wrong content:", string(data))
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Error("partial file was not removed:", err)
+	}
+	if len(*ranges) != 1 || (*ranges)[0] != "bytes=4-" {
+		t.Error("download was not resumed:", *ranges)
+	}
+
+	// whole contents
+	err = os.Remove(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.AssetsDownload(ctx, "foo", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Error("wrong content:", string(data))
+	}
+	if len(*ranges) != 2 || (*ranges)[1] != "" {
+		t.Error("Range should not be requested:", *ranges)
+	}
+}
+
+func testAssetsDownloadBroken(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestAssetServer(t, "0123456789")
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "foo")
+
+	asset, err := c.AssetsInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := filename + "." + asset.Sha256 + ".part"
+	err = os.WriteFile(part, []byte("abcd"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.AssetsDownload(ctx, "foo", filename)
+	if err == nil {
+		t.Error("broken partial file should be detected")
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Error("broken partial file was not removed:", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("broken file should not be created:", err)
+	}
+
+	// retry from the beginning
+	err = c.AssetsDownload(ctx, "foo", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.AssetsDownload(ctx, "bar", filename)
+	if !IsNotFound(err) {
+		t.Error("missing asset should not be found:", err)
+	}
+}
+
+func TestAssetsDownload(t *testing.T) {
+	t.Run("Resume", testAssetsDownloadResume)
+	t.Run("Broken", testAssetsDownloadBroken)
+}
+
+type recordTransport struct {
+	http.RoundTripper
+	requests []string
+}
+
+func (t *recordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, r.Method+" "+r.URL.Path)
+	return t.RoundTripper.RoundTrip(r)
+}
+
+func testAssetsUploadResumable(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestAssetServer(t, "")
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "bar.txt")
+	err := os.WriteFile(filename, []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.AssetsUploadResumable(ctx, "bar", filename, map[string]string{"version": "1"}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != http.StatusCreated {
+		t.Error("status.Status != http.StatusCreated:", status.Status)
+	}
+
+	asset, err := c.AssetsInfo(ctx, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Size != 10 || asset.ContentType != "text/plain; charset=utf-8" || asset.Options["version"] != "1" {
+		t.Error("wrong asset:", asset)
+	}
+	data, err := c.getBytes(ctx, "assets/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Error("wrong content:", string(data))
+	}
+	uploads, err := c.AssetsUploads(ctx, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 0 {
+		t.Error("uploads remain:", uploads)
+	}
+
+	_, err = c.AssetsUploadResumable(ctx, "bar", filename, nil, 0)
+	if err == nil {
+		t.Error("invalid part size should be rejected")
+	}
+}
+
+func testAssetsUploadResume(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestAssetServer(t, "")
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "bar.txt")
+	err := os.WriteFile(filename, []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an interrupted upload with part 1 and a broken part 2
+	upload, err := c.assetsUploadCreate(ctx, "bar", &sabakan.AssetUpload{
+		ContentType: "text/plain; charset=utf-8",
+		Size:        10,
+		Sha256:      sha256Hex("0123456789"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.assetsUploadPart(ctx, "bar", upload.ID, 1, sha256Hex("0123"), strings.NewReader("0123"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.assetsUploadPart(ctx, "bar", upload.ID, 2, sha256Hex("abcd"), strings.NewReader("abcd"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &recordTransport{RoundTripper: c.http.Transport}
+	c.http = &http.Client{Transport: tr}
+	_, err = c.AssetsUploadResumable(ctx, "bar", filename, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "/api/v1/assets/bar/uploads/" + upload.ID
+	expected := []string{
+		"GET /api/v1/assets/bar/uploads",
+		"PUT " + prefix + "/2",
+		"PUT " + prefix + "/3",
+		"POST " + prefix + "/commit",
+	}
+	if !reflect.DeepEqual(tr.requests, expected) {
+		t.Error("upload was not resumed:", tr.requests)
+	}
+
+	data, err := c.getBytes(ctx, "assets/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Error("wrong content:", string(data))
+	}
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAssetsUploadResumable(t *testing.T) {
+	t.Run("Upload", testAssetsUploadResumable)
+	t.Run("Resume", testAssetsUploadResume)
+}
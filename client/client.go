@@ -82,6 +82,12 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 }
 
 func (c *Client) getJSON(ctx context.Context, p string, params map[string]string, data interface{}) error {
+	_, err := c.getJSONWithHeader(ctx, p, params, data)
+	return err
+}
+
+// getJSONWithHeader is the same as getJSON but also returns the response header.
+func (c *Client) getJSONWithHeader(ctx context.Context, p string, params map[string]string, data interface{}) (http.Header, error) {
 	req := c.newRequest(ctx, "GET", p, nil)
 	q := req.URL.Query()
 	for k, v := range params {
@@ -91,16 +97,16 @@ func (c *Client) getJSON(ctx context.Context, p string, params map[string]string
 
 	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	err = json.NewDecoder(resp.Body).Decode(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return resp.Header, nil
 }
 
 func (c *Client) getBytes(ctx context.Context, p string) ([]byte, error) {
@@ -135,6 +141,36 @@ func (c *Client) sendRequestWithJSON(ctx context.Context, method, p string, data
 	return nil
 }
 
+// sendRequestWithJSONResult sends data as JSON with params and decodes
+// the JSON response body into result.  data and result may be nil.
+func (c *Client) sendRequestWithJSONResult(ctx context.Context, method, p string, params map[string]string, data, result interface{}) error {
+	b := new(bytes.Buffer)
+	if data != nil {
+		err := json.NewEncoder(b).Encode(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := c.newRequest(ctx, method, p, b)
+	q := req.URL.Query()
+	for k, v := range params {
+		q.Add(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
 func (c *Client) sendRequest(ctx context.Context, method, p string, r io.Reader) error {
 	req := c.newRequest(ctx, method, p, r)
 	resp, err := c.do(req)
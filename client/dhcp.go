@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"path"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
@@ -20,3 +21,49 @@ func (c *Client) DHCPConfigGet(ctx context.Context) (*sabakan.DHCPConfig, error)
 func (c *Client) DHCPConfigSet(ctx context.Context, conf *sabakan.DHCPConfig) error {
 	return c.sendRequestWithJSON(ctx, "PUT", "config/dhcp", conf)
 }
+
+// DHCPLeasesList retrieves active DHCP leases.
+// params may have "ip", "mac" and/or "declined" to filter leases.
+func (c *Client) DHCPLeasesList(ctx context.Context, params map[string]string) ([]*sabakan.DHCPLease, error) {
+	var leases []*sabakan.DHCPLease
+	err := c.getJSON(ctx, "dhcp/leases", params, &leases)
+	if err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// DHCPLeaseDelete releases the DHCP lease of ip forcibly.
+func (c *Client) DHCPLeaseDelete(ctx context.Context, ip string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("dhcp/leases", ip), nil)
+}
+
+// DHCPReservationsList retrieves static DHCP reservations.
+func (c *Client) DHCPReservationsList(ctx context.Context) ([]*sabakan.DHCPReservation, error) {
+	var reservations []*sabakan.DHCPReservation
+	err := c.getJSON(ctx, "dhcp/reservations", nil, &reservations)
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// DHCPReservationGet retrieves the static DHCP reservation of mac.
+func (c *Client) DHCPReservationGet(ctx context.Context, mac string) (*sabakan.DHCPReservation, error) {
+	r := new(sabakan.DHCPReservation)
+	err := c.getJSON(ctx, path.Join("dhcp/reservations", mac), nil, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// DHCPReservationSet adds or updates a static DHCP reservation.
+func (c *Client) DHCPReservationSet(ctx context.Context, r *sabakan.DHCPReservation) error {
+	return c.sendRequestWithJSON(ctx, "PUT", path.Join("dhcp/reservations", r.MAC), r)
+}
+
+// DHCPReservationDelete deletes the static DHCP reservation of mac.
+func (c *Client) DHCPReservationDelete(ctx context.Context, mac string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("dhcp/reservations", mac), nil)
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// importPollInterval is the interval to poll the status of an import.
+var importPollInterval = 2 * time.Second
+
+func (c *Client) startImport(ctx context.Context, p, src, sum string, meta map[string]string) (*sabakan.ImportStatus, error) {
+	req := c.newRequest(ctx, "POST", p, nil)
+	req.URL.RawQuery = url.Values{"from": {src}, "sha256": {sum}}.Encode()
+	for k, v := range meta {
+		req.Header.Set(fmt.Sprintf("X-Sabakan-Asset-Options-%s", k), v)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var status sabakan.ImportStatus
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// waitImport polls the status of an import until it finishes.
+// f is called with each status unless it is nil.
+func waitImport(ctx context.Context, get func() (*sabakan.ImportStatus, error), f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error) {
+	for {
+		status, err := get()
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			f(status)
+		}
+
+		switch status.State {
+		case sabakan.ImportCompleted:
+			return status, nil
+		case sabakan.ImportFailed:
+			return status, errors.New("import failed: " + status.Error)
+		}
+
+		select {
+		case <-time.After(importPollInterval):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
+
+// AssetsImport makes the server fetch an asset from src.
+// sum is the hex-encoded SHA256 checksum of the asset.
+func (c *Client) AssetsImport(ctx context.Context, name, src, sum string, meta map[string]string) (*sabakan.ImportStatus, error) {
+	return c.startImport(ctx, "assets/"+name, src, sum, meta)
+}
+
+// AssetsImportStatus retrieves the status of the last import of an asset.
+func (c *Client) AssetsImportStatus(ctx context.Context, name string) (*sabakan.ImportStatus, error) {
+	var status sabakan.ImportStatus
+	err := c.getJSON(ctx, "assets/"+name+"/import", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// AssetsImportWait waits for the import of an asset to finish.
+// f is called with the status each time it is polled unless it is nil.
+func (c *Client) AssetsImportWait(ctx context.Context, name string, f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error) {
+	return waitImport(ctx, func() (*sabakan.ImportStatus, error) {
+		return c.AssetsImportStatus(ctx, name)
+	}, f)
+}
+
+// AssetsImportCancel cancels the running import of an asset.
+func (c *Client) AssetsImportCancel(ctx context.Context, name string) error {
+	return c.sendRequest(ctx, "DELETE", "assets/"+name+"/import", nil)
+}
+
+// ImagesImport makes the server fetch a tar archive of an image from src.
+// sum is the hex-encoded SHA256 checksum of the archive.
+func (c *Client) ImagesImport(ctx context.Context, os, id, src, sum string) (*sabakan.ImportStatus, error) {
+	return c.startImport(ctx, path.Join("images", os, id), src, sum, nil)
+}
+
+// ImagesImportStatus retrieves the status of the last import of an image.
+func (c *Client) ImagesImportStatus(ctx context.Context, os, id string) (*sabakan.ImportStatus, error) {
+	var status sabakan.ImportStatus
+	err := c.getJSON(ctx, path.Join("images", os, id, "import"), nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ImagesImportWait waits for the import of an image to finish.
+// f is called with the status each time it is polled unless it is nil.
+func (c *Client) ImagesImportWait(ctx context.Context, os, id string, f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error) {
+	return waitImport(ctx, func() (*sabakan.ImportStatus, error) {
+		return c.ImagesImportStatus(ctx, os, id)
+	}, f)
+}
+
+// ImagesImportCancel cancels the running import of an image.
+func (c *Client) ImagesImportCancel(ctx context.Context, os, id string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("images", os, id, "import"), nil)
+}
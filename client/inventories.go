@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"path"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// InventoriesList gets the latest inventories of machines that match filter.
+func (c *Client) InventoriesList(ctx context.Context, filter string) ([]sabakan.Inventory, error) {
+	var inventories []sabakan.Inventory
+	var params map[string]string
+	if len(filter) > 0 {
+		params = map[string]string{"filter": filter}
+	}
+	err := c.getJSON(ctx, "inventories", params, &inventories)
+	if err != nil {
+		return nil, err
+	}
+	return inventories, nil
+}
+
+// InventoriesGet gets the latest inventory of a machine.
+func (c *Client) InventoriesGet(ctx context.Context, serial string) (*sabakan.Inventory, error) {
+	inv := new(sabakan.Inventory)
+	err := c.getJSON(ctx, path.Join("inventories", serial), nil, inv)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// InventoriesHistory gets the stored versions of the inventory of a machine.
+func (c *Client) InventoriesHistory(ctx context.Context, serial string) ([]sabakan.Inventory, error) {
+	var history []sabakan.Inventory
+	err := c.getJSON(ctx, path.Join("inventories", serial, "history"), nil, &history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// InventoriesPut uploads the inventory of a machine to sabakan HTTPS server.
+func (c *Client) InventoriesPut(ctx context.Context, serial string, inv *sabakan.Inventory) error {
+	return c.sendRequestWithJSON(ctx, "PUT", path.Join("inventories", serial), inv)
+}
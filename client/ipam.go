@@ -20,3 +20,35 @@ func (c *Client) IPAMConfigGet(ctx context.Context) (*sabakan.IPAMConfig, error)
 func (c *Client) IPAMConfigSet(ctx context.Context, conf *sabakan.IPAMConfig) error {
 	return c.sendRequestWithJSON(ctx, "PUT", "config/ipam", conf)
 }
+
+// IPAMConfigForceSet sets IPAM configurations even if they would change
+// addresses of registered machines.
+func (c *Client) IPAMConfigForceSet(ctx context.Context, conf *sabakan.IPAMConfig) error {
+	return c.sendRequestWithJSONResult(ctx, "PUT", "config/ipam", map[string]string{"force": "true"}, conf, nil)
+}
+
+// IPAMConfigDryRun returns changes of addresses of registered machines
+// and conflicts by IPAM configurations without setting them.
+func (c *Client) IPAMConfigDryRun(ctx context.Context, conf *sabakan.IPAMConfig) (*sabakan.IPAMDryRun, error) {
+	result := new(sabakan.IPAMDryRun)
+	err := c.sendRequestWithJSONResult(ctx, "PUT", "config/ipam", map[string]string{"dry-run": "true"}, conf, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// IPAMMigrate rewrites addresses of registered machines with the current
+// IPAM configurations.  If dryRun is true, this only returns the changes.
+func (c *Client) IPAMMigrate(ctx context.Context, dryRun bool) ([]*sabakan.IPAMChange, error) {
+	params := map[string]string{}
+	if dryRun {
+		params["dry-run"] = "true"
+	}
+	var changes []*sabakan.IPAMChange
+	err := c.sendRequestWithJSONResult(ctx, "POST", "config/ipam/migrate", params, nil, &changes)
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
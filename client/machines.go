@@ -10,14 +10,42 @@ import (
 	"github.com/cybozu-go/sabakan/v3"
 )
 
-// MachinesGet get machine information from sabakan server
+// MachinesGet get machine information from sabakan server.
+//
+// If params has "limit", machines are retrieved page by page
+// and all of them are returned.
 func (c *Client) MachinesGet(ctx context.Context, params map[string]string) ([]sabakan.Machine, error) {
+	p := make(map[string]string, len(params))
+	for k, v := range params {
+		p[k] = v
+	}
+
+	var machines []sabakan.Machine
+	for {
+		page, next, err := c.MachinesGetPage(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, page...)
+		if len(next) == 0 {
+			return machines, nil
+		}
+		p["continue"] = next
+	}
+}
+
+// MachinesGetPage gets a page of machines from sabakan server.
+//
+// The page size is specified by "limit" in params.
+// This returns a non-empty continue token if more machines follow.
+// Set it as "continue" in params to get the next page.
+func (c *Client) MachinesGetPage(ctx context.Context, params map[string]string) ([]sabakan.Machine, string, error) {
 	var machines []sabakan.Machine
-	err := c.getJSON(ctx, "machines", params, &machines)
+	header, err := c.getJSONWithHeader(ctx, "machines", params, &machines)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return machines, nil
+	return machines, header.Get("X-Sabakan-Continue"), nil
 }
 
 // MachinesCreate create machines information to sabakan server
@@ -63,8 +91,47 @@ func (c *Client) MachinesRemoveLabel(ctx context.Context, serial string, label s
 	return c.sendRequest(ctx, "DELETE", path.Join("labels", serial, label), nil)
 }
 
+// MachinesSetAnnotation adds or updates an annotation for a machine on sabakan server.
+func (c *Client) MachinesSetAnnotation(ctx context.Context, serial string, name, value string) error {
+	r := strings.NewReader(value)
+	return c.sendRequest(ctx, "PUT", path.Join("annotations", serial, name), r)
+}
+
+// MachinesRemoveAnnotation removes an annotation from a machine on sabakan server.
+func (c *Client) MachinesRemoveAnnotation(ctx context.Context, serial string, name string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("annotations", serial, name), nil)
+}
+
+// MachinesAddMAC binds a MAC address to a machine on sabakan server.
+func (c *Client) MachinesAddMAC(ctx context.Context, serial string, mac string) error {
+	return c.sendRequest(ctx, "PUT", path.Join("macs", serial, mac), nil)
+}
+
+// MachinesRemoveMAC unbinds a MAC address from a machine on sabakan server.
+func (c *Client) MachinesRemoveMAC(ctx context.Context, serial string, mac string) error {
+	return c.sendRequest(ctx, "DELETE", path.Join("macs", serial, mac), nil)
+}
+
 // MachinesSetRetireDate set the retire date of the machine.
 func (c *Client) MachinesSetRetireDate(ctx context.Context, serial string, date time.Time) error {
 	input := strings.NewReader(date.Format(time.RFC3339))
 	return c.sendRequest(ctx, "PUT", "retire-date/"+serial, input)
 }
+
+// MachinesIssueToken issues a new token for the machine to download
+// restricted assets.  The previous token is revoked.
+func (c *Client) MachinesIssueToken(ctx context.Context, serial string) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	err := c.sendRequestWithJSONResult(ctx, "POST", "tokens/"+serial, nil, nil, &result)
+	if err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// MachinesRevokeToken revokes the token of the machine.
+func (c *Client) MachinesRevokeToken(ctx context.Context, serial string) error {
+	return c.sendRequest(ctx, "DELETE", "tokens/"+serial, nil)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// replicationPollInterval is the interval to poll the replication status.
+var replicationPollInterval = 2 * time.Second
+
+// ReplicationStatus retrieves the replication status of assets and images
+func (c *Client) ReplicationStatus(ctx context.Context) (*sabakan.ReplicationStatus, error) {
+	var status sabakan.ReplicationStatus
+	err := c.getJSON(ctx, "replication", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// AssetsWait waits until the current version of an asset is held by
+// replicas servers.  If replicas is zero, this waits for all servers.
+// It returns an error if no more servers can pull the asset.
+func (c *Client) AssetsWait(ctx context.Context, name string, replicas int) (*sabakan.ReplicaStatus, error) {
+	for {
+		status, err := c.ReplicationStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rs := status.FindAsset(name)
+		if rs == nil {
+			return nil, &httpError{code: http.StatusNotFound, reason: "asset not found: " + name}
+		}
+
+		n := replicas
+		if n == 0 {
+			n = len(status.Servers)
+		}
+		// no server has reported yet if n is zero
+		if n > 0 && len(rs.Servers) >= n {
+			return rs, nil
+		}
+		if len(rs.Pending) == 0 && len(rs.Failed) != 0 {
+			f := rs.Failed[0]
+			return rs, fmt.Errorf("failed to replicate asset %s to %s: %s", name, f.Server, f.Error)
+		}
+
+		select {
+		case <-time.After(replicationPollInterval):
+		case <-ctx.Done():
+			return rs, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func TestAssetsWait(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestAssetServer(t, "0123456789")
+	ctx := context.Background()
+
+	rs, err := c.AssetsWait(ctx, "foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Servers) != 1 || len(rs.Pending) != 0 {
+		t.Error("wrong replication status:", rs)
+	}
+
+	rs, err = c.AssetsWait(ctx, "foo", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Name != "foo" {
+		t.Error("wrong asset:", rs.Name)
+	}
+
+	ctx2, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = c.AssetsWait(ctx2, "foo", 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("waiting for missing replicas should not finish:", err)
+	}
+
+	_, err = c.AssetsWait(ctx, "bar", 1)
+	if !IsNotFound(err) {
+		t.Error("missing asset should not be found:", err)
+	}
+}
+
+func newTestReplicationServer(t *testing.T, status *sabakan.ReplicationStatus) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAssetsWaitUnreplicated(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// no server has reported
+	c := newTestReplicationServer(t, &sabakan.ReplicationStatus{
+		Servers: []string{},
+		Assets:  []*sabakan.ReplicaStatus{{Name: "foo", ID: "1", Servers: []string{}, Pending: []string{}}},
+	})
+	ctx2, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err := c.AssetsWait(ctx2, "foo", 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("waiting without reports should not finish:", err)
+	}
+
+	// all servers but one failed
+	c = newTestReplicationServer(t, &sabakan.ReplicationStatus{
+		Servers: []string{"http://10.0.0.1:10080", "http://10.0.0.2:10080"},
+		Assets: []*sabakan.ReplicaStatus{{
+			Name:    "foo",
+			ID:      "1",
+			Servers: []string{"http://10.0.0.1:10080"},
+			Pending: []string{},
+			Failed:  []*sabakan.ReplicaFailure{{Server: "http://10.0.0.2:10080", Error: "disk full"}},
+		}},
+	})
+	rs, err := c.AssetsWait(ctx, "foo", 0)
+	if err == nil {
+		t.Error("waiting for failed servers should fail")
+	}
+	if rs == nil || len(rs.Failed) != 1 {
+		t.Error("wrong replication status:", rs)
+	}
+	rs, err = c.AssetsWait(ctx, "foo", 1)
+	if err != nil || len(rs.Servers) != 1 {
+		t.Error("enough replicas should be found:", rs, err)
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"path"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func secretPath(scope sabakan.SecretScope, target, name string) string {
+	if scope == sabakan.SecretScopeGlobal {
+		return path.Join("secrets", string(scope), name)
+	}
+	return path.Join("secrets", string(scope), target, name)
+}
+
+// SecretsList retrieves the meta data of all secrets.
+func (c *Client) SecretsList(ctx context.Context) ([]*sabakan.SecretInfo, error) {
+	var secrets []*sabakan.SecretInfo
+	err := c.getJSON(ctx, "secrets", nil, &secrets)
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// SecretsPut stores a secret.  target is the role or the serial of the scope.
+func (c *Client) SecretsPut(ctx context.Context, scope sabakan.SecretScope, target, name string, value []byte) error {
+	return c.sendRequest(ctx, "PUT", secretPath(scope, target, name), bytes.NewReader(value))
+}
+
+// SecretsDelete removes a secret.
+func (c *Client) SecretsDelete(ctx context.Context, scope sabakan.SecretScope, target, name string) error {
+	return c.sendRequest(ctx, "DELETE", secretPath(scope, target, name), nil)
+}
@@ -1,8 +1,11 @@
 package sabakan
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"net"
+	"sort"
 	"time"
 )
 
@@ -14,6 +17,23 @@ type DHCPConfig struct {
 	LeaseMinutes uint     `json:"lease-minutes"`
 	DNSServers   []string `json:"dns-servers,omitempty"`
 
+	// DenyUnknownMAC makes DHCP server refuse leases to MAC addresses
+	// that are not bound to any machine.
+	DenyUnknownMAC bool `json:"deny-unknown-mac,omitempty"`
+
+	// Options is a list of extra DHCP options sent to all clients.
+	Options []DHCPOption `json:"options,omitempty"`
+
+	// RackOptions overrides Options per logical rack number.
+	RackOptions map[uint][]DHCPOption `json:"rack-options,omitempty"`
+
+	// SwitchPorts maps switch ports to the expected machine locations.
+	SwitchPorts []SwitchPort `json:"switch-ports,omitempty"`
+
+	// QuarantineMinutes is the period for which declined addresses are
+	// not leased.  If zero, the lease duration is used.
+	QuarantineMinutes uint `json:"quarantine-minutes,omitempty"`
+
 	// obsoleted fields
 	GatewayOffset uint `json:"gateway-offset"`
 }
@@ -26,6 +46,14 @@ func (c *DHCPConfig) LeaseDuration() time.Duration {
 	return time.Duration(c.LeaseMinutes) * time.Minute
 }
 
+// QuarantineDuration returns the period for which declined addresses are not leased.
+func (c *DHCPConfig) QuarantineDuration() time.Duration {
+	if c.QuarantineMinutes == 0 {
+		return c.LeaseDuration()
+	}
+	return time.Duration(c.QuarantineMinutes) * time.Minute
+}
+
 // Validate validates configurations
 func (c *DHCPConfig) Validate() error {
 	for _, server := range c.DNSServers {
@@ -35,5 +63,82 @@ func (c *DHCPConfig) Validate() error {
 		}
 	}
 
+	if err := validateDHCPOptions(c.Options); err != nil {
+		return err
+	}
+	for rack, opts := range c.RackOptions {
+		if err := validateDHCPOptions(opts); err != nil {
+			return fmt.Errorf("rack-options for rack %d: %v", rack, err)
+		}
+	}
+
+	ports := make(map[[2]string]bool)
+	for _, p := range c.SwitchPorts {
+		if len(p.CircuitID) == 0 {
+			return errors.New("empty circuit-id in switch-ports")
+		}
+		key := [2]string{p.CircuitID, p.RemoteID}
+		if ports[key] {
+			return fmt.Errorf("duplicate switch port: circuit-id=%s remote-id=%s", p.CircuitID, p.RemoteID)
+		}
+		ports[key] = true
+	}
+
 	return nil
 }
+
+// SwitchPort maps a switch port identified by DHCP relay agent information
+// (option 82) to the location of the machine expected to be connected to it.
+//
+// CircuitID and RemoteID are compared with sub-options of option 82.
+// Sub-options consisting of printable ASCII characters are compared as
+// strings, and others are compared as hex-encoded strings prefixed by "0x".
+// If RemoteID is empty, it matches any remote ID.
+type SwitchPort struct {
+	CircuitID   string `json:"circuit-id"`
+	RemoteID    string `json:"remote-id,omitempty"`
+	Rack        uint   `json:"rack"`
+	IndexInRack uint   `json:"index-in-rack"`
+}
+
+// FindSwitchPort returns the SwitchPort for circuitID and remoteID.
+// An entry that has the same remote ID is preferred to an entry
+// without remote ID.  It returns nil if not found.
+func (c *DHCPConfig) FindSwitchPort(circuitID, remoteID string) *SwitchPort {
+	var found *SwitchPort
+	for i := range c.SwitchPorts {
+		p := &c.SwitchPorts[i]
+		if p.CircuitID != circuitID {
+			continue
+		}
+		if p.RemoteID == remoteID {
+			return p
+		}
+		if len(p.RemoteID) == 0 {
+			found = p
+		}
+	}
+	return found
+}
+
+// DHCPLease represents an IP address leased by the DHCP server.
+type DHCPLease struct {
+	// Range is the key of the LeaseRange, i.e. the first address of the range.
+	Range string `json:"range"`
+	IP    string `json:"ip"`
+
+	// MAC is empty if the address is declined.
+	MAC      string    `json:"mac,omitempty"`
+	Expire   time.Time `json:"expire"`
+	Declined bool      `json:"declined"`
+
+	// DeclinedBy is the MAC address of the client that declined the address.
+	DeclinedBy string `json:"declined-by,omitempty"`
+}
+
+// SortDHCPLeases sorts leases by IP addresses.
+func SortDHCPLeases(leases []*DHCPLease) {
+	sort.Slice(leases, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(leases[i].IP).To16(), net.ParseIP(leases[j].IP).To16()) < 0
+	})
+}
@@ -0,0 +1,278 @@
+package sabakan
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+)
+
+// DHCP option value types.
+const (
+	DHCPOptionIPv4       = "ipv4"
+	DHCPOptionIPv4List   = "ipv4-list"
+	DHCPOptionString     = "string"
+	DHCPOptionUint8      = "uint8"
+	DHCPOptionUint16     = "uint16"
+	DHCPOptionUint32     = "uint32"
+	DHCPOptionBool       = "bool"
+	DHCPOptionDomainList = "domain-list"
+	DHCPOptionRoutes     = "routes"
+	DHCPOptionHex        = "hex"
+)
+
+// reservedDHCPOptions are option codes that cannot be configured
+// because sabakan or the DHCP protocol determines their values.
+var reservedDHCPOptions = map[uint8]bool{
+	0:   true, // pad
+	1:   true, // subnet mask
+	3:   true, // router
+	50:  true, // requested IP address
+	51:  true, // lease time
+	52:  true, // option overload
+	53:  true, // message type
+	54:  true, // server identifier
+	55:  true, // parameter request list
+	57:  true, // maximum message size
+	60:  true, // vendor class identifier
+	61:  true, // client identifier
+	82:  true, // relay agent information
+	255: true, // end
+}
+
+// DHCPOption is an extra DHCP option sent to clients.
+//
+// Value is a JSON value whose format depends on Type:
+//
+//   - "ipv4": an IPv4 address string
+//   - "ipv4-list": an array of IPv4 address strings
+//   - "string": a string
+//   - "uint8", "uint16", "uint32": a number
+//   - "bool": a boolean
+//   - "domain-list": an array of domain names (RFC 3397)
+//   - "routes": an array of DHCPRoute (RFC 3442)
+//   - "hex": a hex-encoded string of raw bytes
+type DHCPOption struct {
+	Code  uint8           `json:"code"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// DHCPRoute is a classless static route for "routes" option type.
+type DHCPRoute struct {
+	Destination string `json:"destination"`
+	Router      string `json:"router"`
+}
+
+// Encode returns the wire format of the option value.
+func (o *DHCPOption) Encode() ([]byte, error) {
+	if reservedDHCPOptions[o.Code] {
+		return nil, fmt.Errorf("option %d is reserved", o.Code)
+	}
+
+	var buf []byte
+	var err error
+	switch o.Type {
+	case DHCPOptionIPv4:
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err != nil {
+			return nil, err
+		}
+		buf, err = encodeIPv4(s)
+	case DHCPOptionIPv4List:
+		var l []string
+		if err := json.Unmarshal(o.Value, &l); err != nil {
+			return nil, err
+		}
+		for _, s := range l {
+			b, err := encodeIPv4(s)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+	case DHCPOptionString:
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err != nil {
+			return nil, err
+		}
+		buf = []byte(s)
+	case DHCPOptionUint8, DHCPOptionUint16, DHCPOptionUint32:
+		buf, err = o.encodeUint()
+	case DHCPOptionBool:
+		var b bool
+		if err := json.Unmarshal(o.Value, &b); err != nil {
+			return nil, err
+		}
+		buf = []byte{0}
+		if b {
+			buf[0] = 1
+		}
+	case DHCPOptionDomainList:
+		var l []string
+		if err := json.Unmarshal(o.Value, &l); err != nil {
+			return nil, err
+		}
+		for _, s := range l {
+			b, err := encodeDomainName(s)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+	case DHCPOptionRoutes:
+		var routes []DHCPRoute
+		if err := json.Unmarshal(o.Value, &routes); err != nil {
+			return nil, err
+		}
+		for _, r := range routes {
+			b, err := encodeRoute(r)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+	case DHCPOptionHex:
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err != nil {
+			return nil, err
+		}
+		buf, err = hex.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown type for option %d: %s", o.Code, o.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for option %d: %v", o.Code, err)
+	}
+
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("empty value for option %d", o.Code)
+	}
+	if len(buf) > 255 {
+		return nil, fmt.Errorf("too long value for option %d: %d bytes", o.Code, len(buf))
+	}
+	return buf, nil
+}
+
+func (o *DHCPOption) encodeUint() ([]byte, error) {
+	var n uint64
+	if err := json.Unmarshal(o.Value, &n); err != nil {
+		return nil, err
+	}
+
+	var size int
+	var max uint64
+	switch o.Type {
+	case DHCPOptionUint8:
+		size, max = 1, math.MaxUint8
+	case DHCPOptionUint16:
+		size, max = 2, math.MaxUint16
+	default:
+		size, max = 4, math.MaxUint32
+	}
+	if n > max {
+		return nil, fmt.Errorf("%d overflows %s", n, o.Type)
+	}
+
+	buf := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		buf[i] = byte(n)
+		n >>= 8
+	}
+	return buf, nil
+}
+
+func encodeIPv4(s string) ([]byte, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() == nil {
+		return nil, errors.New("invalid IPv4 address: " + s)
+	}
+	return ip.To4(), nil
+}
+
+// encodeDomainName encodes a domain name as described in RFC 1035 section 3.1.
+func encodeDomainName(s string) ([]byte, error) {
+	name := strings.TrimSuffix(s, ".")
+	if len(name) == 0 {
+		return nil, errors.New("empty domain name")
+	}
+
+	var buf []byte
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errors.New("invalid domain name: " + s)
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0), nil
+}
+
+// encodeRoute encodes a route as described in RFC 3442.
+func encodeRoute(r DHCPRoute) ([]byte, error) {
+	_, dest, err := net.ParseCIDR(r.Destination)
+	if err != nil || dest.IP.To4() == nil {
+		return nil, errors.New("invalid destination: " + r.Destination)
+	}
+	router, err := encodeIPv4(r.Router)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, _ := dest.Mask.Size()
+	buf := []byte{byte(ones)}
+	buf = append(buf, dest.IP.To4()[:(ones+7)/8]...)
+	return append(buf, router...), nil
+}
+
+func validateDHCPOptions(opts []DHCPOption) error {
+	codes := make(map[uint8]bool)
+	for i := range opts {
+		o := &opts[i]
+		if codes[o.Code] {
+			return fmt.Errorf("duplicate option %d", o.Code)
+		}
+		codes[o.Code] = true
+
+		if _, err := o.Encode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OptionsForRack returns extra DHCP options for machines in rack.
+// Options in RackOptions for rack override those in Options that
+// have the same code.  The result is sorted by option codes.
+func (c *DHCPConfig) OptionsForRack(rack uint) []DHCPOption {
+	return mergeDHCPOptions(c.Options, c.RackOptions[rack])
+}
+
+// GlobalOptions returns extra DHCP options for clients that do not
+// belong to any rack.  The result is sorted by option codes.
+func (c *DHCPConfig) GlobalOptions() []DHCPOption {
+	return mergeDHCPOptions(c.Options, nil)
+}
+
+func mergeDHCPOptions(base, override []DHCPOption) []DHCPOption {
+	m := make(map[uint8]DHCPOption, len(base)+len(override))
+	for _, o := range base {
+		m[o.Code] = o
+	}
+	for _, o := range override {
+		m[o.Code] = o
+	}
+
+	opts := make([]DHCPOption, 0, len(m))
+	for _, o := range m {
+		opts = append(opts, o)
+	}
+	sort.Slice(opts, func(i, j int) bool {
+		return opts[i].Code < opts[j].Code
+	})
+	return opts
+}
@@ -0,0 +1,161 @@
+package sabakan
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testDHCPOptionEncode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		option   DHCPOption
+		expected []byte
+	}{
+		{
+			DHCPOption{Code: 28, Type: "ipv4", Value: json.RawMessage(`"10.69.0.63"`)},
+			[]byte{10, 69, 0, 63},
+		},
+		{
+			DHCPOption{Code: 42, Type: "ipv4-list", Value: json.RawMessage(`["10.0.0.1", "10.0.0.2"]`)},
+			[]byte{10, 0, 0, 1, 10, 0, 0, 2},
+		},
+		{
+			DHCPOption{Code: 15, Type: "string", Value: json.RawMessage(`"example.com"`)},
+			[]byte("example.com"),
+		},
+		{
+			DHCPOption{Code: 23, Type: "uint8", Value: json.RawMessage(`64`)},
+			[]byte{64},
+		},
+		{
+			DHCPOption{Code: 26, Type: "uint16", Value: json.RawMessage(`9000`)},
+			[]byte{0x23, 0x28},
+		},
+		{
+			DHCPOption{Code: 58, Type: "uint32", Value: json.RawMessage(`1800`)},
+			[]byte{0, 0, 0x07, 0x08},
+		},
+		{
+			DHCPOption{Code: 19, Type: "bool", Value: json.RawMessage(`true`)},
+			[]byte{1},
+		},
+		{
+			DHCPOption{Code: 119, Type: "domain-list", Value: json.RawMessage(`["example.com", "a.example.org."]`)},
+			[]byte("\x07example\x03com\x00\x01a\x07example\x03org\x00"),
+		},
+		{
+			DHCPOption{Code: 121, Type: "routes", Value: json.RawMessage(`[
+				{"destination": "0.0.0.0/0", "router": "10.69.0.1"},
+				{"destination": "10.0.0.0/8", "router": "10.69.0.2"},
+				{"destination": "192.168.100.0/22", "router": "10.69.0.3"}
+			]`)},
+			[]byte{
+				0, 10, 69, 0, 1,
+				8, 10, 10, 69, 0, 2,
+				22, 192, 168, 100, 10, 69, 0, 3,
+			},
+		},
+		{
+			DHCPOption{Code: 43, Type: "hex", Value: json.RawMessage(`"0104c0a80001"`)},
+			[]byte{1, 4, 192, 168, 0, 1},
+		},
+	}
+	for _, c := range testCases {
+		buf, err := c.option.Encode()
+		if err != nil {
+			t.Error(c.option.Code, err)
+			continue
+		}
+		if !bytes.Equal(buf, c.expected) {
+			t.Errorf("wrong encoding for option %d: %v", c.option.Code, buf)
+		}
+	}
+
+	badCases := []DHCPOption{
+		{Code: 1, Type: "ipv4", Value: json.RawMessage(`"255.255.255.0"`)},
+		{Code: 82, Type: "hex", Value: json.RawMessage(`"0102"`)},
+		{Code: 28, Type: "ipv4", Value: json.RawMessage(`"::1"`)},
+		{Code: 28, Type: "ipv4", Value: json.RawMessage(`1`)},
+		{Code: 42, Type: "ipv4-list", Value: json.RawMessage(`[]`)},
+		{Code: 15, Type: "string", Value: json.RawMessage(`""`)},
+		{Code: 23, Type: "uint8", Value: json.RawMessage(`256`)},
+		{Code: 26, Type: "uint16", Value: json.RawMessage(`-1`)},
+		{Code: 119, Type: "domain-list", Value: json.RawMessage(`["example..com"]`)},
+		{Code: 121, Type: "routes", Value: json.RawMessage(`[{"destination": "10.0.0.0", "router": "10.69.0.1"}]`)},
+		{Code: 43, Type: "hex", Value: json.RawMessage(`"xyz"`)},
+		{Code: 43, Type: "bytes", Value: json.RawMessage(`"0102"`)},
+		{Code: 43, Type: "hex", Value: json.RawMessage(`"` + string(bytes.Repeat([]byte("00"), 256)) + `"`)},
+	}
+	for _, o := range badCases {
+		_, err := o.Encode()
+		if err == nil {
+			t.Error("option should be invalid:", o.Code, o.Type, string(o.Value))
+		}
+	}
+}
+
+func testDHCPConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	mtu := DHCPOption{Code: 26, Type: "uint16", Value: json.RawMessage(`9000`)}
+	badMTU := DHCPOption{Code: 26, Type: "uint16", Value: json.RawMessage(`"9000"`)}
+
+	c := &DHCPConfig{
+		Options:     []DHCPOption{mtu},
+		RackOptions: map[uint][]DHCPOption{1: {mtu}},
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	c = &DHCPConfig{Options: []DHCPOption{mtu, mtu}}
+	if err := c.Validate(); err == nil {
+		t.Error("duplicate options should be invalid")
+	}
+
+	c = &DHCPConfig{Options: []DHCPOption{badMTU}}
+	if err := c.Validate(); err == nil {
+		t.Error("invalid options should be rejected")
+	}
+
+	c = &DHCPConfig{RackOptions: map[uint][]DHCPOption{1: {badMTU}}}
+	if err := c.Validate(); err == nil {
+		t.Error("invalid rack options should be rejected")
+	}
+}
+
+func testDHCPConfigOptionsForRack(t *testing.T) {
+	t.Parallel()
+
+	domain := DHCPOption{Code: 15, Type: "string", Value: json.RawMessage(`"example.com"`)}
+	mtu := DHCPOption{Code: 26, Type: "uint16", Value: json.RawMessage(`1500`)}
+	mtu2 := DHCPOption{Code: 26, Type: "uint16", Value: json.RawMessage(`9000`)}
+	ntp := DHCPOption{Code: 42, Type: "ipv4-list", Value: json.RawMessage(`["10.0.0.1"]`)}
+
+	c := &DHCPConfig{
+		Options:     []DHCPOption{mtu, domain},
+		RackOptions: map[uint][]DHCPOption{1: {ntp, mtu2}},
+	}
+
+	opts := c.GlobalOptions()
+	if !reflect.DeepEqual(opts, []DHCPOption{domain, mtu}) {
+		t.Error("wrong global options:", opts)
+	}
+	opts = c.OptionsForRack(0)
+	if !reflect.DeepEqual(opts, []DHCPOption{domain, mtu}) {
+		t.Error("wrong options for rack 0:", opts)
+	}
+	opts = c.OptionsForRack(1)
+	if !reflect.DeepEqual(opts, []DHCPOption{domain, mtu2, ntp}) {
+		t.Error("wrong options for rack 1:", opts)
+	}
+}
+
+func TestDHCPOption(t *testing.T) {
+	t.Run("Encode", testDHCPOptionEncode)
+	t.Run("Validate", testDHCPConfigValidate)
+	t.Run("OptionsForRack", testDHCPConfigOptionsForRack)
+}
@@ -0,0 +1,74 @@
+package sabakan
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+var reValidHostname = regexp.MustCompile(`^(?i)[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?(\.[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?)*$`)
+
+// DHCPReservation is a static DHCP reservation that binds a MAC address
+// to a fixed IPv4 address.
+type DHCPReservation struct {
+	MAC string `json:"mac"`
+	IP  string `json:"ip"`
+
+	// Hostname is sent to the client as host name option (12) if not empty.
+	Hostname string `json:"hostname,omitempty"`
+
+	// BootURL overrides the boot file URL sent to the client if not empty.
+	BootURL string `json:"boot-url,omitempty"`
+}
+
+// Validate validates the reservation against IPAM configurations.
+//
+// The reserved address must be in a DHCP lease range so that it does not
+// overlap node or BMC addresses assigned by IPAM.
+func (r *DHCPReservation) Validate(ipam *IPAMConfig) error {
+	mac, err := NormalizeMAC(r.MAC)
+	if err != nil {
+		return err
+	}
+	if mac != r.MAC {
+		return errors.New("MAC address is not normalized: " + r.MAC)
+	}
+
+	ip := net.ParseIP(r.IP)
+	if ip == nil || ip.To4() == nil {
+		return errors.New("invalid IPv4 address: " + r.IP)
+	}
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return errors.New("IP address is not in any rack subnet: " + r.IP)
+	}
+	if _, ok := lr.Index(ip); !ok {
+		return errors.New("IP address is not in the DHCP lease range of the rack: " + r.IP)
+	}
+
+	if len(r.Hostname) > 0 && (len(r.Hostname) > 253 || !reValidHostname.MatchString(r.Hostname)) {
+		return errors.New("invalid hostname: " + r.Hostname)
+	}
+
+	if len(r.BootURL) > 0 {
+		u, err := url.Parse(r.BootURL)
+		if err != nil {
+			return err
+		}
+		if !u.IsAbs() || len(u.Host) == 0 {
+			return errors.New("boot-url must be an absolute URL: " + r.BootURL)
+		}
+	}
+
+	return nil
+}
+
+// SortDHCPReservations sorts reservations by IP addresses.
+func SortDHCPReservations(reservations []*DHCPReservation) {
+	sort.Slice(reservations, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(reservations[i].IP).To16(), net.ParseIP(reservations[j].IP).To16()) < 0
+	})
+}
@@ -0,0 +1,50 @@
+package sabakan
+
+import "testing"
+
+func TestDHCPReservation(t *testing.T) {
+	t.Parallel()
+
+	valid := []DHCPReservation{
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.32"},
+		{MAC: "00:11:22:33:44:55", IP: "10.69.10.62", Hostname: "sw-mgmt-10"},
+		{MAC: "00:11:22:33:44:55", IP: "10.69.10.40", Hostname: "appliance.example.com", BootURL: "http://10.0.0.1/boot.ipxe"},
+	}
+	for _, r := range valid {
+		if err := r.Validate(testIPAMConfig); err != nil {
+			t.Error("reservation should be valid:", r, err)
+		}
+	}
+
+	invalid := []DHCPReservation{
+		// invalid or not normalized MAC
+		{MAC: "foo", IP: "10.69.0.32"},
+		{MAC: "00-11-22-33-44-55", IP: "10.69.0.32"},
+		// not IPv4
+		{MAC: "00:11:22:33:44:55", IP: "fd00::1"},
+		// out of node pool; BMC address
+		{MAC: "00:11:22:33:44:55", IP: "10.72.17.1"},
+		// node address
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.3"},
+		// broadcast address
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.63"},
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.32", Hostname: "-foo"},
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.32", Hostname: "foo_bar"},
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.32", BootURL: "/boot.ipxe"},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(testIPAMConfig); err == nil {
+			t.Error("reservation should be invalid:", r)
+		}
+	}
+
+	reservations := []*DHCPReservation{
+		{MAC: "00:11:22:33:44:55", IP: "10.69.1.32"},
+		{MAC: "00:11:22:33:44:66", IP: "10.69.0.40"},
+		{MAC: "00:11:22:33:44:77", IP: "10.69.0.33"},
+	}
+	SortDHCPReservations(reservations)
+	if reservations[0].IP != "10.69.0.33" || reservations[1].IP != "10.69.0.40" || reservations[2].IP != "10.69.1.32" {
+		t.Error("reservations are not sorted:", reservations)
+	}
+}
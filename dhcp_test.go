@@ -21,6 +21,59 @@ func testLeaseDuration(t *testing.T) {
 	}
 }
 
+func testQuarantineDuration(t *testing.T) {
+	t.Parallel()
+
+	c := &DHCPConfig{LeaseMinutes: 30}
+	if c.QuarantineDuration() != 30*time.Minute {
+		t.Error(`c.QuarantineDuration() != 30 * time.Minute`)
+	}
+
+	c.QuarantineMinutes = 1440
+	if c.QuarantineDuration() != 24*time.Hour {
+		t.Error(`c.QuarantineDuration() != 24 * time.Hour`)
+	}
+}
+
+func testFindSwitchPort(t *testing.T) {
+	t.Parallel()
+
+	c := &DHCPConfig{
+		SwitchPorts: []SwitchPort{
+			{CircuitID: "swp1", Rack: 1, IndexInRack: 4},
+			{CircuitID: "swp1", RemoteID: "tor-2", Rack: 2, IndexInRack: 4},
+			{CircuitID: "swp2", RemoteID: "tor-2", Rack: 2, IndexInRack: 5},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := c.FindSwitchPort("swp1", "tor-1")
+	if p == nil || p.Rack != 1 {
+		t.Error("wrong switch port:", p)
+	}
+	p = c.FindSwitchPort("swp1", "tor-2")
+	if p == nil || p.Rack != 2 {
+		t.Error("wrong switch port:", p)
+	}
+	p = c.FindSwitchPort("swp2", "tor-1")
+	if p != nil {
+		t.Error("switch port should not be found:", p)
+	}
+
+	c.SwitchPorts = append(c.SwitchPorts, SwitchPort{CircuitID: "swp1"})
+	if err := c.Validate(); err == nil {
+		t.Error("duplicate switch ports should be invalid")
+	}
+	c.SwitchPorts = []SwitchPort{{RemoteID: "tor-1"}}
+	if err := c.Validate(); err == nil {
+		t.Error("empty circuit-id should be invalid")
+	}
+}
+
 func TestDHCP(t *testing.T) {
 	t.Run("LeaseDuration", testLeaseDuration)
+	t.Run("QuarantineDuration", testQuarantineDuration)
+	t.Run("FindSwitchPort", testFindSwitchPort)
 }
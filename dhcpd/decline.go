@@ -2,6 +2,8 @@ package dhcpd
 
 import (
 	"context"
+	"fmt"
+	"net"
 
 	"github.com/cybozu-go/log"
 	"go.universe.tf/netboot/dhcp4"
@@ -38,5 +40,26 @@ func (h DHCPHandler) handleDecline(ctx context.Context, pkt *dhcp4.Packet, intf
 	log.Info("dhcp: marked address as declined", addPacketLog(pkt, map[string]interface{}{
 		optionLogKey(dhcp4.OptRequestedIP): requestedIP,
 	}))
+	h.countDecline(requestedIP)
 	return nil, errNoAction
 }
+
+// countDecline increments the decline counter for the lease range of ip.
+func (h DHCPHandler) countDecline(ip net.IP) {
+	if h.Counter == nil {
+		return
+	}
+	ipam, err := h.IPAM.GetConfig()
+	if err != nil {
+		return
+	}
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return
+	}
+	rack := ""
+	if r, ok := ipam.Rack(lr.BeginAddress); ok {
+		rack = fmt.Sprint(r)
+	}
+	h.Counter.IncDeclined(lr.Key(), rack)
+}
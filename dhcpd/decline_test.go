@@ -5,6 +5,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/cybozu-go/sabakan/v3/metrics"
 	"go.universe.tf/netboot/dhcp4"
 )
 
@@ -40,6 +41,25 @@ func testDecline(t *testing.T) {
 	if resp != nil {
 		t.Error("unknown resp error")
 	}
+
+	// decline a leased address
+	h.Counter = metrics.NewDHCPCounter()
+	ip, err := h.DHCP.Lease(context.Background(), net.ParseIP("10.69.1.3"), pkt.HardwareAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt.Options[dhcp4.OptRequestedIP] = ip.To4()
+	_, err = h.handleDecline(context.Background(), pkt, intf)
+	if err != errNoAction {
+		t.Error("invalid error:", err)
+	}
+	leases, err := h.DHCP.GetLeases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || !leases[0].Declined || leases[0].DeclinedBy != pkt.HardwareAddr.String() {
+		t.Error("address is not declined:", leases)
+	}
 }
 
 func TestDecline(t *testing.T) {
@@ -0,0 +1,307 @@
+package dhcpd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// MessageType6 is the type of DHCPv6 messages (RFC 8415 section 7.3).
+type MessageType6 uint8
+
+// DHCPv6 message types.
+const (
+	MsgSolicit6            MessageType6 = 1
+	MsgAdvertise6          MessageType6 = 2
+	MsgRequest6            MessageType6 = 3
+	MsgConfirm6            MessageType6 = 4
+	MsgRenew6              MessageType6 = 5
+	MsgRebind6             MessageType6 = 6
+	MsgReply6              MessageType6 = 7
+	MsgRelease6            MessageType6 = 8
+	MsgDecline6            MessageType6 = 9
+	MsgReconfigure6        MessageType6 = 10
+	MsgInformationRequest6 MessageType6 = 11
+	MsgRelayForw6          MessageType6 = 12
+	MsgRelayRepl6          MessageType6 = 13
+)
+
+var messageType6Names = map[MessageType6]string{
+	MsgSolicit6:            "SOLICIT",
+	MsgAdvertise6:          "ADVERTISE",
+	MsgRequest6:            "REQUEST",
+	MsgConfirm6:            "CONFIRM",
+	MsgRenew6:              "RENEW",
+	MsgRebind6:             "REBIND",
+	MsgReply6:              "REPLY",
+	MsgRelease6:            "RELEASE",
+	MsgDecline6:            "DECLINE",
+	MsgReconfigure6:        "RECONFIGURE",
+	MsgInformationRequest6: "INFORMATION-REQUEST",
+	MsgRelayForw6:          "RELAY-FORW",
+	MsgRelayRepl6:          "RELAY-REPL",
+}
+
+func (t MessageType6) String() string {
+	if name, ok := messageType6Names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+}
+
+// DHCPv6 option codes.
+const (
+	OptClientID6            uint16 = 1
+	OptServerID6            uint16 = 2
+	OptIANA6                uint16 = 3
+	OptIAAddr6              uint16 = 5
+	OptRequestedOptions6    uint16 = 6
+	OptElapsedTime6         uint16 = 8
+	OptRelayMsg6            uint16 = 9
+	OptStatusCode6          uint16 = 13
+	OptRapidCommit6         uint16 = 14
+	OptUserClass6           uint16 = 15
+	OptVendorClass6         uint16 = 16
+	OptInterfaceID6         uint16 = 18
+	OptBootFileURL6         uint16 = 59
+	OptClientArchType6      uint16 = 61
+	OptClientLinkLayerAddr6 uint16 = 79
+)
+
+// DHCPv6 status codes.
+const (
+	statusSuccess6      uint16 = 0
+	statusNoAddrsAvail6 uint16 = 2
+	statusNoBinding6    uint16 = 3
+)
+
+// Option6 is a DHCPv6 option.
+type Option6 struct {
+	Code uint16
+	Data []byte
+}
+
+// Options6 is a list of DHCPv6 options in the order of appearance.
+type Options6 []Option6
+
+// Get returns the data of the first option of code, or nil if not found.
+func (o Options6) Get(code uint16) []byte {
+	for _, opt := range o {
+		if opt.Code == code {
+			return opt.Data
+		}
+	}
+	return nil
+}
+
+// Has returns true if o has an option of code.
+func (o Options6) Has(code uint16) bool {
+	for _, opt := range o {
+		if opt.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends an option.
+func (o *Options6) Add(code uint16, data []byte) {
+	*o = append(*o, Option6{Code: code, Data: data})
+}
+
+func parseOptions6(data []byte) (Options6, error) {
+	var opts Options6
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("truncated DHCPv6 option header")
+		}
+		code := binary.BigEndian.Uint16(data[0:2])
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+l {
+			return nil, fmt.Errorf("truncated DHCPv6 option %d", code)
+		}
+		opts.Add(code, data[4:4+l])
+		data = data[4+l:]
+	}
+	return opts, nil
+}
+
+func (o Options6) marshal() []byte {
+	var buf []byte
+	for _, opt := range o {
+		var hdr [4]byte
+		binary.BigEndian.PutUint16(hdr[0:2], opt.Code)
+		binary.BigEndian.PutUint16(hdr[2:4], uint16(len(opt.Data)))
+		buf = append(buf, hdr[:]...)
+		buf = append(buf, opt.Data...)
+	}
+	return buf
+}
+
+// Packet6 is a DHCPv6 message.
+//
+// For relay messages, HopCount, LinkAddr and PeerAddr are used.
+// For other messages, TransactionID is used.
+type Packet6 struct {
+	Type          MessageType6
+	TransactionID [3]byte
+
+	HopCount uint8
+	LinkAddr net.IP
+	PeerAddr net.IP
+
+	Options Options6
+}
+
+// IsRelay returns true if p is a relay message.
+func (p *Packet6) IsRelay() bool {
+	return p.Type == MsgRelayForw6 || p.Type == MsgRelayRepl6
+}
+
+// ParsePacket6 parses a DHCPv6 message.
+func ParsePacket6(data []byte) (*Packet6, error) {
+	if len(data) < 4 {
+		return nil, errors.New("too short DHCPv6 message")
+	}
+
+	p := &Packet6{Type: MessageType6(data[0])}
+	var optData []byte
+	if p.IsRelay() {
+		if len(data) < 34 {
+			return nil, errors.New("too short DHCPv6 relay message")
+		}
+		p.HopCount = data[1]
+		p.LinkAddr = net.IP(append([]byte(nil), data[2:18]...))
+		p.PeerAddr = net.IP(append([]byte(nil), data[18:34]...))
+		optData = data[34:]
+	} else {
+		copy(p.TransactionID[:], data[1:4])
+		optData = data[4:]
+	}
+
+	opts, err := parseOptions6(optData)
+	if err != nil {
+		return nil, err
+	}
+	p.Options = opts
+	return p, nil
+}
+
+// Marshal returns the wire format of p.
+func (p *Packet6) Marshal() []byte {
+	var buf []byte
+	if p.IsRelay() {
+		buf = make([]byte, 34)
+		buf[0] = byte(p.Type)
+		buf[1] = p.HopCount
+		copy(buf[2:18], p.LinkAddr.To16())
+		copy(buf[18:34], p.PeerAddr.To16())
+	} else {
+		buf = make([]byte, 4)
+		buf[0] = byte(p.Type)
+		copy(buf[1:4], p.TransactionID[:])
+	}
+	return append(buf, p.Options.marshal()...)
+}
+
+// iaNA6 is an IA_NA option (RFC 8415 section 21.4).
+type iaNA6 struct {
+	IAID    [4]byte
+	T1      uint32
+	T2      uint32
+	Options Options6
+}
+
+func parseIANA6(data []byte) (*iaNA6, error) {
+	if len(data) < 12 {
+		return nil, errors.New("too short IA_NA option")
+	}
+	ia := &iaNA6{
+		T1: binary.BigEndian.Uint32(data[4:8]),
+		T2: binary.BigEndian.Uint32(data[8:12]),
+	}
+	copy(ia.IAID[:], data[0:4])
+	opts, err := parseOptions6(data[12:])
+	if err != nil {
+		return nil, err
+	}
+	ia.Options = opts
+	return ia, nil
+}
+
+func (ia *iaNA6) marshal() []byte {
+	buf := make([]byte, 12)
+	copy(buf[0:4], ia.IAID[:])
+	binary.BigEndian.PutUint32(buf[4:8], ia.T1)
+	binary.BigEndian.PutUint32(buf[8:12], ia.T2)
+	return append(buf, ia.Options.marshal()...)
+}
+
+// address returns the address in the first IA Address option, or nil.
+func (ia *iaNA6) address() net.IP {
+	data := ia.Options.Get(OptIAAddr6)
+	if len(data) < 24 {
+		return nil
+	}
+	return net.IP(append([]byte(nil), data[0:16]...))
+}
+
+func makeIAAddr6(ip net.IP, preferred, valid uint32) []byte {
+	buf := make([]byte, 24)
+	copy(buf[0:16], ip.To16())
+	binary.BigEndian.PutUint32(buf[16:20], preferred)
+	binary.BigEndian.PutUint32(buf[20:24], valid)
+	return buf
+}
+
+func makeStatusCode6(code uint16, msg string) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, code)
+	return append(buf, msg...)
+}
+
+// parseClasses6 parses user class or vendor class data, which is a list of
+// length-prefixed opaque values.
+func parseClasses6(data []byte) []string {
+	var classes []string
+	for len(data) >= 2 {
+		l := int(binary.BigEndian.Uint16(data[0:2]))
+		if len(data) < 2+l {
+			break
+		}
+		classes = append(classes, string(data[2:2+l]))
+		data = data[2+l:]
+	}
+	return classes
+}
+
+func makeClasses6(classes ...string) []byte {
+	var buf []byte
+	for _, c := range classes {
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(c)))
+		buf = append(buf, l[:]...)
+		buf = append(buf, c...)
+	}
+	return buf
+}
+
+// hardwareAddrFromDUID returns the Ethernet address in a DUID-LLT or DUID-LL,
+// or nil if duid does not have one.
+func hardwareAddrFromDUID(duid []byte) net.HardwareAddr {
+	if len(duid) < 4 || binary.BigEndian.Uint16(duid[2:4]) != 1 {
+		return nil
+	}
+	switch binary.BigEndian.Uint16(duid[0:2]) {
+	case 1: // DUID-LLT
+		if len(duid) == 14 {
+			return net.HardwareAddr(duid[8:14])
+		}
+	case 3: // DUID-LL
+		if len(duid) == 10 {
+			return net.HardwareAddr(duid[4:10])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package dhcpd
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func testParsePacket6(t *testing.T) {
+	t.Parallel()
+
+	data := []byte{
+		1, 0xaa, 0xbb, 0xcc, // SOLICIT
+		0, 1, 0, 10, 0, 3, 0, 1, 1, 2, 3, 4, 5, 6, // client ID: DUID-LL
+		0, 8, 0, 2, 0, 0, // elapsed time
+		0, 3, 0, 12, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, // IA_NA
+	}
+	pkt, err := ParsePacket6(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.Type != MsgSolicit6 {
+		t.Error("wrong type:", pkt.Type)
+	}
+	if pkt.TransactionID != [3]byte{0xaa, 0xbb, 0xcc} {
+		t.Error("wrong transaction ID:", pkt.TransactionID)
+	}
+	if len(pkt.Options) != 3 {
+		t.Fatal("wrong number of options:", len(pkt.Options))
+	}
+	if !pkt.Options.Has(OptElapsedTime6) || pkt.Options.Has(OptRapidCommit6) {
+		t.Error("wrong options:", pkt.Options)
+	}
+	ia, err := parseIANA6(pkt.Options.Get(OptIANA6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ia.IAID != [4]byte{0, 0, 0, 1} {
+		t.Error("wrong IAID:", ia.IAID)
+	}
+	if !bytes.Equal(pkt.Marshal(), data) {
+		t.Error("wrong marshal result:", pkt.Marshal())
+	}
+
+	relay := &Packet6{
+		Type:     MsgRelayForw6,
+		HopCount: 1,
+		LinkAddr: net.ParseIP("fd00:69::81"),
+		PeerAddr: net.ParseIP("fe80::1"),
+	}
+	relay.Options.Add(OptInterfaceID6, []byte("eth0"))
+	relay.Options.Add(OptRelayMsg6, data)
+	pkt, err = ParsePacket6(relay.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pkt, relay) {
+		t.Error("wrong relay message:", pkt)
+	}
+
+	badCases := [][]byte{
+		{1, 0xaa},
+		{1, 0xaa, 0xbb, 0xcc, 0, 1, 0},
+		{1, 0xaa, 0xbb, 0xcc, 0, 1, 0, 10, 0, 3},
+		{12, 0, 0, 0},
+	}
+	for _, c := range badCases {
+		_, err := ParsePacket6(c)
+		if err == nil {
+			t.Error("message should be invalid:", c)
+		}
+	}
+}
+
+func testHardwareAddrFromDUID(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		duid     []byte
+		expected net.HardwareAddr
+	}{
+		{
+			[]byte{0, 1, 0, 1, 0x22, 0x33, 0x44, 0x55, 1, 2, 3, 4, 5, 6},
+			net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		},
+		{
+			[]byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6},
+			net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		},
+		// DUID-EN
+		{[]byte{0, 2, 0, 0, 0, 9, 1, 2, 3, 4}, nil},
+		// not Ethernet
+		{[]byte{0, 3, 0, 6, 1, 2, 3, 4, 5, 6}, nil},
+		// truncated
+		{[]byte{0, 3, 0, 1, 1, 2, 3}, nil},
+		{nil, nil},
+	}
+	for _, c := range testCases {
+		mac := hardwareAddrFromDUID(c.duid)
+		if !bytes.Equal(mac, c.expected) {
+			t.Error("wrong hardware address:", c.duid, mac)
+		}
+	}
+}
+
+func testClasses6(t *testing.T) {
+	t.Parallel()
+
+	data := makeClasses6("iPXE", "HTTPClient")
+	classes := parseClasses6(data)
+	if !reflect.DeepEqual(classes, []string{"iPXE", "HTTPClient"}) {
+		t.Error("wrong classes:", classes)
+	}
+}
+
+func TestDHCP6(t *testing.T) {
+	t.Run("ParsePacket6", testParsePacket6)
+	t.Run("HardwareAddrFromDUID", testHardwareAddrFromDUID)
+	t.Run("Classes6", testClasses6)
+}
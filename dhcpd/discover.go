@@ -54,6 +54,34 @@ func isIPXEBoot(pkt *dhcp4.Packet) bool {
 	return ucls == "iPXE"
 }
 
+// pxeBootFile returns the name of iPXE firmware to be loaded via TFTP
+// for PXE clients, or an empty string if pkt is not from PXE firmware.
+func pxeBootFile(pkt *dhcp4.Packet) string {
+	vcls, err := pkt.Options.String(dhcp4.OptVendorIdentifier)
+	if err != nil || !strings.HasPrefix(vcls, "PXEClient") {
+		return ""
+	}
+
+	// RFC4578: Client System Architecture Type
+	// PXE clients must send option 93, but some old BIOS do not.
+	bs, err := pkt.Options.Bytes(93)
+	if err != nil {
+		return "undionly.kpxe"
+	}
+	if len(bs) < 2 {
+		return ""
+	}
+	switch binary.BigEndian.Uint16(bs[0:2]) {
+	case 0x00:
+		// x86 BIOS
+		return "undionly.kpxe"
+	case 0x07, 0x09:
+		// x64 UEFI
+		return "ipxe.efi"
+	}
+	return ""
+}
+
 func (h DHCPHandler) handleDiscover(ctx context.Context, pkt *dhcp4.Packet, intf Interface) (*dhcp4.Packet, error) {
 	serverAddr, err := getIPv4AddrForInterface(intf)
 	if err != nil {
@@ -67,6 +95,19 @@ func (h DHCPHandler) handleDiscover(ctx context.Context, pkt *dhcp4.Packet, intf
 		time.Sleep(50 * time.Millisecond)
 	}
 
+	serial, err := h.lookupMachine(ctx, pkt)
+	if err != nil {
+		return nil, err
+	}
+	// failures of the check should not prevent machines from booting
+	_, err = h.checkSwitchPort(ctx, pkt, serial)
+	if err != nil {
+		log.Warn("dhcp: failed to check switch port", addPacketLog(pkt, map[string]interface{}{
+			log.FnError: err.Error(),
+			"serial":    serial,
+		}))
+	}
+
 	yourip, err := h.DHCP.Lease(ctx, ifaddr, pkt.HardwareAddr)
 	if err != nil {
 		return nil, err
@@ -75,6 +116,10 @@ func (h DHCPHandler) handleDiscover(ctx context.Context, pkt *dhcp4.Packet, intf
 	if err != nil {
 		return nil, err
 	}
+	reservation, err := h.addReservationOptions(ctx, pkt.HardwareAddr, yourip, opts)
+	if err != nil {
+		return nil, err
+	}
 	opts[dhcp4.OptServerIdentifier] = serverAddr
 	resp := &dhcp4.Packet{
 		Type:           dhcp4.MsgOffer,
@@ -92,19 +137,44 @@ func (h DHCPHandler) handleDiscover(ctx context.Context, pkt *dhcp4.Packet, intf
 	if isUEFIHTTPBoot(pkt) {
 		log.Info("dhcp: requested UEFI HTTP boot", addPacketLog(pkt, map[string]interface{}{
 			pktYiaddr: yourip.String(),
+			"serial":  serial,
 		}))
 		opts[dhcp4.OptVendorIdentifier] = []byte("HTTPClient")
 		resp.BootFilename = h.makeBootAPIURL("ipxe.efi")
 	}
 
+	// PXE Boot
+	// iPXE also identifies itself as PXEClient, so it is excluded.
+	if h.TFTP && !isIPXEBoot(pkt) {
+		if f := pxeBootFile(pkt); f != "" {
+			log.Info("dhcp: requested PXE boot", addPacketLog(pkt, map[string]interface{}{
+				pktYiaddr:  yourip.String(),
+				"serial":   serial,
+				"filename": f,
+			}))
+			// siaddr is the TFTP server
+			resp.BootFilename = f
+		}
+	}
+
 	// iPXE Boot
 	if isIPXEBoot(pkt) {
 		log.Info("dhcp: requested iPXE boot", addPacketLog(pkt, map[string]interface{}{
 			pktYiaddr: yourip.String(),
+			"serial":  serial,
 		}))
 		// iPXE script to boot CoreOS Container Linux
 		resp.BootFilename = h.makeBootAPIURL("coreos/ipxe")
 	}
 
+	// boot URL of static reservation
+	if reservation != nil && len(reservation.BootURL) > 0 {
+		log.Info("dhcp: offering reserved boot URL", addPacketLog(pkt, map[string]interface{}{
+			pktYiaddr:  yourip.String(),
+			"boot_url": reservation.BootURL,
+		}))
+		resp.BootFilename = reservation.BootURL
+	}
+
 	return resp, nil
 }
@@ -3,9 +3,11 @@ package dhcpd
 import (
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"net"
 	"testing"
 
+	"github.com/cybozu-go/sabakan/v3"
 	"go.universe.tf/netboot/dhcp4"
 )
 
@@ -153,9 +155,190 @@ func testDiscoverIPXE(t *testing.T) {
 
 }
 
+func testDiscoverUnknownMAC(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	err := h.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{DenyUnknownMAC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := testDiscoverPacket()
+	intf := testInterface()
+	_, err = h.handleDiscover(ctx, pkt, intf)
+	if err != errUnknownMAC {
+		t.Fatal("unknown MAC address should be refused:", err)
+	}
+
+	err = h.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: "1234abcd",
+			MACs:   []string{pkt.HardwareAddr.String()},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := h.MachineSerial(ctx, pkt.HardwareAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != "1234abcd" {
+		t.Error("wrong serial:", serial)
+	}
+
+	resp, err := h.handleDiscover(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != dhcp4.MsgOffer {
+		t.Error("wrong resp.Type:", resp.Type)
+	}
+}
+
+func testDiscoverExtraOptions(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	err := h.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{
+		DNSServers: []string{"10.0.0.1", "10.0.0.2"},
+		Options: []sabakan.DHCPOption{
+			{Code: 15, Type: "string", Value: json.RawMessage(`"example.com"`)},
+			{Code: 26, Type: "uint16", Value: json.RawMessage(`1500`)},
+			{Code: 42, Type: "ipv4-list", Value: json.RawMessage(`["10.0.0.3"]`)},
+			{Code: 119, Type: "domain-list", Value: json.RawMessage(`["example.com"]`)},
+			{Code: 121, Type: "routes", Value: json.RawMessage(`[{"destination": "10.0.0.0/8", "router": "10.69.0.1"}]`)},
+		},
+		RackOptions: map[uint][]sabakan.DHCPOption{
+			1: {
+				{Code: 6, Type: "ipv4-list", Value: json.RawMessage(`["10.0.1.1"]`)},
+				{Code: 26, Type: "uint16", Value: json.RawMessage(`9000`)},
+				{Code: 43, Type: "hex", Value: json.RawMessage(`"0104c0a80001"`)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// direct; 10.69.1.32 is in rack 1
+	pkt := testDiscoverPacket()
+	intf := testInterface()
+	resp, err := h.handleDiscover(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testDiscoverPacket()
+	expected.Type = dhcp4.MsgOffer
+	expected.YourAddr = []byte{10, 69, 1, 32}
+	expected.ServerAddr = []byte{10, 69, 1, 3}
+	expected.BootServerName = "10.69.1.3"
+	expected.Options[dhcp4.OptSubnetMask] = []byte{255, 255, 255, 192}
+	expected.Options[dhcp4.OptRouters] = []byte{10, 69, 1, 1}
+	expected.Options[dhcp4.OptDNSServers] = []byte{10, 0, 1, 1}
+	expected.Options[dhcp4.OptDomainName] = []byte("example.com")
+	expected.Options[26] = []byte{0x23, 0x28}
+	expected.Options[dhcp4.OptNTPServers] = []byte{10, 0, 0, 3}
+	expected.Options[dhcp4.OptVendorSpecific] = []byte{1, 4, 192, 168, 0, 1}
+	expected.Options[119] = []byte("\x07example\x03com\x00")
+	expected.Options[121] = []byte{8, 10, 10, 69, 0, 1}
+	testComparePacket(t, resp, expected)
+
+	// relayed; 10.69.0.160 is in rack 0
+	pkt = testDiscoverPacket()
+	pkt.RelayAddr = []byte{10, 69, 0, 129}
+	resp, err = h.handleDiscover(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.YourAddr = []byte{10, 69, 0, 160}
+	expected.RelayAddr = []byte{10, 69, 0, 129}
+	expected.Options[dhcp4.OptRouters] = []byte{10, 69, 0, 129}
+	expected.Options[dhcp4.OptDNSServers] = []byte{10, 0, 0, 1, 10, 0, 0, 2}
+	expected.Options[26] = []byte{0x05, 0xdc}
+	delete(expected.Options, dhcp4.OptVendorSpecific)
+	testComparePacket(t, resp, expected)
+	if _, ok := resp.Options[dhcp4.OptVendorSpecific]; ok {
+		t.Error("rack options should not be applied to other racks")
+	}
+}
+
+func testDiscoverPXE(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	h.TFTP = true
+	ctx := context.Background()
+	intf := testInterface()
+
+	testCases := []struct {
+		arch      []byte
+		userClass string
+		filename  string
+	}{
+		{[]byte{0x00, 0x00}, "", "undionly.kpxe"},
+		{nil, "", "undionly.kpxe"},
+		{[]byte{0x00, 0x07}, "", "ipxe.efi"},
+		{[]byte{0x00, 0x09}, "", "ipxe.efi"},
+		{[]byte{0x00, 0x02}, "", ""},
+		// chainloaded iPXE
+		{[]byte{0x00, 0x00}, "iPXE", "http://10.69.0.195:10080/api/v1/boot/coreos/ipxe"},
+	}
+	for _, c := range testCases {
+		pkt := testDiscoverPacket()
+		pkt.Options[dhcp4.OptVendorIdentifier] = []byte("PXEClient:Arch:00000:UNDI:002001")
+		if c.arch != nil {
+			pkt.Options[93] = c.arch
+		}
+		if c.userClass != "" {
+			pkt.Options[77] = []byte(c.userClass)
+		}
+		resp, err := h.handleDiscover(ctx, pkt, intf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.BootFilename != c.filename {
+			t.Error("wrong boot filename:", c.arch, resp.BootFilename, c.filename)
+		}
+		if !resp.ServerAddr.Equal(net.IPv4(10, 69, 1, 3)) {
+			t.Error("wrong siaddr:", resp.ServerAddr)
+		}
+	}
+
+	// not a PXE client
+	pkt := testDiscoverPacket()
+	pkt.Options[93] = []byte{0x00, 0x00}
+	resp, err := h.handleDiscover(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BootFilename != "" {
+		t.Error("boot filename should not be set:", resp.BootFilename)
+	}
+
+	// TFTP is disabled
+	h.TFTP = false
+	pkt = testDiscoverPacket()
+	pkt.Options[dhcp4.OptVendorIdentifier] = []byte("PXEClient:Arch:00000:UNDI:002001")
+	pkt.Options[93] = []byte{0x00, 0x00}
+	resp, err = h.handleDiscover(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BootFilename != "" {
+		t.Error("boot filename should not be set:", resp.BootFilename)
+	}
+}
+
 func TestDiscover(t *testing.T) {
 	t.Run("Direct", testDiscoverDirect)
 	t.Run("Relayed", testDiscoverRelayed)
 	t.Run("HTTPBoot", testDiscoverHTTPBoot)
 	t.Run("iPXE", testDiscoverIPXE)
+	t.Run("PXE", testDiscoverPXE)
+	t.Run("UnknownMAC", testDiscoverUnknownMAC)
+	t.Run("ExtraOptions", testDiscoverExtraOptions)
 }
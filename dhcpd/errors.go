@@ -1,10 +1,35 @@
 package dhcpd
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
 
 var (
 	errUnknownMsgType = errors.New("unknown message type")
 	errNotChosen      = errors.New("not chosen")
 	errNoRecord       = errors.New("no record of the client")
 	errNoAction       = errors.New("no need to reply")
+	errUnknownMAC     = errors.New("unknown MAC address")
+	errNoHardwareAddr = errors.New("no hardware address of the client")
 )
+
+// errorReason returns the reason label of err for metrics.
+func errorReason(err error) string {
+	switch {
+	case err == errNotChosen:
+		return "not_chosen"
+	case err == errNoRecord:
+		return "no_record"
+	case err == errUnknownMAC:
+		return "unknown_mac"
+	case err == errUnknownMsgType:
+		return "unknown_type"
+	case err == errNoHardwareAddr:
+		return "no_hardware_address"
+	case errors.Is(err, sabakan.ErrNoLeasableAddress):
+		return "no_leasable_address"
+	}
+	return "internal"
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/metrics"
 	"go.universe.tf/netboot/dhcp4"
 )
 
@@ -18,31 +19,99 @@ type Handler interface {
 	ServeDHCP(ctx context.Context, pkt *dhcp4.Packet, intf Interface) (*dhcp4.Packet, error)
 }
 
-// DHCPHandler is an implementation of Handler using sabakan.Model.
+// DHCPHandler is an implementation of Handler and Handler6 using sabakan.Model.
 type DHCPHandler struct {
 	sabakan.Model
 	MyURL *url.URL
+
+	// MyURL6 is used for boot file URLs sent to DHCPv6 clients.
+	// If nil, MyURL is used.
+	MyURL6 *url.URL
+
+	// TFTP should be true if TFTP server runs on the same host
+	// to serve iPXE firmware to PXE clients.
+	TFTP bool
+
+	// Counter counts declined addresses and switch port mismatches if not nil.
+	Counter *metrics.DHCPCounter
 }
 
 // ServeDHCP implements Handler interface
+//
+// Relay agent information option in pkt is echoed back in the response.
 func (h DHCPHandler) ServeDHCP(ctx context.Context, pkt *dhcp4.Packet, intf Interface) (*dhcp4.Packet, error) {
+	var resp *dhcp4.Packet
+	var err error
 	switch pkt.Type {
 	case dhcp4.MsgDiscover:
-		return h.handleDiscover(ctx, pkt, intf)
+		resp, err = h.handleDiscover(ctx, pkt, intf)
 	case dhcp4.MsgRequest:
-		return h.handleRequest(ctx, pkt, intf)
+		resp, err = h.handleRequest(ctx, pkt, intf)
 	case dhcp4.MsgDecline:
-		return h.handleDecline(ctx, pkt, intf)
+		resp, err = h.handleDecline(ctx, pkt, intf)
 	case dhcp4.MsgRelease:
-		return h.handleRelease(ctx, pkt, intf)
+		resp, err = h.handleRelease(ctx, pkt, intf)
 	case dhcp4.MsgInform:
-		return h.handleInform(ctx, pkt, intf)
+		resp, err = h.handleInform(ctx, pkt, intf)
 	default:
 		log.Error("unexpected message type", map[string]interface{}{
 			"type": pkt.Type.String(),
 		})
+		return nil, errUnknownMsgType
+	}
+	if err != nil {
+		return nil, err
+	}
+	echoRelayAgentInfo(pkt, resp)
+	return resp, nil
+}
+
+// MachineSerial returns the serial of the machine to which mac is bound.
+// It returns an empty string if mac is not bound to any machine.
+func (h DHCPHandler) MachineSerial(ctx context.Context, mac net.HardwareAddr) (string, error) {
+	machines, err := h.Machine.Query(ctx, sabakan.Query{"mac": mac.String()})
+	if err != nil {
+		return "", err
+	}
+	if len(machines) == 0 {
+		return "", nil
+	}
+	return machines[0].Spec.Serial, nil
+}
+
+// lookupMachine returns the serial of the machine to which the client MAC
+// address is bound, or an empty string if it is not bound to any machine.
+// It returns errUnknownMAC for such clients if the DHCP server is configured
+// to refuse unknown MAC addresses.
+func (h DHCPHandler) lookupMachine(ctx context.Context, pkt *dhcp4.Packet) (string, error) {
+	return h.lookupMachineByMAC(ctx, pkt.HardwareAddr, addPacketLog(pkt, nil))
+}
+
+// lookupMachineByMAC is the same as lookupMachine but takes the MAC address
+// and log fields for the refusal.
+func (h DHCPHandler) lookupMachineByMAC(ctx context.Context, mac net.HardwareAddr, fields map[string]interface{}) (string, error) {
+	config, err := h.DHCP.GetConfig()
+	if err != nil {
+		return "", err
+	}
+
+	serial, err := h.MachineSerial(ctx, mac)
+	if err != nil {
+		return "", err
 	}
-	return nil, errUnknownMsgType
+	if len(serial) == 0 && config.DenyUnknownMAC {
+		// MAC addresses having static reservations are known.
+		_, err := h.DHCP.GetReservation(ctx, mac)
+		if err == nil {
+			return "", nil
+		}
+		if err != sabakan.ErrNotFound {
+			return "", err
+		}
+		log.Warn("dhcp: refused unknown MAC address", fields)
+		return "", errUnknownMAC
+	}
+	return serial, nil
 }
 
 func getIPv4AddrForInterface(intf Interface) (net.IP, error) {
@@ -93,6 +162,8 @@ func flattenIPv4Strings(addrs []string) ([]byte, error) {
 // * Router (3)
 // * Domain Name Server (6) (if specified in DHCP config)
 // * Lease seconds (51)
+//
+// In addition, extra options in DHCP config for the rack of ciaddr are included.
 func (h DHCPHandler) makeOptions(ciaddr net.IP) (dhcp4.Options, error) {
 	ipam, err := h.IPAM.GetConfig()
 	if err != nil {
@@ -106,7 +177,7 @@ func (h DHCPHandler) makeOptions(ciaddr net.IP) (dhcp4.Options, error) {
 	opts := make(dhcp4.Options)
 
 	// subnet mask
-	mask := net.CIDRMask(int(ipam.NodeRangeMask), 32)
+	mask := net.CIDRMask(int(ipam.PoolForIP(ciaddr).NodeRangeMask), 32)
 	opts[dhcp4.OptSubnetMask] = mask
 
 	// default gateway address (router)
@@ -128,11 +199,28 @@ func (h DHCPHandler) makeOptions(ciaddr net.IP) (dhcp4.Options, error) {
 	binary.BigEndian.PutUint32(buf, secs)
 	opts[dhcp4.OptLeaseTime] = buf
 
+	// extra options
+	extra := config.GlobalOptions()
+	if rack, ok := ipam.Rack(ciaddr); ok {
+		extra = config.OptionsForRack(rack)
+	}
+	for _, o := range extra {
+		v, err := o.Encode()
+		if err != nil {
+			return nil, err
+		}
+		opts[dhcp4.Option(o.Code)] = v
+	}
+
 	return opts, nil
 }
 
 func (h DHCPHandler) makeBootAPIURL(p string) string {
-	u := *h.MyURL
+	return makeBootAPIURL(h.MyURL, p)
+}
+
+func makeBootAPIURL(base *url.URL, p string) string {
+	u := *base
 	u.Path = path.Join("/api/v1/boot", p)
 	return u.String()
 }
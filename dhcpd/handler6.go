@@ -0,0 +1,416 @@
+package dhcpd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cybozu-go/log"
+)
+
+// Handler6 defines an interface for Server6.
+type Handler6 interface {
+	ServeDHCP6(ctx context.Context, pkt *Packet6, intf Interface) (*Packet6, error)
+}
+
+// ServeDHCP6 implements Handler6 interface.
+//
+// Relay-forward messages are unwrapped and the reply is wrapped in
+// relay-reply messages for the same relay agents.
+func (h DHCPHandler) ServeDHCP6(ctx context.Context, pkt *Packet6, intf Interface) (*Packet6, error) {
+	if pkt.Type == MsgRelayForw6 {
+		return h.handleRelayForw6(ctx, pkt)
+	}
+
+	linkAddr, err := getIPv6AddrForInterface(intf)
+	if err != nil {
+		return nil, err
+	}
+	return h.handleMessage6(ctx, pkt, linkAddr, nil)
+}
+
+func (h DHCPHandler) handleRelayForw6(ctx context.Context, relay *Packet6) (*Packet6, error) {
+	data := relay.Options.Get(OptRelayMsg6)
+	if data == nil {
+		return nil, errors.New("no relay message option in RELAY-FORW")
+	}
+	inner, err := ParsePacket6(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *Packet6
+	if inner.Type == MsgRelayForw6 {
+		resp, err = h.handleRelayForw6(ctx, inner)
+	} else {
+		// relay is the nearest agent to the client;
+		// its link-address identifies the link of the client.
+		resp, err = h.handleMessage6(ctx, inner, relay.LinkAddr, relay)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repl := &Packet6{
+		Type:     MsgRelayRepl6,
+		HopCount: relay.HopCount,
+		LinkAddr: relay.LinkAddr,
+		PeerAddr: relay.PeerAddr,
+	}
+	if id := relay.Options.Get(OptInterfaceID6); id != nil {
+		repl.Options.Add(OptInterfaceID6, id)
+	}
+	repl.Options.Add(OptRelayMsg6, resp.Marshal())
+	return repl, nil
+}
+
+func (h DHCPHandler) handleMessage6(ctx context.Context, pkt *Packet6, linkAddr net.IP, relay *Packet6) (*Packet6, error) {
+	switch pkt.Type {
+	case MsgSolicit6, MsgRequest6:
+		return h.handleSolicit6(ctx, pkt, linkAddr, relay)
+	case MsgRenew6, MsgRebind6:
+		return h.handleRenew6(ctx, pkt, relay)
+	case MsgRelease6, MsgDecline6:
+		return h.handleRelease6(ctx, pkt, relay)
+	case MsgInformationRequest6:
+		resp := h.makeReply6(MsgReply6, pkt)
+		h.addBootOptions6(pkt, resp, addPacket6Log(pkt, nil, nil))
+		return resp, nil
+	case MsgConfirm6:
+		// sabakan does not know whether addresses are appropriate for links
+		return nil, errNoAction
+	default:
+		log.Error("unexpected message type", map[string]interface{}{
+			"type": pkt.Type.String(),
+		})
+	}
+	return nil, errUnknownMsgType
+}
+
+// handleSolicit6 handles SOLICIT and REQUEST.
+// Only the first IA_NA option is served.
+func (h DHCPHandler) handleSolicit6(ctx context.Context, pkt *Packet6, linkAddr net.IP, relay *Packet6) (*Packet6, error) {
+	if pkt.Type == MsgRequest6 && !h.isChosen6(pkt) {
+		log.Info("dhcp6: ignored request to another server", addPacket6Log(pkt, nil, nil))
+		return nil, errNotChosen
+	}
+	if !pkt.Options.Has(OptIANA6) {
+		log.Info("dhcp6: ignored message without IA_NA", addPacket6Log(pkt, nil, nil))
+		return nil, errNoAction
+	}
+
+	mac, err := clientHardwareAddr6(pkt, relay)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := h.lookupMachineByMAC(ctx, mac, addPacket6Log(pkt, mac, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	ipam, err := h.IPAM.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	ifaddr := ipam.NodeIPv4Address(linkAddr)
+	if ifaddr == nil {
+		return nil, fmt.Errorf("no IPv6 lease range for link address %s", linkAddr)
+	}
+	ip4, err := h.DHCP.Lease(ctx, ifaddr, mac)
+	if err != nil {
+		return nil, err
+	}
+	ip := ipam.NodeIPv6Address(ip4)
+	if ip == nil {
+		return nil, fmt.Errorf("no IPv6 address for %s", ip4)
+	}
+
+	typ := MsgAdvertise6
+	if pkt.Type == MsgRequest6 || pkt.Options.Has(OptRapidCommit6) {
+		typ = MsgReply6
+	}
+	resp := h.makeReply6(typ, pkt)
+	if pkt.Type == MsgSolicit6 && typ == MsgReply6 {
+		resp.Options.Add(OptRapidCommit6, nil)
+	}
+	ia, err := h.makeIANA6(pkt, ip, statusSuccess6)
+	if err != nil {
+		return nil, err
+	}
+	resp.Options.Add(OptIANA6, ia)
+
+	h.addBootOptions6(pkt, resp, addPacket6Log(pkt, mac, map[string]interface{}{
+		"address": ip.String(),
+		"serial":  serial,
+	}))
+	return resp, nil
+}
+
+// handleRenew6 handles RENEW and REBIND.
+func (h DHCPHandler) handleRenew6(ctx context.Context, pkt *Packet6, relay *Packet6) (*Packet6, error) {
+	if pkt.Type == MsgRenew6 && !h.isChosen6(pkt) {
+		log.Info("dhcp6: ignored renewal to another server", addPacket6Log(pkt, nil, nil))
+		return nil, errNotChosen
+	}
+	ip, err := iaAddress6(pkt)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := clientHardwareAddr6(pkt, relay)
+	if err != nil {
+		return nil, err
+	}
+	_, err = h.lookupMachineByMAC(ctx, mac, addPacket6Log(pkt, mac, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := addPacket6Log(pkt, mac, map[string]interface{}{
+		"address": ip.String(),
+	})
+	log.Info("dhcp6: requested renewal", fields)
+
+	status := statusSuccess6
+	ipam, err := h.IPAM.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	ip4 := ipam.NodeIPv4Address(ip)
+	if ip4 == nil {
+		status = statusNoBinding6
+	} else if err := h.DHCP.Renew(ctx, ip4, mac); err != nil {
+		log.Warn("dhcp6: requested renewal but found no record", fields)
+		status = statusNoBinding6
+	}
+
+	resp := h.makeReply6(MsgReply6, pkt)
+	ia, err := h.makeIANA6(pkt, ip, status)
+	if err != nil {
+		return nil, err
+	}
+	resp.Options.Add(OptIANA6, ia)
+	return resp, nil
+}
+
+// handleRelease6 handles RELEASE and DECLINE.
+func (h DHCPHandler) handleRelease6(ctx context.Context, pkt *Packet6, relay *Packet6) (*Packet6, error) {
+	if !h.isChosen6(pkt) {
+		log.Warn("dhcp6: requested release with inconsistent server id", addPacket6Log(pkt, nil, nil))
+		return nil, errNotChosen
+	}
+	ip, err := iaAddress6(pkt)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := clientHardwareAddr6(pkt, relay)
+	if err != nil {
+		return nil, err
+	}
+
+	ipam, err := h.IPAM.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	ip4 := ipam.NodeIPv4Address(ip)
+	if ip4 == nil {
+		return nil, fmt.Errorf("invalid IPv6 address: %s", ip)
+	}
+
+	fields := addPacket6Log(pkt, mac, map[string]interface{}{
+		"address": ip.String(),
+	})
+	if pkt.Type == MsgDecline6 {
+		err = h.DHCP.Decline(ctx, ip4, mac)
+		if err != nil {
+			return nil, err
+		}
+		log.Warn("dhcp6: declined address", fields)
+		h.countDecline(ip4)
+	} else {
+		err = h.DHCP.Release(ctx, ip4, mac)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("dhcp6: released address", fields)
+	}
+
+	resp := h.makeReply6(MsgReply6, pkt)
+	resp.Options.Add(OptStatusCode6, makeStatusCode6(statusSuccess6, ""))
+	return resp, nil
+}
+
+// serverDUID returns DUID-UUID (RFC 6355) derived from MyURL
+// so that it does not change across restarts.
+func (h DHCPHandler) serverDUID() []byte {
+	sum := sha256.Sum256([]byte(h.MyURL.String()))
+	return append([]byte{0, 4}, sum[:16]...)
+}
+
+func (h DHCPHandler) isChosen6(pkt *Packet6) bool {
+	sid := pkt.Options.Get(OptServerID6)
+	return sid != nil && string(sid) == string(h.serverDUID())
+}
+
+func (h DHCPHandler) makeReply6(typ MessageType6, pkt *Packet6) *Packet6 {
+	resp := &Packet6{
+		Type:          typ,
+		TransactionID: pkt.TransactionID,
+	}
+	if cid := pkt.Options.Get(OptClientID6); cid != nil {
+		resp.Options.Add(OptClientID6, cid)
+	}
+	resp.Options.Add(OptServerID6, h.serverDUID())
+	return resp
+}
+
+func (h DHCPHandler) makeIANA6(pkt *Packet6, ip net.IP, status uint16) ([]byte, error) {
+	req, err := parseIANA6(pkt.Options.Get(OptIANA6))
+	if err != nil {
+		return nil, err
+	}
+	ia := &iaNA6{IAID: req.IAID}
+	if status != statusSuccess6 {
+		ia.Options.Add(OptStatusCode6, makeStatusCode6(status, "no binding for "+ip.String()))
+		return ia.marshal(), nil
+	}
+
+	config, err := h.DHCP.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	lifetime := uint32(config.LeaseDuration().Seconds())
+	ia.T1 = lifetime / 2
+	ia.T2 = lifetime / 5 * 4
+	ia.Options.Add(OptIAAddr6, makeIAAddr6(ip, lifetime, lifetime))
+	return ia.marshal(), nil
+}
+
+func (h DHCPHandler) addBootOptions6(pkt, resp *Packet6, fields map[string]interface{}) {
+	base := h.MyURL
+	if h.MyURL6 != nil {
+		base = h.MyURL6
+	}
+
+	switch {
+	case isIPXEBoot6(pkt):
+		log.Info("dhcp6: requested iPXE boot", fields)
+		// iPXE script to boot CoreOS Container Linux
+		resp.Options.Add(OptBootFileURL6, []byte(makeBootAPIURL(base, "coreos/ipxe")))
+	case isUEFIHTTPBoot6(pkt):
+		log.Info("dhcp6: requested UEFI HTTP boot", fields)
+		vendor := make([]byte, 4)
+		binary.BigEndian.PutUint32(vendor, enterpriseNumberIntel)
+		resp.Options.Add(OptVendorClass6, append(vendor, makeClasses6("HTTPClient")...))
+		resp.Options.Add(OptBootFileURL6, []byte(makeBootAPIURL(base, "ipxe.efi")))
+	}
+}
+
+// enterpriseNumberIntel is used in vendor class options by UEFI HTTP boot.
+const enterpriseNumberIntel = 343
+
+func isUEFIHTTPBoot6(pkt *Packet6) bool {
+	// RFC5970: Client System Architecture Type
+	// Option 61 is a list of uint16 values
+	bs := pkt.Options.Get(OptClientArchType6)
+	if len(bs)%2 == 1 {
+		return false
+	}
+
+	ok := false
+	for i := 0; i < len(bs)/2; i++ {
+		switch binary.BigEndian.Uint16(bs[i*2 : (i+1)*2]) {
+		case 0x0F, 0x10:
+			// x86/x64 UEFI HTTP Boot
+			ok = true
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	// Option 16 is an enterprise number followed by vendor class data
+	vcls := pkt.Options.Get(OptVendorClass6)
+	if len(vcls) < 4 {
+		return false
+	}
+	for _, c := range parseClasses6(vcls[4:]) {
+		if strings.HasPrefix(c, "HTTPClient") {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPXEBoot6(pkt *Packet6) bool {
+	for _, c := range parseClasses6(pkt.Options.Get(OptUserClass6)) {
+		if c == "iPXE" {
+			return true
+		}
+	}
+	return false
+}
+
+// clientHardwareAddr6 returns the MAC address of the client.
+// It is taken from the client link-layer address option (RFC 6939) added by
+// the relay agent, or from the DUID of the client.
+func clientHardwareAddr6(pkt, relay *Packet6) (net.HardwareAddr, error) {
+	if relay != nil {
+		lladdr := relay.Options.Get(OptClientLinkLayerAddr6)
+		if len(lladdr) == 8 && binary.BigEndian.Uint16(lladdr[0:2]) == 1 {
+			return net.HardwareAddr(lladdr[2:]), nil
+		}
+	}
+
+	mac := hardwareAddrFromDUID(pkt.Options.Get(OptClientID6))
+	if mac == nil {
+		log.Warn("dhcp6: no hardware address of the client", addPacket6Log(pkt, nil, nil))
+		return nil, errNoHardwareAddr
+	}
+	return mac, nil
+}
+
+func iaAddress6(pkt *Packet6) (net.IP, error) {
+	ia, err := parseIANA6(pkt.Options.Get(OptIANA6))
+	if err != nil {
+		return nil, err
+	}
+	ip := ia.address()
+	if ip == nil {
+		return nil, errors.New("no IA address in IA_NA")
+	}
+	return ip, nil
+}
+
+func getIPv6AddrForInterface(intf Interface) (net.IP, error) {
+	addrs, err := intf.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipaddr, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipaddr.IP.To4() == nil && ipaddr.IP.IsGlobalUnicast() {
+			return ipaddr.IP, nil
+		}
+	}
+	return nil, errors.New("No global IPv6 address for " + intf.Name())
+}
+
+func addPacket6Log(pkt *Packet6, mac net.HardwareAddr, fields map[string]interface{}) map[string]interface{} {
+	ret := fields
+	if ret == nil {
+		ret = make(map[string]interface{})
+	}
+	ret["type"] = pkt.Type.String()
+	ret["xid"] = fmt.Sprintf("%x", pkt.TransactionID[:])
+	if mac != nil {
+		ret["chaddr"] = mac.String()
+	}
+	return ret
+}
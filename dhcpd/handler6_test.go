@@ -0,0 +1,382 @@
+package dhcpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+var testDUID6 = []byte{0, 3, 0, 1, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+func testPacket6(typ MessageType6) *Packet6 {
+	pkt := &Packet6{
+		Type:          typ,
+		TransactionID: [3]byte{0xaa, 0xbb, 0xcc},
+	}
+	pkt.Options.Add(OptClientID6, testDUID6)
+	ia := &iaNA6{IAID: [4]byte{0, 0, 0, 1}}
+	pkt.Options.Add(OptIANA6, ia.marshal())
+	return pkt
+}
+
+func testPacket6WithAddress(h DHCPHandler, typ MessageType6, ip string) *Packet6 {
+	pkt := &Packet6{
+		Type:          typ,
+		TransactionID: [3]byte{0xaa, 0xbb, 0xcc},
+	}
+	pkt.Options.Add(OptClientID6, testDUID6)
+	pkt.Options.Add(OptServerID6, h.serverDUID())
+	ia := &iaNA6{IAID: [4]byte{0, 0, 0, 1}}
+	ia.Options.Add(OptIAAddr6, makeIAAddr6(net.ParseIP(ip), 0, 0))
+	pkt.Options.Add(OptIANA6, ia.marshal())
+	return pkt
+}
+
+func testCheckReply6(t *testing.T, h DHCPHandler, resp *Packet6, typ MessageType6, ip string) {
+	t.Helper()
+
+	if resp.Type != typ {
+		t.Error("wrong resp.Type:", resp.Type, typ)
+	}
+	if resp.TransactionID != [3]byte{0xaa, 0xbb, 0xcc} {
+		t.Error("wrong resp.TransactionID:", resp.TransactionID)
+	}
+	if !bytes.Equal(resp.Options.Get(OptClientID6), testDUID6) {
+		t.Error("wrong client ID:", resp.Options.Get(OptClientID6))
+	}
+	if !bytes.Equal(resp.Options.Get(OptServerID6), h.serverDUID()) {
+		t.Error("wrong server ID:", resp.Options.Get(OptServerID6))
+	}
+	if ip == "" {
+		return
+	}
+
+	ia, err := parseIANA6(resp.Options.Get(OptIANA6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ia.IAID != [4]byte{0, 0, 0, 1} {
+		t.Error("wrong IAID:", ia.IAID)
+	}
+	if ia.T1 != 1800 || ia.T2 != 2880 {
+		t.Error("wrong T1/T2:", ia.T1, ia.T2)
+	}
+	if !ia.address().Equal(net.ParseIP(ip)) {
+		t.Error("wrong address:", ia.address(), ip)
+	}
+	data := ia.Options.Get(OptIAAddr6)
+	if binary.BigEndian.Uint32(data[16:20]) != 3600 || binary.BigEndian.Uint32(data[20:24]) != 3600 {
+		t.Error("wrong lifetimes:", data[16:])
+	}
+}
+
+func testStatusCode6(t *testing.T, data []byte, expected uint16) {
+	t.Helper()
+
+	if len(data) < 2 {
+		t.Fatal("no status code")
+	}
+	if code := binary.BigEndian.Uint16(data[0:2]); code != expected {
+		t.Error("wrong status code:", code, expected)
+	}
+}
+
+func testSolicit6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	resp, err := h.ServeDHCP6(ctx, testPacket6(MsgSolicit6), intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgAdvertise6, "fd00:69::120")
+	if resp.Options.Has(OptRapidCommit6) || resp.Options.Has(OptBootFileURL6) {
+		t.Error("unexpected options:", resp.Options)
+	}
+
+	// the same address is leased for the same MAC address
+	pkt := testPacket6(MsgSolicit6)
+	pkt.Options.Add(OptRapidCommit6, nil)
+	resp, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "fd00:69::120")
+	if !resp.Options.Has(OptRapidCommit6) {
+		t.Error("rapid commit option should be returned")
+	}
+
+	leases, err := h.DHCP.GetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].IP != "10.69.1.32" {
+		t.Error("wrong leases:", leases)
+	}
+}
+
+func testRequest6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	pkt := testPacket6(MsgRequest6)
+	_, err := h.ServeDHCP6(ctx, pkt, intf)
+	if err != errNotChosen {
+		t.Error("request without server ID should be ignored:", err)
+	}
+
+	pkt = testPacket6(MsgRequest6)
+	pkt.Options.Add(OptServerID6, []byte{0, 3, 0, 1, 1, 1, 1, 1, 1, 1})
+	_, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != errNotChosen {
+		t.Error("request to another server should be ignored:", err)
+	}
+
+	pkt = testPacket6(MsgRequest6)
+	pkt.Options.Add(OptServerID6, h.serverDUID())
+	resp, err := h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "fd00:69::120")
+}
+
+func testRenew6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	pkt := testPacket6WithAddress(h, MsgRenew6, "fd00:69::120")
+	resp, err := h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "")
+	ia, err := parseIANA6(resp.Options.Get(OptIANA6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStatusCode6(t, ia.Options.Get(OptStatusCode6), statusNoBinding6)
+
+	_, err = h.ServeDHCP6(ctx, testPacket6(MsgSolicit6), intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "fd00:69::120")
+
+	pkt = testPacket6WithAddress(h, MsgRebind6, "fd00:69::120")
+	pkt.Options = append(pkt.Options[:1], pkt.Options[2:]...)
+	resp, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "fd00:69::120")
+}
+
+func testRelease6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	_, err := h.ServeDHCP6(ctx, testPacket6(MsgSolicit6), intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := testPacket6WithAddress(h, MsgRelease6, "fd00:69::120")
+	resp, err := h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCheckReply6(t, h, resp, MsgReply6, "")
+	testStatusCode6(t, resp.Options.Get(OptStatusCode6), statusSuccess6)
+
+	leases, err := h.DHCP.GetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Error("lease should be released:", leases)
+	}
+}
+
+func testRelayed6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	// the client uses DUID-EN; the MAC address is taken from option 79
+	pkt := testPacket6(MsgSolicit6)
+	pkt.Options[0].Data = []byte{0, 2, 0, 0, 0, 9, 1, 2, 3, 4}
+
+	relay := &Packet6{
+		Type:     MsgRelayForw6,
+		LinkAddr: net.ParseIP("fd00:69::81"),
+		PeerAddr: net.ParseIP("fe80::1"),
+	}
+	relay.Options.Add(OptInterfaceID6, []byte("swp1"))
+	relay.Options.Add(OptClientLinkLayerAddr6, []byte{0, 1, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	relay.Options.Add(OptRelayMsg6, pkt.Marshal())
+
+	// nested relay; the link address of the outer relay is not used
+	outer := &Packet6{
+		Type:     MsgRelayForw6,
+		HopCount: 1,
+		LinkAddr: net.ParseIP("fd00:69::103"),
+		PeerAddr: net.ParseIP("fd00:69::81"),
+	}
+	outer.Options.Add(OptRelayMsg6, relay.Marshal())
+
+	resp, err := h.ServeDHCP6(ctx, outer, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != MsgRelayRepl6 || resp.HopCount != 1 || !resp.PeerAddr.Equal(outer.PeerAddr) {
+		t.Fatal("wrong outer relay-reply:", resp)
+	}
+	if resp.Options.Has(OptInterfaceID6) {
+		t.Error("interface ID should not be added")
+	}
+
+	resp, err = ParsePacket6(resp.Options.Get(OptRelayMsg6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != MsgRelayRepl6 || !resp.LinkAddr.Equal(relay.LinkAddr) || !resp.PeerAddr.Equal(relay.PeerAddr) {
+		t.Fatal("wrong relay-reply:", resp)
+	}
+	if string(resp.Options.Get(OptInterfaceID6)) != "swp1" {
+		t.Error("interface ID should be echoed")
+	}
+
+	resp, err = ParsePacket6(resp.Options.Get(OptRelayMsg6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != MsgAdvertise6 {
+		t.Error("wrong resp.Type:", resp.Type)
+	}
+	ia, err := parseIANA6(resp.Options.Get(OptIANA6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ia.address().Equal(net.ParseIP("fd00:69::a0")) {
+		t.Error("wrong address:", ia.address())
+	}
+
+	leases, err := h.DHCP.GetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].MAC != "01:02:03:04:05:06" {
+		t.Error("wrong leases:", leases)
+	}
+}
+
+func testBoot6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+
+	pkt := testPacket6(MsgSolicit6)
+	pkt.Options.Add(OptUserClass6, makeClasses6("iPXE"))
+	resp, err := h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := string(resp.Options.Get(OptBootFileURL6))
+	if url != "http://10.69.0.195:10080/api/v1/boot/coreos/ipxe" {
+		t.Error("wrong boot file URL:", url)
+	}
+
+	h.MyURL6, _ = h.MyURL.Parse("http://[fd00:69::195]:10080")
+	pkt = testPacket6(MsgSolicit6)
+	pkt.Options.Add(OptClientArchType6, []byte{0x00, 0x10})
+	pkt.Options.Add(OptVendorClass6, append([]byte{0, 0, 1, 0x57}, makeClasses6("HTTPClient:Arch:00016")...))
+	resp, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url = string(resp.Options.Get(OptBootFileURL6))
+	if url != "http://[fd00:69::195]:10080/api/v1/boot/ipxe.efi" {
+		t.Error("wrong boot file URL:", url)
+	}
+	vcls := resp.Options.Get(OptVendorClass6)
+	if len(vcls) < 4 || parseClasses6(vcls[4:])[0] != "HTTPClient" {
+		t.Error("wrong vendor class:", vcls)
+	}
+}
+
+func testUnknownMAC6(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	intf := testInterface6()
+	err := h.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{DenyUnknownMAC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = h.ServeDHCP6(ctx, testPacket6(MsgSolicit6), intf)
+	if err != errUnknownMAC {
+		t.Fatal("unknown MAC address should be refused:", err)
+	}
+
+	err = h.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: "1234abcd",
+			MACs:   []string{"01:02:03:04:05:06"},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.ServeDHCP6(ctx, testPacket6(MsgSolicit6), intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != MsgAdvertise6 {
+		t.Error("wrong resp.Type:", resp.Type)
+	}
+
+	// no hardware address in DUID-EN
+	pkt := testPacket6(MsgSolicit6)
+	pkt.Options[0].Data = []byte{0, 2, 0, 0, 0, 9, 1, 2, 3, 4}
+	_, err = h.ServeDHCP6(ctx, pkt, intf)
+	if err != errNoHardwareAddr {
+		t.Error("client without hardware address should be ignored:", err)
+	}
+}
+
+func TestHandler6(t *testing.T) {
+	t.Run("Solicit", testSolicit6)
+	t.Run("Request", testRequest6)
+	t.Run("Renew", testRenew6)
+	t.Run("Release", testRelease6)
+	t.Run("Relayed", testRelayed6)
+	t.Run("Boot", testBoot6)
+	t.Run("UnknownMAC", testUnknownMAC6)
+}
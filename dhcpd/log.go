@@ -37,6 +37,7 @@ var optionNames = map[dhcp4.Option]string{
 	dhcp4.OptVendorIdentifier:   "vendor_class_identifier",
 	dhcp4.OptClientIdentifier:   "client_identifier",
 	dhcp4.OptFQDN:               "fqdn",
+	optRelayAgentInfo:           "relay_agent_information",
 }
 
 func optionLogKey(n dhcp4.Option) string {
@@ -117,6 +118,19 @@ func getOptionsLog(pkt *dhcp4.Packet) map[string]interface{} {
 			if err != nil {
 				continue
 			}
+		case optRelayAgentInfo:
+			data, _ := pkt.Options.Bytes(targetOpt)
+			info, err := parseRelayAgentInfo(data)
+			if err != nil {
+				continue
+			}
+			if len(info.CircuitID) > 0 {
+				optLog[optionLogKey(targetOpt)+"_circuit_id"] = info.CircuitID
+			}
+			if len(info.RemoteID) > 0 {
+				optLog[optionLogKey(targetOpt)+"_remote_id"] = info.RemoteID
+			}
+			continue
 		default:
 			// TODO: escape non-ASCII string
 			out, err = pkt.Options.String(targetOpt)
@@ -0,0 +1,130 @@
+package dhcpd
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/cybozu-go/log"
+	"go.universe.tf/netboot/dhcp4"
+)
+
+// optRelayAgentInfo is the relay agent information option (RFC 3046).
+const optRelayAgentInfo dhcp4.Option = 82
+
+// Sub-options of relay agent information option.
+const (
+	agentCircuitID = 1
+	agentRemoteID  = 2
+)
+
+// relayAgentInfo is the parsed relay agent information option.
+type relayAgentInfo struct {
+	CircuitID string
+	RemoteID  string
+}
+
+func parseRelayAgentInfo(data []byte) (*relayAgentInfo, error) {
+	info := new(relayAgentInfo)
+	for len(data) > 0 {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return nil, errors.New("truncated relay agent information")
+		}
+		code, value := data[0], data[2:2+int(data[1])]
+		switch code {
+		case agentCircuitID:
+			info.CircuitID = formatAgentID(value)
+		case agentRemoteID:
+			info.RemoteID = formatAgentID(value)
+		}
+		data = data[2+int(data[1]):]
+	}
+	return info, nil
+}
+
+// getRelayAgentInfo returns the relay agent information in pkt,
+// or nil if pkt does not have one.
+func getRelayAgentInfo(pkt *dhcp4.Packet) *relayAgentInfo {
+	data, err := pkt.Options.Bytes(optRelayAgentInfo)
+	if err != nil {
+		return nil
+	}
+	info, err := parseRelayAgentInfo(data)
+	if err != nil {
+		log.Warn("dhcp: invalid relay agent information", addPacketLog(pkt, map[string]interface{}{
+			log.FnError: err.Error(),
+		}))
+		return nil
+	}
+	return info
+}
+
+// formatAgentID returns the string as is if it consists of printable ASCII
+// characters, or hex-encoded string prefixed by "0x" otherwise.
+func formatAgentID(id []byte) string {
+	for _, c := range id {
+		if c < 0x20 || c > 0x7e {
+			return "0x" + hex.EncodeToString(id)
+		}
+	}
+	return string(id)
+}
+
+// echoRelayAgentInfo copies the relay agent information option in pkt to resp
+// as RFC 3046 section 2.2 requires.
+func echoRelayAgentInfo(pkt, resp *dhcp4.Packet) {
+	data, err := pkt.Options.Bytes(optRelayAgentInfo)
+	if err != nil {
+		return
+	}
+	if resp.Options == nil {
+		resp.Options = make(dhcp4.Options)
+	}
+	resp.Options[optRelayAgentInfo] = data
+}
+
+// checkSwitchPort compares the location of the machine identified by serial
+// with the location expected for the switch port through which pkt was relayed.
+// It returns false if the machine is connected to an unexpected port.
+// Such mismatches are counted by h.Counter.
+func (h DHCPHandler) checkSwitchPort(ctx context.Context, pkt *dhcp4.Packet, serial string) (bool, error) {
+	if len(serial) == 0 {
+		return true, nil
+	}
+	info := getRelayAgentInfo(pkt)
+	if info == nil {
+		return true, nil
+	}
+
+	config, err := h.DHCP.GetConfig()
+	if err != nil {
+		return false, err
+	}
+	port := config.FindSwitchPort(info.CircuitID, info.RemoteID)
+	if port == nil {
+		return true, nil
+	}
+
+	m, err := h.Machine.Get(ctx, serial)
+	if err != nil {
+		return false, err
+	}
+	if m.Spec.Rack == port.Rack && m.Spec.IndexInRack == port.IndexInRack {
+		return true, nil
+	}
+
+	log.Warn("dhcp: machine is connected to unexpected switch port", addPacketLog(pkt, map[string]interface{}{
+		"serial":                 serial,
+		"rack":                   m.Spec.Rack,
+		"index_in_rack":          m.Spec.IndexInRack,
+		"expected_rack":          port.Rack,
+		"expected_index_in_rack": port.IndexInRack,
+		"circuit_id":             info.CircuitID,
+		"remote_id":              info.RemoteID,
+	}))
+	if h.Counter != nil {
+		h.Counter.IncSwitchPortMismatch(strconv.FormatUint(uint64(m.Spec.Rack), 10), strconv.FormatUint(uint64(m.Spec.IndexInRack), 10))
+	}
+	return false, nil
+}
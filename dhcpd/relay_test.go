@@ -0,0 +1,182 @@
+package dhcpd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/metrics"
+	dto "github.com/prometheus/client_model/go"
+	"go.universe.tf/netboot/dhcp4"
+)
+
+// circuit-id "swp1" and remote-id 00:11:22:33:44:55
+var testRelayAgentInfo = []byte{
+	1, 4, 's', 'w', 'p', '1',
+	2, 6, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+}
+
+func testParseRelayAgentInfo(t *testing.T) {
+	t.Parallel()
+
+	info, err := parseRelayAgentInfo(testRelayAgentInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CircuitID != "swp1" {
+		t.Error("wrong circuit-id:", info.CircuitID)
+	}
+	if info.RemoteID != "0x001122334455" {
+		t.Error("wrong remote-id:", info.RemoteID)
+	}
+
+	// unknown sub-options are ignored
+	info, err = parseRelayAgentInfo([]byte{5, 1, 0, 1, 2, 'e', '0'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CircuitID != "e0" || info.RemoteID != "" {
+		t.Error("wrong relay agent information:", info)
+	}
+
+	_, err = parseRelayAgentInfo([]byte{1, 4, 's', 'w'})
+	if err == nil {
+		t.Error("truncated relay agent information should be invalid")
+	}
+
+	pkt := testDiscoverPacket()
+	pkt.Options[optRelayAgentInfo] = testRelayAgentInfo
+	optLog := getOptionsLog(pkt)
+	if optLog["option_82_relay_agent_information_circuit_id"] != "swp1" {
+		t.Error("circuit-id is not logged:", optLog)
+	}
+	if optLog["option_82_relay_agent_information_remote_id"] != "0x001122334455" {
+		t.Error("remote-id is not logged:", optLog)
+	}
+}
+
+func testEchoRelayAgentInfo(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+
+	pkt := testDiscoverPacket()
+	pkt.RelayAddr = []byte{10, 69, 0, 129}
+	pkt.Options[optRelayAgentInfo] = testRelayAgentInfo
+	resp, err := h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.Options[optRelayAgentInfo], testRelayAgentInfo) {
+		t.Error("relay agent information is not echoed:", resp.Options[optRelayAgentInfo])
+	}
+
+	pkt = testDiscoverPacket()
+	resp, err = h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.Options[optRelayAgentInfo]; ok {
+		t.Error("relay agent information should not be added")
+	}
+}
+
+func testCheckSwitchPort(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	err := h.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{
+		SwitchPorts: []sabakan.SwitchPort{
+			{CircuitID: "swp1", Rack: 1, IndexInRack: 4},
+			{CircuitID: "swp2", Rack: 1, IndexInRack: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial:      "1234abcd",
+			Rack:        1,
+			IndexInRack: 4,
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		serial string
+		info   []byte
+		ok     bool
+	}{
+		{"1234abcd", []byte{1, 4, 's', 'w', 'p', '1'}, true},
+		{"1234abcd", []byte{1, 4, 's', 'w', 'p', '2'}, false},
+		// unknown port
+		{"1234abcd", []byte{1, 4, 's', 'w', 'p', '3'}, true},
+		// no relay agent information
+		{"1234abcd", nil, true},
+		// unknown machine
+		{"", []byte{1, 4, 's', 'w', 'p', '2'}, true},
+	}
+	h.Counter = metrics.NewDHCPCounter()
+	mismatches := func() float64 {
+		m := &dto.Metric{}
+		err := metrics.DHCPSwitchPortMismatchTotal.WithLabelValues("1", "4").Write(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	before := mismatches()
+
+	for _, c := range testCases {
+		pkt := testDiscoverPacket()
+		if c.info != nil {
+			pkt.Options[optRelayAgentInfo] = c.info
+		}
+		ok, err := h.checkSwitchPort(ctx, pkt, c.serial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.ok {
+			t.Error("wrong result:", c.serial, c.info, ok)
+		}
+	}
+
+	if diff := mismatches() - before; diff != 1 {
+		t.Error("mismatch was not counted:", diff)
+	}
+
+	// errors are returned, and handleDiscover only logs them
+	pkt := testDiscoverPacket()
+	pkt.Options[optRelayAgentInfo] = []byte{1, 4, 's', 'w', 'p', '2'}
+	_, err = h.checkSwitchPort(ctx, pkt, "unknown")
+	if err == nil {
+		t.Error("checking an unknown machine should fail")
+	}
+
+	// mismatch is not fatal
+	pkt = testDiscoverPacket()
+	pkt.Options[optRelayAgentInfo] = []byte{1, 4, 's', 'w', 'p', '2'}
+	err = h.Machine.AddMAC(ctx, "1234abcd", pkt.HardwareAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != dhcp4.MsgOffer {
+		t.Error("wrong resp.Type:", resp.Type)
+	}
+}
+
+func TestRelayAgentInfo(t *testing.T) {
+	t.Run("Parse", testParseRelayAgentInfo)
+	t.Run("Echo", testEchoRelayAgentInfo)
+	t.Run("CheckSwitchPort", testCheckSwitchPort)
+}
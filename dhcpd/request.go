@@ -53,6 +53,11 @@ func (h DHCPHandler) handleRequest(ctx context.Context, pkt *dhcp4.Packet, intf
 			optionLogKey(dhcp4.OptRequestedIP): requestedIP,
 		}))
 
+		_, err = h.lookupMachine(ctx, pkt)
+		if err != nil {
+			return nil, err
+		}
+
 		err = h.DHCP.Renew(ctx, requestedIP, pkt.HardwareAddr)
 		if err != nil {
 			log.Warn("dhcp: requested confirmation but found no record", addPacketLog(pkt, map[string]interface{}{
@@ -65,6 +70,10 @@ func (h DHCPHandler) handleRequest(ctx context.Context, pkt *dhcp4.Packet, intf
 		if err != nil {
 			return nil, err
 		}
+		_, err = h.addReservationOptions(ctx, pkt.HardwareAddr, requestedIP, opts)
+		if err != nil {
+			return nil, err
+		}
 		opts[dhcp4.OptServerIdentifier] = serverAddr
 		resp := &dhcp4.Packet{
 			Type:           dhcp4.MsgAck,
@@ -86,6 +95,11 @@ func (h DHCPHandler) handleRequest(ctx context.Context, pkt *dhcp4.Packet, intf
 		pktCiaddr: pkt.ClientAddr,
 	}))
 
+	_, err = h.lookupMachine(ctx, pkt)
+	if err != nil {
+		return nil, err
+	}
+
 	err = h.DHCP.Renew(ctx, pkt.ClientAddr, pkt.HardwareAddr)
 	if err != nil {
 		log.Warn("dhcp: requested renewal but found no record", addPacketLog(pkt, map[string]interface{}{
@@ -98,6 +112,10 @@ func (h DHCPHandler) handleRequest(ctx context.Context, pkt *dhcp4.Packet, intf
 	if err != nil {
 		return nil, err
 	}
+	_, err = h.addReservationOptions(ctx, pkt.HardwareAddr, pkt.ClientAddr, opts)
+	if err != nil {
+		return nil, err
+	}
 	opts[dhcp4.OptServerIdentifier] = serverAddr
 	resp := &dhcp4.Packet{
 		Type:           dhcp4.MsgAck,
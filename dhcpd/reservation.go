@@ -0,0 +1,38 @@
+package dhcpd
+
+import (
+	"context"
+	"net"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"go.universe.tf/netboot/dhcp4"
+)
+
+// getReservation returns the static reservation of ip for mac,
+// or nil if ip is not reserved for mac.
+func (h DHCPHandler) getReservation(ctx context.Context, mac net.HardwareAddr, ip net.IP) (*sabakan.DHCPReservation, error) {
+	r, err := h.DHCP.GetReservation(ctx, mac)
+	if err == sabakan.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ip.Equal(net.ParseIP(r.IP)) {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// addReservationOptions adds options for the static reservation of ip to opts.
+// It returns the reservation, or nil if ip is not reserved for mac.
+func (h DHCPHandler) addReservationOptions(ctx context.Context, mac net.HardwareAddr, ip net.IP, opts dhcp4.Options) (*sabakan.DHCPReservation, error) {
+	r, err := h.getReservation(ctx, mac, ip)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if len(r.Hostname) > 0 {
+		opts[dhcp4.OptHostname] = []byte(r.Hostname)
+	}
+	return r, nil
+}
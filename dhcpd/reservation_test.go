@@ -0,0 +1,108 @@
+package dhcpd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"go.universe.tf/netboot/dhcp4"
+)
+
+func testReservationDiscover(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+
+	pkt := testDiscoverPacket()
+	err := h.DHCP.PutReservation(ctx, &sabakan.DHCPReservation{
+		MAC:      pkt.HardwareAddr.String(),
+		IP:       "10.69.1.40",
+		Hostname: "appliance",
+		BootURL:  "http://10.0.0.1/appliance.ipxe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt.Options[77] = []byte("iPXE")
+	resp, err := h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.YourAddr.Equal(net.ParseIP("10.69.1.40")) {
+		t.Error("reserved address is not offered:", resp.YourAddr)
+	}
+	if string(resp.Options[dhcp4.OptHostname]) != "appliance" {
+		t.Error("wrong host name:", string(resp.Options[dhcp4.OptHostname]))
+	}
+	if resp.BootFilename != "http://10.0.0.1/appliance.ipxe" {
+		t.Error("wrong boot filename:", resp.BootFilename)
+	}
+
+	// renewal
+	pkt = testRequestPacket()
+	pkt.ClientAddr = net.ParseIP("10.69.1.40").To4()
+	resp, err = h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != dhcp4.MsgAck {
+		t.Error("wrong resp.Type:", resp.Type)
+	}
+	if string(resp.Options[dhcp4.OptHostname]) != "appliance" {
+		t.Error("wrong host name:", string(resp.Options[dhcp4.OptHostname]))
+	}
+
+	// other clients
+	pkt = testDiscoverPacket()
+	pkt.HardwareAddr = []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}
+	resp, err = h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.YourAddr.Equal(net.ParseIP("10.69.1.32")) {
+		t.Error("wrong address:", resp.YourAddr)
+	}
+	if _, ok := resp.Options[dhcp4.OptHostname]; ok {
+		t.Error("host name should not be sent")
+	}
+}
+
+func testReservationDenyUnknownMAC(t *testing.T) {
+	t.Parallel()
+
+	h := testNewHandler(26, 1, 0)
+	ctx := context.Background()
+	err := h.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{DenyUnknownMAC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := testDiscoverPacket()
+	_, err = h.ServeDHCP(ctx, pkt, testInterface())
+	if err != errUnknownMAC {
+		t.Error("unknown MAC address should be refused:", err)
+	}
+
+	err = h.DHCP.PutReservation(ctx, &sabakan.DHCPReservation{
+		MAC: pkt.HardwareAddr.String(),
+		IP:  "10.69.1.40",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.ServeDHCP(ctx, pkt, testInterface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.YourAddr.Equal(net.ParseIP("10.69.1.40")) {
+		t.Error("reserved address is not offered:", resp.YourAddr)
+	}
+}
+
+func TestReservation(t *testing.T) {
+	t.Run("Discover", testReservationDiscover)
+	t.Run("DenyUnknownMAC", testReservationDenyUnknownMAC)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3/metrics"
 	"github.com/cybozu-go/well"
 	"go.universe.tf/netboot/dhcp4"
 )
@@ -12,6 +13,9 @@ import (
 type Server struct {
 	Handler Handler
 	Conn    *dhcp4.Conn
+
+	// Counter counts messages if not nil.
+	Counter *metrics.DHCPCounter
 }
 
 // Serve runs until context is canceled.
@@ -40,13 +44,15 @@ func (s Server) Serve(ctx context.Context) error {
 		}
 		log.Info("dhcp: received", getPacketLog(pkt, intf))
 		log.Debug("dhcp: options", getOptionsLog(pkt))
+		s.countReceived(pkt)
 
 		wrappedIntf := nativeInterface{intf}
 
 		env.Go(func(ctx context.Context) error {
 			resp, err := s.Handler.ServeDHCP(ctx, pkt, wrappedIntf)
+			s.countError(err)
 			switch err {
-			case errNotChosen, errNoRecord, errNoAction:
+			case errNotChosen, errNoRecord, errNoAction, errUnknownMAC:
 				// do nothing
 				return nil
 			case errUnknownMsgType:
@@ -68,7 +74,12 @@ func (s Server) Serve(ctx context.Context) error {
 				log.Error("SendDHCP returns an error", map[string]interface{}{
 					log.FnError: err.Error(),
 				})
+				if s.Counter != nil {
+					s.Counter.IncError("send_failed")
+				}
+				return nil
 			}
+			s.countSent(resp)
 			return nil
 		})
 	}
@@ -76,3 +87,40 @@ func (s Server) Serve(ctx context.Context) error {
 	env.Stop()
 	return env.Wait()
 }
+
+func (s Server) countReceived(pkt *dhcp4.Packet) {
+	if s.Counter != nil {
+		s.Counter.IncReceived(messageTypeLabel(pkt.Type))
+	}
+}
+
+func (s Server) countSent(pkt *dhcp4.Packet) {
+	if s.Counter != nil {
+		s.Counter.IncSent(messageTypeLabel(pkt.Type))
+	}
+}
+
+func (s Server) countError(err error) {
+	if s.Counter == nil || err == nil || err == errNoAction {
+		return
+	}
+	s.Counter.IncError(errorReason(err))
+}
+
+var messageTypeLabels = map[dhcp4.MessageType]string{
+	dhcp4.MsgDiscover: "discover",
+	dhcp4.MsgOffer:    "offer",
+	dhcp4.MsgRequest:  "request",
+	dhcp4.MsgDecline:  "decline",
+	dhcp4.MsgAck:      "ack",
+	dhcp4.MsgNack:     "nak",
+	dhcp4.MsgRelease:  "release",
+	dhcp4.MsgInform:   "inform",
+}
+
+func messageTypeLabel(t dhcp4.MessageType) string {
+	if l, ok := messageTypeLabels[t]; ok {
+		return l
+	}
+	return "unknown"
+}
@@ -0,0 +1,225 @@
+package dhcpd
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3/metrics"
+	"github.com/cybozu-go/well"
+	"golang.org/x/net/ipv6"
+)
+
+// allDHCPRelayAgentsAndServers is the link-scoped multicast address
+// to which DHCPv6 clients send messages.
+var allDHCPRelayAgentsAndServers = net.ParseIP("ff02::1:2")
+
+// Conn6 is a DHCPv6 connection.
+type Conn6 struct {
+	conn *ipv6.PacketConn
+}
+
+// NewConn6 creates a Conn6 that listens on addr.
+//
+// The connection joins All_DHCP_Relay_Agents_and_Servers multicast group
+// on all multicast-capable interfaces to receive messages from clients on
+// the same links.  Relay agents send messages to addr by unicast.
+func NewConn6(addr string) (*Conn6, error) {
+	c, err := net.ListenPacket("udp6", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := ipv6.NewPacketConn(c)
+	err = conn.SetControlMessage(ipv6.FlagInterface, true)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	intfs, err := net.Interfaces()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	group := &net.UDPAddr{IP: allDHCPRelayAgentsAndServers}
+	for i := range intfs {
+		intf := &intfs[i]
+		if intf.Flags&net.FlagUp == 0 || intf.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		err := conn.JoinGroup(intf, group)
+		if err != nil {
+			log.Warn("dhcp6: failed to join multicast group", map[string]interface{}{
+				"intf":      intf.Name,
+				log.FnError: err.Error(),
+			})
+		}
+	}
+
+	return &Conn6{conn: conn}, nil
+}
+
+// RecvDHCP6 receives a DHCPv6 message.
+// It returns the message, the receiving interface and the source address.
+func (c *Conn6) RecvDHCP6() (*Packet6, *net.Interface, net.Addr, error) {
+	buf := make([]byte, 65536)
+	for {
+		n, cm, src, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pkt, err := ParsePacket6(buf[:n])
+		if err != nil {
+			log.Warn("dhcp6: failed to parse message", map[string]interface{}{
+				"src":       src.String(),
+				log.FnError: err.Error(),
+			})
+			continue
+		}
+		if cm == nil {
+			return pkt, nil, src, nil
+		}
+		intf, err := net.InterfaceByIndex(cm.IfIndex)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return pkt, intf, src, nil
+	}
+}
+
+// SendDHCP6 sends a DHCPv6 message to dst through intf.
+func (c *Conn6) SendDHCP6(pkt *Packet6, dst net.Addr, intf *net.Interface) error {
+	cm := &ipv6.ControlMessage{IfIndex: intf.Index}
+	_, err := c.conn.WriteTo(pkt.Marshal(), cm, dst)
+	return err
+}
+
+// Close closes the connection.
+func (c *Conn6) Close() error {
+	return c.conn.Close()
+}
+
+// Server6 is DHCPv6 server.
+type Server6 struct {
+	Handler Handler6
+	Conn    *Conn6
+
+	// Counter counts messages if not nil.
+	Counter *metrics.DHCPCounter
+}
+
+// Serve runs until context is canceled.
+//
+// Once ctx is canceled, s.Conn will be closed.
+func (s Server6) Serve(ctx context.Context) error {
+	env := well.NewEnvironment(ctx)
+	env.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return s.Conn.Close()
+	})
+
+	for {
+		pkt, intf, src, err := s.Conn.RecvDHCP6()
+		if err != nil {
+			if ctx.Err() != context.Canceled {
+				log.Error("RecvDHCP6 returns an error, exiting", map[string]interface{}{
+					log.FnError: err.Error(),
+				})
+			}
+			break
+		}
+		if intf == nil {
+			log.Error("received DHCPv6 message with no interface information", nil)
+			continue
+		}
+		log.Info("dhcp6: received", addPacket6Log(pkt, nil, map[string]interface{}{
+			"intf": intf.Name,
+			"src":  src.String(),
+		}))
+		s.countReceived(pkt)
+
+		wrappedIntf := nativeInterface{intf}
+
+		env.Go(func(ctx context.Context) error {
+			resp, err := s.Handler.ServeDHCP6(ctx, pkt, wrappedIntf)
+			s.countError(err)
+			switch err {
+			case errNotChosen, errNoRecord, errNoAction, errUnknownMAC, errNoHardwareAddr:
+				// do nothing
+				return nil
+			case errUnknownMsgType:
+				// already logged
+				return nil
+			case nil:
+				// continue to SendDHCP6
+			default:
+				log.Error("handler returns an error", map[string]interface{}{
+					log.FnError: err.Error(),
+				})
+				return nil
+			}
+
+			log.Info("dhcp6: sending", addPacket6Log(resp, nil, map[string]interface{}{
+				"intf": intf.Name,
+				"dst":  src.String(),
+			}))
+			err = s.Conn.SendDHCP6(resp, src, intf)
+			if err != nil {
+				log.Error("SendDHCP6 returns an error", map[string]interface{}{
+					log.FnError: err.Error(),
+				})
+				if s.Counter != nil {
+					s.Counter.IncError("send_failed")
+				}
+				return nil
+			}
+			s.countSent(resp)
+			return nil
+		})
+	}
+
+	env.Stop()
+	return env.Wait()
+}
+
+func (s Server6) countReceived(pkt *Packet6) {
+	if s.Counter != nil {
+		s.Counter.IncReceived(messageType6Label(pkt))
+	}
+}
+
+func (s Server6) countSent(pkt *Packet6) {
+	if s.Counter != nil {
+		s.Counter.IncSent(messageType6Label(pkt))
+	}
+}
+
+func (s Server6) countError(err error) {
+	if s.Counter == nil || err == nil || err == errNoAction {
+		return
+	}
+	s.Counter.IncError(errorReason(err))
+}
+
+// messageType6Label returns the label of the client or server message
+// type for metrics.  Relay messages are labeled by the message they carry.
+//
+// Labels have "6" suffix not to be mixed up with DHCPv4 messages.
+func messageType6Label(pkt *Packet6) string {
+	for pkt.Type == MsgRelayForw6 || pkt.Type == MsgRelayRepl6 {
+		data := pkt.Options.Get(OptRelayMsg6)
+		if data == nil {
+			return "unknown"
+		}
+		inner, err := ParsePacket6(data)
+		if err != nil {
+			return "unknown"
+		}
+		pkt = inner
+	}
+	if name, ok := messageType6Names[pkt.Type]; ok {
+		return strings.ToLower(name) + "6"
+	}
+	return "unknown"
+}
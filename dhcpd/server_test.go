@@ -0,0 +1,70 @@
+package dhcpd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"go.universe.tf/netboot/dhcp4"
+)
+
+func testErrorReason(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		reason string
+	}{
+		{errNotChosen, "not_chosen"},
+		{errNoRecord, "no_record"},
+		{errUnknownMAC, "unknown_mac"},
+		{errUnknownMsgType, "unknown_type"},
+		{errNoHardwareAddr, "no_hardware_address"},
+		{fmt.Errorf("%w from 10.69.0.32", sabakan.ErrNoLeasableAddress), "no_leasable_address"},
+		{errors.New("etcd is down"), "internal"},
+	}
+	for _, c := range testCases {
+		if r := errorReason(c.err); r != c.reason {
+			t.Error("wrong reason:", c.err, r, c.reason)
+		}
+	}
+
+	if l := messageTypeLabel(dhcp4.MsgDiscover); l != "discover" {
+		t.Error("wrong label:", l)
+	}
+	if l := messageTypeLabel(dhcp4.MessageType(100)); l != "unknown" {
+		t.Error("wrong label:", l)
+	}
+}
+
+func testMessageType6Label(t *testing.T) {
+	t.Parallel()
+
+	solicit := &Packet6{Type: MsgSolicit6}
+	if l := messageType6Label(solicit); l != "solicit6" {
+		t.Error("wrong label:", l)
+	}
+	if l := messageType6Label(&Packet6{Type: MsgInformationRequest6}); l != "information-request6" {
+		t.Error("wrong label:", l)
+	}
+	if l := messageType6Label(&Packet6{Type: MessageType6(100)}); l != "unknown" {
+		t.Error("wrong label:", l)
+	}
+
+	relay := &Packet6{Type: MsgRelayForw6}
+	relay.Options.Add(OptRelayMsg6, solicit.Marshal())
+	outer := &Packet6{Type: MsgRelayForw6}
+	outer.Options.Add(OptRelayMsg6, relay.Marshal())
+	if l := messageType6Label(outer); l != "solicit6" {
+		t.Error("wrong label:", l)
+	}
+	if l := messageType6Label(&Packet6{Type: MsgRelayRepl6}); l != "unknown" {
+		t.Error("wrong label:", l)
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Run("ErrorReason", testErrorReason)
+	t.Run("MessageType6Label", testMessageType6Label)
+}
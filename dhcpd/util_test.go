@@ -30,6 +30,7 @@ func testNewHandler(maskbits, gwoffset, leasemin uint) DHCPHandler {
 	m.IPAM.PutConfig(context.Background(), &sabakan.IPAMConfig{
 		MaxNodesInRack:    28,
 		NodeIPv4Pool:      "10.69.0.0/20",
+		NodeIPv6Pool:      "fd00:69::/64",
 		NodeIPv4Offset:    "",
 		NodeRangeSize:     6,
 		NodeRangeMask:     maskbits,
@@ -65,6 +66,19 @@ func testInterface() Interface {
 	}
 }
 
+func testInterface6() Interface {
+	lla, llnet, _ := net.ParseCIDR("fe80::1/64")
+	llnet.IP = lla
+
+	v6, v6net, _ := net.ParseCIDR("fd00:69::103/64")
+	v6net.IP = v6
+
+	return mockInterface{
+		name:  "mock1",
+		addrs: []net.Addr{llnet, v6net},
+	}
+}
+
 func testIPEqual(t *testing.T, name string, respIP net.IP, expectedIP net.IP) {
 	if expectedIP.Equal(net.IPv4zero) {
 		if respIP == nil {
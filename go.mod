@@ -24,6 +24,7 @@ require (
 	go.etcd.io/etcd/client/v3 v3.6.13
 	go.universe.tf/netboot v0.0.0-20260201190555-f5d248c4db46
 	golang.org/x/crypto v0.53.0
+	golang.org/x/net v0.56.0
 	sigs.k8s.io/yaml v1.6.0
 )
 
@@ -76,7 +77,6 @@ require (
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
 	golang.org/x/exp/typeparams v0.0.0-20231108232855-2478ac86f678 // indirect
 	golang.org/x/mod v0.37.0 // indirect
-	golang.org/x/net v0.56.0 // indirect
 	golang.org/x/sync v0.21.0 // indirect
 	golang.org/x/sys v0.46.0 // indirect
 	golang.org/x/telemetry v0.0.0-20260625142307-59b4966ccb57 // indirect
@@ -3,9 +3,21 @@
 package model
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
 	sabakan "github.com/cybozu-go/sabakan/v3"
 )
 
+// Annotation represents an arbitrary name-value pair.
+// Unlike labels, the value can be any UTF-8 string.
+type Annotation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 // Label represents an arbitrary key-value pairs.
 type Label struct {
 	Name  string `json:"name"`
@@ -18,9 +30,36 @@ type LabelInput struct {
 	Value string `json:"value"`
 }
 
+// MachineConnection is a paginated list of machines.
+type MachineConnection struct {
+	Edges      []*MachineEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+// MachineEdge is a machine with its cursor.
+type MachineEdge struct {
+	Cursor string           `json:"cursor"`
+	Node   *sabakan.Machine `json:"node"`
+}
+
+// MachineOrder specifies the order of machines.
+type MachineOrder struct {
+	Field     MachineOrderField `json:"field"`
+	Direction *OrderDirection   `json:"direction,omitempty"`
+}
+
 // MachineParams is a set of input parameters to search machines.
+//
+// labelSelector is a comma-separated list of label requirements such as
+// "key=value", "key!=value", "key in (v1,v2)", "key notin (v1,v2)", "key" and "!key".
+//
+// inventoryFilter is a comma-separated list of conditions on the hardware inventory
+// such as "memory<256GiB" and "cores>=32".
 type MachineParams struct {
 	Labels              []*LabelInput          `json:"labels,omitempty"`
+	LabelSelector       *string                `json:"labelSelector,omitempty"`
+	InventoryFilter     *string                `json:"inventoryFilter,omitempty"`
 	Racks               []int                  `json:"racks,omitempty"`
 	Roles               []string               `json:"roles,omitempty"`
 	States              []sabakan.MachineState `json:"states,omitempty"`
@@ -30,5 +69,131 @@ type MachineParams struct {
 type Mutation struct {
 }
 
+// PageInfo represents information about a page.
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type Query struct {
 }
+
+// MachineOrderField enumerates fields to sort machines.
+type MachineOrderField string
+
+const (
+	MachineOrderFieldSerial       MachineOrderField = "SERIAL"
+	MachineOrderFieldRack         MachineOrderField = "RACK"
+	MachineOrderFieldIndexInRack  MachineOrderField = "INDEX_IN_RACK"
+	MachineOrderFieldState        MachineOrderField = "STATE"
+	MachineOrderFieldRegisterDate MachineOrderField = "REGISTER_DATE"
+	MachineOrderFieldRetireDate   MachineOrderField = "RETIRE_DATE"
+)
+
+var AllMachineOrderField = []MachineOrderField{
+	MachineOrderFieldSerial,
+	MachineOrderFieldRack,
+	MachineOrderFieldIndexInRack,
+	MachineOrderFieldState,
+	MachineOrderFieldRegisterDate,
+	MachineOrderFieldRetireDate,
+}
+
+func (e MachineOrderField) IsValid() bool {
+	switch e {
+	case MachineOrderFieldSerial, MachineOrderFieldRack, MachineOrderFieldIndexInRack, MachineOrderFieldState, MachineOrderFieldRegisterDate, MachineOrderFieldRetireDate:
+		return true
+	}
+	return false
+}
+
+func (e MachineOrderField) String() string {
+	return string(e)
+}
+
+func (e *MachineOrderField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MachineOrderField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MachineOrderField", str)
+	}
+	return nil
+}
+
+func (e MachineOrderField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MachineOrderField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MachineOrderField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// OrderDirection enumerates sort directions.
+type OrderDirection string
+
+const (
+	OrderDirectionAsc  OrderDirection = "ASC"
+	OrderDirectionDesc OrderDirection = "DESC"
+)
+
+var AllOrderDirection = []OrderDirection{
+	OrderDirectionAsc,
+	OrderDirectionDesc,
+}
+
+func (e OrderDirection) IsValid() bool {
+	switch e {
+	case OrderDirectionAsc, OrderDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e OrderDirection) String() string {
+	return string(e)
+}
+
+func (e *OrderDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderDirection", str)
+	}
+	return nil
+}
+
+func (e OrderDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrderDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrderDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -1,6 +1,14 @@
 package graph
 
-import sabakan "github.com/cybozu-go/sabakan/v3"
+import (
+	"context"
+	"time"
+
+	sabakan "github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/gql"
+	"github.com/cybozu-go/sabakan/v3/gql/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
 
 // This file will not be regenerated automatically.
 //
@@ -9,3 +17,66 @@ import sabakan "github.com/cybozu-go/sabakan/v3"
 type Resolver struct {
 	Model sabakan.Model
 }
+
+// searchMachines returns machines that match having and do not match notHaving.
+func (r *Resolver) searchMachines(ctx context.Context, having, notHaving *model.MachineParams) ([]*sabakan.Machine, error) {
+	now := time.Now()
+
+	needInventory := false
+	for _, params := range []*model.MachineParams{having, notHaving} {
+		if params == nil {
+			continue
+		}
+		if params.LabelSelector != nil {
+			_, err := sabakan.ParseLabelSelector(*params.LabelSelector)
+			if err != nil {
+				return nil, &gqlerror.Error{
+					Message: err.Error(),
+					Extensions: map[string]interface{}{
+						"type": gql.ErrInvalidLabelSelector,
+					},
+				}
+			}
+		}
+		if params.InventoryFilter != nil {
+			_, err := sabakan.ParseInventoryFilter(*params.InventoryFilter)
+			if err != nil {
+				return nil, &gqlerror.Error{
+					Message: err.Error(),
+					Extensions: map[string]interface{}{
+						"type": gql.ErrInvalidInventoryFilter,
+					},
+				}
+			}
+			needInventory = true
+		}
+	}
+
+	inventories := make(map[string]*sabakan.Inventory)
+	if needInventory {
+		all, err := r.Model.Inventory.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, inv := range all {
+			inventories[inv.Serial] = inv
+		}
+	}
+
+	machines, err := r.Model.Machine.Query(ctx, sabakan.Query{})
+	if err != nil {
+		return nil, err
+	}
+	var filtered []*sabakan.Machine
+	for _, m := range machines {
+		m.Status.Duration = now.Sub(m.Status.Timestamp).Seconds()
+		if !gql.MatchMachine(m, having, notHaving, now) {
+			continue
+		}
+		if needInventory && !gql.MatchInventory(inventories[m.Spec.Serial], having, notHaving) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered, nil
+}
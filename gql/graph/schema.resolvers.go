@@ -30,6 +30,55 @@ func (r *bMCResolver) Ipv4(ctx context.Context, obj *sabakan.MachineBMC) (*gql.I
 	return &gql.IPAddress{IP: net.ParseIP(obj.IPv4)}, nil
 }
 
+// Range is the resolver for the range field.
+func (r *dHCPLeaseResolver) Range(ctx context.Context, obj *sabakan.DHCPLease) (*gql.IPAddress, error) {
+	return &gql.IPAddress{IP: net.ParseIP(obj.Range)}, nil
+}
+
+// IP is the resolver for the ip field.
+func (r *dHCPLeaseResolver) IP(ctx context.Context, obj *sabakan.DHCPLease) (*gql.IPAddress, error) {
+	return &gql.IPAddress{IP: net.ParseIP(obj.IP)}, nil
+}
+
+// Expire is the resolver for the expire field.
+func (r *dHCPLeaseResolver) Expire(ctx context.Context, obj *sabakan.DHCPLease) (*gql.DateTime, error) {
+	t := gql.DateTime(obj.Expire)
+	return &t, nil
+}
+
+// Machine is the resolver for the machine field.
+func (r *dHCPLeaseResolver) Machine(ctx context.Context, obj *sabakan.DHCPLease) (*sabakan.Machine, error) {
+	if len(obj.MAC) == 0 {
+		return nil, nil
+	}
+	machines, err := r.Model.Machine.Query(ctx, sabakan.Query{"mac": obj.MAC})
+	if err != nil {
+		return nil, err
+	}
+	if len(machines) == 0 {
+		return nil, nil
+	}
+	return machines[0], nil
+}
+
+// Timestamp is the resolver for the timestamp field.
+func (r *inventoryResolver) Timestamp(ctx context.Context, obj *sabakan.Inventory) (*gql.DateTime, error) {
+	t := gql.DateTime(obj.Timestamp)
+	return &t, nil
+}
+
+// Inventory is the resolver for the inventory field.
+func (r *machineResolver) Inventory(ctx context.Context, obj *sabakan.Machine) (*sabakan.Inventory, error) {
+	inv, err := r.Model.Inventory.Get(ctx, obj.Spec.Serial)
+	if err == sabakan.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
 // Labels is the resolver for the labels field.
 func (r *machineSpecResolver) Labels(ctx context.Context, obj *sabakan.MachineSpec) ([]*model.Label, error) {
 	if len(obj.Labels) == 0 {
@@ -49,14 +98,32 @@ func (r *machineSpecResolver) Labels(ctx context.Context, obj *sabakan.MachineSp
 	return labels, nil
 }
 
-// Rack is the resolver for the rack field.
-func (r *machineSpecResolver) Rack(ctx context.Context, obj *sabakan.MachineSpec) (int, error) {
-	return int(obj.Rack), nil
+// Annotations is the resolver for the annotations field.
+func (r *machineSpecResolver) Annotations(ctx context.Context, obj *sabakan.MachineSpec) ([]*model.Annotation, error) {
+	if len(obj.Annotations) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(obj.Annotations))
+	for k := range obj.Annotations {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	annotations := make([]*model.Annotation, 0, len(obj.Annotations))
+	for _, k := range names {
+		annotations = append(annotations, &model.Annotation{Name: k, Value: obj.Annotations[k]})
+	}
+	return annotations, nil
 }
 
-// IndexInRack is the resolver for the indexInRack field.
-func (r *machineSpecResolver) IndexInRack(ctx context.Context, obj *sabakan.MachineSpec) (int, error) {
-	return int(obj.IndexInRack), nil
+// Annotation is the resolver for the annotation field.
+func (r *machineSpecResolver) Annotation(ctx context.Context, obj *sabakan.MachineSpec, name string) (*string, error) {
+	v, ok := obj.Annotations[name]
+	if !ok {
+		return nil, nil
+	}
+	return &v, nil
 }
 
 // Ipv4 is the resolver for the ipv4 field.
@@ -144,6 +211,34 @@ func (r *mutationResolver) SetMachineState(ctx context.Context, serial string, s
 	return &machine.Status, nil
 }
 
+// DeleteDHCPLease is the resolver for the deleteDHCPLease field.
+func (r *mutationResolver) DeleteDHCPLease(ctx context.Context, ip gql.IPAddress) (bool, error) {
+	log.Info("DeleteDHCPLease is called", map[string]interface{}{
+		"ip": ip.String(),
+	})
+
+	err := r.Model.DHCP.DeleteLease(ctx, ip.IP)
+	switch err {
+	case nil:
+		return true, nil
+	case sabakan.ErrNotFound:
+		return false, &gqlerror.Error{
+			Message: err.Error(),
+			Extensions: map[string]interface{}{
+				"ip":   ip.String(),
+				"type": gql.ErrLeaseNotFound,
+			},
+		}
+	default:
+		return false, &gqlerror.Error{
+			Message: err.Error(),
+			Extensions: map[string]interface{}{
+				"type": gql.ErrInternalServerError,
+			},
+		}
+	}
+}
+
 // Address is the resolver for the address field.
 func (r *nICConfigResolver) Address(ctx context.Context, obj *sabakan.NICConfig) (*gql.IPAddress, error) {
 	return &gql.IPAddress{IP: net.ParseIP(obj.Address)}, nil
@@ -177,30 +272,116 @@ func (r *queryResolver) Machine(ctx context.Context, serial string) (*sabakan.Ma
 
 // SearchMachines is the resolver for the searchMachines field.
 func (r *queryResolver) SearchMachines(ctx context.Context, having *model.MachineParams, notHaving *model.MachineParams) ([]*sabakan.Machine, error) {
-	now := time.Now()
-
 	log.Info("SearchMachines is called", map[string]interface{}{
 		"having":    having,
 		"nothaving": notHaving,
 	})
 
-	machines, err := r.Model.Machine.Query(ctx, sabakan.Query{})
+	return r.searchMachines(ctx, having, notHaving)
+}
+
+// SearchMachinesConnection is the resolver for the searchMachinesConnection field.
+func (r *queryResolver) SearchMachinesConnection(ctx context.Context, having *model.MachineParams, notHaving *model.MachineParams, orderBy *model.MachineOrder, first *int, after *string) (*model.MachineConnection, error) {
+	log.Info("SearchMachinesConnection is called", map[string]interface{}{
+		"having":    having,
+		"nothaving": notHaving,
+		"orderby":   orderBy,
+		"first":     first,
+		"after":     after,
+	})
+
+	opts := gql.MachineListOptions(orderBy, first, after)
+	if opts.Limit < 0 {
+		return nil, &gqlerror.Error{
+			Message: "first must not be negative",
+			Extensions: map[string]interface{}{
+				"type": gql.ErrInvalidPagination,
+			},
+		}
+	}
+
+	machines, err := r.searchMachines(ctx, having, notHaving)
+	if err != nil {
+		return nil, err
+	}
+
+	page, next, err := sabakan.PaginateMachines(machines, opts)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message: err.Error(),
+			Extensions: map[string]interface{}{
+				"type": gql.ErrInvalidPagination,
+			},
+		}
+	}
+
+	conn := &model.MachineConnection{
+		Edges:      make([]*model.MachineEdge, len(page)),
+		PageInfo:   &model.PageInfo{HasNextPage: len(next) > 0},
+		TotalCount: len(machines),
+	}
+	for i, m := range page {
+		conn.Edges[i] = &model.MachineEdge{
+			Cursor: sabakan.MachineCursor(m, opts.SortBy, opts.Descending),
+			Node:   m,
+		}
+	}
+	if len(page) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(page)-1].Cursor
+	}
+	return conn, nil
+}
+
+// DhcpLeases is the resolver for the dhcpLeases field.
+func (r *queryResolver) DhcpLeases(ctx context.Context, ip *gql.IPAddress, mac *string) ([]*sabakan.DHCPLease, error) {
+	fields := map[string]interface{}{}
+	if ip != nil {
+		fields["ip"] = ip.String()
+	}
+	if mac != nil {
+		fields["mac"] = *mac
+	}
+	log.Info("DhcpLeases is called", fields)
+
+	var hwaddr string
+	if mac != nil {
+		var err error
+		hwaddr, err = sabakan.NormalizeMAC(*mac)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	all, err := r.Model.DHCP.GetLeases(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var filtered []*sabakan.Machine
-	for _, m := range machines {
-		m.Status.Duration = now.Sub(m.Status.Timestamp).Seconds()
-		if gql.MatchMachine(m, having, notHaving, now) {
-			filtered = append(filtered, m)
+
+	leases := make([]*sabakan.DHCPLease, 0, len(all))
+	for _, l := range all {
+		if ip != nil && !ip.Equal(net.ParseIP(l.IP)) {
+			continue
+		}
+		if len(hwaddr) > 0 && hwaddr != l.MAC {
+			continue
 		}
+		leases = append(leases, l)
 	}
-	return filtered, nil
+	return leases, nil
 }
 
 // BMC returns generated.BMCResolver implementation.
 func (r *Resolver) BMC() generated.BMCResolver { return &bMCResolver{r} }
 
+// DHCPLease returns generated.DHCPLeaseResolver implementation.
+func (r *Resolver) DHCPLease() generated.DHCPLeaseResolver { return &dHCPLeaseResolver{r} }
+
+// Inventory returns generated.InventoryResolver implementation.
+func (r *Resolver) Inventory() generated.InventoryResolver { return &inventoryResolver{r} }
+
+// Machine returns generated.MachineResolver implementation.
+func (r *Resolver) Machine() generated.MachineResolver { return &machineResolver{r} }
+
 // MachineSpec returns generated.MachineSpecResolver implementation.
 func (r *Resolver) MachineSpec() generated.MachineSpecResolver { return &machineSpecResolver{r} }
 
@@ -218,9 +399,27 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
 type (
 	bMCResolver           struct{ *Resolver }
+	dHCPLeaseResolver     struct{ *Resolver }
+	inventoryResolver     struct{ *Resolver }
+	machineResolver       struct{ *Resolver }
 	machineSpecResolver   struct{ *Resolver }
 	machineStatusResolver struct{ *Resolver }
 	mutationResolver      struct{ *Resolver }
 	nICConfigResolver     struct{ *Resolver }
 	queryResolver         struct{ *Resolver }
 )
+
+// !!! WARNING !!!
+// The code below was going to be deleted when updating resolvers. It has been copied here so you have
+// one last chance to move it out of harms way if you want. There are two reasons this happens:
+//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
+//    it when you're done.
+//  - You have helper methods in this file. Move them out to keep these resolver files clean.
+/*
+	func (r *machineSpecResolver) Rack(ctx context.Context, obj *sabakan.MachineSpec) (int, error) {
+	return int(obj.Rack), nil
+}
+func (r *machineSpecResolver) IndexInRack(ctx context.Context, obj *sabakan.MachineSpec) (int, error) {
+	return int(obj.IndexInRack), nil
+}
+*/
@@ -16,6 +16,13 @@ func MatchMachine(m *sabakan.Machine, h, nh *model.MachineParams, now time.Time)
 		return false
 	}
 
+	if !matchLabelSelector(h, m.Spec.Labels, true) {
+		return false
+	}
+	if matchLabelSelector(nh, m.Spec.Labels, false) {
+		return false
+	}
+
 	if !containsRack(h, int(m.Spec.Rack), true) {
 		return false
 	}
@@ -52,6 +59,18 @@ func MatchMachine(m *sabakan.Machine, h, nh *model.MachineParams, now time.Time)
 	return true
 }
 
+// MatchInventory tests if an inventory matches the given conditions.
+// inv is nil if the machine has not reported its inventory.
+func MatchInventory(inv *sabakan.Inventory, h, nh *model.MachineParams) bool {
+	if !matchInventoryFilter(h, inv, true) {
+		return false
+	}
+	if matchInventoryFilter(nh, inv, false) {
+		return false
+	}
+	return true
+}
+
 func containsAllLabels(h *model.MachineParams, labels map[string]string) bool {
 	if h == nil {
 		return true
@@ -84,6 +103,31 @@ func containsAnyLabel(h *model.MachineParams, labels map[string]string) bool {
 	return false
 }
 
+func matchLabelSelector(h *model.MachineParams, labels map[string]string, base bool) bool {
+	if h == nil || h.LabelSelector == nil || len(*h.LabelSelector) == 0 {
+		return base
+	}
+	sel, err := sabakan.ParseLabelSelector(*h.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(labels)
+}
+
+func matchInventoryFilter(h *model.MachineParams, inv *sabakan.Inventory, base bool) bool {
+	if h == nil || h.InventoryFilter == nil || len(*h.InventoryFilter) == 0 {
+		return base
+	}
+	if inv == nil {
+		return false
+	}
+	filter, err := sabakan.ParseInventoryFilter(*h.InventoryFilter)
+	if err != nil {
+		return false
+	}
+	return filter.Matches(inv)
+}
+
 func containsRack(h *model.MachineParams, target int, base bool) bool {
 	if h == nil || len(h.Racks) == 0 {
 		return base
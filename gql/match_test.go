@@ -12,6 +12,10 @@ func testInt(i int) *int {
 	return &i
 }
 
+func testString(s string) *string {
+	return &s
+}
+
 func TestMatchMachine(t *testing.T) {
 	now := time.Date(2018, time.November, 26, 0, 0, 0, 0, time.UTC)
 	nowPlus60 := now.Add(time.Hour * 24 * 60)
@@ -123,6 +127,58 @@ func TestMatchMachine(t *testing.T) {
 			now:    now,
 			expect: false,
 		},
+		{
+			name: "label-selector-match",
+			machine: &sabakan.Machine{
+				Spec: sabakan.MachineSpec{
+					Labels: map[string]string{
+						"foo":  "bar",
+						"foo2": "bar2",
+					},
+				},
+				Status: sabakan.MachineStatus{},
+			},
+			having: &model.MachineParams{
+				LabelSelector: testString("foo in (bar,zot),foo2!=bar3,!foo3"),
+			},
+			notHaving: &model.MachineParams{},
+			now:       now,
+			expect:    true,
+		},
+		{
+			name: "label-selector-mismatch",
+			machine: &sabakan.Machine{
+				Spec: sabakan.MachineSpec{
+					Labels: map[string]string{
+						"foo": "bar",
+					},
+				},
+				Status: sabakan.MachineStatus{},
+			},
+			having: &model.MachineParams{
+				LabelSelector: testString("foo notin (bar,zot)"),
+			},
+			notHaving: &model.MachineParams{},
+			now:       now,
+			expect:    false,
+		},
+		{
+			name: "label-selector-excluded",
+			machine: &sabakan.Machine{
+				Spec: sabakan.MachineSpec{
+					Labels: map[string]string{
+						"foo": "bar",
+					},
+				},
+				Status: sabakan.MachineStatus{},
+			},
+			having: &model.MachineParams{},
+			notHaving: &model.MachineParams{
+				LabelSelector: testString("foo"),
+			},
+			now:    now,
+			expect: false,
+		},
 		{
 			name: "rack-mismatch",
 			machine: &sabakan.Machine{
@@ -336,3 +392,35 @@ func TestMatchMachine(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchInventory(t *testing.T) {
+	inv := &sabakan.Inventory{
+		CPUs:   []sabakan.InventoryCPU{{Model: "Xeon", Cores: 20, Threads: 40}},
+		Memory: 192 << 30,
+	}
+
+	testCases := []struct {
+		name      string
+		inventory *sabakan.Inventory
+		having    *model.MachineParams
+		notHaving *model.MachineParams
+		expect    bool
+	}{
+		{"trivial", inv, &model.MachineParams{}, &model.MachineParams{}, true},
+		{"no-inventory", nil, &model.MachineParams{}, &model.MachineParams{}, true},
+		{"having-match", inv, &model.MachineParams{InventoryFilter: testString("memory<256GiB")}, nil, true},
+		{"having-mismatch", inv, &model.MachineParams{InventoryFilter: testString("memory<128GiB")}, nil, false},
+		{"having-no-inventory", nil, &model.MachineParams{InventoryFilter: testString("memory<256GiB")}, nil, false},
+		{"not-having-match", inv, nil, &model.MachineParams{InventoryFilter: testString("cores>=20")}, false},
+		{"not-having-mismatch", inv, nil, &model.MachineParams{InventoryFilter: testString("cores>20")}, true},
+		{"not-having-no-inventory", nil, nil, &model.MachineParams{InventoryFilter: testString("cores>=20")}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if MatchInventory(tc.inventory, tc.having, tc.notHaving) != tc.expect {
+				t.Error("unexpected result")
+			}
+		})
+	}
+}
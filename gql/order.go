@@ -0,0 +1,35 @@
+package gql
+
+import (
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/gql/graph/model"
+)
+
+// MachineListOptions converts GraphQL pagination arguments into sabakan.MachineListOptions.
+func MachineListOptions(orderBy *model.MachineOrder, first *int, after *string) sabakan.MachineListOptions {
+	var opts sabakan.MachineListOptions
+
+	opts.SortBy = sabakan.SortBySerial
+	if orderBy != nil {
+		switch orderBy.Field {
+		case model.MachineOrderFieldRack:
+			opts.SortBy = sabakan.SortByRack
+		case model.MachineOrderFieldIndexInRack:
+			opts.SortBy = sabakan.SortByIndex
+		case model.MachineOrderFieldState:
+			opts.SortBy = sabakan.SortByState
+		case model.MachineOrderFieldRegisterDate:
+			opts.SortBy = sabakan.SortByRegisterDate
+		case model.MachineOrderFieldRetireDate:
+			opts.SortBy = sabakan.SortByRetireDate
+		}
+		opts.Descending = orderBy.Direction != nil && *orderBy.Direction == model.OrderDirectionDesc
+	}
+	if first != nil {
+		opts.Limit = *first
+	}
+	if after != nil {
+		opts.Continue = *after
+	}
+	return opts
+}
@@ -22,9 +22,21 @@ const (
 	// ErrEncryptionKeyExists is an error code when a retiring machine to retired that still has disk encryption keys.
 	ErrEncryptionKeyExists = "ENCRYPTION_KEY_EXISTS"
 
+	// ErrInvalidLabelSelector is an error code when label selector is invalid.
+	ErrInvalidLabelSelector = "INVALID_LABEL_SELECTOR"
+
+	// ErrInvalidInventoryFilter is an error code when inventory filter is invalid.
+	ErrInvalidInventoryFilter = "INVALID_INVENTORY_FILTER"
+
+	// ErrInvalidPagination is an error code when pagination arguments are invalid.
+	ErrInvalidPagination = "INVALID_PAGINATION"
+
 	// ErrMachineNotFound is an error code when no specified machine found.
 	ErrMachineNotFound = "MACHINE_NOT_FOUND"
 
+	// ErrLeaseNotFound is an error code when no specified DHCP lease found.
+	ErrLeaseNotFound = "LEASE_NOT_FOUND"
+
 	// ErrInternalServerError is an error code when internal server error has occurred.
 	ErrInternalServerError = "INTERNAL_SERVER_ERROR"
 )
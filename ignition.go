@@ -2,6 +2,14 @@ package sabakan
 
 import (
 	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+var (
+	reAssetURL  = regexp.MustCompile(`/api/v1/assets/([^/?#"\s{}]+)`)
+	reAssetFunc = regexp.MustCompile(`\bAsset\s+"([^"]+)"`)
 )
 
 // IgnitionVersion represents the specification version of Ignition.
@@ -20,3 +28,53 @@ type IgnitionTemplate struct {
 	Template json.RawMessage        `json:"template"`
 	Metadata map[string]interface{} `json:"meta"`
 }
+
+// ReferencedAssets returns names of assets referenced by URLs or by
+// `Asset` template function in any string of the template, such as
+// sources of remote files and contents of systemd units.
+//
+// Names generated by template actions are not recognized.
+func (t *IgnitionTemplate) ReferencedAssets() ([]string, error) {
+	var cfg map[string]interface{}
+	err := json.Unmarshal(t.Template, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	walkStrings(cfg, func(s string) {
+		for _, m := range reAssetURL.FindAllStringSubmatch(s, -1) {
+			name, err := url.PathUnescape(m[1])
+			if err != nil {
+				continue
+			}
+			names = append(names, name)
+		}
+		for _, m := range reAssetFunc.FindAllStringSubmatch(s, -1) {
+			names = append(names, m[1])
+		}
+	})
+	return names, nil
+}
+
+// walkStrings calls f for each string in a decoded JSON value.
+// Object members are visited in the order of their keys.
+func walkStrings(v interface{}, f func(string)) {
+	switch v := v.(type) {
+	case string:
+		f(v)
+	case []interface{}:
+		for _, e := range v {
+			walkStrings(e, f)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkStrings(v[k], f)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package sabakan
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestReferencedAssets(t *testing.T) {
+	t.Parallel()
+
+	tmpl := &IgnitionTemplate{
+		Version: Ignition2_3,
+		Template: json.RawMessage(`{
+  "storage": {
+    "files": [
+      {"path": "/a", "contents": {"source": "{{ MyURL }}/api/v1/assets/foo"}},
+      {"path": "/b", "contents": {"source": "http://10.0.0.1:10080/api/v1/assets/bar%2B1?x=y"}},
+      {"path": "/c", "contents": {"source": "{{ MyURL }}/api/v1/assets/{{ .Serial }}"}},
+      {"path": "/d", "contents": {"source": "data:,hello"}},
+      {"path": "/e", "contents": {"source": "{{ (Asset \"baz\").URL }}"}},
+      {"path": "/f", "contents": {"source": "data:,{{ (Asset \"data\").Sha256 }}"}}
+    ]
+  },
+  "systemd": {
+    "units": [
+      {"name": "fetch.service", "contents": "ExecStart=/usr/bin/curl -o /tmp/x {{ (Asset \"unit\").URL }}"}
+    ]
+  }
+}`),
+	}
+	names, err := tmpl.ReferencedAssets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"foo", "bar+1", "baz", "data", "unit"}) {
+		t.Error("wrong names:", names)
+	}
+
+	tmpl.Template = json.RawMessage(`[]`)
+	_, err = tmpl.ReferencedAssets()
+	if err == nil {
+		t.Error("invalid template should be rejected")
+	}
+}
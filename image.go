@@ -38,6 +38,9 @@ type Image struct {
 	Size   int64     `json:"size"`
 	URLs   []string  `json:"urls"`
 	Exists bool      `json:"exists"`
+
+	// Sha256 is SHA256 checksums of image files keyed by filenames.
+	Sha256 map[string]string `json:"sha256,omitempty"`
 }
 
 // ImageIndex is a list of *Image.
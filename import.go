@@ -0,0 +1,67 @@
+package sabakan
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// ImportState is the state of an import.
+type ImportState string
+
+// Import states.
+const (
+	ImportRunning   = ImportState("running")
+	ImportCompleted = ImportState("completed")
+	ImportFailed    = ImportState("failed")
+)
+
+// ImportStatus is the progress of an asset or a boot image being imported
+// from an external URL by a sabakan server.
+type ImportStatus struct {
+	// Kind is either ReplicaKindAsset or ReplicaKindImage.
+	Kind string `json:"kind"`
+
+	// Name is the asset name or the OS name of the image.
+	Name string `json:"name"`
+
+	// ID is the image ID, or the asset ID assigned when completed.
+	ID string `json:"id,omitempty"`
+
+	Source string `json:"source"`
+	Sha256 string `json:"sha256"`
+
+	// Server is the URL of the sabakan server that fetches the source.
+	Server string `json:"server"`
+
+	State ImportState `json:"state"`
+
+	// Received is the number of bytes fetched so far.
+	Received int64 `json:"received"`
+
+	// Size is the size of the source, or zero if unknown.
+	Size int64 `json:"size,omitempty"`
+
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+}
+
+// ValidateImportSource validates the source URL and the SHA256 checksum of an import.
+// It returns the decoded checksum.
+func ValidateImportSource(src, sum string) ([]byte, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, errors.New("invalid source URL: " + src)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("source URL must be http or https: " + src)
+	}
+
+	csum, err := hex.DecodeString(sum)
+	if err != nil || len(csum) != 32 {
+		return nil, errors.New("invalid sha256: " + sum)
+	}
+	return csum, nil
+}
@@ -0,0 +1,32 @@
+package sabakan
+
+import "testing"
+
+func TestValidateImportSource(t *testing.T) {
+	t.Parallel()
+
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	cases := []struct {
+		src   string
+		sum   string
+		valid bool
+	}{
+		{"https://example.com/flatcar.tar", sum, true},
+		{"http://10.0.0.1:8080/containerd.tar.gz", sum, true},
+		{"", sum, false},
+		{"ftp://example.com/foo", sum, false},
+		{"/api/v1/assets/foo", sum, false},
+		{"https://example.com/foo", "", false},
+		{"https://example.com/foo", sum[:62], false},
+		{"https://example.com/foo", "zz" + sum[2:], false},
+	}
+	for _, c := range cases {
+		csum, err := ValidateImportSource(c.src, c.sum)
+		if (err == nil) != c.valid {
+			t.Errorf("%q %q: unexpected result: %v", c.src, c.sum, err)
+		}
+		if err == nil && len(csum) != 32 {
+			t.Errorf("%q: wrong checksum length: %d", c.src, len(csum))
+		}
+	}
+}
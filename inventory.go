@@ -0,0 +1,408 @@
+package sabakan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inventory is a hardware inventory document reported by a machine.
+//
+// Serial, Version and Timestamp are filled by sabakan.
+type Inventory struct {
+	Serial    string    `json:"serial"`
+	Version   int64     `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+
+	CPUs   []InventoryCPU  `json:"cpus"`
+	Memory uint64          `json:"memory"`
+	Disks  []InventoryDisk `json:"disks"`
+	NICs   []InventoryNIC  `json:"nics"`
+}
+
+// InventoryCPU represents a CPU package.
+type InventoryCPU struct {
+	Model   string `json:"model"`
+	Cores   uint   `json:"cores"`
+	Threads uint   `json:"threads"`
+}
+
+// InventoryDisk represents a disk device.
+// Path is the name of the device in /dev/disk/by-path.
+type InventoryDisk struct {
+	Path   string `json:"path"`
+	Model  string `json:"model"`
+	Serial string `json:"serial"`
+	Size   uint64 `json:"size"`
+}
+
+// InventoryNIC represents a network interface.
+// Speed is the link speed in Mbps.
+type InventoryNIC struct {
+	Name  string `json:"name"`
+	MAC   string `json:"mac"`
+	Speed uint   `json:"speed"`
+}
+
+// Validate validates the inventory.
+func (inv *Inventory) Validate() error {
+	paths := make(map[string]bool)
+	for _, d := range inv.Disks {
+		if len(d.Path) == 0 {
+			return errors.New("disk path is empty")
+		}
+		if paths[d.Path] {
+			return errors.New("duplicate disk path: " + d.Path)
+		}
+		paths[d.Path] = true
+	}
+
+	names := make(map[string]bool)
+	for _, n := range inv.NICs {
+		if len(n.Name) == 0 {
+			return errors.New("NIC name is empty")
+		}
+		if names[n.Name] {
+			return errors.New("duplicate NIC name: " + n.Name)
+		}
+		names[n.Name] = true
+		if len(n.MAC) > 0 {
+			if _, err := net.ParseMAC(n.MAC); err != nil {
+				return errors.New("invalid MAC address: " + n.MAC)
+			}
+		}
+	}
+	return nil
+}
+
+// TotalCores returns the number of CPU cores.
+func (inv *Inventory) TotalCores() uint {
+	var n uint
+	for _, c := range inv.CPUs {
+		n += c.Cores
+	}
+	return n
+}
+
+// TotalThreads returns the number of CPU threads.
+func (inv *Inventory) TotalThreads() uint {
+	var n uint
+	for _, c := range inv.CPUs {
+		n += c.Threads
+	}
+	return n
+}
+
+// TotalDiskSize returns the sum of disk sizes in bytes.
+func (inv *Inventory) TotalDiskSize() uint64 {
+	var n uint64
+	for _, d := range inv.Disks {
+		n += d.Size
+	}
+	return n
+}
+
+func (c InventoryCPU) String() string {
+	return fmt.Sprintf("%s (%d cores, %d threads)", c.Model, c.Cores, c.Threads)
+}
+
+func (d InventoryDisk) String() string {
+	return fmt.Sprintf("%s (%s, %s, %d bytes)", d.Path, d.Model, d.Serial, d.Size)
+}
+
+func (n InventoryNIC) String() string {
+	return fmt.Sprintf("%s (%s, %d Mbps)", n.Name, n.MAC, n.Speed)
+}
+
+// DiffInventory returns human-readable descriptions of hardware changes
+// from prev to cur.  Serial, Version and Timestamp are ignored.
+// If prev is nil, every part of cur is reported as added.
+func DiffInventory(prev, cur *Inventory) []string {
+	if prev == nil {
+		prev = &Inventory{}
+	}
+	var diffs []string
+
+	for i := 0; i < len(prev.CPUs) || i < len(cur.CPUs); i++ {
+		switch {
+		case i >= len(cur.CPUs):
+			diffs = append(diffs, fmt.Sprintf("cpu %d removed: %s", i, prev.CPUs[i]))
+		case i >= len(prev.CPUs):
+			diffs = append(diffs, fmt.Sprintf("cpu %d added: %s", i, cur.CPUs[i]))
+		case prev.CPUs[i] != cur.CPUs[i]:
+			diffs = append(diffs, fmt.Sprintf("cpu %d changed: %s -> %s", i, prev.CPUs[i], cur.CPUs[i]))
+		}
+	}
+
+	if prev.Memory != cur.Memory {
+		diffs = append(diffs, fmt.Sprintf("memory changed: %d -> %d", prev.Memory, cur.Memory))
+	}
+
+	prevDisks := make(map[string]InventoryDisk)
+	for _, d := range prev.Disks {
+		prevDisks[d.Path] = d
+	}
+	curDisks := make(map[string]InventoryDisk)
+	for _, d := range cur.Disks {
+		curDisks[d.Path] = d
+	}
+	for _, p := range sortedKeys(prevDisks, curDisks) {
+		pd, inPrev := prevDisks[p]
+		cd, inCur := curDisks[p]
+		switch {
+		case !inCur:
+			diffs = append(diffs, "disk removed: "+pd.String())
+		case !inPrev:
+			diffs = append(diffs, "disk added: "+cd.String())
+		case pd != cd:
+			diffs = append(diffs, "disk changed: "+pd.String()+" -> "+cd.String())
+		}
+	}
+
+	prevNICs := make(map[string]InventoryNIC)
+	for _, n := range prev.NICs {
+		prevNICs[n.Name] = n
+	}
+	curNICs := make(map[string]InventoryNIC)
+	for _, n := range cur.NICs {
+		curNICs[n.Name] = n
+	}
+	for _, name := range sortedKeys(prevNICs, curNICs) {
+		pn, inPrev := prevNICs[name]
+		cn, inCur := curNICs[name]
+		switch {
+		case !inCur:
+			diffs = append(diffs, "nic removed: "+pn.String())
+		case !inPrev:
+			diffs = append(diffs, "nic added: "+cn.String())
+		case pn != cn:
+			diffs = append(diffs, "nic changed: "+pn.String()+" -> "+cn.String())
+		}
+	}
+
+	return diffs
+}
+
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Inventory filter fields.
+const (
+	InventoryFieldMemory   = "memory"
+	InventoryFieldCPUs     = "cpus"
+	InventoryFieldCores    = "cores"
+	InventoryFieldThreads  = "threads"
+	InventoryFieldDisks    = "disks"
+	InventoryFieldDiskSize = "disk-size"
+	InventoryFieldNICs     = "nics"
+	InventoryFieldCPUModel = "cpu-model"
+	InventoryFieldMAC      = "mac"
+)
+
+var inventoryFilterOperators = []string{"<=", ">=", "!=", "<", ">", "="}
+
+// InventoryCondition is a condition on an inventory such as "memory<256GiB".
+type InventoryCondition struct {
+	Field    string
+	Operator string
+	Value    string
+
+	number uint64
+}
+
+// InventoryFilter is a list of inventory conditions.
+// An inventory matches the filter when it satisfies all the conditions.
+type InventoryFilter []InventoryCondition
+
+// ParseInventoryFilter parses a comma-separated list of conditions.
+//
+// Numeric fields are "memory", "cpus", "cores", "threads", "disks", "disk-size" and "nics".
+// They can be compared with "=", "!=", "<", "<=", ">" and ">=".
+// Values of "memory" and "disk-size" may have a unit suffix such as "GiB" or "TB".
+//
+// String fields are "cpu-model" and "mac".  They can be compared with "=" and "!=".
+// "cpu-model=X" matches inventories having a CPU of model X.
+// "mac=X" matches inventories having a NIC whose MAC address is X.
+func ParseInventoryFilter(s string) (InventoryFilter, error) {
+	var filter InventoryFilter
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if len(term) == 0 {
+			continue
+		}
+		c, err := parseInventoryCondition(term)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, c)
+	}
+	return filter, nil
+}
+
+func parseInventoryCondition(term string) (InventoryCondition, error) {
+	idx := strings.IndexAny(term, "<>=!")
+	if idx <= 0 {
+		return InventoryCondition{}, errors.New("invalid inventory condition: " + term)
+	}
+	c := InventoryCondition{Field: strings.TrimSpace(term[:idx])}
+	rest := term[idx:]
+	for _, op := range inventoryFilterOperators {
+		if strings.HasPrefix(rest, op) {
+			c.Operator = op
+			c.Value = strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+	if len(c.Operator) == 0 || len(c.Value) == 0 {
+		return InventoryCondition{}, errors.New("invalid inventory condition: " + term)
+	}
+
+	switch c.Field {
+	case InventoryFieldMemory, InventoryFieldDiskSize:
+		n, err := ParseSize(c.Value)
+		if err != nil {
+			return InventoryCondition{}, err
+		}
+		c.number = n
+	case InventoryFieldCPUs, InventoryFieldCores, InventoryFieldThreads, InventoryFieldDisks, InventoryFieldNICs:
+		n, err := strconv.ParseUint(c.Value, 10, 64)
+		if err != nil {
+			return InventoryCondition{}, errors.New("invalid number in inventory condition: " + term)
+		}
+		c.number = n
+	case InventoryFieldCPUModel:
+		if c.Operator != "=" && c.Operator != "!=" {
+			return InventoryCondition{}, errors.New("invalid operator for cpu-model: " + c.Operator)
+		}
+	case InventoryFieldMAC:
+		if c.Operator != "=" && c.Operator != "!=" {
+			return InventoryCondition{}, errors.New("invalid operator for mac: " + c.Operator)
+		}
+		mac, err := net.ParseMAC(c.Value)
+		if err != nil {
+			return InventoryCondition{}, errors.New("invalid MAC address in inventory condition: " + term)
+		}
+		c.Value = mac.String()
+	default:
+		return InventoryCondition{}, errors.New("unknown inventory field: " + c.Field)
+	}
+	return c, nil
+}
+
+// Matches returns true if inv satisfies c.
+func (c InventoryCondition) Matches(inv *Inventory) bool {
+	switch c.Field {
+	case InventoryFieldCPUModel:
+		found := false
+		for _, cpu := range inv.CPUs {
+			if cpu.Model == c.Value {
+				found = true
+				break
+			}
+		}
+		return found == (c.Operator == "=")
+	case InventoryFieldMAC:
+		found := false
+		for _, nic := range inv.NICs {
+			mac, err := net.ParseMAC(nic.MAC)
+			if err == nil && mac.String() == c.Value {
+				found = true
+				break
+			}
+		}
+		return found == (c.Operator == "=")
+	}
+
+	var v uint64
+	switch c.Field {
+	case InventoryFieldMemory:
+		v = inv.Memory
+	case InventoryFieldCPUs:
+		v = uint64(len(inv.CPUs))
+	case InventoryFieldCores:
+		v = uint64(inv.TotalCores())
+	case InventoryFieldThreads:
+		v = uint64(inv.TotalThreads())
+	case InventoryFieldDisks:
+		v = uint64(len(inv.Disks))
+	case InventoryFieldDiskSize:
+		v = inv.TotalDiskSize()
+	case InventoryFieldNICs:
+		v = uint64(len(inv.NICs))
+	default:
+		return false
+	}
+
+	switch c.Operator {
+	case "=":
+		return v == c.number
+	case "!=":
+		return v != c.number
+	case "<":
+		return v < c.number
+	case "<=":
+		return v <= c.number
+	case ">":
+		return v > c.number
+	case ">=":
+		return v >= c.number
+	}
+	return false
+}
+
+// Matches returns true if inv satisfies all the conditions in f.
+func (f InventoryFilter) Matches(inv *Inventory) bool {
+	for _, c := range f {
+		if !c.Matches(inv) {
+			return false
+		}
+	}
+	return true
+}
+
+var sizeUnits = []struct {
+	suffix string
+	factor uint64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes such as "1024", "256GiB" or "4TB".
+func ParseSize(s string) (uint64, error) {
+	num := s
+	var factor uint64 = 1
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			num = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			factor = u.factor
+			break
+		}
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid size: " + s)
+	}
+	if n > 0 && factor > ^uint64(0)/n {
+		return 0, errors.New("too large size: " + s)
+	}
+	return n * factor, nil
+}
@@ -0,0 +1,157 @@
+package sabakan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testInventory() *Inventory {
+	return &Inventory{
+		CPUs: []InventoryCPU{
+			{Model: "Xeon Gold 6230", Cores: 20, Threads: 40},
+			{Model: "Xeon Gold 6230", Cores: 20, Threads: 40},
+		},
+		Memory: 192 << 30,
+		Disks: []InventoryDisk{
+			{Path: "pci-0000:00:1f.2-ata-1", Model: "SSD1", Serial: "S1", Size: 480e9},
+			{Path: "pci-0000:00:1f.2-ata-2", Model: "SSD1", Serial: "S2", Size: 480e9},
+		},
+		NICs: []InventoryNIC{
+			{Name: "eno1", MAC: "0C:C4:7A:00:00:01", Speed: 25000},
+			{Name: "eno2", MAC: "0c:c4:7a:00:00:02", Speed: 25000},
+		},
+	}
+}
+
+func TestInventoryValidate(t *testing.T) {
+	t.Parallel()
+
+	inv := testInventory()
+	if err := inv.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	inv = testInventory()
+	inv.Disks[1].Path = inv.Disks[0].Path
+	if err := inv.Validate(); err == nil {
+		t.Error("duplicate disk path should be rejected")
+	}
+
+	inv = testInventory()
+	inv.NICs[0].Name = ""
+	if err := inv.Validate(); err == nil {
+		t.Error("empty NIC name should be rejected")
+	}
+
+	inv = testInventory()
+	inv.NICs[0].MAC = "foo"
+	if err := inv.Validate(); err == nil {
+		t.Error("invalid MAC address should be rejected")
+	}
+}
+
+func TestDiffInventory(t *testing.T) {
+	t.Parallel()
+
+	prev := testInventory()
+	cur := testInventory()
+	if diffs := DiffInventory(prev, cur); len(diffs) != 0 {
+		t.Error("no difference should be reported:", diffs)
+	}
+
+	cur.Memory = 384 << 30
+	cur.Disks = cur.Disks[:1]
+	cur.NICs[1].Speed = 10000
+	cur.NICs = append(cur.NICs, InventoryNIC{Name: "eno3", MAC: "0c:c4:7a:00:00:03", Speed: 1000})
+	expected := []string{
+		"memory changed: 206158430208 -> 412316860416",
+		"disk removed: pci-0000:00:1f.2-ata-2 (SSD1, S2, 480000000000 bytes)",
+		"nic changed: eno2 (0c:c4:7a:00:00:02, 25000 Mbps) -> eno2 (0c:c4:7a:00:00:02, 10000 Mbps)",
+		"nic added: eno3 (0c:c4:7a:00:00:03, 1000 Mbps)",
+	}
+	if diffs := DiffInventory(prev, cur); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("wrong diffs: %#v", diffs)
+	}
+
+	if diffs := DiffInventory(nil, prev); len(diffs) != 7 {
+		t.Errorf("every part should be reported as added: %#v", diffs)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input    string
+		expected uint64
+		err      bool
+	}{
+		{"1024", 1024, false},
+		{"256GiB", 256 << 30, false},
+		{"256Gi", 256 << 30, false},
+		{"4TB", 4e12, false},
+		{"100B", 100, false},
+		{"GiB", 0, true},
+		{"-1", 0, true},
+		{"99999999999TiB", 0, true},
+	}
+
+	for _, c := range cases {
+		n, err := ParseSize(c.input)
+		if c.err {
+			if err == nil {
+				t.Errorf("error should be returned for %q", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.input, err)
+			continue
+		}
+		if n != c.expected {
+			t.Errorf("wrong size for %q: %d", c.input, n)
+		}
+	}
+}
+
+func TestInventoryFilter(t *testing.T) {
+	t.Parallel()
+
+	inv := testInventory()
+	cases := []struct {
+		filter  string
+		matches bool
+	}{
+		{"", true},
+		{"memory<256GiB", true},
+		{"memory>=256GiB", false},
+		{"memory=192GiB", true},
+		{"cpus=2,cores>=40", true},
+		{"threads>80", false},
+		{"disks=2,disk-size>=960GB", true},
+		{"nics!=2", false},
+		{"cpu-model=Xeon Gold 6230", true},
+		{"cpu-model!=Xeon Gold 6230", false},
+		{"mac=0c:c4:7a:00:00:01", true},
+		{"mac=0c-c4-7a-00-00-02", true},
+		{"mac!=0c:c4:7a:00:00:03", true},
+	}
+
+	for _, c := range cases {
+		f, err := ParseInventoryFilter(c.filter)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.filter, err)
+			continue
+		}
+		if f.Matches(inv) != c.matches {
+			t.Errorf("wrong result for %q", c.filter)
+		}
+	}
+
+	for _, s := range []string{"memory", "memory<", "<1", "foo=1", "memory<lots", "cpus<=x", "cpu-model<X", "mac=zz"} {
+		_, err := ParseInventoryFilter(s)
+		if err == nil {
+			t.Errorf("error should be returned for %q", s)
+		}
+	}
+}
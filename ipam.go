@@ -2,12 +2,47 @@ package sabakan
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"reflect"
+	"sort"
 
 	"github.com/cybozu-go/netutil"
 )
 
+// IPAMPool is a set of IPAM configurations for a group of racks.
+type IPAMPool struct {
+	MaxNodesInRack    uint   `json:"max-nodes-in-rack"`
+	NodeIPv4Pool      string `json:"node-ipv4-pool"`
+	NodeIPv4Offset    string `json:"node-ipv4-offset,omitempty"`
+	NodeRangeSize     uint   `json:"node-ipv4-range-size"`
+	NodeRangeMask     uint   `json:"node-ipv4-range-mask"`
+	NodeIPPerNode     uint   `json:"node-ip-per-node"`
+	NodeIndexOffset   uint   `json:"node-index-offset"`
+	NodeGatewayOffset uint   `json:"node-gateway-offset"`
+
+	// NodeIPv6Pool is an optional IPv6 network that mirrors NodeIPv4Pool.
+	// It is used by the DHCPv6 server.
+	NodeIPv6Pool string `json:"node-ipv6-pool,omitempty"`
+
+	BMCIPv4Pool      string `json:"bmc-ipv4-pool"`
+	BMCIPv4Offset    string `json:"bmc-ipv4-offset,omitempty"`
+	BMCRangeSize     uint   `json:"bmc-ipv4-range-size"`
+	BMCRangeMask     uint   `json:"bmc-ipv4-range-mask"`
+	BMCGatewayOffset uint   `json:"bmc-ipv4-gateway-offset"`
+}
+
+// RackPool maps racks from Begin to End (inclusive) to a named pool.
+type RackPool struct {
+	Begin uint   `json:"begin"`
+	End   uint   `json:"end"`
+	Pool  string `json:"pool"`
+}
+
 // IPAMConfig is a set of IPAM configurations.
+//
+// The top-level fields make up the default pool used for racks that are
+// not mapped to any named pool by RackPools.
 type IPAMConfig struct {
 	MaxNodesInRack    uint   `json:"max-nodes-in-rack"`
 	NodeIPv4Pool      string `json:"node-ipv4-pool"`
@@ -18,62 +53,390 @@ type IPAMConfig struct {
 	NodeIndexOffset   uint   `json:"node-index-offset"`
 	NodeGatewayOffset uint   `json:"node-gateway-offset"`
 
+	// NodeIPv6Pool is an optional IPv6 network that mirrors NodeIPv4Pool.
+	// It is used by the DHCPv6 server.
+	NodeIPv6Pool string `json:"node-ipv6-pool,omitempty"`
+
 	BMCIPv4Pool      string `json:"bmc-ipv4-pool"`
 	BMCIPv4Offset    string `json:"bmc-ipv4-offset,omitempty"`
 	BMCRangeSize     uint   `json:"bmc-ipv4-range-size"`
 	BMCRangeMask     uint   `json:"bmc-ipv4-range-mask"`
 	BMCGatewayOffset uint   `json:"bmc-ipv4-gateway-offset"`
+
+	Pools     map[string]*IPAMPool `json:"pools,omitempty"`
+	RackPools []RackPool           `json:"rack-pools,omitempty"`
+}
+
+// DefaultPool returns the default pool made of the top-level fields.
+func (c *IPAMConfig) DefaultPool() *IPAMPool {
+	return &IPAMPool{
+		MaxNodesInRack:    c.MaxNodesInRack,
+		NodeIPv4Pool:      c.NodeIPv4Pool,
+		NodeIPv4Offset:    c.NodeIPv4Offset,
+		NodeRangeSize:     c.NodeRangeSize,
+		NodeRangeMask:     c.NodeRangeMask,
+		NodeIPPerNode:     c.NodeIPPerNode,
+		NodeIndexOffset:   c.NodeIndexOffset,
+		NodeGatewayOffset: c.NodeGatewayOffset,
+		NodeIPv6Pool:      c.NodeIPv6Pool,
+		BMCIPv4Pool:       c.BMCIPv4Pool,
+		BMCIPv4Offset:     c.BMCIPv4Offset,
+		BMCRangeSize:      c.BMCRangeSize,
+		BMCRangeMask:      c.BMCRangeMask,
+		BMCGatewayOffset:  c.BMCGatewayOffset,
+	}
 }
 
 // Validate validates configurations
 func (c *IPAMConfig) Validate() error {
-	if c.MaxNodesInRack == 0 {
+	def := c.DefaultPool()
+	err := def.Validate()
+	if err != nil {
+		return err
+	}
+
+	pools := []*IPAMPool{def}
+	for name, p := range c.Pools {
+		if len(name) == 0 {
+			return errors.New("pool name must not be empty")
+		}
+		if p == nil {
+			return fmt.Errorf("pool %s is empty", name)
+		}
+		err := p.Validate()
+		if err != nil {
+			return fmt.Errorf("pool %s: %v", name, err)
+		}
+		pools = append(pools, p)
+	}
+	for i, p := range pools {
+		for _, q := range pools[i+1:] {
+			if cidrOverlaps(p.NodeIPv4Pool, q.NodeIPv4Pool) {
+				return errors.New("node-ipv4-pool of pools must not overlap")
+			}
+			if cidrOverlaps(p.NodeIPv6Pool, q.NodeIPv6Pool) {
+				return errors.New("node-ipv6-pool of pools must not overlap")
+			}
+			if cidrOverlaps(p.BMCIPv4Pool, q.BMCIPv4Pool) {
+				return errors.New("bmc-ipv4-pool of pools must not overlap")
+			}
+		}
+	}
+
+	mapped := make(map[string]bool)
+	for i, rp := range c.RackPools {
+		if _, ok := c.Pools[rp.Pool]; !ok {
+			return fmt.Errorf("rack-pools refers to unknown pool: %s", rp.Pool)
+		}
+		if rp.Begin > rp.End {
+			return fmt.Errorf("invalid rack range for pool %s: %d-%d", rp.Pool, rp.Begin, rp.End)
+		}
+		if mapped[rp.Pool] {
+			return fmt.Errorf("pool %s is mapped more than once", rp.Pool)
+		}
+		mapped[rp.Pool] = true
+		for _, other := range c.RackPools[:i] {
+			if rp.Begin <= other.End && other.Begin <= rp.End {
+				return fmt.Errorf("rack ranges for pool %s and %s overlap", rp.Pool, other.Pool)
+			}
+		}
+	}
+
+	return nil
+}
+
+func cidrOverlaps(a, b string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	_, netA, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+	_, netB, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
+
+// PoolForRack returns the pool for rack and the logical rack number
+// in the pool.
+func (c *IPAMConfig) PoolForRack(rack uint) (*IPAMPool, uint) {
+	for _, rp := range c.RackPools {
+		if rp.Begin <= rack && rack <= rp.End {
+			return c.Pools[rp.Pool], rack - rp.Begin
+		}
+	}
+	return c.DefaultPool(), rack
+}
+
+// PoolForIP returns the pool whose node-ipv4-pool contains ip.
+// If no named pool contains ip, this returns the default pool.
+func (c *IPAMConfig) PoolForIP(ip net.IP) *IPAMPool {
+	_, p := c.poolForIP(ip)
+	return p
+}
+
+func (c *IPAMConfig) poolForIP(ip net.IP) (string, *IPAMPool) {
+	for name, p := range c.Pools {
+		_, ipNet, err := net.ParseCIDR(p.NodeIPv4Pool)
+		if err == nil && ipNet.Contains(ip) {
+			return name, p
+		}
+	}
+	return "", c.DefaultPool()
+}
+
+// GatewayAddress returns a gateway address for the given node address
+func (c *IPAMConfig) GatewayAddress(addr *net.IPNet) *net.IPNet {
+	return c.PoolForIP(addr.IP).GatewayAddress(addr)
+}
+
+// Rack returns the rack number of a node IP address.
+// If ip is not in node-ipv4-pool of any pool, this returns false.
+func (c *IPAMConfig) Rack(ip net.IP) (uint, bool) {
+	name, p := c.poolForIP(ip)
+	lrn, ok := p.rack(ip)
+	if !ok || len(name) == 0 {
+		return lrn, ok
+	}
+	for _, rp := range c.RackPools {
+		if rp.Pool == name && lrn <= rp.End-rp.Begin {
+			return rp.Begin + lrn, true
+		}
+	}
+	return 0, false
+}
+
+// NodeIPv6Address returns the IPv6 address in node-ipv6-pool that
+// corresponds to an IPv4 address in node-ipv4-pool of the same pool.
+// If no such address exists, this returns nil.
+func (c *IPAMConfig) NodeIPv6Address(ip net.IP) net.IP {
+	return c.PoolForIP(ip).NodeIPv6Address(ip)
+}
+
+// NodeIPv4Address returns the IPv4 address in node-ipv4-pool that
+// corresponds to an IPv6 address in node-ipv6-pool of the same pool.
+// If no such address exists, this returns nil.
+func (c *IPAMConfig) NodeIPv4Address(ip net.IP) net.IP {
+	for _, p := range c.Pools {
+		if ip4 := p.NodeIPv4Address(ip); ip4 != nil {
+			return ip4
+		}
+	}
+	return c.DefaultPool().NodeIPv4Address(ip)
+}
+
+// GenerateIP generates IP addresses for a machine using the pool
+// of the machine's rack.
+// Generated IP addresses are stored in mc.
+func (c *IPAMConfig) GenerateIP(mc *Machine) {
+	p, lrn := c.PoolForRack(mc.Spec.Rack)
+	p.generateIP(mc, lrn)
+}
+
+// MachineAddresses is a set of addresses of a machine generated by GenerateIP.
+type MachineAddresses struct {
+	IPv4    []string    `json:"ipv4"`
+	BMCIPv4 string      `json:"bmc-ipv4"`
+	Network []NICConfig `json:"network"`
+	BMC     NICConfig   `json:"bmc"`
+}
+
+func machineAddresses(m *Machine) MachineAddresses {
+	return MachineAddresses{
+		IPv4:    m.Spec.IPv4,
+		BMCIPv4: m.Spec.BMC.IPv4,
+		Network: m.Info.Network.IPv4,
+		BMC:     m.Info.BMC.IPv4,
+	}
+}
+
+// IPAMChange represents how addresses of a machine change by IPAM configurations.
+type IPAMChange struct {
+	Serial string           `json:"serial"`
+	Old    MachineAddresses `json:"old"`
+	New    MachineAddresses `json:"new"`
+}
+
+// AddressChange returns how the addresses of m change if they are
+// generated by c.  If the addresses are kept, this returns nil.
+func (c *IPAMConfig) AddressChange(m *Machine) *IPAMChange {
+	generated := *m
+	c.GenerateIP(&generated)
+
+	before := machineAddresses(m)
+	after := machineAddresses(&generated)
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return &IPAMChange{
+		Serial: m.Spec.Serial,
+		Old:    before,
+		New:    after,
+	}
+}
+
+// IPAMConflict represents a reason why IPAM configurations cannot be
+// applied without force.
+type IPAMConflict struct {
+	// Serial is the serial number of the machine in conflict, if any.
+	Serial string `json:"serial,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// IPAMDryRun is the result of a dry-run of IPAM configurations.
+type IPAMDryRun struct {
+	Changes   []*IPAMChange   `json:"changes"`
+	Conflicts []*IPAMConflict `json:"conflicts"`
+}
+
+// Conflicts returns reasons why c cannot be applied to registered machines,
+// unexpired DHCP leases and DHCP reservations without force.
+//
+// The index in rack of a machine must be in the node index range of the
+// pool for its rack, or the DHCP lease range would cover the node addresses.
+// Leased addresses must stay in the same lease ranges, and reserved
+// addresses must stay in lease ranges.
+func (c *IPAMConfig) Conflicts(machines []*Machine, leases []*DHCPLease, reservations []*DHCPReservation) []*IPAMConflict {
+	conflicts := []*IPAMConflict{}
+	for _, m := range machines {
+		p, _ := c.PoolForRack(m.Spec.Rack)
+		if p == nil {
+			continue
+		}
+		idx := m.Spec.IndexInRack
+		first, last := p.NodeIndexOffset, p.NodeIndexOffset+p.MaxNodesInRack
+		if idx < first || last < idx {
+			conflicts = append(conflicts, &IPAMConflict{
+				Serial: m.Spec.Serial,
+				Reason: fmt.Sprintf("index-in-rack %d is out of node index range [%d, %d]", idx, first, last),
+			})
+		}
+	}
+
+	for _, l := range leases {
+		ip := net.ParseIP(l.IP)
+		lr := c.LeaseRange(ip)
+		if lr == nil || lr.Key() != l.Range {
+			conflicts = append(conflicts, &IPAMConflict{
+				Reason: fmt.Sprintf("lease range of leased address %s would move from %s", l.IP, l.Range),
+			})
+			continue
+		}
+		if _, ok := lr.Index(ip); !ok {
+			conflicts = append(conflicts, &IPAMConflict{
+				Reason: fmt.Sprintf("leased address %s would be out of lease range %s", l.IP, l.Range),
+			})
+		}
+	}
+
+	for _, r := range reservations {
+		ip := net.ParseIP(r.IP)
+		lr := c.LeaseRange(ip)
+		if lr != nil {
+			if _, ok := lr.Index(ip); ok {
+				continue
+			}
+		}
+		conflicts = append(conflicts, &IPAMConflict{
+			Reason: fmt.Sprintf("reserved address %s for %s would be out of lease ranges", r.IP, r.MAC),
+		})
+	}
+
+	return conflicts
+}
+
+// LeaseRange returns a LeaseRange for the interface that receives DHCP requests.
+// If no range can be assigned, this returns nil.
+func (c *IPAMConfig) LeaseRange(ifaddr net.IP) *LeaseRange {
+	return c.PoolForIP(ifaddr).LeaseRange(ifaddr)
+}
+
+// LeaseRanges returns all lease ranges in the default and named pools.
+// Ranges in named pools that are not mapped to any rack are excluded.
+func (c *IPAMConfig) LeaseRanges() []*LeaseRange {
+	ranges := c.DefaultPool().leaseRanges()
+
+	names := make([]string, 0, len(c.Pools))
+	for name := range c.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, lr := range c.Pools[name].leaseRanges() {
+			if _, ok := c.Rack(lr.BeginAddress); ok {
+				ranges = append(ranges, lr)
+			}
+		}
+	}
+	return ranges
+}
+
+// Validate validates configurations
+func (p *IPAMPool) Validate() error {
+	if p.MaxNodesInRack == 0 {
 		return errors.New("max-nodes-in-rack must not be zero")
 	}
 
-	ip, ipNet, err := net.ParseCIDR(c.NodeIPv4Pool)
+	ip, ipNet, err := net.ParseCIDR(p.NodeIPv4Pool)
 	if err != nil {
 		return errors.New("invalid node-ipv4-pool")
 	}
 	if !ip.Equal(ipNet.IP) {
 		return errors.New("host part of node-ipv4-pool must be cleared")
 	}
-	if len(c.NodeIPv4Offset) > 0 && net.ParseIP(c.NodeIPv4Offset) == nil {
+	if len(p.NodeIPv4Offset) > 0 && net.ParseIP(p.NodeIPv4Offset) == nil {
 		return errors.New("invalid node-ipv4-offset")
 	}
-	if c.NodeRangeSize == 0 {
+	if p.NodeRangeSize == 0 {
 		return errors.New("node-ipv4-range-size must not be zero")
 	}
-	if c.NodeRangeMask < 8 || 32 < c.NodeRangeMask {
+	if p.NodeRangeMask < 8 || 32 < p.NodeRangeMask {
 		return errors.New("invalid node-ipv4-range-mask")
 	}
-	if c.NodeIPPerNode == 0 {
+	if p.NodeIPPerNode == 0 {
 		return errors.New("node-ip-per-node must not be zero")
 	}
-	if c.NodeIndexOffset == 0 {
+	if p.NodeIndexOffset == 0 {
 		return errors.New("node-index-offset must not be zero")
 	}
-	if c.NodeGatewayOffset == 0 {
+	if p.NodeGatewayOffset == 0 {
 		return errors.New("node-gateway-offset must not be zero")
 	}
+	if len(p.NodeIPv6Pool) > 0 {
+		ip6, ipNet6, err := net.ParseCIDR(p.NodeIPv6Pool)
+		if err != nil || ip6.To4() != nil {
+			return errors.New("invalid node-ipv6-pool")
+		}
+		if !ip6.Equal(ipNet6.IP) {
+			return errors.New("host part of node-ipv6-pool must be cleared")
+		}
+		ones4, _ := ipNet.Mask.Size()
+		ones6, _ := ipNet6.Mask.Size()
+		if 128-ones6 < 32-ones4 {
+			return errors.New("node-ipv6-pool must be as large as node-ipv4-pool")
+		}
+	}
 
-	ip, ipNet, err = net.ParseCIDR(c.BMCIPv4Pool)
+	ip, ipNet, err = net.ParseCIDR(p.BMCIPv4Pool)
 	if err != nil {
 		return errors.New("invalid bmc-ipv4-pool")
 	}
 	if !ip.Equal(ipNet.IP) {
 		return errors.New("host part of bmc-ipv4-pool must be cleared")
 	}
-	if len(c.BMCIPv4Offset) > 0 && net.ParseIP(c.BMCIPv4Offset) == nil {
+	if len(p.BMCIPv4Offset) > 0 && net.ParseIP(p.BMCIPv4Offset) == nil {
 		return errors.New("invalid bmc-ipv4-offset")
 	}
-	if c.BMCRangeSize == 0 {
+	if p.BMCRangeSize == 0 {
 		return errors.New("bmc-ipv4-range-size must not be zero")
 	}
-	if c.BMCRangeMask < 8 || 32 < c.BMCRangeMask {
+	if p.BMCRangeMask < 8 || 32 < p.BMCRangeMask {
 		return errors.New("invalid bmc-ipv4-range-mask")
 	}
-	if c.BMCGatewayOffset == 0 {
+	if p.BMCGatewayOffset == 0 {
 		return errors.New("bmc-ipv4-gateway-offset must not be zero")
 	}
 
@@ -81,16 +444,84 @@ func (c *IPAMConfig) Validate() error {
 }
 
 // GatewayAddress returns a gateway address for the given node address
-func (c *IPAMConfig) GatewayAddress(addr *net.IPNet) *net.IPNet {
+func (p *IPAMPool) GatewayAddress(addr *net.IPNet) *net.IPNet {
 	return &net.IPNet{
-		IP:   netutil.IPAdd(addr.IP.Mask(addr.Mask), int64(c.NodeGatewayOffset)),
+		IP:   netutil.IPAdd(addr.IP.Mask(addr.Mask), int64(p.NodeGatewayOffset)),
 		Mask: addr.Mask,
 	}
 }
 
-// GenerateIP generates IP addresses for a machine.
+// rack returns the logical rack number of a node IP address in the pool.
+// If ip is not in node-ipv4-pool, this returns false.
+func (p *IPAMPool) rack(ip net.IP) (uint, bool) {
+	ip1, ipNet, err := net.ParseCIDR(p.NodeIPv4Pool)
+	if err != nil || !ipNet.Contains(ip) {
+		return 0, false
+	}
+	var noffset int64
+	if len(p.NodeIPv4Offset) > 0 {
+		for _, b := range []byte(net.ParseIP(p.NodeIPv4Offset).To4()) {
+			noffset <<= 8
+			noffset |= int64(b)
+		}
+	}
+
+	diff := netutil.IPDiff(netutil.IPAdd(ip1, noffset), ip)
+	if diff < 0 {
+		return 0, false
+	}
+	rackSize := int64(uint(1)<<p.NodeRangeSize) * int64(p.NodeIPPerNode)
+	return uint(diff / rackSize), true
+}
+
+// NodeIPv6Address returns the IPv6 address in node-ipv6-pool that
+// corresponds to an IPv4 address in node-ipv4-pool.
+// If node-ipv6-pool is not configured or ip is not in node-ipv4-pool,
+// this returns nil.
+func (p *IPAMPool) NodeIPv6Address(ip net.IP) net.IP {
+	if len(p.NodeIPv6Pool) == 0 {
+		return nil
+	}
+	_, ipNet4, err := net.ParseCIDR(p.NodeIPv4Pool)
+	if err != nil || ip.To4() == nil || !ipNet4.Contains(ip) {
+		return nil
+	}
+	_, ipNet6, err := net.ParseCIDR(p.NodeIPv6Pool)
+	if err != nil {
+		return nil
+	}
+	return netutil.IPAdd(ipNet6.IP, netutil.IPDiff(ipNet4.IP, ip))
+}
+
+// NodeIPv4Address returns the IPv4 address in node-ipv4-pool that
+// corresponds to an IPv6 address in node-ipv6-pool.
+// If node-ipv6-pool is not configured or ip is not in the mirrored
+// part of node-ipv6-pool, this returns nil.
+func (p *IPAMPool) NodeIPv4Address(ip net.IP) net.IP {
+	if len(p.NodeIPv6Pool) == 0 {
+		return nil
+	}
+	_, ipNet6, err := net.ParseCIDR(p.NodeIPv6Pool)
+	if err != nil || ip.To4() != nil {
+		return nil
+	}
+	_, ipNet4, err := net.ParseCIDR(p.NodeIPv4Pool)
+	if err != nil {
+		return nil
+	}
+
+	// only the first part of node-ipv6-pool as large as node-ipv4-pool is mirrored
+	ones4, _ := ipNet4.Mask.Size()
+	mirror := &net.IPNet{IP: ipNet6.IP, Mask: net.CIDRMask(128-(32-ones4), 128)}
+	if !mirror.Contains(ip) {
+		return nil
+	}
+	return netutil.IPAdd(ipNet4.IP, netutil.IPDiff(ipNet6.IP, ip))
+}
+
+// generateIP generates IP addresses for a machine in the logical rack lrn.
 // Generated IP addresses are stored in mc.
-func (c *IPAMConfig) GenerateIP(mc *Machine) {
+func (p *IPAMPool) generateIP(mc *Machine, lrn uint) {
 	// IP addresses are calculated as follows (LRN=Logical Rack Number):
 	// node0: INET_NTOA(INET_ATON(NodeIPv4Pool) + INET_ATON(NodeIPv4Offset) + (2^NodeRangeSize * NodeIPPerNode * LRN) + index-in-rack)
 	// node1: INET_NTOA(INET_ATON(NodeIPv4Pool) + INET_ATON(NodeIPv4Offset) + (2^NodeRangeSize * NodeIPPerNode * LRN) + index-in-rack + 2^NodeRangeSize)
@@ -116,35 +547,34 @@ func (c *IPAMConfig) GenerateIP(mc *Machine) {
 		return result
 	}
 
-	lrn := mc.Spec.Rack
 	idx := mc.Spec.IndexInRack
 
-	ips := calc(c.NodeIPv4Pool, c.NodeIPv4Offset, c.NodeRangeSize, c.NodeIPPerNode, lrn, idx)
+	ips := calc(p.NodeIPv4Pool, p.NodeIPv4Offset, p.NodeRangeSize, p.NodeIPPerNode, lrn, idx)
 	strIPs := make([]string, len(ips))
 	nics := make([]NICConfig, len(ips))
-	mask := net.CIDRMask(int(c.NodeRangeMask), 32)
+	mask := net.CIDRMask(int(p.NodeRangeMask), 32)
 	strMask := net.IP(mask).String()
-	for i, p := range ips {
-		strP := p.String()
-		strIPs[i] = strP
-		nics[i].Address = strP
+	for i, ip := range ips {
+		strIP := ip.String()
+		strIPs[i] = strIP
+		nics[i].Address = strIP
 		nics[i].Netmask = strMask
-		nics[i].MaskBits = int(c.NodeRangeMask)
-		gw := c.GatewayAddress(&net.IPNet{IP: p, Mask: mask})
+		nics[i].MaskBits = int(p.NodeRangeMask)
+		gw := p.GatewayAddress(&net.IPNet{IP: ip, Mask: mask})
 		nics[i].Gateway = gw.IP.String()
 	}
 	mc.Spec.IPv4 = strIPs
 	mc.Spec.IPv6 = nil
 	mc.Info.Network.IPv4 = nics
 
-	bmcIPs := calc(c.BMCIPv4Pool, c.BMCIPv4Offset, c.BMCRangeSize, 1, lrn, idx)
+	bmcIPs := calc(p.BMCIPv4Pool, p.BMCIPv4Offset, p.BMCRangeSize, 1, lrn, idx)
 	mc.Spec.BMC.IPv4 = bmcIPs[0].String()
 	mc.Spec.BMC.IPv6 = ""
-	bmcMask := net.CIDRMask(int(c.BMCRangeMask), 32)
+	bmcMask := net.CIDRMask(int(p.BMCRangeMask), 32)
 	mc.Info.BMC.IPv4.Address = mc.Spec.BMC.IPv4
 	mc.Info.BMC.IPv4.Netmask = net.IP(bmcMask).String()
-	mc.Info.BMC.IPv4.MaskBits = int(c.BMCRangeMask)
-	bmcGW := netutil.IPAdd(bmcIPs[0].Mask(bmcMask), int64(c.BMCGatewayOffset))
+	mc.Info.BMC.IPv4.MaskBits = int(p.BMCRangeMask)
+	bmcGW := netutil.IPAdd(bmcIPs[0].Mask(bmcMask), int64(p.BMCGatewayOffset))
 	mc.Info.BMC.IPv4.Gateway = bmcGW.String()
 }
 
@@ -160,6 +590,16 @@ func (l *LeaseRange) IP(n int) net.IP {
 	return netutil.IPAdd(l.BeginAddress, int64(n))
 }
 
+// Index returns the index of ip in the range.
+// If ip is not in the range, this returns false.
+func (l *LeaseRange) Index(ip net.IP) (int, bool) {
+	diff := netutil.IPDiff(l.BeginAddress, ip)
+	if diff < 0 || diff >= int64(l.Count) {
+		return 0, false
+	}
+	return int(diff), true
+}
+
 // Key return key string.
 func (l *LeaseRange) Key() string {
 	if len(l.key) == 0 {
@@ -170,11 +610,11 @@ func (l *LeaseRange) Key() string {
 
 // LeaseRange returns a LeaseRange for the interface that receives DHCP requests.
 // If no range can be assigned, this returns nil.
-func (c *IPAMConfig) LeaseRange(ifaddr net.IP) *LeaseRange {
-	ip1, _, _ := net.ParseCIDR(c.NodeIPv4Pool)
+func (p *IPAMPool) LeaseRange(ifaddr net.IP) *LeaseRange {
+	ip1, _, _ := net.ParseCIDR(p.NodeIPv4Pool)
 	var noffset1 int64
-	if len(c.NodeIPv4Offset) > 0 {
-		for _, b := range []byte(net.ParseIP(c.NodeIPv4Offset).To4()) {
+	if len(p.NodeIPv4Offset) > 0 {
+		for _, b := range []byte(net.ParseIP(p.NodeIPv4Offset).To4()) {
 			noffset1 <<= 8
 			noffset1 |= int64(b)
 		}
@@ -193,14 +633,43 @@ func (c *IPAMConfig) LeaseRange(ifaddr net.IP) *LeaseRange {
 	// The lease range will start at offset 32, and ends at 62 (64 - 1 - 1).
 	// Therefore the available lease IP address count is 31.
 
-	rangeSize := uint32(1 << c.NodeRangeSize)
-	offset := uint32(c.NodeIndexOffset + c.MaxNodesInRack + 1)
+	rangeSize := uint32(1 << p.NodeRangeSize)
+	offset := uint32(p.NodeIndexOffset + p.MaxNodesInRack + 1)
 
 	ranges := diff / int64(rangeSize)
-	startIP := netutil.IPAdd(ip1, int64(int64(rangeSize)*ranges+int64(c.NodeIndexOffset+c.MaxNodesInRack+1)+noffset1))
+	startIP := netutil.IPAdd(ip1, int64(int64(rangeSize)*ranges+int64(p.NodeIndexOffset+p.MaxNodesInRack+1)+noffset1))
 	count := (rangeSize - 2) - offset + 1
 	return &LeaseRange{
 		BeginAddress: startIP,
 		Count:        int(count),
 	}
 }
+
+// leaseRanges returns all lease ranges in node-ipv4-pool.
+func (p *IPAMPool) leaseRanges() []*LeaseRange {
+	ip1, ipNet, err := net.ParseCIDR(p.NodeIPv4Pool)
+	if err != nil {
+		return nil
+	}
+	var noffset int64
+	if len(p.NodeIPv4Offset) > 0 {
+		for _, b := range []byte(net.ParseIP(p.NodeIPv4Offset).To4()) {
+			noffset <<= 8
+			noffset |= int64(b)
+		}
+	}
+
+	var ranges []*LeaseRange
+	rangeSize := int64(1) << p.NodeRangeSize
+	for start := netutil.IPAdd(ip1, noffset); ; start = netutil.IPAdd(start, rangeSize) {
+		if !ipNet.Contains(start) || !ipNet.Contains(netutil.IPAdd(start, rangeSize-1)) {
+			break
+		}
+		lr := p.LeaseRange(netutil.IPAdd(start, 1))
+		if lr == nil || lr.Count <= 0 {
+			continue
+		}
+		ranges = append(ranges, lr)
+	}
+	return ranges
+}
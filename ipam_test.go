@@ -128,15 +128,333 @@ func testLeaseRange(t *testing.T) {
 	if r.Count != 31 {
 		t.Error(`r.Count != 31:`, r.Count)
 	}
+
+	ranges := testIPAMConfig.LeaseRanges()
+	if len(ranges) != 64 {
+		t.Fatal("wrong number of lease ranges:", len(ranges))
+	}
+	if ranges[0].Key() != "10.69.0.32" || ranges[63].Key() != "10.69.15.224" {
+		t.Error("wrong lease ranges:", ranges[0].Key(), ranges[63].Key())
+	}
 	if r.IP(3).String() != "10.69.10.35" {
 		t.Error(`r.IP(3).String() != "10.69.10.35"`, r.IP(3).String())
 	}
 	if r.Key() != "10.69.10.32" {
 		t.Error(`r.Key() != "10.69.10.32:"`, r.Key())
 	}
+	if idx, ok := r.Index(net.ParseIP("10.69.10.35")); !ok || idx != 3 {
+		t.Error(`r.Index("10.69.10.35") != 3:`, idx, ok)
+	}
+	if _, ok := r.Index(net.ParseIP("10.69.10.31")); ok {
+		t.Error(`10.69.10.31 should not be in the range`)
+	}
+	if _, ok := r.Index(net.ParseIP("10.69.10.63")); ok {
+		t.Error(`10.69.10.63 should not be in the range`)
+	}
+}
+
+func testRack(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		ip   string
+		rack uint
+		ok   bool
+	}{
+		{"10.69.0.5", 0, true},
+		{"10.69.0.191", 0, true},
+		{"10.69.0.192", 1, true},
+		{"10.69.10.20", 13, true},
+		{"10.68.10.20", 0, false},
+		{"10.69.16.0", 0, false},
+	}
+	for _, c := range testCases {
+		rack, ok := testIPAMConfig.Rack(net.ParseIP(c.ip))
+		if rack != c.rack || ok != c.ok {
+			t.Error("wrong rack for "+c.ip, rack, ok)
+		}
+	}
+}
+
+func testNodeIPv6Address(t *testing.T) {
+	t.Parallel()
+
+	c := *testIPAMConfig
+	if c.NodeIPv6Address(net.ParseIP("10.69.0.5")) != nil {
+		t.Error("NodeIPv6Address should return nil without node-ipv6-pool")
+	}
+
+	c.NodeIPv6Pool = "fd00:69::/64"
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		ipv4 string
+		ipv6 string
+	}{
+		{"10.69.0.0", "fd00:69::"},
+		{"10.69.0.5", "fd00:69::5"},
+		{"10.69.10.32", "fd00:69::a20"},
+		{"10.69.15.255", "fd00:69::fff"},
+	}
+	for _, tc := range testCases {
+		ip6 := c.NodeIPv6Address(net.ParseIP(tc.ipv4))
+		if !ip6.Equal(net.ParseIP(tc.ipv6)) {
+			t.Error("wrong IPv6 address for "+tc.ipv4, ip6)
+		}
+		ip4 := c.NodeIPv4Address(net.ParseIP(tc.ipv6))
+		if !ip4.Equal(net.ParseIP(tc.ipv4)) {
+			t.Error("wrong IPv4 address for "+tc.ipv6, ip4)
+		}
+	}
+
+	for _, ip := range []string{"10.70.0.5", "fd00:69::1"} {
+		if c.NodeIPv6Address(net.ParseIP(ip)) != nil {
+			t.Error("NodeIPv6Address should return nil for " + ip)
+		}
+	}
+	for _, ip := range []string{"fd00:69::1000", "fd00:69:0:1::5", "fd00:70::5", "10.69.0.5"} {
+		if c.NodeIPv4Address(net.ParseIP(ip)) != nil {
+			t.Error("NodeIPv4Address should return nil for " + ip)
+		}
+	}
+
+	for _, pool := range []string{"fd00:69::/117", "fd00:69::1/64", "10.0.0.0/8", "fd00::69"} {
+		c.NodeIPv6Pool = pool
+		if err := c.Validate(); err == nil {
+			t.Error("node-ipv6-pool should be invalid: " + pool)
+		}
+	}
+	c.NodeIPv6Pool = "fd00:69::/116"
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func testPoolConfig() *IPAMConfig {
+	c := *testIPAMConfig
+	c.Pools = map[string]*IPAMPool{
+		"hall2": {
+			MaxNodesInRack:    60,
+			NodeIPv4Pool:      "10.80.0.0/16",
+			NodeRangeSize:     7,
+			NodeRangeMask:     25,
+			NodeIPPerNode:     2,
+			NodeIndexOffset:   3,
+			NodeGatewayOffset: 1,
+			BMCIPv4Pool:       "10.81.0.0/16",
+			BMCRangeSize:      7,
+			BMCRangeMask:      16,
+			BMCGatewayOffset:  1,
+		},
+	}
+	c.RackPools = []RackPool{{Begin: 100, End: 119, Pool: "hall2"}}
+	return &c
+}
+
+func testPools(t *testing.T) {
+	t.Parallel()
+
+	c := testPoolConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMachine(MachineSpec{Serial: "1234", Rack: 101, IndexInRack: 5})
+	c.GenerateIP(m)
+	if !reflect.DeepEqual(m.Spec.IPv4, []string{"10.80.1.5", "10.80.1.133"}) {
+		t.Error("wrong IP addresses:", m.Spec.IPv4)
+	}
+	expected := NICConfig{"10.80.1.5", "255.255.255.128", 25, "10.80.1.1"}
+	if !cmp.Equal(m.Info.Network.IPv4[0], expected) {
+		t.Error("unexpected NIC#0 config", cmp.Diff(m.Info.Network.IPv4[0], expected))
+	}
+	if m.Spec.BMC.IPv4 != "10.81.0.133" {
+		t.Error("wrong BMC address:", m.Spec.BMC.IPv4)
+	}
+	if c.AddressChange(m) != nil || testIPAMConfig.AddressChange(m) == nil {
+		t.Error("addresses in hall2 should be kept only with pools")
+	}
+
+	m = NewMachine(MachineSpec{Serial: "5678", Rack: 1, IndexInRack: 3})
+	c.GenerateIP(m)
+	if !reflect.DeepEqual(m.Spec.IPv4, []string{"10.69.0.195", "10.69.1.3", "10.69.1.67"}) {
+		t.Error("wrong IP addresses:", m.Spec.IPv4)
+	}
+	if testIPAMConfig.AddressChange(m) != nil {
+		t.Error("addresses in the default pool should be kept")
+	}
+
+	if p, lrn := c.PoolForRack(119); p != c.Pools["hall2"] || lrn != 19 {
+		t.Error("wrong pool for rack 119:", p, lrn)
+	}
+	if p, lrn := c.PoolForRack(120); !reflect.DeepEqual(p, c.DefaultPool()) || lrn != 120 {
+		t.Error("wrong pool for rack 120:", p, lrn)
+	}
+
+	r := c.LeaseRange(net.ParseIP("10.80.1.10"))
+	if r == nil {
+		t.Fatal("lease range for 10.80.1.10 must not be nil")
+	}
+	if r.BeginAddress.String() != "10.80.1.64" || r.Count != 63 {
+		t.Error("wrong lease range:", r.BeginAddress, r.Count)
+	}
+	r = c.LeaseRange(net.ParseIP("10.69.10.20"))
+	if r == nil || r.BeginAddress.String() != "10.69.10.32" {
+		t.Error("wrong lease range for the default pool:", r)
+	}
+
+	// hall2 has 2 ranges for each of 20 racks.
+	ranges := c.LeaseRanges()
+	if len(ranges) != 64+40 {
+		t.Fatal("wrong number of lease ranges:", len(ranges))
+	}
+	if last := ranges[len(ranges)-1]; last.Key() != "10.80.19.192" {
+		t.Error("wrong last lease range:", last.Key())
+	}
+
+	testCases := []struct {
+		ip   string
+		rack uint
+		ok   bool
+	}{
+		{"10.80.0.5", 100, true},
+		{"10.80.1.5", 101, true},
+		{"10.80.19.255", 119, true},
+		{"10.80.20.0", 0, false},
+		{"10.69.0.195", 1, true},
+	}
+	for _, tc := range testCases {
+		rack, ok := c.Rack(net.ParseIP(tc.ip))
+		if rack != tc.rack || ok != tc.ok {
+			t.Error("wrong rack for "+tc.ip, rack, ok)
+		}
+	}
+
+	gw := c.GatewayAddress(&net.IPNet{IP: net.ParseIP("10.80.1.70"), Mask: net.CIDRMask(25, 32)})
+	if gw.IP.String() != "10.80.1.1" {
+		t.Error("wrong gateway address:", gw.IP)
+	}
+
+	invalid := []func(c *IPAMConfig){
+		func(c *IPAMConfig) { c.RackPools[0].Pool = "hall3" },
+		func(c *IPAMConfig) { c.RackPools[0].Begin = 120 },
+		func(c *IPAMConfig) { c.RackPools = append(c.RackPools, RackPool{Begin: 120, End: 130, Pool: "hall2"}) },
+		func(c *IPAMConfig) { c.Pools["hall2"].NodeIPv4Pool = "10.69.8.0/21" },
+		func(c *IPAMConfig) { c.Pools["hall2"].BMCIPv4Pool = "10.72.0.0/16" },
+		func(c *IPAMConfig) { c.Pools["hall2"].MaxNodesInRack = 0 },
+		func(c *IPAMConfig) {
+			hall3 := *c.Pools["hall2"]
+			hall3.NodeIPv4Pool = "10.82.0.0/16"
+			hall3.BMCIPv4Pool = "10.83.0.0/16"
+			c.Pools["hall3"] = &hall3
+			c.RackPools = append(c.RackPools, RackPool{Begin: 110, End: 130, Pool: "hall3"})
+		},
+	}
+	for i, f := range invalid {
+		c := testPoolConfig()
+		f(c)
+		if err := c.Validate(); err == nil {
+			t.Error("config should be invalid:", i)
+		}
+	}
+}
+
+func testAddressChange(t *testing.T) {
+	t.Parallel()
+
+	m := NewMachine(MachineSpec{Serial: "1234", Rack: 1, IndexInRack: 3})
+	testIPAMConfig.GenerateIP(m)
+	if change := testIPAMConfig.AddressChange(m); change != nil {
+		t.Error("addresses should be kept:", change)
+	}
+
+	c := *testIPAMConfig
+	c.NodeRangeMask = 25
+	change := c.AddressChange(m)
+	if change == nil {
+		t.Fatal("change of netmask should be detected")
+	}
+	if !reflect.DeepEqual(change.Old.IPv4, change.New.IPv4) {
+		t.Error("addresses should be kept:", change.New.IPv4)
+	}
+	if change.Old.Network[0].MaskBits != 26 || change.New.Network[0].MaskBits != 25 {
+		t.Error("wrong netmask change:", change.Old.Network[0], change.New.Network[0])
+	}
+
+	c = *testIPAMConfig
+	c.BMCIPv4Offset = "0.0.2.0"
+	change = c.AddressChange(m)
+	if change == nil {
+		t.Fatal("change of BMC address should be detected")
+	}
+	if change.Serial != "1234" || change.Old.BMCIPv4 != "10.72.17.35" || change.New.BMCIPv4 != "10.72.18.35" {
+		t.Error("wrong BMC address change:", change)
+	}
+	if m.Spec.BMC.IPv4 != "10.72.17.35" {
+		t.Error("machine should not be modified:", m.Spec.BMC.IPv4)
+	}
+}
+
+func testConflicts(t *testing.T) {
+	t.Parallel()
+
+	m := NewMachine(MachineSpec{Serial: "1234", Rack: 0, IndexInRack: 20})
+	testIPAMConfig.GenerateIP(m)
+	leases := []*DHCPLease{
+		{Range: "10.69.0.32", IP: "10.69.0.40", MAC: "00:11:22:33:44:55"},
+	}
+	reservations := []*DHCPReservation{
+		{MAC: "00:11:22:33:44:66", IP: "10.69.0.50"},
+	}
+
+	if cs := testIPAMConfig.Conflicts([]*Machine{m}, leases, reservations); len(cs) != 0 {
+		t.Error("unexpected conflicts:", cs)
+	}
+
+	// lowering max-nodes-in-rack keeps addresses, but the lease range
+	// would cover the node and the leased address.
+	c := *testIPAMConfig
+	c.MaxNodesInRack = 10
+	if change := c.AddressChange(m); change != nil {
+		t.Error("addresses should be kept:", change)
+	}
+	cs := c.Conflicts([]*Machine{m}, leases, reservations)
+	if len(cs) != 2 {
+		t.Fatal("unexpected conflicts:", cs)
+	}
+	if cs[0].Serial != "1234" {
+		t.Error("index conflict was not detected:", cs[0])
+	}
+	if cs[1].Serial != "" {
+		t.Error("lease conflict was not detected:", cs[1])
+	}
+
+	// raising node-index-offset
+	c = *testIPAMConfig
+	c.NodeIndexOffset = 21
+	cs = c.Conflicts([]*Machine{m}, nil, nil)
+	if len(cs) != 1 || cs[0].Serial != "1234" {
+		t.Error("index conflict was not detected:", cs)
+	}
+
+	// shrinking lease ranges
+	c = *testIPAMConfig
+	c.NodeRangeSize = 5
+	c.MaxNodesInRack = 20
+	cs = c.Conflicts(nil, nil, reservations)
+	if len(cs) != 1 {
+		t.Error("reservation conflict was not detected:", cs)
+	}
 }
 
 func TestIPAM(t *testing.T) {
 	t.Run("GenerateIP", testGenerateIP)
 	t.Run("LeaseRange", testLeaseRange)
+	t.Run("Rack", testRack)
+	t.Run("NodeIPv6Address", testNodeIPv6Address)
+	t.Run("Pools", testPools)
+	t.Run("AddressChange", testAddressChange)
+	t.Run("Conflicts", testConflicts)
 }
@@ -0,0 +1,202 @@
+package sabakan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelOperator represents an operator of a label requirement.
+type LabelOperator string
+
+// Label operators.
+const (
+	LabelOpEquals       = LabelOperator("=")
+	LabelOpNotEquals    = LabelOperator("!=")
+	LabelOpIn           = LabelOperator("in")
+	LabelOpNotIn        = LabelOperator("notin")
+	LabelOpExists       = LabelOperator("exists")
+	LabelOpDoesNotExist = LabelOperator("!")
+)
+
+// LabelRequirement is a condition for a label of a machine.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Values   []string
+}
+
+// Matches returns true if labels satisfy the requirement.
+//
+// As Kubernetes does, "!=" and "notin" are satisfied by
+// labels that do not have the key.
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case LabelOpEquals, LabelOpIn:
+		return exists && r.hasValue(value)
+	case LabelOpNotEquals, LabelOpNotIn:
+		return !exists || !r.hasValue(value)
+	case LabelOpExists:
+		return exists
+	case LabelOpDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+func (r LabelRequirement) hasValue(value string) bool {
+	for _, v := range r.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the requirement in the selector syntax.
+func (r LabelRequirement) String() string {
+	switch r.Operator {
+	case LabelOpEquals, LabelOpNotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case LabelOpIn, LabelOpNotIn:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	case LabelOpDoesNotExist:
+		return "!" + r.Key
+	}
+	return r.Key
+}
+
+// LabelSelector is a set of label requirements joined by AND.
+type LabelSelector []LabelRequirement
+
+// Matches returns true if labels satisfy all requirements in the selector.
+// An empty selector matches everything.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabelSelector parses a comma-separated list of label requirements.
+//
+// Each requirement is one of the following forms:
+//
+//	key=value
+//	key==value
+//	key!=value
+//	key in (value1,value2,...)
+//	key notin (value1,value2,...)
+//	key
+//	!key
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	terms, err := splitLabelSelector(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := make(LabelSelector, 0, len(terms))
+	for _, term := range terms {
+		r, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// splitLabelSelector splits s by commas that are not enclosed in parentheses.
+func splitLabelSelector(s string) ([]string, error) {
+	var terms []string
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			if depth > 0 {
+				return nil, fmt.Errorf("nested parenthesis in label selector: %s", s)
+			}
+			depth++
+		case ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced parenthesis in label selector: %s", s)
+			}
+			depth--
+		case ',':
+			if depth > 0 {
+				continue
+			}
+			terms = appendLabelTerm(terms, s[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parenthesis in label selector: %s", s)
+	}
+	return appendLabelTerm(terms, s[start:]), nil
+}
+
+func appendLabelTerm(terms []string, term string) []string {
+	term = strings.TrimSpace(term)
+	if len(term) == 0 {
+		return terms
+	}
+	return append(terms, term)
+}
+
+func parseLabelRequirement(term string) (LabelRequirement, error) {
+	var r LabelRequirement
+
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		r.Key = strings.TrimSpace(term[1:])
+		r.Operator = LabelOpDoesNotExist
+	case strings.Contains(term, "!="):
+		kv := strings.SplitN(term, "!=", 2)
+		r.Key = strings.TrimSpace(kv[0])
+		r.Operator = LabelOpNotEquals
+		r.Values = []string{strings.TrimSpace(kv[1])}
+	case strings.Contains(term, "="):
+		kv := strings.SplitN(term, "=", 2)
+		r.Key = strings.TrimSpace(kv[0])
+		r.Operator = LabelOpEquals
+		r.Values = []string{strings.TrimSpace(strings.TrimPrefix(kv[1], "="))}
+	case strings.HasSuffix(term, ")"):
+		open := strings.Index(term, "(")
+		if open == -1 {
+			return r, fmt.Errorf("invalid label requirement: %s", term)
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 {
+			return r, fmt.Errorf("invalid label requirement: %s", term)
+		}
+		r.Key = fields[0]
+		switch LabelOperator(fields[1]) {
+		case LabelOpIn:
+			r.Operator = LabelOpIn
+		case LabelOpNotIn:
+			r.Operator = LabelOpNotIn
+		default:
+			return r, fmt.Errorf("unknown operator %q in label requirement: %s", fields[1], term)
+		}
+		for _, v := range strings.Split(term[open+1:len(term)-1], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+	default:
+		r.Key = term
+		r.Operator = LabelOpExists
+	}
+
+	if !IsValidLabelName(r.Key) {
+		return r, fmt.Errorf("invalid label name in label requirement: %s", term)
+	}
+	for _, v := range r.Values {
+		if !IsValidLabelValue(v) {
+			return r, fmt.Errorf("invalid label value in label requirement: %s", term)
+		}
+	}
+	return r, nil
+}
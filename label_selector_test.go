@@ -0,0 +1,81 @@
+package sabakan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input    string
+		expected LabelSelector
+	}{
+		{"", LabelSelector{}},
+		{"product=R630", LabelSelector{{Key: "product", Operator: LabelOpEquals, Values: []string{"R630"}}}},
+		{"product==R630", LabelSelector{{Key: "product", Operator: LabelOpEquals, Values: []string{"R630"}}}},
+		{"product!=R630", LabelSelector{{Key: "product", Operator: LabelOpNotEquals, Values: []string{"R630"}}}},
+		{"product=", LabelSelector{{Key: "product", Operator: LabelOpEquals, Values: []string{""}}}},
+		{"product in (R630, R730)", LabelSelector{{Key: "product", Operator: LabelOpIn, Values: []string{"R630", "R730"}}}},
+		{"product notin (R630)", LabelSelector{{Key: "product", Operator: LabelOpNotIn, Values: []string{"R630"}}}},
+		{"gpu", LabelSelector{{Key: "gpu", Operator: LabelOpExists}}},
+		{"!gpu", LabelSelector{{Key: "gpu", Operator: LabelOpDoesNotExist}}},
+		{" product in (R630,R730) , !gpu,datacenter=ty3 ", LabelSelector{
+			{Key: "product", Operator: LabelOpIn, Values: []string{"R630", "R730"}},
+			{Key: "gpu", Operator: LabelOpDoesNotExist},
+			{Key: "datacenter", Operator: LabelOpEquals, Values: []string{"ty3"}},
+		}},
+	}
+
+	for _, c := range cases {
+		sel, err := ParseLabelSelector(c.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(sel, c.expected) {
+			t.Errorf("wrong selector for %q: %#v", c.input, sel)
+		}
+	}
+
+	invalids := []string{
+		"product in (R630",
+		"product in R630)",
+		"product in ((R630))",
+		"product between (R630)",
+		"in (R630)",
+		"!",
+		"invalid key",
+		"product=invalid value",
+		"product in (R630,invalid/value)",
+	}
+	for _, input := range invalids {
+		_, err := ParseLabelSelector(input)
+		if err == nil {
+			t.Errorf("error should be returned for %q", input)
+		}
+	}
+}
+
+func TestLabelSelectorString(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"product=R630",
+		"product!=R630",
+		"product in (R630,R730)",
+		"product notin (R630,R730)",
+		"gpu",
+		"!gpu",
+	}
+	for _, input := range inputs {
+		sel, err := ParseLabelSelector(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sel[0].String() != input {
+			t.Errorf("wrong string for %q: %s", input, sel[0].String())
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package sabakan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MachineSortKey is a key to sort machines.
+type MachineSortKey string
+
+// Machine sort keys.
+const (
+	SortBySerial       = MachineSortKey("serial")
+	SortByRack         = MachineSortKey("rack")
+	SortByIndex        = MachineSortKey("index")
+	SortByState        = MachineSortKey("state")
+	SortByRegisterDate = MachineSortKey("register-date")
+	SortByRetireDate   = MachineSortKey("retire-date")
+)
+
+// IsValid returns true only if the MachineSortKey is pre-defined.
+func (k MachineSortKey) IsValid() bool {
+	switch k {
+	case SortBySerial, SortByRack, SortByIndex, SortByState, SortByRegisterDate, SortByRetireDate:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidContinue is returned when a continue token cannot be used.
+var ErrInvalidContinue = errors.New("invalid continue token")
+
+// MachineListOptions specifies how to sort and paginate machines.
+type MachineListOptions struct {
+	// SortBy is the sort key.  Machines are sorted by serial if empty.
+	SortBy MachineSortKey
+
+	// Descending reverses the sort order.
+	Descending bool
+
+	// Limit is the maximum number of machines in a page.  Zero means no limit.
+	Limit int
+
+	// Continue is a token returned for the previous page.
+	Continue string
+}
+
+// ParseMachineSort parses a sort parameter such as "rack" or "-register-date".
+// A leading "-" means descending order.
+func ParseMachineSort(s string) (MachineSortKey, bool, error) {
+	if len(s) == 0 {
+		return SortBySerial, false, nil
+	}
+	desc := strings.HasPrefix(s, "-")
+	key := MachineSortKey(strings.TrimPrefix(s, "-"))
+	if !key.IsValid() {
+		return "", false, errors.New("invalid sort key: " + string(key))
+	}
+	return key, desc, nil
+}
+
+// compareMachines compares a and b by key.  Ties are broken by serial.
+func compareMachines(a, b *Machine, key MachineSortKey) int {
+	c := 0
+	switch key {
+	case SortByRack:
+		c = compareUint(a.Spec.Rack, b.Spec.Rack)
+		if c == 0 {
+			c = compareUint(a.Spec.IndexInRack, b.Spec.IndexInRack)
+		}
+	case SortByIndex:
+		c = compareUint(a.Spec.IndexInRack, b.Spec.IndexInRack)
+		if c == 0 {
+			c = compareUint(a.Spec.Rack, b.Spec.Rack)
+		}
+	case SortByState:
+		c = strings.Compare(a.Status.State.String(), b.Status.State.String())
+	case SortByRegisterDate:
+		c = a.Spec.RegisterDate.Compare(b.Spec.RegisterDate)
+	case SortByRetireDate:
+		c = a.Spec.RetireDate.Compare(b.Spec.RetireDate)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.Spec.Serial, b.Spec.Serial)
+}
+
+func compareUint(a, b uint) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// SortMachines sorts machines by key.
+func SortMachines(machines []*Machine, key MachineSortKey, desc bool) {
+	sort.Slice(machines, func(i, j int) bool {
+		c := compareMachines(machines[i], machines[j], key)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// machineCursor records the position of a machine in a sorted list.
+type machineCursor struct {
+	SortBy       MachineSortKey `json:"sort"`
+	Descending   bool           `json:"desc,omitempty"`
+	Serial       string         `json:"serial"`
+	Rack         uint           `json:"rack,omitempty"`
+	IndexInRack  uint           `json:"index,omitempty"`
+	State        MachineState   `json:"state,omitempty"`
+	RegisterDate time.Time      `json:"register-date,omitzero"`
+	RetireDate   time.Time      `json:"retire-date,omitzero"`
+}
+
+func (c machineCursor) machine() *Machine {
+	return &Machine{
+		Spec: MachineSpec{
+			Serial:       c.Serial,
+			Rack:         c.Rack,
+			IndexInRack:  c.IndexInRack,
+			RegisterDate: c.RegisterDate,
+			RetireDate:   c.RetireDate,
+		},
+		Status: MachineStatus{State: c.State},
+	}
+}
+
+// MachineCursor returns an opaque token that points to the position
+// just after m in machines sorted by key.
+func MachineCursor(m *Machine, key MachineSortKey, desc bool) string {
+	c := machineCursor{
+		SortBy:     key,
+		Descending: desc,
+		Serial:     m.Spec.Serial,
+	}
+	switch key {
+	case SortByRack, SortByIndex:
+		c.Rack = m.Spec.Rack
+		c.IndexInRack = m.Spec.IndexInRack
+	case SortByState:
+		c.State = m.Status.State
+	case SortByRegisterDate:
+		c.RegisterDate = m.Spec.RegisterDate
+	case SortByRetireDate:
+		c.RetireDate = m.Spec.RetireDate
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeMachineCursor(token string) (*machineCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidContinue
+	}
+	c := new(machineCursor)
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, ErrInvalidContinue
+	}
+	return c, nil
+}
+
+// PaginateMachines sorts machines as specified in opts and returns a page
+// of them.  If more machines follow the page, a non-empty continue token
+// is returned together.
+//
+// Continue tokens do not depend on a snapshot, so machines registered or
+// deleted between requests may appear or disappear in later pages.
+func PaginateMachines(machines []*Machine, opts MachineListOptions) ([]*Machine, string, error) {
+	key := opts.SortBy
+	if len(key) == 0 {
+		key = SortBySerial
+	}
+	if !key.IsValid() {
+		return nil, "", errors.New("invalid sort key: " + string(key))
+	}
+	if opts.Limit < 0 {
+		return nil, "", errors.New("limit must not be negative")
+	}
+
+	SortMachines(machines, key, opts.Descending)
+
+	if len(opts.Continue) > 0 {
+		c, err := decodeMachineCursor(opts.Continue)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.SortBy != key || c.Descending != opts.Descending {
+			return nil, "", ErrInvalidContinue
+		}
+		last := c.machine()
+		i := sort.Search(len(machines), func(i int) bool {
+			cmp := compareMachines(machines[i], last, key)
+			if opts.Descending {
+				return cmp < 0
+			}
+			return cmp > 0
+		})
+		machines = machines[i:]
+	}
+
+	if opts.Limit == 0 || len(machines) <= opts.Limit {
+		return machines, "", nil
+	}
+
+	page := machines[:opts.Limit]
+	return page, MachineCursor(page[len(page)-1], key, opts.Descending), nil
+}
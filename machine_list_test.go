@@ -0,0 +1,142 @@
+package sabakan
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testMachinesForList() []*Machine {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	m1 := NewMachine(MachineSpec{Serial: "1", Rack: 1, IndexInRack: 4, RegisterDate: base.Add(3 * time.Hour)})
+	m2 := NewMachine(MachineSpec{Serial: "2", Rack: 0, IndexInRack: 5, RegisterDate: base.Add(1 * time.Hour)})
+	m3 := NewMachine(MachineSpec{Serial: "3", Rack: 1, IndexInRack: 3, RegisterDate: base.Add(2 * time.Hour)})
+	m4 := NewMachine(MachineSpec{Serial: "4", Rack: 0, IndexInRack: 4, RegisterDate: base.Add(1 * time.Hour)})
+	m2.Status.State = StateHealthy
+	m3.Status.State = StateRetired
+	return []*Machine{m3, m1, m4, m2}
+}
+
+func serialsOf(machines []*Machine) []string {
+	serials := make([]string, len(machines))
+	for i, m := range machines {
+		serials[i] = m.Spec.Serial
+	}
+	return serials
+}
+
+func TestParseMachineSort(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input string
+		key   MachineSortKey
+		desc  bool
+		err   bool
+	}{
+		{"", SortBySerial, false, false},
+		{"rack", SortByRack, false, false},
+		{"-register-date", SortByRegisterDate, true, false},
+		{"-", "", false, true},
+		{"labels", "", false, true},
+	}
+
+	for _, c := range cases {
+		key, desc, err := ParseMachineSort(c.input)
+		if c.err {
+			if err == nil {
+				t.Errorf("error should be returned for %q", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.input, err)
+			continue
+		}
+		if key != c.key || desc != c.desc {
+			t.Errorf("wrong result for %q: %s %v", c.input, key, desc)
+		}
+	}
+}
+
+func TestSortMachines(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		key     MachineSortKey
+		desc    bool
+		serials []string
+	}{
+		{SortBySerial, false, []string{"1", "2", "3", "4"}},
+		{SortBySerial, true, []string{"4", "3", "2", "1"}},
+		{SortByRack, false, []string{"4", "2", "3", "1"}},
+		{SortByIndex, false, []string{"3", "4", "1", "2"}},
+		{SortByState, false, []string{"2", "3", "1", "4"}},
+		{SortByRegisterDate, false, []string{"2", "4", "3", "1"}},
+		{SortByRegisterDate, true, []string{"1", "3", "4", "2"}},
+	}
+
+	for _, c := range cases {
+		machines := testMachinesForList()
+		SortMachines(machines, c.key, c.desc)
+		serials := serialsOf(machines)
+		if !reflect.DeepEqual(serials, c.serials) {
+			t.Errorf("wrong order for %s (desc=%v): %v", c.key, c.desc, serials)
+		}
+	}
+}
+
+func TestPaginateMachines(t *testing.T) {
+	t.Parallel()
+
+	for _, desc := range []bool{false, true} {
+		opts := MachineListOptions{SortBy: SortByRack, Descending: desc, Limit: 3}
+		var serials []string
+		for i := 0; ; i++ {
+			if i > 2 {
+				t.Fatal("too many pages")
+			}
+			page, next, err := PaginateMachines(testMachinesForList(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serials = append(serials, serialsOf(page)...)
+			if len(next) == 0 {
+				break
+			}
+			opts.Continue = next
+		}
+
+		expected := []string{"4", "2", "3", "1"}
+		if desc {
+			expected = []string{"1", "3", "2", "4"}
+		}
+		if !reflect.DeepEqual(serials, expected) {
+			t.Errorf("wrong pages (desc=%v): %v", desc, serials)
+		}
+	}
+
+	page, next, err := PaginateMachines(testMachinesForList(), MachineListOptions{Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 4 || len(next) != 0 {
+		t.Error("a page that contains all machines should not have continue token")
+	}
+
+	_, _, err = PaginateMachines(testMachinesForList(), MachineListOptions{Continue: "!!!"})
+	if err != ErrInvalidContinue {
+		t.Error("broken token should be rejected:", err)
+	}
+
+	token := MachineCursor(testMachinesForList()[0], SortByRack, false)
+	_, _, err = PaginateMachines(testMachinesForList(), MachineListOptions{SortBy: SortByState, Continue: token})
+	if err != ErrInvalidContinue {
+		t.Error("token for another sort key should be rejected:", err)
+	}
+
+	_, _, err = PaginateMachines(testMachinesForList(), MachineListOptions{Limit: -1})
+	if err == nil {
+		t.Error("negative limit should be rejected")
+	}
+}
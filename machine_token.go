@@ -0,0 +1,36 @@
+package sabakan
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// NewMachineToken generates a new token for the machine of serial.
+// A token is formatted as "SERIAL.SECRET".
+func NewMachineToken(serial string) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return serial + "." + hex.EncodeToString(b), nil
+}
+
+// ParseMachineToken returns the serial number of the machine in token.
+// ok is false if token is malformed.
+func ParseMachineToken(token string) (serial string, ok bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 || i == len(token)-1 {
+		return "", false
+	}
+	return token[:i], true
+}
+
+// HashMachineToken returns the hex-encoded SHA256 hash of token.
+// Servers store the hash instead of the token itself.
+func HashMachineToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
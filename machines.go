@@ -3,8 +3,10 @@ package sabakan
 import (
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
 	"time"
+	"unicode/utf8"
 
 	version "github.com/hashicorp/go-version"
 )
@@ -130,6 +132,77 @@ func IsValidLabelValue(value string) bool {
 	return reValidLabelVal.MatchString(value)
 }
 
+// MaxAnnotationsSize is the maximum total size in bytes of
+// annotation names and values of a machine.
+const MaxAnnotationsSize = 256 * 1024
+
+// ErrAnnotationsTooLarge is returned when annotations of a machine
+// exceed MaxAnnotationsSize.
+var ErrAnnotationsTooLarge = errors.New("annotations too large")
+
+// IsValidAnnotationName returns true if annotation name is valid.
+// Annotation names are validated in the same way as label names.
+func IsValidAnnotationName(name string) bool {
+	return reValidLabelName.MatchString(name)
+}
+
+// IsValidAnnotationValue returns true if annotation value is valid.
+// Any UTF-8 string is a valid annotation value.
+func IsValidAnnotationValue(value string) bool {
+	return utf8.ValidString(value)
+}
+
+// ValidateAnnotations validates names, values, and the total size of annotations.
+func ValidateAnnotations(annotations map[string]string) error {
+	size := 0
+	for k, v := range annotations {
+		if !IsValidAnnotationName(k) {
+			return errors.New("invalid annotation name: " + k)
+		}
+		if !IsValidAnnotationValue(v) {
+			return errors.New("annotation value is not valid UTF-8: " + k)
+		}
+		size += len(k) + len(v)
+	}
+	if size > MaxAnnotationsSize {
+		return ErrAnnotationsTooLarge
+	}
+	return nil
+}
+
+// NormalizeMAC parses s as a MAC address and returns it in the canonical
+// form, i.e. lower-case hexadecimal digits separated by colons.
+func NormalizeMAC(s string) (string, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return "", errors.New("invalid MAC address: " + s)
+	}
+	return mac.String(), nil
+}
+
+// NormalizeMACs normalizes MAC addresses by NormalizeMAC.
+// It returns an error if macs contain invalid or duplicate addresses.
+func NormalizeMACs(macs []string) ([]string, error) {
+	if len(macs) == 0 {
+		return nil, nil
+	}
+
+	res := make([]string, len(macs))
+	seen := make(map[string]bool)
+	for i, s := range macs {
+		mac, err := NormalizeMAC(s)
+		if err != nil {
+			return nil, err
+		}
+		if seen[mac] {
+			return nil, errors.New("duplicate MAC address: " + s)
+		}
+		seen[mac] = true
+		res[i] = mac
+	}
+	return res, nil
+}
+
 // MachineBMC is a bmc interface struct for Machine
 type MachineBMC struct {
 	IPv4 string `json:"ipv4"`
@@ -141,6 +214,7 @@ type MachineBMC struct {
 type MachineSpec struct {
 	Serial       string            `json:"serial"`
 	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
 	Rack         uint              `json:"rack"`
 	IndexInRack  uint              `json:"index-in-rack"`
 	Role         string            `json:"role"`
@@ -149,6 +223,7 @@ type MachineSpec struct {
 	RegisterDate time.Time         `json:"register-date"`
 	RetireDate   time.Time         `json:"retire-date"`
 	BMC          MachineBMC        `json:"bmc"`
+	MACs         []string          `json:"macs,omitempty"`
 }
 
 // MachineStatus represents the status of a machine.
@@ -248,3 +323,68 @@ func (m *Machine) DeleteLabel(label string) error {
 	delete(m.Spec.Labels, label)
 	return nil
 }
+
+// PutAnnotation adds an annotation to Machine if no annotation with the same name exists, or replaces an annotation.
+// If the new annotations exceed MaxAnnotationsSize, this returns ErrAnnotationsTooLarge
+// and the machine is not modified.
+func (m *Machine) PutAnnotation(name, value string) error {
+	annotations := make(map[string]string, len(m.Spec.Annotations)+1)
+	for k, v := range m.Spec.Annotations {
+		annotations[k] = v
+	}
+	annotations[name] = value
+
+	err := ValidateAnnotations(annotations)
+	if err != nil {
+		return err
+	}
+
+	m.Spec.Annotations = annotations
+	return nil
+}
+
+// HasMAC returns true if mac is bound to Machine.
+// mac must be normalized by NormalizeMAC.
+func (m *Machine) HasMAC(mac string) bool {
+	for _, v := range m.Spec.MACs {
+		if v == mac {
+			return true
+		}
+	}
+	return false
+}
+
+// AddMAC binds a MAC address to Machine.  mac must be normalized by NormalizeMAC.
+// It returns false if mac is already bound to Machine.
+func (m *Machine) AddMAC(mac string) bool {
+	if m.HasMAC(mac) {
+		return false
+	}
+	m.Spec.MACs = append(m.Spec.MACs, mac)
+	return true
+}
+
+// RemoveMAC unbinds a MAC address from Machine.
+func (m *Machine) RemoveMAC(mac string) error {
+	for i, v := range m.Spec.MACs {
+		if v == mac {
+			m.Spec.MACs = append(m.Spec.MACs[:i:i], m.Spec.MACs[i+1:]...)
+			if len(m.Spec.MACs) == 0 {
+				m.Spec.MACs = nil
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteAnnotation deletes annotation from Machine.
+func (m *Machine) DeleteAnnotation(name string) error {
+	_, ok := m.Spec.Annotations[name]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(m.Spec.Annotations, name)
+	return nil
+}
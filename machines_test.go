@@ -3,6 +3,7 @@ package sabakan
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -124,3 +125,99 @@ func TestMachine(t *testing.T) {
 		t.Error("label in m.Spec.Labels was not deleted correctly:", m.Spec.Labels)
 	}
 }
+
+func TestMachineAnnotations(t *testing.T) {
+	t.Parallel()
+
+	m := NewMachine(MachineSpec{Serial: "abc"})
+
+	err := m.PutAnnotation("note", "ラック前面のLEDが点滅")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.PutAnnotation("inventory", `{"cpu": "Xeon", "ram": "256GiB"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"note":      "ラック前面のLEDが点滅",
+		"inventory": `{"cpu": "Xeon", "ram": "256GiB"}`,
+	}
+	if !reflect.DeepEqual(m.Spec.Annotations, expected) {
+		t.Error("m.Spec.Annotations was not set correctly:", m.Spec.Annotations)
+	}
+
+	err = m.PutAnnotation("ticket/url", "https://example.com/")
+	if err == nil {
+		t.Error("invalid annotation name should be rejected")
+	}
+	err = m.PutAnnotation("binary", "\xff\xfe")
+	if err == nil {
+		t.Error("invalid UTF-8 value should be rejected")
+	}
+	err = m.PutAnnotation("huge", strings.Repeat("a", MaxAnnotationsSize))
+	if err != ErrAnnotationsTooLarge {
+		t.Error("too large annotations should be rejected:", err)
+	}
+	if !reflect.DeepEqual(m.Spec.Annotations, expected) {
+		t.Error("m.Spec.Annotations was modified by failed put:", m.Spec.Annotations)
+	}
+
+	err = m.DeleteAnnotation("note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Spec.Annotations["note"]; ok {
+		t.Error("annotation was not deleted correctly:", m.Spec.Annotations)
+	}
+	err = m.DeleteAnnotation("note")
+	if err != ErrNotFound {
+		t.Error("deleting missing annotation should return ErrNotFound:", err)
+	}
+}
+
+func TestMachineMACs(t *testing.T) {
+	t.Parallel()
+
+	macs, err := NormalizeMACs([]string{"0C:C4:7A:00:00:01", "0c-c4-7a-00-00-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(macs, []string{"0c:c4:7a:00:00:01", "0c:c4:7a:00:00:02"}) {
+		t.Error("MAC addresses were not normalized:", macs)
+	}
+	_, err = NormalizeMACs([]string{"0c:c4:7a:00:00:01", "0C:C4:7A:00:00:01"})
+	if err == nil {
+		t.Error("duplicate MAC addresses should be rejected")
+	}
+	_, err = NormalizeMACs([]string{"foo"})
+	if err == nil {
+		t.Error("invalid MAC address should be rejected")
+	}
+
+	m := NewMachine(MachineSpec{Serial: "abc", MACs: macs})
+	if !m.HasMAC("0c:c4:7a:00:00:02") {
+		t.Error("HasMAC returned false")
+	}
+	if m.AddMAC("0c:c4:7a:00:00:01") {
+		t.Error("AddMAC should return false for bound MAC address")
+	}
+	if !m.AddMAC("0c:c4:7a:00:00:03") {
+		t.Error("AddMAC should return true for new MAC address")
+	}
+
+	err = m.RemoveMAC("0c:c4:7a:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Spec.MACs, []string{"0c:c4:7a:00:00:02", "0c:c4:7a:00:00:03"}) {
+		t.Error("MAC address was not removed correctly:", m.Spec.MACs)
+	}
+	if !reflect.DeepEqual(macs, []string{"0c:c4:7a:00:00:01", "0c:c4:7a:00:00:02"}) {
+		t.Error("RemoveMAC modified the original slice:", macs)
+	}
+	err = m.RemoveMAC("0c:c4:7a:00:00:01")
+	if err != ErrNotFound {
+		t.Error("removing unbound MAC address should return ErrNotFound:", err)
+	}
+}
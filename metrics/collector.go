@@ -3,6 +3,7 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -57,6 +58,14 @@ func NewCollector(model *sabakan.Model) *Collector {
 				collectors: []prometheus.Collector{ImagesBytesTotal, ImagesItemsTotal},
 				updater:    updateImageMetrics,
 			},
+			"dhcp_messages": {
+				collectors: []prometheus.Collector{DHCPReceivedTotal, DHCPSentTotal, DHCPErrorsTotal, DHCPDeclinesTotal, DHCPSwitchPortMismatchTotal},
+				updater:    updateNop,
+			},
+			"dhcp_leases": {
+				collectors: []prometheus.Collector{DHCPLeasedAddresses, DHCPDeclinedAddresses, DHCPFreeAddresses},
+				updater:    updateDHCPLeaseMetrics,
+			},
 		},
 		model: model,
 		mu:    &sync.Mutex{},
@@ -181,6 +190,83 @@ func updateImageMetrics(ctx context.Context, model *sabakan.Model) error {
 	return nil
 }
 
+func updateDHCPLeaseMetrics(ctx context.Context, model *sabakan.Model) error {
+	DHCPLeasedAddresses.Reset()
+	DHCPDeclinedAddresses.Reset()
+	DHCPFreeAddresses.Reset()
+
+	ipam, err := model.IPAM.GetConfig()
+	if err != nil {
+		// IPAM is not configured yet
+		return nil
+	}
+	leases, err := model.DHCP.GetLeases(ctx)
+	if err != nil {
+		return err
+	}
+
+	reservations, err := model.DHCP.GetReservations(ctx)
+	if err != nil {
+		return err
+	}
+
+	type usage struct {
+		leased   int
+		declined int
+		reserved int
+	}
+	usages := make(map[string]*usage)
+	get := func(key string) *usage {
+		u := usages[key]
+		if u == nil {
+			u = new(usage)
+			usages[key] = u
+		}
+		return u
+	}
+	for _, l := range leases {
+		u := get(l.Range)
+		if l.Declined {
+			u.declined++
+		} else {
+			u.leased++
+		}
+	}
+
+	// reserved addresses are not recorded as leases, but cannot be leased.
+	for _, r := range reservations {
+		ip := net.ParseIP(r.IP)
+		if ip == nil {
+			continue
+		}
+		lr := ipam.LeaseRange(ip)
+		if lr == nil {
+			continue
+		}
+		if _, ok := lr.Index(ip); ok {
+			get(lr.Key()).reserved++
+		}
+	}
+
+	for _, lr := range ipam.LeaseRanges() {
+		key := lr.Key()
+		u := get(key)
+		rack := ""
+		if r, ok := ipam.Rack(lr.BeginAddress); ok {
+			rack = fmt.Sprint(r)
+		}
+		DHCPLeasedAddresses.WithLabelValues(key, rack).Set(float64(u.leased))
+		DHCPDeclinedAddresses.WithLabelValues(key, rack).Set(float64(u.declined))
+		free := lr.Count - u.leased - u.declined - u.reserved
+		if free < 0 {
+			free = 0
+		}
+		DHCPFreeAddresses.WithLabelValues(key, rack).Set(float64(free))
+	}
+
+	return nil
+}
+
 func updateNop(_ context.Context, _ *sabakan.Model) error {
 	return nil
 }
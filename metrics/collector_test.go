@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -282,6 +283,134 @@ func testAssetsMetrics(t *testing.T) {
 	}
 }
 
+func testDHCPMetrics(t *testing.T) {
+	model := mock.NewModel()
+	ctx := context.Background()
+	err := model.IPAM.PutConfig(ctx, &sabakan.IPAMConfig{
+		MaxNodesInRack:    28,
+		NodeIPv4Pool:      "10.69.0.0/20",
+		NodeRangeSize:     6,
+		NodeRangeMask:     26,
+		NodeIPPerNode:     3,
+		NodeIndexOffset:   3,
+		NodeGatewayOffset: 1,
+		BMCIPv4Pool:       "10.72.16.0/20",
+		BMCRangeSize:      5,
+		BMCRangeMask:      20,
+		BMCGatewayOffset:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.DHCP.PutConfig(ctx, &sabakan.DHCPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = model.DHCP.PutReservation(ctx, &sabakan.DHCPReservation{
+		MAC: "00:11:22:33:44:77",
+		IP:  "10.69.1.40",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rack 1
+	ifaddr := net.ParseIP("10.69.1.3")
+	for _, mac := range []string{"00:11:22:33:44:55", "00:11:22:33:44:66"} {
+		hw, _ := net.ParseMAC(mac)
+		_, err := model.DHCP.Lease(ctx, ifaddr, hw)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hw, _ := net.ParseMAC("00:11:22:33:44:66")
+	err = model.DHCP.Decline(ctx, net.ParseIP("10.69.1.33"), hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewCollector(&model)
+	handler := GetHandler(collector)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, req)
+	metricsFamily, err := parseMetrics(w.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// metrics are exposed for all lease ranges including unused ones.
+	expected := map[string][2]float64{
+		"sabakan_dhcp_leased_addresses":   {1, 0},
+		"sabakan_dhcp_declined_addresses": {1, 0},
+		"sabakan_dhcp_free_addresses":     {28, 31},
+	}
+	usedLabels := map[string]string{"range": "10.69.1.32", "rack": "1"}
+	unusedLabels := map[string]string{"range": "10.69.0.32", "rack": "0"}
+	for _, mf := range metricsFamily {
+		values, ok := expected[*mf.Name]
+		if !ok {
+			continue
+		}
+		delete(expected, *mf.Name)
+		if len(mf.Metric) != 64 {
+			t.Errorf("wrong number of metrics for %q: %d", *mf.Name, len(mf.Metric))
+			continue
+		}
+		found := 0
+		for _, m := range mf.Metric {
+			var value float64
+			switch lm := labelToMap(m.Label); {
+			case hasLabels(lm, usedLabels):
+				value = values[0]
+			case hasLabels(lm, unusedLabels):
+				value = values[1]
+			default:
+				continue
+			}
+			found++
+			if *m.Gauge.Value != value {
+				t.Errorf("value for %q is wrong.  expected: %f, actual: %f", *mf.Name, value, *m.Gauge.Value)
+			}
+		}
+		if found != 2 {
+			t.Errorf("metrics %q for some ranges were not found", *mf.Name)
+		}
+	}
+	for name := range expected {
+		t.Errorf("metrics %q was not found", name)
+	}
+
+	counter := NewDHCPCounter()
+	testCases := []struct {
+		name   string
+		labels map[string]string
+		inc    func()
+	}{
+		{"sabakan_dhcp_received_total", map[string]string{"type": "discover"}, func() { counter.IncReceived("discover") }},
+		{"sabakan_dhcp_sent_total", map[string]string{"type": "offer"}, func() { counter.IncSent("offer") }},
+		{"sabakan_dhcp_errors_total", map[string]string{"reason": "no_leasable_address"}, func() { counter.IncError("no_leasable_address") }},
+		{"sabakan_dhcp_declines_total", usedLabels, func() { counter.IncDeclined("10.69.1.32", "1") }},
+		{"sabakan_dhcp_switch_port_mismatch_total", map[string]string{"rack": "1", "index": "4"}, func() { counter.IncSwitchPortMismatch("1", "4") }},
+	}
+	for _, tt := range testCases {
+		oldValue, err := getCounterValue(handler, tt.name, tt.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.inc()
+		newValue, err := getCounterValue(handler, tt.name, tt.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (newValue - oldValue) != 1 {
+			t.Errorf("counter value difference of %q must be 1 but %f", tt.name, newValue-oldValue)
+		}
+	}
+}
+
 func twoMachines() (*sabakan.Model, error) {
 	model := mock.NewModel()
 	machines := []*sabakan.Machine{
@@ -439,4 +568,5 @@ func TestMetrics(t *testing.T) {
 	t.Run("MachineStatusWhenMachineDeleted", MachineStatusWhenMachineDeleted)
 	t.Run("APIMetrics", testAPIMetrics)
 	t.Run("AssetsImagesMetrics", testAssetsMetrics)
+	t.Run("DHCPMetrics", testDHCPMetrics)
 }
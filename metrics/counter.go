@@ -22,3 +22,49 @@ func NewCounter() *APICounter {
 func (c *APICounter) Inc(statusCode int, path, verb string) {
 	c.counter.WithLabelValues(fmt.Sprint(statusCode), path, verb).Inc()
 }
+
+// DHCPCounter represents DHCP message counters.
+type DHCPCounter struct {
+	received *prometheus.CounterVec
+	sent     *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	declines *prometheus.CounterVec
+	mismatch *prometheus.CounterVec
+}
+
+// NewDHCPCounter returns a new DHCPCounter.
+func NewDHCPCounter() *DHCPCounter {
+	return &DHCPCounter{
+		received: DHCPReceivedTotal,
+		sent:     DHCPSentTotal,
+		errors:   DHCPErrorsTotal,
+		declines: DHCPDeclinesTotal,
+		mismatch: DHCPSwitchPortMismatchTotal,
+	}
+}
+
+// IncReceived increments the counter of received messages of msgType.
+func (c *DHCPCounter) IncReceived(msgType string) {
+	c.received.WithLabelValues(msgType).Inc()
+}
+
+// IncSent increments the counter of sent messages of msgType.
+func (c *DHCPCounter) IncSent(msgType string) {
+	c.sent.WithLabelValues(msgType).Inc()
+}
+
+// IncError increments the counter of errors for reason.
+func (c *DHCPCounter) IncError(reason string) {
+	c.errors.WithLabelValues(reason).Inc()
+}
+
+// IncDeclined increments the counter of declined addresses in the lease range.
+func (c *DHCPCounter) IncDeclined(lrkey, rack string) {
+	c.declines.WithLabelValues(lrkey, rack).Inc()
+}
+
+// IncSwitchPortMismatch increments the counter of messages from the machine
+// at rack and index connected to an unexpected switch port.
+func (c *DHCPCounter) IncSwitchPortMismatch(rack, index string) {
+	c.mismatch.WithLabelValues(rack, index).Inc()
+}
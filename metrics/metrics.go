@@ -57,3 +57,83 @@ var ImagesItemsTotal = prometheus.NewGauge(
 		Help:      "The total items of Images.",
 	},
 )
+
+// DHCPReceivedTotal returns the total count of received DHCP messages
+var DHCPReceivedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcp_received_total",
+		Help:      "The total count of received DHCP messages.",
+	},
+	[]string{"type"},
+)
+
+// DHCPSentTotal returns the total count of sent DHCP messages
+var DHCPSentTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcp_sent_total",
+		Help:      "The total count of sent DHCP messages.",
+	},
+	[]string{"type"},
+)
+
+// DHCPErrorsTotal returns the total count of DHCP messages that were not answered
+var DHCPErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcp_errors_total",
+		Help:      "The total count of DHCP messages that were not answered.",
+	},
+	[]string{"reason"},
+)
+
+// DHCPDeclinesTotal returns the total count of addresses declined by DHCP clients
+var DHCPDeclinesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcp_declines_total",
+		Help:      "The total count of addresses declined by DHCP clients.",
+	},
+	[]string{"range", "rack"},
+)
+
+// DHCPSwitchPortMismatchTotal returns the total count of DHCP messages from machines connected to unexpected switch ports
+var DHCPSwitchPortMismatchTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dhcp_switch_port_mismatch_total",
+		Help:      "The total count of DHCP messages from machines connected to unexpected switch ports.",
+	},
+	[]string{"rack", "index"},
+)
+
+// DHCPLeasedAddresses returns the number of leased addresses per lease range
+var DHCPLeasedAddresses = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dhcp_leased_addresses",
+		Help:      "The number of leased addresses in a DHCP lease range.",
+	},
+	[]string{"range", "rack"},
+)
+
+// DHCPDeclinedAddresses returns the number of declined addresses per lease range
+var DHCPDeclinedAddresses = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dhcp_declined_addresses",
+		Help:      "The number of declined addresses in a DHCP lease range.",
+	},
+	[]string{"range", "rack"},
+)
+
+// DHCPFreeAddresses returns the number of free addresses per lease range
+var DHCPFreeAddresses = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dhcp_free_addresses",
+		Help:      "The number of free addresses in a DHCP lease range.",
+	},
+	[]string{"range", "rack"},
+)
@@ -16,10 +16,17 @@ var ErrConflicted = errors.New("key conflicted")
 // A model should return this when it cannot find a resource by a specified key.
 var ErrNotFound = errors.New("not found")
 
+// ErrNoLeasableAddress is returned when a DHCP lease range is exhausted.
+var ErrNoLeasableAddress = errors.New("no leasable IP address found")
+
 // ErrBadRequest is a special err for models.
 // A model should return this when the request is bad
 var ErrBadRequest = errors.New("bad request")
 
+// ErrForbidden is a special err for models.
+// A model should return this when the requester is not allowed to access a resource.
+var ErrForbidden = errors.New("forbidden")
+
 // ErrEncryptionKeyExists is a special err for models.
 // A model should return this when encryption key exists.
 var ErrEncryptionKeyExists = errors.New("encryption key exists")
@@ -38,15 +45,42 @@ type MachineModel interface {
 	SetState(ctx context.Context, serial string, state MachineState) error
 	PutLabel(ctx context.Context, serial string, label, value string) error
 	DeleteLabel(ctx context.Context, serial string, label string) error
+	PutAnnotation(ctx context.Context, serial string, name, value string) error
+	DeleteAnnotation(ctx context.Context, serial string, name string) error
+	AddMAC(ctx context.Context, serial string, mac string) error
+	RemoveMAC(ctx context.Context, serial string, mac string) error
 	SetRetireDate(ctx context.Context, serial string, date time.Time) error
 	Query(ctx context.Context, query Query) ([]*Machine, error)
 	Delete(ctx context.Context, serial string) error
+
+	// IssueToken issues a new token for the machine to identify itself,
+	// and revokes the previous one.
+	IssueToken(ctx context.Context, serial string) (string, error)
+	// RevokeToken revokes the token of the machine.
+	// It returns ErrNotFound if no token is issued.
+	RevokeToken(ctx context.Context, serial string) error
 }
 
 // IPAMModel is an interface for IPAMConfig.
 type IPAMModel interface {
+	// PutConfig returns ErrConflicted if config would change addresses
+	// of registered machines, or conflict with them, DHCP leases or
+	// reservations as IPAMConfig.Conflicts reports.
 	PutConfig(ctx context.Context, config *IPAMConfig) error
+	// ForcePutConfig stores config even if config would change addresses
+	// of registered machines or conflict.  Use Migrate to rewrite their addresses.
+	ForcePutConfig(ctx context.Context, config *IPAMConfig) error
 	GetConfig() (*IPAMConfig, error)
+
+	// AddressChanges returns changes of addresses of registered machines
+	// if their addresses are generated by config.
+	AddressChanges(ctx context.Context, config *IPAMConfig) ([]*IPAMChange, error)
+	// Conflicts returns reasons why config cannot be applied without force
+	// other than changes of addresses.
+	Conflicts(ctx context.Context, config *IPAMConfig) ([]*IPAMConflict, error)
+	// Migrate rewrites addresses of registered machines with the current
+	// configurations atomically, and returns the changes.
+	Migrate(ctx context.Context) ([]*IPAMChange, error)
 }
 
 // DHCPModel is an interface for DHCPConfig.
@@ -57,6 +91,26 @@ type DHCPModel interface {
 	Renew(ctx context.Context, ciaddr net.IP, mac net.HardwareAddr) error
 	Release(ctx context.Context, ciaddr net.IP, mac net.HardwareAddr) error
 	Decline(ctx context.Context, ciaddr net.IP, mac net.HardwareAddr) error
+
+	// GetLeases returns unexpired leases sorted by IP addresses.
+	GetLeases(ctx context.Context) ([]*DHCPLease, error)
+	// GetLease returns the unexpired lease of ip.
+	// It returns ErrNotFound if ip is not leased.
+	GetLease(ctx context.Context, ip net.IP) (*DHCPLease, error)
+	// DeleteLease releases a lease or clears a declined address.
+	// It returns ErrNotFound if ip is not leased.
+	DeleteLease(ctx context.Context, ip net.IP) error
+
+	// PutReservation adds or updates a static reservation.
+	// It returns ErrConflicted if the address is reserved for or leased to
+	// another MAC address.
+	PutReservation(ctx context.Context, r *DHCPReservation) error
+	// GetReservation returns ErrNotFound if mac has no reservation.
+	GetReservation(ctx context.Context, mac net.HardwareAddr) (*DHCPReservation, error)
+	// GetReservations returns reservations sorted by IP addresses.
+	GetReservations(ctx context.Context) ([]*DHCPReservation, error)
+	// DeleteReservation returns ErrNotFound if mac has no reservation.
+	DeleteReservation(ctx context.Context, mac net.HardwareAddr) error
 }
 
 // ImageModel is an interface to manage boot images.
@@ -68,10 +122,20 @@ type ImageModel interface {
 	Download(ctx context.Context, os, id string, out io.Writer) error
 	Delete(ctx context.Context, os, id string) error
 
+	// Import starts fetching a tar archive of the image from src in background.
+	// It returns ErrConflicted if the image is being imported.
+	Import(ctx context.Context, os, id, src string, csum []byte) (*ImportStatus, error)
+	// GetImport returns the status of the last import of the image.
+	GetImport(ctx context.Context, os, id string) (*ImportStatus, error)
+	// CancelImport cancels the running import of the image.
+	// It returns ErrNotFound if the image is not being imported.
+	CancelImport(ctx context.Context, os, id string) error
+
 	// This is for /api/v1/boot/OS/{kernel,initrd.gz}
 	// Calling f will serve the content to the HTTP client.
+	// sha256 is the checksum of the file, or empty if unknown.
 	ServeFile(ctx context.Context, os, filename string,
-		f func(modtime time.Time, content io.ReadSeeker)) error
+		f func(modtime time.Time, sha256 string, content io.ReadSeeker)) error
 }
 
 // AssetHandler is an interface for AssetModel.Get
@@ -88,6 +152,52 @@ type AssetModel interface {
 	Put(ctx context.Context, name, contentType string, csum []byte, options map[string]string, r io.Reader) (*AssetStatus, error)
 	Get(ctx context.Context, name string, h AssetHandler) error
 	Delete(ctx context.Context, name string) error
+
+	// GetHistory returns the versions of the asset kept for rollback
+	// including the current one, sorted by IDs.
+	GetHistory(ctx context.Context, name string) ([]*Asset, error)
+	// GetVersion is the same as Get except that it serves the version of id.
+	// It returns ErrNotFound if the version is not kept.
+	GetVersion(ctx context.Context, name string, id int, h AssetHandler) error
+	// Rollback makes the version of id the current one.
+	// It returns ErrNotFound if the version is not kept.
+	Rollback(ctx context.Context, name string, id int) error
+
+	// These are for resumable uploads.
+	// Methods other than CreateUpload return ErrNotFound if the upload does not exist.
+
+	// CreateUpload starts a resumable upload.  ID and Date are assigned by the model.
+	CreateUpload(ctx context.Context, upload *AssetUpload) (*AssetUpload, error)
+	// GetUploads returns uploads of the asset in progress.
+	GetUploads(ctx context.Context, name string) ([]*AssetUpload, error)
+	// GetUpload returns the upload with its parts sorted by their numbers.
+	GetUpload(ctx context.Context, name, id string) (*AssetUpload, error)
+	// PutUploadPart stores a part of the upload.  The part of the same number is replaced.
+	// It returns ErrChecksumMismatch if the data does not match csum.
+	PutUploadPart(ctx context.Context, name, id string, number int, csum []byte, r io.Reader) (*AssetUploadPart, error)
+	// CommitUpload stores the parts as a new version of the asset, and removes the upload.
+	// It returns ErrIncompleteUpload or ErrChecksumMismatch if the parts are not valid.
+	CommitUpload(ctx context.Context, name, id string) (*AssetStatus, error)
+	// AbortUpload removes the upload.
+	AbortUpload(ctx context.Context, name, id string) error
+
+	// GC returns a report of assets that no ignition template references.
+	// If dryRun is false, the reported assets are removed.
+	GC(ctx context.Context, dryRun bool) (*AssetGCReport, error)
+
+	// Import starts fetching the asset from src in background.
+	// It returns ErrConflicted if the asset is being imported.
+	Import(ctx context.Context, name, src string, csum []byte, options map[string]string) (*ImportStatus, error)
+	// GetImport returns the status of the last import of the asset.
+	GetImport(ctx context.Context, name string) (*ImportStatus, error)
+	// CancelImport cancels the running import of the asset.
+	// It returns ErrNotFound if the asset is not being imported.
+	CancelImport(ctx context.Context, name string) error
+
+	// CheckAccess returns ErrForbidden if downloads of the version id of
+	// the asset are restricted and req is not allowed to download it.
+	// id 0 means the current version.  Denials are recorded in audit logs.
+	CheckAccess(ctx context.Context, name string, id int, req *AssetRequester) error
 }
 
 // IgnitionModel is an interface for ignition template.
@@ -98,6 +208,16 @@ type IgnitionModel interface {
 	DeleteTemplate(ctx context.Context, role string, id string) error
 }
 
+// InventoryModel is an interface for hardware inventories of machines.
+type InventoryModel interface {
+	// Put stores inv as the latest inventory of the machine, and returns the latest one.
+	// If inv has no hardware changes from the latest one, this does not store inv.
+	Put(ctx context.Context, serial string, inv *Inventory) (*Inventory, error)
+	Get(ctx context.Context, serial string) (*Inventory, error)
+	GetHistory(ctx context.Context, serial string) ([]*Inventory, error)
+	GetAll(ctx context.Context) ([]*Inventory, error)
+}
+
 // LogModel is an interface for audit logs.
 type LogModel interface {
 	Dump(ctx context.Context, since, until time.Time, w io.Writer) error
@@ -109,6 +229,11 @@ type KernelParamsModel interface {
 	GetParams(ctx context.Context, os string) (string, error)
 }
 
+// ReplicationModel is an interface for replication status of assets and images.
+type ReplicationModel interface {
+	GetStatus(ctx context.Context) (*ReplicationStatus, error)
+}
+
 // HealthModel is an interface for etcd health status
 type HealthModel interface {
 	GetHealth(ctx context.Context) error
@@ -135,6 +260,21 @@ type Runner interface {
 	Run(ctx context.Context, ch chan<- struct{}) error
 }
 
+// SecretModel is an interface for secrets injected into ignition configurations.
+type SecretModel interface {
+	// Put stores the value of a secret encrypted.
+	// It returns ErrNoSecretKey if the server has no key to encrypt secrets.
+	Put(ctx context.Context, scope SecretScope, target, name string, value []byte) error
+	// List returns the meta data of all secrets.
+	List(ctx context.Context) ([]*SecretInfo, error)
+	// Delete removes a secret.
+	Delete(ctx context.Context, scope SecretScope, target, name string) error
+	// Resolve returns the value of the named secret for the machine.
+	// The secret for the serial of the machine takes precedence over the
+	// one for its role, which takes precedence over the global one.
+	Resolve(ctx context.Context, m *Machine, name string) ([]byte, error)
+}
+
 // Model is a struct that consists of sub-models.
 type Model struct {
 	Runner
@@ -145,8 +285,11 @@ type Model struct {
 	Image        ImageModel
 	Asset        AssetModel
 	Ignition     IgnitionModel
+	Inventory    InventoryModel
 	Log          LogModel
 	KernelParams KernelParamsModel
+	Replication  ReplicationModel
 	Health       HealthModel
 	Schema       SchemaModel
+	Secret       SecretModel
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"time"
 
@@ -23,6 +25,14 @@ func (d *driver) getAssetDir() AssetDir {
 	}
 }
 
+func keyAssetHistoryPrefix(name string) string {
+	return KeyAssetHistory + name + "/"
+}
+
+func keyAssetHistory(name string, id int) string {
+	return fmt.Sprintf("%s%020d", keyAssetHistoryPrefix(name), id)
+}
+
 func (d *driver) assetNewID(ctx context.Context) (int, error) {
 RETRY:
 	resp, err := d.client.Get(ctx, KeyAssetsID)
@@ -130,6 +140,13 @@ func (d *driver) assetGetInfo(ctx context.Context, name string) (*sabakan.Asset,
 
 func (d *driver) assetPut(ctx context.Context, name, contentType string,
 	csum []byte, options map[string]string, r io.Reader) (*sabakan.AssetStatus, error) {
+	return d.assetPutWithUpload(ctx, name, contentType, csum, options, r, "")
+}
+
+// assetPutWithUpload stores an asset.  upload is the ID of the resumable
+// upload being committed, which is excluded from the quota.
+func (d *driver) assetPutWithUpload(ctx context.Context, name, contentType string,
+	csum []byte, options map[string]string, r io.Reader, upload string) (*sabakan.AssetStatus, error) {
 	id, err := d.assetNewID(ctx)
 	if err != nil {
 		return nil, err
@@ -146,6 +163,20 @@ func (d *driver) assetPut(ctx context.Context, name, contentType string,
 		return nil, err
 	}
 
+	if d.store != nil {
+		err = d.storeAsset(ctx, id)
+		if err != nil {
+			dir.Remove(id)
+			return nil, err
+		}
+	}
+	removeNew := func() {
+		dir.Remove(id)
+		if d.store != nil {
+			d.removeAssetObjects(ctx, name, []int{id})
+		}
+	}
+
 	hsumString := hex.EncodeToString(hsum)
 	a := &sabakan.Asset{
 		Name:        name,
@@ -163,39 +194,109 @@ func (d *driver) assetPut(ctx context.Context, name, contentType string,
 	}
 
 	key := KeyAssets + name
-	resp, err := d.client.Get(ctx, key, clientv3.WithKeysOnly())
+
+RETRY:
+	resp, err := d.client.Get(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
-	ifop := clientv3util.KeyMissing(key)
+	cmps := []clientv3.Cmp{clientv3util.KeyMissing(key)}
 	retStatus := http.StatusCreated
+	ops := []clientv3.Op{clientv3.OpPut(keyAssetHistory(name, id), string(data))}
+	var prevRev int64
+	var evicted []int
 
 	if resp.Count != 0 {
-		rev := resp.Kvs[0].ModRevision
-		ifop = clientv3.Compare(clientv3.ModRevision(key), "=", rev)
+		prevRev = resp.Kvs[0].ModRevision
+		cmps[0] = clientv3.Compare(clientv3.ModRevision(key), "=", prevRev)
 		retStatus = http.StatusOK
+
+		prev, err := decodeAsset(resp.Kvs[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		a.History, evicted = assetHistoryAfterPut(prev)
+		for _, old := range evicted {
+			ops = append(ops, clientv3.OpDelete(keyAssetHistory(name, old)))
+		}
+
+		// assets stored before history was introduced have no history entry.
+		prev.History = nil
+		prevData, err := json.Marshal(prev)
+		if err != nil {
+			return nil, err
+		}
+		prevKey := keyAssetHistory(name, prev.ID)
+		ops = append(ops, clientv3.OpTxn(
+			[]clientv3.Cmp{clientv3util.KeyMissing(prevKey)},
+			[]clientv3.Op{clientv3.OpPut(prevKey, string(prevData))},
+			nil))
+	} else {
+		a.History = nil
+	}
+
+	if d.assetQuota > 0 {
+		// the quota is checked against the snapshot at the revision of resp,
+		// and assets must not be changed until the transaction.
+		rev := resp.Header.Revision
+		err = d.checkAssetQuota(ctx, rev, size, evicted, upload)
+		if err != nil {
+			removeNew()
+			return nil, err
+		}
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(KeyAssets).WithPrefix(), "<", rev+1))
 	}
 
+	data, err = json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	ops = append(ops, clientv3.OpPut(key, string(data)))
+
 	tresp, err := d.client.Txn(ctx).
-		If(ifop).Then(clientv3.OpPut(key, string(data))).Commit()
+		If(cmps...).
+		Then(ops...).
+		Else(clientv3.OpGet(key)).
+		Commit()
 	if err != nil {
 		return nil, err
 	}
 	if !tresp.Succeeded {
-		dir.Remove(id)
+		// retry if only other assets have been changed
+		kvs := tresp.Responses[0].GetResponseRange().Kvs
+		if (len(kvs) == 0 && prevRev == 0) || (len(kvs) != 0 && kvs[0].ModRevision == prevRev) {
+			goto RETRY
+		}
+		removeNew()
 		return nil, sabakan.ErrConflicted
 	}
 
 	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditAssets,
 		name, "put", "new checksum: "+hsumString)
 
+	if d.store != nil {
+		d.removeAssetObjects(ctx, name, evicted)
+		d.trimAssetCache(id)
+	}
+
 	return &sabakan.AssetStatus{
 		Status: retStatus,
 		ID:     id,
 	}, nil
 }
 
+// assetHistoryAfterPut returns the history of an asset replacing prev,
+// and IDs of the versions to be evicted.
+func assetHistoryAfterPut(prev *sabakan.Asset) (history, evicted []int) {
+	history = append([]int{prev.ID}, prev.History...)
+	if len(history) > MaxAssetVersions-1 {
+		evicted = history[MaxAssetVersions-1:]
+		history = history[:MaxAssetVersions-1]
+	}
+	return history, evicted
+}
+
 func (d *driver) assetGet(ctx context.Context, name string, h sabakan.AssetHandler) error {
 	key := KeyAssets + name
 	resp, err := d.client.Get(ctx, key)
@@ -213,8 +314,26 @@ func (d *driver) assetGet(ctx context.Context, name string, h sabakan.AssetHandl
 		return err
 	}
 
+	return d.serveAsset(ctx, a, h, "")
+}
+
+// serveAsset serves the local copy of an asset if exists.
+// Otherwise, the asset is served from the object storage or a server
+// in a.URLs with query.
+func (d *driver) serveAsset(ctx context.Context, a *sabakan.Asset, h sabakan.AssetHandler, query string) error {
 	dir := d.getAssetDir()
 
+	if !dir.Exists(a.ID) && d.store != nil {
+		if d.storeRedirect {
+			h.Redirect(d.store.PresignGet(assetObjectKey(a.ID), presignExpiry))
+			return nil
+		}
+		err := d.cacheAsset(ctx, a)
+		if err != nil {
+			return err
+		}
+	}
+
 	if dir.Exists(a.ID) {
 		g, err := os.Open(dir.Path(a.ID))
 		if err != nil {
@@ -222,31 +341,176 @@ func (d *driver) assetGet(ctx context.Context, name string, h sabakan.AssetHandl
 		}
 		defer g.Close()
 
+		if d.store != nil {
+			dir.Touch(a.ID)
+		}
 		h.ServeContent(a, g)
 		return nil
 	}
 
-	h.Redirect(a.URLs[rand.Intn(len(a.URLs))])
+	h.Redirect(a.URLs[rand.Intn(len(a.URLs))] + query)
 	return nil
 }
 
 func (d *driver) assetDelete(ctx context.Context, name string) error {
-	key := KeyAssets + name
+RETRY:
+	a, rev, err := d.assetGetInfoWithRev(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	ok, err := d.assetDeleteWithRev(ctx, a, rev, "delete")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		goto RETRY
+	}
+	return nil
+}
+
+// assetDeleteWithRev deletes an asset unless it has been modified since rev.
+// action is recorded in the audit log.
+func (d *driver) assetDeleteWithRev(ctx context.Context, a *sabakan.Asset, rev int64, action string) (bool, error) {
+	key := KeyAssets + a.Name
 	resp, err := d.client.Txn(ctx).
-		If(clientv3util.KeyExists(key)).
-		Then(clientv3.OpDelete(key)).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(
+			clientv3.OpDelete(key),
+			clientv3.OpDelete(keyAssetHistoryPrefix(a.Name), clientv3.WithPrefix()),
+		).
 		Commit()
+	if err != nil {
+		return false, err
+	}
+	if !resp.Succeeded {
+		return false, nil
+	}
+
+	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditAssets, a.Name, action, "")
+
+	if d.store != nil {
+		d.removeAssetObjects(ctx, a.Name, append([]int{a.ID}, a.History...))
+	}
+	return true, nil
+}
 
+func (d *driver) assetGetHistory(ctx context.Context, name string) ([]*sabakan.Asset, error) {
+	cresp, err := d.client.Get(ctx, KeyAssets+name)
+	if err != nil {
+		return nil, err
+	}
+	if cresp.Count == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+	cur, err := decodeAsset(cresp.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Get(ctx, keyAssetHistoryPrefix(name),
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		clientv3.WithRev(cresp.Header.Revision))
+	if err != nil {
+		return nil, err
+	}
+
+	dir := d.getAssetDir()
+	history := make([]*sabakan.Asset, 0, len(resp.Kvs)+1)
+	for _, kv := range resp.Kvs {
+		a, err := decodeAsset(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		a.Exists = dir.Exists(a.ID)
+		history = append(history, a)
+	}
+
+	// the current version has no history entry if it was stored
+	// before history was introduced.
+	if !slices.ContainsFunc(history, func(a *sabakan.Asset) bool { return a.ID == cur.ID }) {
+		cur.History = nil
+		cur.Exists = dir.Exists(cur.ID)
+		history = append(history, cur)
+		slices.SortFunc(history, func(a, b *sabakan.Asset) int { return a.ID - b.ID })
+	}
+	return history, nil
+}
+
+func (d *driver) assetGetVersion(ctx context.Context, name string, id int, h sabakan.AssetHandler) error {
+	resp, err := d.client.Get(ctx, keyAssetHistory(name, id))
+	if err != nil {
+		return err
+	}
+	if resp.Count == 0 {
+		// the current version may have no history entry
+		cur, err := d.assetGetInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		if cur.ID != id {
+			return sabakan.ErrNotFound
+		}
+		return d.serveAsset(ctx, cur, h, "?id="+strconv.Itoa(id))
+	}
+
+	a, err := decodeAsset(resp.Kvs[0].Value)
 	if err != nil {
 		return err
 	}
 
-	if !resp.Succeeded {
+	return d.serveAsset(ctx, a, h, "?id="+strconv.Itoa(a.ID))
+}
+
+func (d *driver) assetRollback(ctx context.Context, name string, id int) error {
+	key := KeyAssets + name
+
+RETRY:
+	cur, rev, err := d.assetGetInfoWithRev(ctx, name)
+	if err != nil {
+		return err
+	}
+	if cur.ID == id {
+		return nil
+	}
+
+	resp, err := d.client.Get(ctx, keyAssetHistory(name, id))
+	if err != nil {
+		return err
+	}
+	if resp.Count == 0 || !slices.Contains(cur.History, id) {
 		return sabakan.ErrNotFound
 	}
+	a, err := decodeAsset(resp.Kvs[0].Value)
+	if err != nil {
+		return err
+	}
 
-	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditAssets, name, "delete", "")
+	a.History = []int{cur.ID}
+	for _, old := range cur.History {
+		if old != id {
+			a.History = append(a.History, old)
+		}
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
 
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditAssets,
+		name, "rollback", fmt.Sprintf("id: %d, checksum: %s", a.ID, a.Sha256))
 	return nil
 }
 
@@ -278,3 +542,19 @@ func (d assetDriver) Get(ctx context.Context, name string, h sabakan.AssetHandle
 func (d assetDriver) Delete(ctx context.Context, name string) error {
 	return d.assetDelete(ctx, name)
 }
+
+func (d assetDriver) GetHistory(ctx context.Context, name string) ([]*sabakan.Asset, error) {
+	return d.assetGetHistory(ctx, name)
+}
+
+func (d assetDriver) GetVersion(ctx context.Context, name string, id int, h sabakan.AssetHandler) error {
+	return d.assetGetVersion(ctx, name, id, h)
+}
+
+func (d assetDriver) Rollback(ctx context.Context, name string, id int) error {
+	return d.assetRollback(ctx, name, id)
+}
+
+func (d assetDriver) GC(ctx context.Context, dryRun bool) (*sabakan.AssetGCReport, error) {
+	return d.assetGC(ctx, dryRun)
+}
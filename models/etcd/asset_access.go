@@ -0,0 +1,171 @@
+package etcd
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*
+Downloads of restricted assets are allowed only to:
+
+- trusted requesters such as administrators,
+- machines that match the asset options, identified by their tokens or
+  source addresses, and
+- sabakan servers reporting their replication status, to pull assets.
+*/
+
+// peerAddrs is a cached result of name resolution of a sabakan server.
+type peerAddrs struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// identifyRequester returns the machine that sent the request, or nil if unknown.
+// It returns ErrForbidden if the token is not valid.
+func (d *driver) identifyRequester(ctx context.Context, req *sabakan.AssetRequester) (*sabakan.Machine, error) {
+	if len(req.Token) > 0 {
+		return d.machineVerifyToken(ctx, req.Token)
+	}
+	if req.IP == nil {
+		return nil, nil
+	}
+
+	q := sabakan.Query{"ipv4": req.IP.String()}
+	if req.IP.To4() == nil {
+		q = sabakan.Query{"ipv6": req.IP.String()}
+	}
+	machines, err := d.machineQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(machines) == 0 {
+		return nil, nil
+	}
+	return machines[0], nil
+}
+
+// lookupPeer returns addresses of a sabakan server.
+// Results are cached for peerLookupInterval to avoid resolving names
+// for every download.
+func (d *driver) lookupPeer(ctx context.Context, host string) []net.IP {
+	d.peerMu.Lock()
+	defer d.peerMu.Unlock()
+
+	now := time.Now()
+	if c, ok := d.peerAddrs[host]; ok && now.Before(c.expires) {
+		return c.ips
+	}
+
+	// failures are cached as well to keep unresolvable peers from
+	// delaying every request.
+	var ips []net.IP
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		log.Warn("asset: failed to resolve a peer", map[string]interface{}{
+			log.FnError: err,
+			"host":      host,
+		})
+	}
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+
+	if d.peerAddrs == nil {
+		d.peerAddrs = make(map[string]peerAddrs)
+	}
+	d.peerAddrs[host] = peerAddrs{ips: ips, expires: now.Add(peerLookupInterval)}
+	return ips
+}
+
+// isPeer returns true if ip is an address of a sabakan server.
+func (d *driver) isPeer(ctx context.Context, ip net.IP) (bool, error) {
+	if ip == nil {
+		return false, nil
+	}
+
+	resp, err := d.client.Get(ctx, KeyReplicas, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return false, err
+	}
+	for _, kv := range resp.Kvs {
+		host := string(kv.Key[len(KeyReplicas):])
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if hip := net.ParseIP(host); hip != nil {
+			if hip.Equal(ip) {
+				return true, nil
+			}
+			continue
+		}
+
+		for _, pip := range d.lookupPeer(ctx, host) {
+			if pip.Equal(ip) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// assetGetAccessTarget returns the version id of the asset, or the current
+// version if id is 0.
+func (d *driver) assetGetAccessTarget(ctx context.Context, name string, id int) (*sabakan.Asset, error) {
+	if id == 0 {
+		a, _, err := d.assetGetInfoWithRev(ctx, name)
+		return a, err
+	}
+
+	resp, err := d.client.Get(ctx, keyAssetHistory(name, id))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+	return decodeAsset(resp.Kvs[0].Value)
+}
+
+func (d *driver) assetCheckAccess(ctx context.Context, name string, id int, req *sabakan.AssetRequester) error {
+	a, err := d.assetGetAccessTarget(ctx, name, id)
+	if err != nil {
+		return err
+	}
+	if !a.IsRestricted() || req.Trusted {
+		return nil
+	}
+
+	var detail string
+	m, err := d.identifyRequester(ctx, req)
+	switch {
+	case err == sabakan.ErrForbidden:
+		detail = "invalid token"
+	case err != nil:
+		return err
+	case m == nil:
+		peer, err := d.isPeer(ctx, req.IP)
+		if err != nil {
+			return err
+		}
+		if peer {
+			return nil
+		}
+		detail = "unknown requester"
+	case a.AllowsMachine(m):
+		return nil
+	default:
+		detail = "serial=" + m.Spec.Serial
+	}
+
+	d.addEventLog(ctx, sabakan.AuditAssets, name, "deny", detail)
+	return sabakan.ErrForbidden
+}
+
+func (d assetDriver) CheckAccess(ctx context.Context, name string, id int, req *sabakan.AssetRequester) error {
+	return d.assetCheckAccess(ctx, name, id, req)
+}
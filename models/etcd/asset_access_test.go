@@ -0,0 +1,221 @@
+package etcd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testAssetDenials(t *testing.T, d *driver) []*sabakan.AuditLog {
+	buf := new(bytes.Buffer)
+	err := d.logDump(context.Background(), time.Time{}, time.Time{}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []*sabakan.AuditLog
+	s := bufio.NewScanner(buf)
+	for s.Scan() {
+		a := new(sabakan.AuditLog)
+		err := json.Unmarshal(s.Bytes(), a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category == sabakan.AuditAssets && a.Action == "deny" {
+			logs = append(logs, a)
+		}
+	}
+	return logs
+}
+
+func testAssetCheckAccess(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	d.dataDir = t.TempDir()
+	machines, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 12345678 and 12345679 match, 123456789 does not
+	_, err = d.assetPut(ctx, "foo", "text/plain", nil, map[string]string{
+		sabakan.AssetOptionAllowRoles:  "worker",
+		sabakan.AssetOptionAllowLabels: "product=R630",
+	}, strings.NewReader("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPut(ctx, "bar", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipOf := func(m *sabakan.Machine) net.IP {
+		return net.ParseIP(m.Spec.IPv4[0])
+	}
+
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: ipOf(machines[0])})
+	if err != nil {
+		t.Error("matching machine should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: ipOf(machines[2])})
+	if err != sabakan.ErrForbidden {
+		t.Error("unmatched machine should be denied:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1")})
+	if err != sabakan.ErrForbidden {
+		t.Error("unknown requester should be denied:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1"), Trusted: true})
+	if err != nil {
+		t.Error("trusted requester should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "bar", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1")})
+	if err != nil {
+		t.Error("unrestricted asset should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "baz", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1")})
+	if err != sabakan.ErrNotFound {
+		t.Error("missing asset should not be found:", err)
+	}
+
+	// tokens take precedence over source addresses
+	token, err := d.machineIssueToken(ctx, "12345679")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1"), Token: token})
+	if err != nil {
+		t.Error("machine identified by token should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: ipOf(machines[0]), Token: token + "0"})
+	if err != sabakan.ErrForbidden {
+		t.Error("invalid token should be denied:", err)
+	}
+	err = d.machineRevokeToken(ctx, "12345679")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{Token: token})
+	if err != sabakan.ErrForbidden {
+		t.Error("revoked token should be denied:", err)
+	}
+	err = d.machineRevokeToken(ctx, "12345679")
+	if err != sabakan.ErrNotFound {
+		t.Error("revoked token should not be found:", err)
+	}
+	_, err = d.machineIssueToken(ctx, "00000000")
+	if err != sabakan.ErrNotFound {
+		t.Error("token should not be issued for missing machine:", err)
+	}
+
+	// sabakan servers pull assets from each other
+	_, err = d.client.Put(ctx, KeyReplicas+"192.0.2.2:10080", "{}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", 0, &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.2")})
+	if err != nil {
+		t.Error("peer server should be allowed:", err)
+	}
+
+	denials := testAssetDenials(t, d)
+	expected := []string{"serial=123456789", "unknown requester", "invalid token", "invalid token"}
+	if len(denials) != len(expected) {
+		t.Fatal("wrong number of denials:", len(denials))
+	}
+	for i, a := range denials {
+		if a.Instance != "foo" || a.Detail != expected[i] {
+			t.Errorf("wrong denial log #%d: %+v", i, a)
+		}
+	}
+}
+
+func testAssetCheckAccessVersion(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	d.dataDir = t.TempDir()
+	ctx := context.Background()
+
+	status, err := d.assetPut(ctx, "foo", "text/plain", nil, map[string]string{
+		sabakan.AssetOptionAllowRoles: "boot",
+	}, strings.NewReader("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted := status.ID
+	status, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("public"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &sabakan.AssetRequester{IP: net.ParseIP("192.0.2.1")}
+	err = d.assetCheckAccess(ctx, "foo", 0, req)
+	if err != nil {
+		t.Error("current version should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", status.ID, req)
+	if err != nil {
+		t.Error("unrestricted version should be allowed:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", restricted, req)
+	if err != sabakan.ErrForbidden {
+		t.Error("restricted version should be denied:", err)
+	}
+	err = d.assetCheckAccess(ctx, "foo", status.ID+1, req)
+	if err != sabakan.ErrNotFound {
+		t.Error("missing version should not be found:", err)
+	}
+}
+
+func testAssetIsPeer(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	ctx := context.Background()
+
+	_, err := d.client.Put(ctx, KeyReplicas+"localhost:10080", "{}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		peer, err := d.isPeer(ctx, net.ParseIP("127.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !peer {
+			t.Error("localhost should be a peer")
+		}
+	}
+
+	// resolved addresses are cached
+	d.peerMu.Lock()
+	d.peerAddrs["localhost"] = peerAddrs{
+		ips:     []net.IP{net.ParseIP("192.0.2.3")},
+		expires: time.Now().Add(time.Hour),
+	}
+	d.peerMu.Unlock()
+	peer, err := d.isPeer(ctx, net.ParseIP("192.0.2.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !peer {
+		t.Error("cached address should be used")
+	}
+}
+
+func TestAssetAccess(t *testing.T) {
+	t.Run("CheckAccess", testAssetCheckAccess)
+	t.Run("CheckAccessVersion", testAssetCheckAccessVersion)
+	t.Run("IsPeer", testAssetIsPeer)
+}
@@ -5,11 +5,15 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
 )
 
 // AssetDir is a struct to manage the assets directory.
@@ -66,7 +70,7 @@ func (d AssetDir) Save(id int, r io.Reader, csum []byte) ([]byte, error) {
 	hsum := h.Sum(nil)
 	if csum != nil && !bytes.Equal(csum, hsum) {
 		os.Remove(f.Name())
-		return nil, fmt.Errorf("checksum mismatch for id %d", id)
+		return nil, fmt.Errorf("%w for id %d", sabakan.ErrChecksumMismatch, id)
 	}
 
 	err = f.Sync()
@@ -83,6 +87,60 @@ func (d AssetDir) Save(id int, r io.Reader, csum []byte) ([]byte, error) {
 	return hsum, nil
 }
 
+// Touch updates the access time of an asset to keep it in the cache.
+func (d AssetDir) Touch(id int) error {
+	now := time.Now()
+	return os.Chtimes(d.Path(id), now, now)
+}
+
+// Trim removes least recently used assets until the total size of assets
+// becomes equal to or less than limit.  The asset of keep is not removed.
+func (d AssetDir) Trim(limit int64, keep int) error {
+	fil, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var files []fs.FileInfo
+	for _, fi := range fil {
+		if !fi.Type().IsRegular() {
+			continue
+		}
+		if _, err := strconv.Atoi(fi.Name()); err != nil {
+			continue
+		}
+		info, err := fi.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		files = append(files, info)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if total <= limit {
+			break
+		}
+		if fi.Name() == strconv.Itoa(keep) {
+			continue
+		}
+		log.Info("removing cached asset", map[string]interface{}{
+			"name": fi.Name(),
+		})
+		err := os.Remove(filepath.Join(d.Dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+		total -= fi.Size()
+	}
+
+	return nil
+}
+
 // GC removes garbage, that is, files whose names are not in ids.
 func (d AssetDir) GC(ids map[int]bool) error {
 	fil, err := os.ReadDir(d.Dir)
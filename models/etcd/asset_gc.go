@@ -0,0 +1,181 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// checkAssetQuota returns ErrQuotaExceeded if the total size of assets
+// exceeds the quota when a new version of size bytes is added and
+// the versions in evicted are removed.
+//
+// All versions kept in the history and pending uploads except upload
+// are counted.  Assets are read at rev, or the latest revision if rev is 0.
+func (d *driver) checkAssetQuota(ctx context.Context, rev int64, size int64, evicted []int, upload string) error {
+	if d.assetQuota == 0 {
+		return nil
+	}
+
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if rev != 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	resp, err := d.client.Txn(ctx).
+		Then(
+			clientv3.OpGet(KeyAssets, opts...),
+			clientv3.OpGet(KeyAssetHistory, opts...),
+		).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	// versions are counted once because the current one is also in the history
+	sizes := make(map[int]int64)
+	for _, r := range resp.Responses {
+		for _, kv := range r.GetResponseRange().Kvs {
+			a, err := decodeAsset(kv.Value)
+			if err != nil {
+				return err
+			}
+			sizes[a.ID] = a.Size
+		}
+	}
+	for _, id := range evicted {
+		delete(sizes, id)
+	}
+
+	pending, err := d.pendingUploadSize(upload)
+	if err != nil {
+		return err
+	}
+
+	total := size + pending
+	for _, s := range sizes {
+		total += s
+	}
+	if total > d.assetQuota {
+		return fmt.Errorf("%w: %d bytes in total exceeds %d bytes", sabakan.ErrQuotaExceeded, total, d.assetQuota)
+	}
+	return nil
+}
+
+// removeExpiredAssets removes assets whose TTL has passed at now.
+func (d *driver) removeExpiredAssets(ctx context.Context, now time.Time) error {
+	resp, err := d.client.Get(ctx, KeyAssets, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range resp.Kvs {
+		a, err := decodeAsset(kv.Value)
+		if err != nil {
+			return err
+		}
+		expires, ok := a.Expires()
+		if !ok || now.Before(expires) {
+			continue
+		}
+
+		// another server may have removed or updated the asset
+		removed, err := d.assetDeleteWithRev(ctx, a, kv.ModRevision, "expire")
+		if err != nil {
+			return err
+		}
+		if removed {
+			log.Info("asset: removed an expired asset", map[string]interface{}{
+				"name":    a.Name,
+				"id":      a.ID,
+				"expires": expires,
+			})
+		}
+	}
+	return nil
+}
+
+func (d *driver) startAssetExpirer(ctx context.Context) error {
+	ticker := time.NewTicker(assetExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		err := d.removeExpiredAssets(ctx, time.Now())
+		if err != nil {
+			log.Error("asset: failed to remove expired assets", map[string]interface{}{
+				log.FnError: err,
+			})
+		}
+	}
+}
+
+// assetReferences returns names of assets referenced by ignition templates.
+func (d *driver) assetReferences(ctx context.Context) (map[string]bool, error) {
+	resp, err := d.client.Get(ctx, KeyIgnitions, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]bool)
+	for _, kv := range resp.Kvs {
+		tmpl := new(sabakan.IgnitionTemplate)
+		err = json.Unmarshal(kv.Value, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		names, err := tmpl.ReferencedAssets()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kv.Key, err)
+		}
+		for _, name := range names {
+			refs[name] = true
+		}
+	}
+	return refs, nil
+}
+
+func (d *driver) assetGC(ctx context.Context, dryRun bool) (*sabakan.AssetGCReport, error) {
+	refs, err := d.assetReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Get(ctx, KeyAssets, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	assets := make([]*sabakan.Asset, len(resp.Kvs))
+	revs := make(map[string]int64)
+	for i, kv := range resp.Kvs {
+		assets[i], err = decodeAsset(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		revs[assets[i].Name] = kv.ModRevision
+	}
+
+	report := sabakan.NewAssetGCReport(assets, refs)
+	if dryRun {
+		return report, nil
+	}
+
+	for _, a := range report.Unreferenced {
+		// assets updated after the report was made are kept
+		_, err := d.assetDeleteWithRev(ctx, a, revs[a.Name], "gc")
+		if err != nil {
+			return nil, err
+		}
+	}
+	report.Removed = true
+	return report, nil
+}
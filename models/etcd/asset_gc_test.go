@@ -0,0 +1,218 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testNewAssetDriver(t *testing.T) *driver {
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempdir) })
+	d.dataDir = tempdir
+	return d
+}
+
+func testAssetQuota(t *testing.T) {
+	t.Parallel()
+
+	d := testNewAssetDriver(t)
+	d.assetQuota = 10
+	ctx := context.Background()
+
+	_, err := d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("012345"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPut(ctx, "bar", "text/plain", nil, nil, strings.NewReader("012345"))
+	if !errors.Is(err, sabakan.ErrQuotaExceeded) {
+		t.Error("quota should be exceeded:", err)
+	}
+	_, err = d.assetGetInfo(ctx, "bar")
+	if err != sabakan.ErrNotFound {
+		t.Error("rejected asset should not be stored:", err)
+	}
+
+	// previous versions kept in the history are counted
+	_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("0123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("0"))
+	if !errors.Is(err, sabakan.ErrQuotaExceeded) {
+		t.Error("quota should be exceeded:", err)
+	}
+
+	_, err = d.assetCreateUpload(ctx, &sabakan.AssetUpload{
+		Name:        "bar",
+		ContentType: "text/plain",
+		Size:        1,
+		Sha256:      "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b",
+	})
+	if !errors.Is(err, sabakan.ErrQuotaExceeded) {
+		t.Error("quota should be exceeded:", err)
+	}
+
+}
+
+func testAssetQuotaEviction(t *testing.T) {
+	t.Parallel()
+
+	d := testNewAssetDriver(t)
+	d.assetQuota = MaxAssetVersions
+	ctx := context.Background()
+
+	// evicted versions are not counted
+	for i := 0; i < MaxAssetVersions+1; i++ {
+		_, err := d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("0"))
+		if err != nil {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func testAssetQuotaUploads(t *testing.T) {
+	t.Parallel()
+
+	d := testNewAssetDriver(t)
+	d.assetQuota = 10
+	ctx := context.Background()
+
+	// pending uploads are counted except the one being committed
+	u, err := d.assetCreateUpload(ctx, &sabakan.AssetUpload{
+		Name:        "bar",
+		ContentType: "text/plain",
+		Size:        6,
+		Sha256:      "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("012345"))
+	if !errors.Is(err, sabakan.ErrQuotaExceeded) {
+		t.Error("quota should be exceeded:", err)
+	}
+	_, err = d.assetPutWithUpload(ctx, "bar", "text/plain", nil, nil, strings.NewReader("012345"), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAssetExpire(t *testing.T) {
+	t.Parallel()
+
+	d := testNewAssetDriver(t)
+	ctx := context.Background()
+
+	_, err := d.assetPut(ctx, "foo", "text/plain", nil, map[string]string{"ttl": "1h"}, strings.NewReader("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPut(ctx, "bar", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.removeExpiredAssets(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Error("asset should not expire yet:", err)
+	}
+
+	err = d.removeExpiredAssets(ctx, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetGetInfo(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("expired asset should be removed:", err)
+	}
+	_, err = d.assetGetInfo(ctx, "bar")
+	if err != nil {
+		t.Error("asset without ttl should be kept:", err)
+	}
+}
+
+func testAssetGC(t *testing.T) {
+	t.Parallel()
+
+	d := testNewAssetDriver(t)
+	ctx := context.Background()
+
+	tmpl := &sabakan.IgnitionTemplate{
+		Version: sabakan.Ignition2_3,
+		Template: json.RawMessage(`{
+  "storage": {
+    "files": [
+      {
+        "path": "/opt/bin/foo",
+        "contents": {"source": "{{ MyURL }}/api/v1/assets/foo"}
+      }
+    ]
+  }
+}`),
+	}
+	err := d.PutTemplate(ctx, "cs", "1.0.0", tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar", "baz"} {
+		_, err = d.assetPut(ctx, name, "text/plain", nil, nil, strings.NewReader(name+"!"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := d.assetGC(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unreferenced) != 2 || report.Unreferenced[0].Name != "bar" ||
+		report.Unreferenced[1].Name != "baz" || report.Size != 8 || report.Removed {
+		t.Error("wrong report:", report)
+	}
+	names, err := d.assetGetIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Error("dry-run should not remove assets:", names)
+	}
+
+	report, err = d.assetGC(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unreferenced) != 2 || !report.Removed {
+		t.Error("wrong report:", report)
+	}
+	names, err = d.assetGetIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "foo" {
+		t.Error("unreferenced assets should be removed:", names)
+	}
+}
+
+func TestAssetGC(t *testing.T) {
+	t.Run("Quota", testAssetQuota)
+	t.Run("QuotaEviction", testAssetQuotaEviction)
+	t.Run("QuotaUploads", testAssetQuotaUploads)
+	t.Run("Expire", testAssetExpire)
+	t.Run("GC", testAssetGC)
+}
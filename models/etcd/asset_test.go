@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func testAssetGetIndex(t *testing.T) {
@@ -470,6 +472,219 @@ func testAssetDelete(t *testing.T) {
 	}
 }
 
+func testAssetHistory(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dataDir = tempdir
+	defer os.RemoveAll(tempdir)
+	ctx := context.Background()
+
+	_, err = d.assetGetHistory(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+
+	for i := 1; i <= MaxAssetVersions+2; i++ {
+		_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader(fmt.Sprintf("v%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset, err := d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != 7 || !reflect.DeepEqual(asset.History, []int{6, 5, 4, 3}) {
+		t.Error("wrong history:", asset.ID, asset.History)
+	}
+
+	history, err := d.assetGetHistory(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, a := range history {
+		ids = append(ids, a.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{3, 4, 5, 6, 7}) {
+		t.Error("wrong versions:", ids)
+	}
+
+	h := new(mockHandler)
+	err = d.assetGetVersion(ctx, "foo", 3, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.calledServeContent || string(h.content) != "v3" {
+		t.Error("wrong content of version 3:", string(h.content))
+	}
+	err = d.assetGetVersion(ctx, "foo", 2, new(mockHandler))
+	if err != sabakan.ErrNotFound {
+		t.Error("version 2 should have been removed:", err)
+	}
+
+	err = os.Remove(d.getAssetDir().Path(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = new(mockHandler)
+	err = d.assetGetVersion(ctx, "foo", 4, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := *d.advertiseURL
+	u.Path = "/api/v1/assets/foo"
+	if !h.calledRedirect || h.redirectURL != u.String()+"?id=4" {
+		t.Error("Redirect() received wrong URL:", h.redirectURL)
+	}
+
+	// rollback
+	err = d.assetRollback(ctx, "foo", 2)
+	if err != sabakan.ErrNotFound {
+		t.Error("rollback to a removed version should fail:", err)
+	}
+	err = d.assetRollback(ctx, "foo", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolled, err := d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolled.ID != 5 || !reflect.DeepEqual(rolled.History, []int{7, 6, 4, 3}) {
+		t.Error("wrong rollback:", rolled.ID, rolled.History)
+	}
+	h = new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "v5" {
+		t.Error("wrong content after rollback:", string(h.content))
+	}
+
+	resp, err := d.client.Get(ctx, KeyAudit, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rollbacks int
+	for _, kv := range resp.Kvs {
+		var a sabakan.AuditLog
+		err = json.Unmarshal(kv.Value, &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category == sabakan.AuditAssets && a.Action == "rollback" {
+			rollbacks++
+		}
+	}
+	if rollbacks != 1 {
+		t.Error("rollback was not audited:", rollbacks)
+	}
+
+	// local copies of the kept versions are not removed
+	err = d.handleAssetUpdate(ctx, asset, rolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{3, 5, 6, 7} {
+		if !d.getAssetDir().Exists(id) {
+			t.Error("local copy was removed:", id)
+		}
+	}
+
+	err = d.assetDelete(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetGetHistory(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("history should be deleted:", err)
+	}
+	err = d.handleAssetDelete(ctx, rolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{3, 5, 6, 7} {
+		if d.getAssetDir().Exists(id) {
+			t.Error("local copy was not removed:", id)
+		}
+	}
+}
+
+func testAssetHistoryLegacy(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dataDir = tempdir
+	defer os.RemoveAll(tempdir)
+	ctx := context.Background()
+
+	st, err := d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assets stored by older versions of sabakan have no history entry.
+	_, err = d.client.Delete(ctx, keyAssetHistoryPrefix("foo"), clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := d.assetGetHistory(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].ID != st.ID || !history[0].Exists {
+		t.Error("current version should be listed:", history)
+	}
+	h := new(mockHandler)
+	err = d.assetGetVersion(ctx, "foo", st.ID, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "v1" {
+		t.Error("wrong content of the current version:", string(h.content))
+	}
+
+	// the history entry is backfilled when a new version is stored.
+	_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err = d.assetGetHistory(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ID != st.ID || history[0].History != nil {
+		t.Error("wrong history:", history)
+	}
+	err = d.assetRollback(ctx, "foo", st.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "v1" {
+		t.Error("wrong content after rollback:", string(h.content))
+	}
+}
+
 func TestAsset(t *testing.T) {
 	t.Run("GetIndex", testAssetGetIndex)
 	t.Run("GetInfo", testAssetGetInfo)
@@ -477,4 +692,6 @@ func TestAsset(t *testing.T) {
 	t.Run("Put", testAssetPut)
 	t.Run("Get", testAssetGet)
 	t.Run("Delete", testAssetDelete)
+	t.Run("History", testAssetHistory)
+	t.Run("HistoryLegacy", testAssetHistoryLegacy)
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/sabakan/v3"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
 )
 
 func decodeAsset(data []byte) (*sabakan.Asset, error) {
@@ -50,15 +51,29 @@ RETRY:
 		return nil
 	}
 	key := KeyAssets + a.Name
+	hkey := keyAssetHistory(a.Name, a.ID)
 
 	j, err := json.Marshal(a)
 	if err != nil {
 		return err
 	}
+	h := *a
+	h.History = nil
+	hj, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
 
+	// the version in the history also needs the URL to be pulled after rollback.
 	resp, err := d.client.Txn(ctx).
 		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
-		Then(clientv3.OpPut(key, string(j))).
+		Then(
+			clientv3.OpPut(key, string(j)),
+			clientv3.OpTxn(
+				[]clientv3.Cmp{clientv3util.KeyExists(hkey)},
+				[]clientv3.Op{clientv3.OpPut(hkey, string(hj))},
+				nil),
+		).
 		Commit()
 	if err != nil {
 		return err
@@ -156,26 +171,16 @@ REDO:
 			return err
 		}
 		ids[asset.ID] = true
-		if dir.Exists(asset.ID) {
-			continue
+		for _, id := range asset.History {
+			ids[id] = true
 		}
-		err = d.downloadAsset(ctx, asset)
-		if err == nil {
-			log.Info("asset: downloaded a local copy", map[string]interface{}{
-				"name": asset.Name,
-				"id":   asset.ID,
-			})
+		if dir.Exists(asset.ID) || d.store != nil {
 			continue
 		}
-
 		// continue even when download failed because, if sabakan died,
 		// operators could not workaround by, for example, re-uploading
 		// the asset.
-		log.Error("asset: download failed", map[string]interface{}{
-			log.FnError: err,
-			"name":      asset.Name,
-			"id":        asset.ID,
-		})
+		d.pullAsset(ctx, asset)
 	}
 
 	if resp.More {
@@ -194,39 +199,52 @@ REDO:
 	}
 
 	dir.GC(ids)
+	d.notifyReport()
 	return nil
 }
 
 func (d *driver) handleAssetAdd(ctx context.Context, asset *sabakan.Asset) error {
-	if d.getAssetDir().Exists(asset.ID) {
+	// with an object storage, assets are cached on demand
+	if d.getAssetDir().Exists(asset.ID) || d.store != nil {
 		return nil
 	}
 
+	// continue even when download failed because, if sabakan died,
+	// operators could not workaround by, for example, re-uploading
+	// the asset.
+	d.pullAsset(ctx, asset)
+	return nil
+}
+
+// pullAsset downloads an asset and records the result for the replica report.
+func (d *driver) pullAsset(ctx context.Context, asset *sabakan.Asset) {
+	id := strconv.Itoa(asset.ID)
 	err := d.downloadAsset(ctx, asset)
-	if err == nil {
-		log.Info("asset: downloaded a local copy", map[string]interface{}{
-			"name": asset.Name,
-			"id":   asset.ID,
-		})
-	} else {
-		// continue even when download failed because, if sabakan died,
-		// operators could not workaround by, for example, re-uploading
-		// the asset.
+	if err != nil {
 		log.Error("asset: download failed", map[string]interface{}{
 			log.FnError: err,
 			"name":      asset.Name,
 			"id":        asset.ID,
 		})
+		d.recordPullFailure(sabakan.ReplicaKindAsset, asset.Name, id, err)
+		return
 	}
 
-	return nil
+	log.Info("asset: downloaded a local copy", map[string]interface{}{
+		"name": asset.Name,
+		"id":   asset.ID,
+	})
+	d.clearPullFailure(sabakan.ReplicaKindAsset, asset.Name, id)
 }
 
 func (d *driver) handleAssetUpdate(ctx context.Context, oldA, newA *sabakan.Asset) error {
-	if oldA.ID != newA.ID {
-		err := d.handleAssetDelete(ctx, oldA)
-		if err != nil {
-			return err
+	kept := map[int]bool{newA.ID: true}
+	for _, id := range newA.History {
+		kept[id] = true
+	}
+	for _, id := range append([]int{oldA.ID}, oldA.History...) {
+		if !kept[id] {
+			d.removeAssetFile(oldA.Name, id)
 		}
 	}
 
@@ -234,25 +252,32 @@ func (d *driver) handleAssetUpdate(ctx context.Context, oldA, newA *sabakan.Asse
 }
 
 func (d *driver) handleAssetDelete(ctx context.Context, asset *sabakan.Asset) error {
+	for _, id := range append([]int{asset.ID}, asset.History...) {
+		d.removeAssetFile(asset.Name, id)
+		d.clearPullFailure(sabakan.ReplicaKindAsset, asset.Name, strconv.Itoa(id))
+	}
+	return nil
+}
+
+func (d *driver) removeAssetFile(name string, id int) {
 	dir := d.getAssetDir()
 
-	if !dir.Exists(asset.ID) {
-		return nil
+	if !dir.Exists(id) {
+		return
 	}
 
 	log.Info("asset: delete a local copy", map[string]interface{}{
-		"name": asset.Name,
-		"id":   asset.ID,
+		"name": name,
+		"id":   id,
 	})
-	err := dir.Remove(asset.ID)
+	err := dir.Remove(id)
 	if err != nil {
 		log.Error("asset: failed to remove a local copy", map[string]interface{}{
 			log.FnError: err,
-			"name":      asset.Name,
-			"id":        asset.ID,
+			"name":      name,
+			"id":        id,
 		})
 	}
-	return nil
 }
 
 func (d *driver) handleAssetEvent(ctx context.Context, ev *clientv3.Event) error {
@@ -306,6 +331,7 @@ func (d *driver) startAssetUpdater(ctx context.Context, ch <-chan EventPool) err
 		if err != nil {
 			return err
 		}
+		d.notifyReport()
 	}
 	return nil
 }
@@ -0,0 +1,471 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+/*
+Resumable uploads are stored only in the server that received them:
+
+/var/lib/sabakan/asset-uploads/
+    - UPLOAD_ID/
+        - upload.json
+        - NUMBER.SHA256
+        - ...
+*/
+
+const assetUploadMeta = "upload.json"
+
+// AssetUploadDir is a struct to manage directories of resumable uploads.
+type AssetUploadDir struct {
+	Dir string
+}
+
+func (d *driver) getAssetUploadDir() AssetUploadDir {
+	return AssetUploadDir{
+		Dir: filepath.Join(d.dataDir, "asset-uploads"),
+	}
+}
+
+// Path returns a path to the directory of an upload.
+func (d AssetUploadDir) Path(id string) string {
+	return filepath.Join(d.Dir, id)
+}
+
+// Create creates a directory for an upload.
+func (d AssetUploadDir) Create(u *sabakan.AssetUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(d.Path(u.ID), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.Path(u.ID), assetUploadMeta), data, 0644)
+}
+
+// Get loads an upload and its parts.
+func (d AssetUploadDir) Get(id string) (*sabakan.AssetUpload, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, sabakan.ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(d.Path(id), assetUploadMeta))
+	if os.IsNotExist(err) {
+		return nil, sabakan.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := new(sabakan.AssetUpload)
+	err = json.Unmarshal(data, u)
+	if err != nil {
+		return nil, err
+	}
+
+	fil, err := os.ReadDir(d.Path(id))
+	if err != nil {
+		return nil, err
+	}
+	u.Parts = nil
+	for _, fi := range fil {
+		fields := strings.Split(fi.Name(), ".")
+		if len(fields) != 2 || !fi.Type().IsRegular() {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		info, err := fi.Info()
+		if err != nil {
+			return nil, err
+		}
+		u.Parts = append(u.Parts, sabakan.AssetUploadPart{
+			Number: n,
+			Size:   info.Size(),
+			Sha256: fields[1],
+		})
+	}
+	sort.Slice(u.Parts, func(i, j int) bool {
+		return u.Parts[i].Number < u.Parts[j].Number
+	})
+	return u, nil
+}
+
+// SavePart stores a part of an upload.
+func (d AssetUploadDir) SavePart(id string, number int, r io.Reader, csum []byte) (*sabakan.AssetUploadPart, error) {
+	f, err := os.CreateTemp(d.Path(id), ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return nil, err
+	}
+	hsum := h.Sum(nil)
+	if !bytes.Equal(csum, hsum) {
+		return nil, sabakan.ErrChecksumMismatch
+	}
+	err = f.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := filepath.Glob(filepath.Join(d.Path(id), strconv.Itoa(number)+".*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range old {
+		err = os.Remove(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sum := hex.EncodeToString(hsum)
+	err = os.Rename(f.Name(), filepath.Join(d.Path(id), strconv.Itoa(number)+"."+sum))
+	if err != nil {
+		return nil, err
+	}
+	return &sabakan.AssetUploadPart{
+		Number: number,
+		Size:   size,
+		Sha256: sum,
+	}, nil
+}
+
+// Open returns a reader of the concatenated parts.
+// Callers must call the returned function to close files.
+func (d AssetUploadDir) Open(u *sabakan.AssetUpload) (io.Reader, func(), error) {
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	readers := make([]io.Reader, len(u.Parts))
+	for i, p := range u.Parts {
+		f, err := os.Open(filepath.Join(d.Path(u.ID), strconv.Itoa(p.Number)+"."+p.Sha256))
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		readers[i] = f
+	}
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// Remove removes an upload.
+func (d AssetUploadDir) Remove(id string) error {
+	return os.RemoveAll(d.Path(id))
+}
+
+// GC removes uploads that have not been updated for expiry.
+func (d AssetUploadDir) GC(expiry time.Duration) error {
+	fil, err := os.ReadDir(d.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fil {
+		if !fi.IsDir() {
+			continue
+		}
+		info, err := fi.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < expiry {
+			continue
+		}
+		log.Info("removing expired asset upload", map[string]interface{}{
+			"id": fi.Name(),
+		})
+		err = os.RemoveAll(filepath.Join(d.Dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (d *driver) assetCreateUpload(ctx context.Context, upload *sabakan.AssetUpload) (*sabakan.AssetUpload, error) {
+	// reject early; the quota is checked again when committed
+	if d.assetQuota > 0 {
+		var evicted []int
+		a, err := d.assetGetInfo(ctx, upload.Name)
+		switch err {
+		case nil:
+			_, evicted = assetHistoryAfterPut(a)
+		case sabakan.ErrNotFound:
+		default:
+			return nil, err
+		}
+		err = d.checkAssetQuota(ctx, 0, upload.Size, evicted, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	dir := d.getAssetUploadDir()
+	err := dir.GC(assetUploadExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	u := *upload
+	u.ID = id
+	u.Date = time.Now().UTC()
+	u.Parts = nil
+
+	err = dir.Create(&u)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (d *driver) assetGetUploads(ctx context.Context, name string) ([]*sabakan.AssetUpload, error) {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	dir := d.getAssetUploadDir()
+	fil, err := os.ReadDir(dir.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	uploads := []*sabakan.AssetUpload{}
+	for _, fi := range fil {
+		if !fi.IsDir() {
+			continue
+		}
+		u, err := dir.Get(fi.Name())
+		if err == sabakan.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if u.Name == name {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Date.Before(uploads[j].Date)
+	})
+	return uploads, nil
+}
+
+// pendingUploadSize returns the total size of uploads other than except
+// that have not been committed.
+func (d *driver) pendingUploadSize(except string) (int64, error) {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	dir := d.getAssetUploadDir()
+	fil, err := os.ReadDir(dir.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	var total int64
+	for _, fi := range fil {
+		if !fi.IsDir() || fi.Name() == except {
+			continue
+		}
+		u, err := dir.Get(fi.Name())
+		if err == sabakan.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += u.Size
+	}
+	return total, nil
+}
+
+func (d *driver) getUpload(name, id string) (*sabakan.AssetUpload, error) {
+	u, err := d.getAssetUploadDir().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Name != name {
+		return nil, sabakan.ErrNotFound
+	}
+	return u, nil
+}
+
+func (d *driver) assetGetUpload(ctx context.Context, name, id string) (*sabakan.AssetUpload, error) {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	return d.getUpload(name, id)
+}
+
+func (d *driver) assetPutUploadPart(ctx context.Context, name, id string, number int, csum []byte, r io.Reader) (*sabakan.AssetUploadPart, error) {
+	d.uploadMu.Lock()
+	_, err := d.getUpload(name, id)
+	d.uploadMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// receive the data without the lock because it takes long
+	part, err := d.getAssetUploadDir().SavePart(id, number, r, csum)
+	if os.IsNotExist(err) {
+		// aborted
+		return nil, sabakan.ErrNotFound
+	}
+	return part, err
+}
+
+// startCommit marks the upload as being committed.
+// It returns ErrConflicted if the upload is already being committed.
+func (d *driver) startCommit(name, id string) (*sabakan.AssetUpload, error) {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	u, err := d.getUpload(name, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.committing[id] {
+		return nil, sabakan.ErrConflicted
+	}
+	if d.committing == nil {
+		d.committing = make(map[string]bool)
+	}
+	d.committing[id] = true
+	return u, nil
+}
+
+func (d *driver) endCommit(id string) {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	delete(d.committing, id)
+}
+
+func (d *driver) assetCommitUpload(ctx context.Context, name, id string) (*sabakan.AssetStatus, error) {
+	// assetPut takes long for large assets, so this does not hold uploadMu
+	u, err := d.startCommit(name, id)
+	if err != nil {
+		return nil, err
+	}
+	defer d.endCommit(id)
+
+	err = u.VerifyParts()
+	if err != nil {
+		return nil, err
+	}
+	csum, err := hex.DecodeString(u.Sha256)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := d.getAssetUploadDir()
+	r, closeAll, err := dir.Open(u)
+	if err != nil {
+		return nil, err
+	}
+	status, err := d.assetPutWithUpload(ctx, name, u.ContentType, csum, u.Options, r, id)
+	closeAll()
+	if err != nil {
+		return nil, err
+	}
+
+	err = dir.Remove(id)
+	if err != nil {
+		log.Error("asset: failed to remove a committed upload", map[string]interface{}{
+			log.FnError: err,
+			"name":      name,
+			"upload":    id,
+		})
+	}
+	return status, nil
+}
+
+func (d *driver) assetAbortUpload(ctx context.Context, name, id string) error {
+	d.uploadMu.Lock()
+	defer d.uploadMu.Unlock()
+
+	_, err := d.getUpload(name, id)
+	if err != nil {
+		return err
+	}
+	if d.committing[id] {
+		return sabakan.ErrConflicted
+	}
+	return d.getAssetUploadDir().Remove(id)
+}
+
+func (d assetDriver) CreateUpload(ctx context.Context, upload *sabakan.AssetUpload) (*sabakan.AssetUpload, error) {
+	return d.assetCreateUpload(ctx, upload)
+}
+
+func (d assetDriver) GetUploads(ctx context.Context, name string) ([]*sabakan.AssetUpload, error) {
+	return d.assetGetUploads(ctx, name)
+}
+
+func (d assetDriver) GetUpload(ctx context.Context, name, id string) (*sabakan.AssetUpload, error) {
+	return d.assetGetUpload(ctx, name, id)
+}
+
+func (d assetDriver) PutUploadPart(ctx context.Context, name, id string, number int, csum []byte, r io.Reader) (*sabakan.AssetUploadPart, error) {
+	return d.assetPutUploadPart(ctx, name, id, number, csum, r)
+}
+
+func (d assetDriver) CommitUpload(ctx context.Context, name, id string) (*sabakan.AssetStatus, error) {
+	return d.assetCommitUpload(ctx, name, id)
+}
+
+func (d assetDriver) AbortUpload(ctx context.Context, name, id string) error {
+	return d.assetAbortUpload(ctx, name, id)
+}
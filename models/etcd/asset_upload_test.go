@@ -0,0 +1,240 @@
+package etcd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testSum(data string) []byte {
+	sum := sha256.Sum256([]byte(data))
+	return sum[:]
+}
+
+func testAssetUploadCommit(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dataDir = tempdir
+	defer os.RemoveAll(tempdir)
+	ctx := context.Background()
+
+	u, err := d.assetCreateUpload(ctx, &sabakan.AssetUpload{
+		Name:        "foo",
+		ContentType: "text/plain",
+		Size:        10,
+		Sha256:      hex.EncodeToString(testSum("0123456789")),
+		Options:     map[string]string{"version": "1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.ID) == 0 {
+		t.Fatal("empty upload ID")
+	}
+
+	_, err = d.assetPutUploadPart(ctx, "foo", u.ID, 1, testSum("abcd"), strings.NewReader("0123"))
+	if !errors.Is(err, sabakan.ErrChecksumMismatch) {
+		t.Error("broken part should be rejected:", err)
+	}
+	_, err = d.assetPutUploadPart(ctx, "bar", u.ID, 1, testSum("0123"), strings.NewReader("0123"))
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+
+	part, err := d.assetPutUploadPart(ctx, "foo", u.ID, 2, testSum("4567"), strings.NewReader("4567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Number != 2 || part.Size != 4 || part.Sha256 != hex.EncodeToString(testSum("4567")) {
+		t.Error("wrong part:", part)
+	}
+
+	_, err = d.assetCommitUpload(ctx, "foo", u.ID)
+	if !errors.Is(err, sabakan.ErrIncompleteUpload) {
+		t.Error("incomplete upload should not be committed:", err)
+	}
+
+	for i, data := range []string{"0123", "4567", "89"} {
+		_, err = d.assetPutUploadPart(ctx, "foo", u.ID, i+1, testSum(data), strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	u2, err := d.assetGetUpload(ctx, "foo", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u2.Parts) != 3 || u2.Parts[2].Size != 2 {
+		t.Error("wrong parts:", u2.Parts)
+	}
+
+	status, err := d.assetCommitUpload(ctx, "foo", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != http.StatusCreated {
+		t.Error("status.Status != http.StatusCreated:", status.Status)
+	}
+
+	asset, err := d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Size != 10 || asset.Options["version"] != "1.0.0" {
+		t.Error("wrong asset:", asset)
+	}
+	h := new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "0123456789" {
+		t.Error("wrong content:", string(h.content))
+	}
+
+	_, err = d.assetGetUpload(ctx, "foo", u.ID)
+	if err != sabakan.ErrNotFound {
+		t.Error("committed upload should be removed:", err)
+	}
+}
+
+func testAssetUploadMismatch(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dataDir = tempdir
+	defer os.RemoveAll(tempdir)
+	ctx := context.Background()
+
+	u, err := d.assetCreateUpload(ctx, &sabakan.AssetUpload{
+		Name:        "foo",
+		ContentType: "text/plain",
+		Size:        4,
+		Sha256:      hex.EncodeToString(testSum("0123")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPutUploadPart(ctx, "foo", u.ID, 1, testSum("abcd"), strings.NewReader("abcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.assetCommitUpload(ctx, "foo", u.ID)
+	if !errors.Is(err, sabakan.ErrChecksumMismatch) {
+		t.Error("upload not matching the declared checksum should be rejected:", err)
+	}
+	_, err = d.assetGetInfo(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("asset should not be created:", err)
+	}
+
+	// the upload remains to be fixed
+	_, err = d.assetPutUploadPart(ctx, "foo", u.ID, 1, testSum("0123"), strings.NewReader("0123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetCommitUpload(ctx, "foo", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAssetUploadAbort(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-asset-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dataDir = tempdir
+	defer os.RemoveAll(tempdir)
+	ctx := context.Background()
+
+	upload := &sabakan.AssetUpload{
+		Name:        "foo",
+		ContentType: "text/plain",
+		Size:        4,
+		Sha256:      hex.EncodeToString(testSum("0123")),
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		u, err := d.assetCreateUpload(ctx, upload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+	upload.Name = "bar"
+	_, err = d.assetCreateUpload(ctx, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, err := d.assetGetUploads(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 2 {
+		t.Error("wrong uploads:", uploads)
+	}
+
+	err = d.assetAbortUpload(ctx, "bar", ids[0])
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+	err = d.assetAbortUpload(ctx, "foo", ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetPutUploadPart(ctx, "foo", ids[0], 1, testSum("0123"), strings.NewReader("0123"))
+	if err != sabakan.ErrNotFound {
+		t.Error("aborted upload should not be found:", err)
+	}
+
+	// expired uploads are removed when a new upload is created
+	dir := d.getAssetUploadDir()
+	past := time.Now().Add(-assetUploadExpiry - time.Minute)
+	err = os.Chtimes(dir.Path(ids[1]), past, past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetCreateUpload(ctx, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads, err = d.assetGetUploads(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 0 {
+		t.Error("expired upload was not removed:", uploads)
+	}
+}
+
+func TestAssetUpload(t *testing.T) {
+	t.Run("Commit", testAssetUploadCommit)
+	t.Run("Mismatch", testAssetUploadMismatch)
+	t.Run("Abort", testAssetUploadAbort)
+}
@@ -10,15 +10,24 @@ const (
 	KeyDHCP             = "dhcp"
 	KeyIPAM             = "ipam"
 	KeyLeaseUsages      = "lease-usages/"
+	KeyReservations     = "dhcp-reservations/"
 	KeyMachines         = "machines/"
 	KeyNodeIndices      = "node-indices/"
 	KeyImages           = "images/"
 	KeyAssets           = "assets/"
 	KeyAssetsID         = "assets"
+	KeyAssetHistory     = "asset-history/"
 	KeyIgnitions        = "ignitions/"
+	KeyInventories      = "inventories/"
+	KeyInventoryHistory = "inventory-history/"
 	KeyAudit            = "audit/"
 	KeyAuditLastGC      = "audit"
 	KeyKernelParams     = "kernel-params/"
+	KeyReplicas         = "replicas/"
+	KeyImports          = "imports/"
+	KeyMachineTokens    = "machine-tokens/"
+	KeySecrets          = "secrets/"
+	KeyAuditSequence    = "audit-sequence"
 )
 
 // MaxDeleted is the maximum number of deleted image IDs stored in etcd.
@@ -27,16 +36,40 @@ const MaxDeleted = 10
 // MaxIgnitions is a number of the ignition templates to keep on etcd
 const MaxIgnitions = 10
 
+// MaxInventories is a number of the inventory versions to keep on etcd for each machine
+const MaxInventories = 10
+
+// MaxAssetVersions is a number of the versions to keep for each asset including the current one
+const MaxAssetVersions = 5
+
 // LastRevFile is the filename that keeps the last revision that
 // the stateful watcher processed successfully.
 const LastRevFile = "lastrev"
 
 // Miscellaneous
 const (
-	assetPageSize    = 100
-	maxJitterSeconds = 30
-	maxAssetURLs     = 10
-	maxImageURLs     = 10
+	assetPageSize       = 100
+	maxJitterSeconds    = 30
+	maxAssetURLs        = 10
+	maxImageURLs        = 10
+	presignExpiry       = 10 * time.Minute
+	assetUploadExpiry   = 24 * time.Hour
+	assetExpiryInterval = 10 * time.Minute
+	peerLookupInterval  = 5 * time.Minute
+)
+
+// Replica report parameters
+const (
+	replicaReportTTL           = 60 // seconds
+	replicaReportRetryInterval = 10 * time.Second
+)
+
+// Import parameters
+const (
+	importSessionTTL       = 60           // seconds
+	importStatusTTL        = 24 * 60 * 60 // seconds
+	importProgressInterval = 5 * time.Second
+	importIdleTimeout      = 5 * time.Minute
 )
 
 // Log parameters
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"path"
 	"time"
@@ -58,6 +59,9 @@ func (d *driver) handleDHCPConfig(ev *clientv3.Event) error {
 type leaseInfo struct {
 	Index      int       `json:"index"`
 	LeaseUntil time.Time `json:"lease"`
+
+	// DeclinedBy is the MAC address of the client that declined the address.
+	DeclinedBy string `json:"declined-by,omitempty"`
 }
 
 type leaseUsage struct {
@@ -100,7 +104,9 @@ func (l *leaseUsage) gc() {
 	}
 }
 
-func (l *leaseUsage) lease(mac net.HardwareAddr, lr *sabakan.LeaseRange, du time.Duration) (net.IP, error) {
+// lease leases an address in lr for mac.
+// Addresses whose indices are in reserved are not leased dynamically.
+func (l *leaseUsage) lease(mac net.HardwareAddr, lr *sabakan.LeaseRange, du time.Duration, reserved map[int]bool) (net.IP, error) {
 	hwAddr := mac.String()
 	leaseUntil := time.Now().Add(du)
 	if v, ok := l.hwMap[hwAddr]; ok {
@@ -112,11 +118,11 @@ func (l *leaseUsage) lease(mac net.HardwareAddr, lr *sabakan.LeaseRange, du time
 	l.gc()
 
 	for i := 0; i < lr.Count; i++ {
-		if l.usageMap[i] {
+		if l.usageMap[i] || reserved[i] {
 			continue
 		}
 		l.usageMap[i] = true
-		l.hwMap[hwAddr] = leaseInfo{i, leaseUntil}
+		l.hwMap[hwAddr] = leaseInfo{Index: i, LeaseUntil: leaseUntil}
 		log.Debug("etcd/dhcp: lease", map[string]interface{}{
 			"node_index":  i,
 			"mac":         hwAddr,
@@ -126,7 +132,7 @@ func (l *leaseUsage) lease(mac net.HardwareAddr, lr *sabakan.LeaseRange, du time
 		return lr.IP(i), nil
 	}
 
-	return nil, errors.New("no leasable IP address found from " + lr.Key())
+	return nil, fmt.Errorf("%w from %s", sabakan.ErrNoLeasableAddress, lr.Key())
 }
 
 func (l *leaseUsage) renew(mac net.HardwareAddr, du time.Duration) error {
@@ -163,22 +169,91 @@ func (l *leaseUsage) release(mac net.HardwareAddr) {
 	delete(l.hwMap, hwAddr)
 }
 
-func (l *leaseUsage) decline(mac net.HardwareAddr) {
+// decline quarantines the address leased for mac for du.
+// It returns false if no address is leased for mac.
+func (l *leaseUsage) decline(mac net.HardwareAddr, du time.Duration) bool {
 	hwAddr := mac.String()
 
 	v, ok := l.hwMap[hwAddr]
 	if !ok {
-		return
+		return false
 	}
 
+	v.LeaseUntil = time.Now().Add(du)
+	v.DeclinedBy = hwAddr
 	log.Debug("etcd/dhcp: decline", map[string]interface{}{
-		"node_index": v.Index,
-		"mac":        hwAddr,
+		"node_index":       v.Index,
+		"mac":              hwAddr,
+		"quarantine_until": v.LeaseUntil,
 	})
 
 	declineKey := generateDummyMAC(v.Index).String()
 	l.hwMap[declineKey] = v
 	delete(l.hwMap, hwAddr)
+	return true
+}
+
+// leases returns unexpired leases in lr.
+func (l *leaseUsage) leases(lr *sabakan.LeaseRange) []*sabakan.DHCPLease {
+	now := time.Now()
+
+	var leases []*sabakan.DHCPLease
+	for k, v := range l.hwMap {
+		if v.LeaseUntil.Before(now) {
+			continue
+		}
+		lease := &sabakan.DHCPLease{
+			Range:  lr.Key(),
+			IP:     lr.IP(v.Index).String(),
+			Expire: v.LeaseUntil,
+		}
+		if isDummyMAC(k) {
+			lease.Declined = true
+			lease.DeclinedBy = v.DeclinedBy
+		} else {
+			lease.MAC = k
+		}
+		leases = append(leases, lease)
+	}
+	return leases
+}
+
+// leasedTo returns the MAC address to which the idx-th address is leased.
+// For declined addresses, it returns the dummy MAC address.
+func (l *leaseUsage) leasedTo(idx int) (string, bool) {
+	now := time.Now()
+	for k, v := range l.hwMap {
+		if v.Index == idx && !v.LeaseUntil.Before(now) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// remove releases the lease of the idx-th address.
+// It returns the MAC address of the lease, or false if the address is not leased.
+func (l *leaseUsage) remove(idx int) (string, bool) {
+	for k, v := range l.hwMap {
+		if v.Index != idx {
+			continue
+		}
+		log.Debug("etcd/dhcp: remove", map[string]interface{}{
+			"node_index": v.Index,
+			"mac":        k,
+		})
+		delete(l.usageMap, v.Index)
+		delete(l.hwMap, k)
+		return k, true
+	}
+	return "", false
+}
+
+func isDummyMAC(hwAddr string) bool {
+	mac, err := net.ParseMAC(hwAddr)
+	if err != nil {
+		return false
+	}
+	return len(mac) == 6 && mac[0] == 0xff && mac[1] == 0
 }
 
 func generateDummyMAC(idx int) net.HardwareAddr {
@@ -245,13 +320,23 @@ RETRY:
 }
 
 func (d *driver) updateLeaseUsage(ctx context.Context, lrkey string, lu *leaseUsage) (bool, error) {
+	tresp, err := d.commitLeaseUsage(ctx, lrkey, lu)
+	if err != nil {
+		return false, err
+	}
+
+	return tresp.Succeeded, nil
+}
+
+// commitLeaseUsage is the same as updateLeaseUsage but returns the response of the transaction.
+func (d *driver) commitLeaseUsage(ctx context.Context, lrkey string, lu *leaseUsage) (*clientv3.TxnResponse, error) {
 	key := d.leaseUsageKey(lrkey)
 	j, err := json.Marshal(lu)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	tresp, err := d.client.Txn(ctx).
+	return d.client.Txn(ctx).
 		If(
 			clientv3.Compare(clientv3.ModRevision(key), "=", lu.revision),
 		).
@@ -260,11 +345,6 @@ func (d *driver) updateLeaseUsage(ctx context.Context, lrkey string, lu *leaseUs
 		).
 		Else().
 		Commit()
-	if err != nil {
-		return false, err
-	}
-
-	return tresp.Succeeded, nil
 }
 
 func (d *driver) dhcpLease(ctx context.Context, ifaddr net.IP, mac net.HardwareAddr) (net.IP, error) {
@@ -283,6 +363,11 @@ func (d *driver) dhcpLease(ctx context.Context, ifaddr net.IP, mac net.HardwareA
 		return nil, errors.New("invalid ifaddr: " + ifaddr.String())
 	}
 
+	r, reserved := d.reservations.inRange(lr, mac)
+	if r != nil {
+		return net.ParseIP(r.IP).To4(), nil
+	}
+
 	lrkey := lr.Key()
 
 RETRY:
@@ -291,7 +376,7 @@ RETRY:
 		return nil, err
 	}
 
-	ip, err := lu.lease(mac, lr, dc.LeaseDuration())
+	ip, err := lu.lease(mac, lr, dc.LeaseDuration(), reserved)
 	if err != nil {
 		return nil, err
 	}
@@ -324,6 +409,14 @@ func (d *driver) dhcpRenew(ctx context.Context, ciaddr net.IP, mac net.HardwareA
 		return errors.New("invalid ciaddr: " + ciaddr.String())
 	}
 
+	reserved, err := d.isReserved(ctx, ciaddr, mac)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return nil
+	}
+
 	lrkey := lr.Key()
 
 RETRY:
@@ -388,11 +481,28 @@ func (d *driver) dhcpDecline(ctx context.Context, ciaddr net.IP, mac net.Hardwar
 		return err
 	}
 
+	dc, err := d.getDHCPConfig()
+	if err != nil {
+		return err
+	}
+
 	lr := ipam.LeaseRange(ciaddr)
 	if lr == nil {
 		return errors.New("invalid ciaddr: " + ciaddr.String())
 	}
 
+	reserved, err := d.isReserved(ctx, ciaddr, mac)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		log.Warn("etcd/dhcp: reserved address was declined", map[string]interface{}{
+			"mac": mac.String(),
+			"ip":  ciaddr.String(),
+		})
+		return nil
+	}
+
 	lrkey := lr.Key()
 
 RETRY:
@@ -401,17 +511,119 @@ RETRY:
 		return err
 	}
 
-	lu.decline(mac)
+	if !lu.decline(mac, dc.QuarantineDuration()) {
+		return nil
+	}
 
-	succeeded, err := d.updateLeaseUsage(ctx, lrkey, lu)
+	tresp, err := d.commitLeaseUsage(ctx, lrkey, lu)
 	if err != nil {
 		return err
 	}
-	if !succeeded {
+	if !tresp.Succeeded {
+		log.Info("etcd: revision mismatch; retrying...", nil)
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditDHCP, ciaddr.String(), "decline", mac.String())
+	return nil
+}
+
+// dhcpGetLeases returns all leases.  opts are passed to Get of etcd.
+func (d *driver) dhcpGetLeases(ctx context.Context, opts ...clientv3.OpOption) ([]*sabakan.DHCPLease, error) {
+	resp, err := d.client.Get(ctx, KeyLeaseUsages, append(opts, clientv3.WithPrefix())...)
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make([]*sabakan.DHCPLease, 0)
+	for _, kv := range resp.Kvs {
+		lrkey := string(kv.Key[len(KeyLeaseUsages):])
+		begin := net.ParseIP(lrkey)
+		if begin == nil {
+			log.Warn("etcd/dhcp: invalid lease range key", map[string]interface{}{
+				"key": string(kv.Key),
+			})
+			continue
+		}
+
+		lu := new(leaseUsage)
+		err = json.Unmarshal(kv.Value, lu)
+		if err != nil {
+			return nil, err
+		}
+		lr := &sabakan.LeaseRange{BeginAddress: begin}
+		leases = append(leases, lu.leases(lr)...)
+	}
+
+	sabakan.SortDHCPLeases(leases)
+	return leases, nil
+}
+
+func (d *driver) dhcpGetLease(ctx context.Context, ip net.IP) (*sabakan.DHCPLease, error) {
+	ipam, err := d.getIPAMConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return nil, sabakan.ErrNotFound
+	}
+
+	lu, err := d.getLeaseUsage(ctx, lr.Key())
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range lu.leases(lr) {
+		if net.ParseIP(lease.IP).Equal(ip) {
+			return lease, nil
+		}
+	}
+	return nil, sabakan.ErrNotFound
+}
+
+func (d *driver) dhcpDeleteLease(ctx context.Context, ip net.IP) error {
+	ipam, err := d.getIPAMConfig()
+	if err != nil {
+		return err
+	}
+
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return sabakan.ErrNotFound
+	}
+	idx, ok := lr.Index(ip)
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+
+	lrkey := lr.Key()
+
+RETRY:
+	lu, err := d.getLeaseUsage(ctx, lrkey)
+	if err != nil {
+		return err
+	}
+
+	mac, ok := lu.remove(idx)
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+
+	tresp, err := d.commitLeaseUsage(ctx, lrkey, lu)
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
 		log.Info("etcd: revision mismatch; retrying...", nil)
 		goto RETRY
 	}
 
+	detail := mac
+	if isDummyMAC(mac) {
+		detail = "declined"
+	}
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditDHCP, ip.String(), "delete-lease", detail)
 	return nil
 }
 
@@ -442,3 +654,31 @@ func (d dhcpDriver) Release(ctx context.Context, ciaddr net.IP, mac net.Hardware
 func (d dhcpDriver) Decline(ctx context.Context, ciaddr net.IP, mac net.HardwareAddr) error {
 	return d.dhcpDecline(ctx, ciaddr, mac)
 }
+
+func (d dhcpDriver) GetLeases(ctx context.Context) ([]*sabakan.DHCPLease, error) {
+	return d.dhcpGetLeases(ctx)
+}
+
+func (d dhcpDriver) GetLease(ctx context.Context, ip net.IP) (*sabakan.DHCPLease, error) {
+	return d.dhcpGetLease(ctx, ip)
+}
+
+func (d dhcpDriver) DeleteLease(ctx context.Context, ip net.IP) error {
+	return d.dhcpDeleteLease(ctx, ip)
+}
+
+func (d dhcpDriver) PutReservation(ctx context.Context, r *sabakan.DHCPReservation) error {
+	return d.dhcpPutReservation(ctx, r)
+}
+
+func (d dhcpDriver) GetReservation(ctx context.Context, mac net.HardwareAddr) (*sabakan.DHCPReservation, error) {
+	return d.dhcpGetReservation(ctx, mac)
+}
+
+func (d dhcpDriver) GetReservations(ctx context.Context) ([]*sabakan.DHCPReservation, error) {
+	return d.dhcpGetReservations(ctx)
+}
+
+func (d dhcpDriver) DeleteReservation(ctx context.Context, mac net.HardwareAddr) error {
+	return d.dhcpDeleteReservation(ctx, mac)
+}
@@ -0,0 +1,238 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (d *driver) dhcpPutReservation(ctx context.Context, r *sabakan.DHCPReservation) error {
+	ipam, err := d.getIPAMConfig()
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(r.IP)
+	if ip == nil {
+		return errors.New("invalid IP address: " + r.IP)
+	}
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return errors.New("IP address is not in any lease range: " + r.IP)
+	}
+	idx, ok := lr.Index(ip)
+	if !ok {
+		return errors.New("IP address is not in any lease range: " + r.IP)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	key := KeyReservations + r.MAC
+	lrkey := lr.Key()
+
+RETRY:
+	resp, err := d.client.Get(ctx, KeyReservations, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		other := new(sabakan.DHCPReservation)
+		err = json.Unmarshal(kv.Value, other)
+		if err != nil {
+			return err
+		}
+		if other.MAC != r.MAC && ip.Equal(net.ParseIP(other.IP)) {
+			return sabakan.ErrConflicted
+		}
+	}
+
+	lu, err := d.getLeaseUsage(ctx, lrkey)
+	if err != nil {
+		return err
+	}
+	if mac, ok := lu.leasedTo(idx); ok && mac != r.MAC {
+		return sabakan.ErrConflicted
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.ModRevision(KeyReservations), "<", resp.Header.Revision+1).WithPrefix(),
+			clientv3.Compare(clientv3.ModRevision(d.leaseUsageKey(lrkey)), "=", lu.revision),
+		).
+		Then(
+			clientv3.OpPut(key, string(data)),
+		).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		log.Info("etcd: revision mismatch; retrying...", nil)
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditDHCP, r.MAC, "put-reservation", string(data))
+	return nil
+}
+
+func (d *driver) dhcpGetReservation(ctx context.Context, mac net.HardwareAddr) (*sabakan.DHCPReservation, error) {
+	resp, err := d.client.Get(ctx, KeyReservations+mac.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+
+	r := new(sabakan.DHCPReservation)
+	err = json.Unmarshal(resp.Kvs[0].Value, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// dhcpGetReservations returns all reservations.  opts are passed to Get of etcd.
+func (d *driver) dhcpGetReservations(ctx context.Context, opts ...clientv3.OpOption) ([]*sabakan.DHCPReservation, error) {
+	resp, err := d.client.Get(ctx, KeyReservations, append(opts, clientv3.WithPrefix())...)
+	if err != nil {
+		return nil, err
+	}
+
+	reservations := make([]*sabakan.DHCPReservation, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		r := new(sabakan.DHCPReservation)
+		err = json.Unmarshal(kv.Value, r)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	sabakan.SortDHCPReservations(reservations)
+	return reservations, nil
+}
+
+func (d *driver) dhcpDeleteReservation(ctx context.Context, mac net.HardwareAddr) error {
+	resp, err := d.client.Delete(ctx, KeyReservations+mac.String())
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return sabakan.ErrNotFound
+	}
+
+	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditDHCP, mac.String(), "delete-reservation", "")
+	return nil
+}
+
+// reservationCache is an on-memory copy of DHCP reservations.
+// It is kept updated by the stateless watcher so that DHCPDISCOVER
+// does not read all reservations from etcd.
+type reservationCache struct {
+	mu           sync.RWMutex
+	reservations map[string]*sabakan.DHCPReservation
+}
+
+func (c *reservationCache) init(ctx context.Context, client *clientv3.Client) error {
+	resp, err := client.Get(ctx, KeyReservations, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+
+	reservations := make(map[string]*sabakan.DHCPReservation)
+	for _, kv := range resp.Kvs {
+		r := new(sabakan.DHCPReservation)
+		err = json.Unmarshal(kv.Value, r)
+		if err != nil {
+			return err
+		}
+		reservations[r.MAC] = r
+	}
+
+	c.mu.Lock()
+	c.reservations = reservations
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *reservationCache) put(r *sabakan.DHCPReservation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reservations == nil {
+		c.reservations = make(map[string]*sabakan.DHCPReservation)
+	}
+	c.reservations[r.MAC] = r
+}
+
+func (c *reservationCache) delete(mac string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.reservations, mac)
+}
+
+// inRange returns the reservation for mac if it is in lr, and
+// a set of indices of addresses reserved in lr.
+func (c *reservationCache) inRange(lr *sabakan.LeaseRange, mac net.HardwareAddr) (*sabakan.DHCPReservation, map[int]bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var found *sabakan.DHCPReservation
+	reserved := make(map[int]bool)
+	for _, r := range c.reservations {
+		idx, ok := lr.Index(net.ParseIP(r.IP))
+		if r.MAC == mac.String() {
+			if !ok {
+				log.Warn("etcd/dhcp: reserved address is not in the lease range of the interface", map[string]interface{}{
+					"mac":   r.MAC,
+					"ip":    r.IP,
+					"range": lr.Key(),
+				})
+				continue
+			}
+			found = r
+		}
+		if ok {
+			reserved[idx] = true
+		}
+	}
+	return found, reserved
+}
+
+func (d *driver) handleReservation(ev *clientv3.Event) error {
+	if ev.Type == clientv3.EventTypeDelete {
+		d.reservations.delete(string(ev.Kv.Key[len(KeyReservations):]))
+		return nil
+	}
+
+	r := new(sabakan.DHCPReservation)
+	err := json.Unmarshal(ev.Kv.Value, r)
+	if err != nil {
+		return err
+	}
+	d.reservations.put(r)
+	return nil
+}
+
+// isReserved returns true if ip is reserved for mac.
+func (d *driver) isReserved(ctx context.Context, ip net.IP, mac net.HardwareAddr) (bool, error) {
+	r, err := d.dhcpGetReservation(ctx, mac)
+	if err == sabakan.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ip.Equal(net.ParseIP(r.IP)), nil
+}
@@ -0,0 +1,183 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// testWaitReservation waits for the stateless watcher to cache the reservation for mac.
+func testWaitReservation(t *testing.T, d *driver, mac net.HardwareAddr) {
+	for i := 0; i < 50; i++ {
+		d.reservations.mu.RLock()
+		_, ok := d.reservations.reservations[mac.String()]
+		d.reservations.mu.RUnlock()
+		if ok {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("reservation was not cached:", mac)
+}
+
+func testDHCPReservationLease(t *testing.T) {
+	d, ch := testNewDriver(t)
+	testSetupConfig(t, d, ch)
+
+	ctx := context.Background()
+	interfaceip := net.ParseIP("10.69.0.195")
+	mac1 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	mac2 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x67})
+
+	err := d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{
+		MAC:      mac1.String(),
+		IP:       "10.69.0.224",
+		Hostname: "appliance",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	testWaitReservation(t, d, mac1)
+
+	// reserved address is not leased to others
+	ip2, err := d.dhcpLease(ctx, interfaceip, mac2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip2.String() != "10.69.0.225" {
+		t.Error("reserved address should be skipped:", ip2)
+	}
+
+	ip1, err := d.dhcpLease(ctx, interfaceip, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip1.String() != "10.69.0.224" {
+		t.Error("reserved address should be leased:", ip1)
+	}
+	err = d.dhcpRenew(ctx, ip1, mac1)
+	if err != nil {
+		t.Error("reserved address should be renewed:", err)
+	}
+	err = d.dhcpDecline(ctx, ip1, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leases, err := d.dhcpGetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].IP != "10.69.0.225" {
+		t.Error("reserved address should not be recorded as leases:", leases)
+	}
+
+	// reservation in another rack is not used
+	ip, err := d.dhcpLease(ctx, net.ParseIP("10.69.1.3"), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "10.69.1.32" {
+		t.Error("address should be leased dynamically:", ip)
+	}
+}
+
+func testDHCPReservationConflict(t *testing.T) {
+	d, ch := testNewDriver(t)
+	testSetupConfig(t, d, ch)
+
+	ctx := context.Background()
+	mac1 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	mac2 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x67})
+
+	ip, err := d.dhcpLease(ctx, net.ParseIP("10.69.0.195"), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leased to another MAC address
+	err = d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{MAC: mac2.String(), IP: ip.String()})
+	if err != sabakan.ErrConflicted {
+		t.Error("reserving a leased address should be conflicted:", err)
+	}
+
+	// leased to the same MAC address
+	err = d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{MAC: mac1.String(), IP: ip.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reserved for another MAC address
+	err = d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{MAC: mac2.String(), IP: ip.String()})
+	if err != sabakan.ErrConflicted {
+		t.Error("reserving a reserved address should be conflicted:", err)
+	}
+
+	// update
+	err = d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{MAC: mac1.String(), IP: "10.69.0.240", Hostname: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.dhcpPutReservation(ctx, &sabakan.DHCPReservation{MAC: mac2.String(), IP: "10.69.0.40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := d.dhcpGetReservation(ctx, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.IP != "10.69.0.240" || r.Hostname != "foo" {
+		t.Error("reservation was not updated:", r)
+	}
+
+	reservations, err := d.dhcpGetReservations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 2 || reservations[0].MAC != mac2.String() || reservations[1].MAC != mac1.String() {
+		t.Error("unexpected reservations:", reservations)
+	}
+
+	err = d.dhcpDeleteReservation(ctx, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.dhcpDeleteReservation(ctx, mac1)
+	if err != sabakan.ErrNotFound {
+		t.Error("deleting a deleted reservation should fail with ErrNotFound:", err)
+	}
+	_, err = d.dhcpGetReservation(ctx, mac1)
+	if err != sabakan.ErrNotFound {
+		t.Error("reservation was not deleted:", err)
+	}
+
+	resp, err := d.client.Get(ctx, KeyAudit, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make(map[string]int)
+	for _, kv := range resp.Kvs {
+		var a sabakan.AuditLog
+		err = json.Unmarshal(kv.Value, &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category == sabakan.AuditDHCP {
+			actions[a.Action]++
+		}
+	}
+	if actions["put-reservation"] != 3 || actions["delete-reservation"] != 1 {
+		t.Error("reservations were not audited:", actions)
+	}
+}
+
+func TestDHCPReservation(t *testing.T) {
+	t.Run("Lease", testDHCPReservationLease)
+	t.Run("Conflict", testDHCPReservationConflict)
+}
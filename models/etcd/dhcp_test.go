@@ -73,7 +73,7 @@ func testSetupConfig(t *testing.T, d *driver, ch <-chan struct{}) {
 	ipam := &testIPAMConfig
 	config := &testDHCPConfig
 
-	err := d.putIPAMConfig(context.Background(), ipam)
+	err := d.putIPAMConfig(context.Background(), ipam, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -240,6 +240,14 @@ func testDHCPDecline(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	config := testDHCPConfig
+	config.QuarantineMinutes = 1440
+	err = d.putDHCPConfig(context.Background(), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
 	err = d.dhcpDecline(context.Background(), dhcpip, mac)
 	if err != nil {
 		t.Error(err)
@@ -253,6 +261,52 @@ func testDHCPDecline(t *testing.T) {
 	if dhcpip.Equal(dhcpip2) {
 		t.Error("declined IP address should not be used until expired")
 	}
+
+	leases, err := d.dhcpGetLeases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var declined *sabakan.DHCPLease
+	for _, l := range leases {
+		if l.Declined {
+			declined = l
+		}
+	}
+	if declined == nil {
+		t.Fatal("declined address is not listed", leases)
+	}
+	if declined.IP != dhcpip.String() || declined.DeclinedBy != mac.String() {
+		t.Error("unexpected declined lease", declined)
+	}
+	if declined.Expire.Before(time.Now().Add(23 * time.Hour)) {
+		t.Error("declined address should be quarantined for quarantine-minutes", declined.Expire)
+	}
+
+	resp, err := d.client.Get(context.Background(), KeyAudit, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, kv := range resp.Kvs {
+		var a sabakan.AuditLog
+		err = json.Unmarshal(kv.Value, &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category == sabakan.AuditDHCP && a.Instance == dhcpip.String() &&
+			a.Action == "decline" && a.Detail == mac.String() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("decline was not audited")
+	}
+
+	// declining an address not leased to the client is ignored
+	err = d.dhcpDecline(context.Background(), dhcpip, mac)
+	if err != nil {
+		t.Error(err)
+	}
 }
 
 func testDHCPLeaseExpiration(t *testing.T) {
@@ -349,6 +403,93 @@ RETRY:
 	}
 }
 
+func testDHCPLeases(t *testing.T) {
+	d, ch := testNewDriver(t)
+	testSetupConfig(t, d, ch)
+
+	ctx := context.Background()
+	interfaceip := net.ParseIP("10.69.0.195")
+	mac := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	mac2 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x67})
+
+	dhcpip, err := d.dhcpLease(ctx, interfaceip, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dhcpip2, err := d.dhcpLease(ctx, interfaceip, mac2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.dhcpDecline(ctx, dhcpip2, mac2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leases, err := d.dhcpGetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Fatal("unexpected number of leases", len(leases))
+	}
+	if leases[0].IP != dhcpip.String() || leases[0].MAC != mac.String() || leases[0].Declined {
+		t.Error("unexpected lease", leases[0])
+	}
+	if leases[1].IP != dhcpip2.String() || leases[1].MAC != "" || !leases[1].Declined || leases[1].DeclinedBy != mac2.String() {
+		t.Error("unexpected declined lease", leases[1])
+	}
+	if !leases[0].Expire.After(time.Now()) {
+		t.Error("lease should not be expired", leases[0].Expire)
+	}
+
+	lease, err := d.dhcpGetLease(ctx, dhcpip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.IP != dhcpip.String() || lease.MAC != mac.String() {
+		t.Error("unexpected lease", lease)
+	}
+	_, err = d.dhcpGetLease(ctx, net.ParseIP("192.168.0.1"))
+	if err != sabakan.ErrNotFound {
+		t.Error("getting an address out of range should fail with ErrNotFound", err)
+	}
+
+	err = d.dhcpDeleteLease(ctx, dhcpip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.dhcpGetLease(ctx, dhcpip)
+	if err != sabakan.ErrNotFound {
+		t.Error("getting a deleted lease should fail with ErrNotFound", err)
+	}
+	err = d.dhcpDeleteLease(ctx, dhcpip)
+	if err != sabakan.ErrNotFound {
+		t.Error("deleting a deleted lease should fail with ErrNotFound", err)
+	}
+	err = d.dhcpDeleteLease(ctx, net.ParseIP("192.168.0.1"))
+	if err != sabakan.ErrNotFound {
+		t.Error("deleting an address out of range should fail with ErrNotFound", err)
+	}
+
+	leases, err = d.dhcpGetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].IP != dhcpip2.String() {
+		t.Error("lease was not deleted", leases)
+	}
+
+	// the freed address can be leased again
+	mac3 := net.HardwareAddr([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x68})
+	dhcpip3, err := d.dhcpLease(ctx, interfaceip, mac3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dhcpip3.Equal(dhcpip) {
+		t.Error("deleted address was not reused", dhcpip3)
+	}
+}
+
 func testDummyMAC(t *testing.T) {
 	t.Parallel()
 
@@ -367,6 +508,7 @@ func TestDHCP(t *testing.T) {
 	t.Run("Decline", testDHCPDecline)
 	t.Run("Expire", testDHCPLeaseExpiration)
 	t.Run("Race", testDHCPLeaseRace)
+	t.Run("Leases", testDHCPLeases)
 
 	t.Run("Generate Dummy MAC", testDummyMAC)
 }
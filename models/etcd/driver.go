@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/objstore"
 	"github.com/cybozu-go/well"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -21,10 +24,81 @@ type driver struct {
 	mi           *machinesIndex
 	ipamConfig   atomic.Value
 	dhcpConfig   atomic.Value
+	uploadMu     sync.Mutex
+	committing   map[string]bool
+	pullMu       sync.Mutex
+	pullFailures map[string]*sabakan.PullFailure
+	peerMu       sync.Mutex
+	peerAddrs    map[string]peerAddrs
+	reservations reservationCache
+	reportCh     chan struct{}
+	importCh     chan *importJob
+
+	// HTTP client to fetch sources of imports
+	importClient *http.Client
+
+	// imports are aborted if no data is received for this duration
+	importIdleTimeout time.Duration
+
+	// the maximum total size of assets; zero means unlimited
+	assetQuota int64
+
+	// AES-256 key to encrypt secrets; nil if not configured
+	secretKey []byte
+
+	// object storage for assets and images; nil if not configured
+	store         *objstore.Client
+	storeRedirect bool
+	cacheSize     int64
+}
+
+// Option is an optional configuration of the model.
+type Option func(*driver)
+
+// WithAssetQuota rejects assets that make the total size of assets exceed
+// quota bytes.
+func WithAssetQuota(quota int64) Option {
+	return func(d *driver) {
+		d.assetQuota = quota
+	}
+}
+
+// WithSecretKey encrypts secrets with key by AES-256-GCM.
+// key must be 32 bytes long.
+func WithSecretKey(key []byte) Option {
+	return func(d *driver) {
+		d.secretKey = key
+	}
+}
+
+// WithImportProxy fetches sources of imports through proxy instead of
+// proxies given by environment variables such as HTTPS_PROXY.
+func WithImportProxy(proxy *url.URL) Option {
+	return func(d *driver) {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.Proxy = http.ProxyURL(proxy)
+		d.importClient = &http.Client{Transport: tr}
+	}
+}
+
+// WithObjectStore stores assets and images in an S3-compatible object storage
+// instead of replicating them between sabakan servers.
+//
+// Local copies of assets are kept as a cache whose total size is limited to
+// cacheSize bytes.  Zero means unlimited.
+// If redirect is true, clients without a cached copy are redirected to
+// presigned URLs of the object storage.  Otherwise, assets are streamed
+// through the cache.
+func WithObjectStore(store *objstore.Client, redirect bool, cacheSize int64) Option {
+	return func(d *driver) {
+		d.store = store
+		d.storeRedirect = redirect
+		d.cacheSize = cacheSize
+	}
 }
 
 // NewModel returns sabakan.Model
-func NewModel(client *clientv3.Client, dataDir string, advertiseURL *url.URL) sabakan.Model {
+func NewModel(client *clientv3.Client, dataDir string, advertiseURL *url.URL, opts ...Option) sabakan.Model {
 	d := &driver{
 		client: client,
 		httpclient: &well.HTTPClient{
@@ -33,6 +107,14 @@ func NewModel(client *clientv3.Client, dataDir string, advertiseURL *url.URL) sa
 		dataDir:      dataDir,
 		advertiseURL: advertiseURL,
 		mi:           newMachinesIndex(),
+		reportCh:     make(chan struct{}, 1),
+		importCh:     make(chan *importJob),
+		importClient: &http.Client{},
+
+		importIdleTimeout: importIdleTimeout,
+	}
+	for _, o := range opts {
+		o(d)
 	}
 	return sabakan.Model{
 		Runner:       d,
@@ -44,9 +126,12 @@ func NewModel(client *clientv3.Client, dataDir string, advertiseURL *url.URL) sa
 		Asset:        assetDriver{d},
 		Log:          logDriver{d},
 		Ignition:     d,
+		Inventory:    inventoryDriver{d},
 		KernelParams: kernelParamsDriver{d},
+		Replication:  replicationDriver{d},
 		Health:       healthDriver{d},
 		Schema:       d,
+		Secret:       secretDriver{d},
 	}
 }
 
@@ -102,6 +187,15 @@ func (d *driver) Run(ctx context.Context, ch chan<- struct{}) error {
 		return d.startAssetUpdater(ctx, epCh)
 	})
 
+	// replica report
+	env.Go(d.startReplicaReporter)
+
+	// imports from external URLs
+	env.Go(d.startImporter)
+
+	// removal of expired assets
+	env.Go(d.startAssetExpirer)
+
 	// log compaction
 	env.Go(d.logCompactor)
 
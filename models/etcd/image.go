@@ -155,12 +155,24 @@ RETRY:
 	if err != nil {
 		return err
 	}
+	sums, err := dir.Checksums(id, imageMembers[os])
+	if err != nil {
+		return err
+	}
+
+	if d.store != nil {
+		err = d.storeImage(ctx, os, id)
+		if err != nil {
+			return err
+		}
+	}
 
 	index, dels := index.Append(&sabakan.Image{
-		ID:   id,
-		Date: time.Now().UTC(),
-		Size: size,
-		URLs: []string{d.myURL("/api/v1/images", os, id)},
+		ID:     id,
+		Date:   time.Now().UTC(),
+		Size:   size,
+		URLs:   []string{d.myURL("/api/v1/images", os, id)},
+		Sha256: sums,
 	})
 	deleted = append(deleted, dels...)
 	if len(deleted) > MaxDeleted {
@@ -178,6 +190,10 @@ RETRY:
 	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditImage, os, "upload",
 		"id="+id)
 
+	if d.store != nil {
+		d.removeImageObjects(ctx, os, dels)
+	}
+
 	return nil
 }
 
@@ -250,11 +266,15 @@ RETRY:
 	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditImage, os, "delete",
 		"id="+id)
 
+	if d.store != nil {
+		d.removeImageObjects(ctx, os, []string{id})
+	}
+
 	return nil
 }
 
 func (d *driver) imageServeFile(ctx context.Context, os, filename string,
-	f func(modtime time.Time, content io.ReadSeeker)) error {
+	f func(modtime time.Time, sha256 string, content io.ReadSeeker)) error {
 
 	index, err := d.imageGetIndex(ctx, os)
 	if err != nil {
@@ -265,6 +285,7 @@ func (d *driver) imageServeFile(ctx context.Context, os, filename string,
 	for i := len(index) - 1; i >= 0; i-- {
 		id := index[i].ID
 		date := index[i].Date
+		sum := index[i].Sha256[filename]
 		if !dir.Exists(id) {
 			log.Warn("imageServeFile: no local copy", map[string]interface{}{
 				"id": id,
@@ -273,7 +294,7 @@ func (d *driver) imageServeFile(ctx context.Context, os, filename string,
 		}
 
 		return dir.ServeFile(id, filename, func(content io.ReadSeeker) {
-			f(date, content)
+			f(date, sum, content)
 		})
 	}
 
@@ -305,6 +326,6 @@ func (d imageDriver) Delete(ctx context.Context, os, id string) error {
 }
 
 func (d imageDriver) ServeFile(ctx context.Context, os, filename string,
-	f func(modtime time.Time, content io.ReadSeeker)) error {
+	f func(modtime time.Time, sha256 string, content io.ReadSeeker)) error {
 	return d.imageServeFile(ctx, os, filename, f)
 }
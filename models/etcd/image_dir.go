@@ -3,6 +3,8 @@ package etcd
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -274,6 +276,20 @@ func (d ImageDir) GC(ids []string) error {
 	return nil
 }
 
+// Checksums returns SHA256 checksums of files in "id" directory.
+func (d ImageDir) Checksums(id string, members []string) (map[string]string, error) {
+	sums := make(map[string]string)
+	for _, m := range members {
+		h := sha256.New()
+		err := copyFile(h, filepath.Join(d.Dir, id, m))
+		if err != nil {
+			return nil, err
+		}
+		sums[m] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
+
 // Size returns byte size of the image
 func (d ImageDir) Size(id string) (int64, error) {
 	var imageSize int64
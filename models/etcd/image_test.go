@@ -199,6 +199,10 @@ func testImageUpload(t *testing.T) {
 	if index[0].ID != "1234.5" {
 		t.Error("ID mismatch", index[0].ID)
 	}
+	// echo -n abcd | sha256sum
+	if index[0].Sha256[sabakan.ImageKernelFilename] != "88d4266fd4e6338d13b845fcf289579d209c897823b9217da3e161936f031589" {
+		t.Error("wrong checksum of kernel", index[0].Sha256)
+	}
 
 	if !d.getImageDir("coreos").Exists("1234.5") {
 		t.Error("image is not stored")
@@ -461,7 +465,7 @@ func testImageServeFile(t *testing.T) {
 	testImagePutIndex(t, d, index, "coreos")
 
 	buf := new(bytes.Buffer)
-	f := func(mt time.Time, content io.ReadSeeker) {
+	f := func(mt time.Time, sum string, content io.ReadSeeker) {
 		buf.Reset()
 		io.Copy(buf, content)
 	}
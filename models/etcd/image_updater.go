@@ -3,6 +3,7 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"path"
 	"strings"
@@ -71,14 +72,35 @@ OUTER:
 			continue
 		}
 
+		if d.store != nil {
+			err = d.pullImage(ctx, os, img.ID)
+			if err != nil {
+				log.Error("failed to pull image from object storage", map[string]interface{}{
+					log.FnError: err,
+					"os":        os,
+					"id":        img.ID,
+				})
+				d.recordPullFailure(sabakan.ReplicaKindImage, os, img.ID, err)
+				continue
+			}
+			log.Info("image updater: pulled image from object storage", map[string]interface{}{
+				"os": os,
+				"id": img.ID,
+			})
+			d.clearPullFailure(sabakan.ReplicaKindImage, os, img.ID)
+			continue
+		}
+
 		urls := make([]string, len(img.URLs))
 		for i, v := range rand.Perm(len(urls)) {
 			urls[v] = img.URLs[i]
 		}
 
+		lastErr := errors.New("no URL to pull")
 		for _, u := range urls {
 			resp, err := d.pullURL(ctx, u)
 			if err != nil {
+				lastErr = err
 				continue
 			}
 
@@ -94,6 +116,7 @@ OUTER:
 				"id":  img.ID,
 				"url": u,
 			})
+			d.clearPullFailure(sabakan.ReplicaKindImage, os, img.ID)
 
 			if len(img.URLs) < maxImageURLs {
 				err = d.addImageURL(ctx, os, img.ID)
@@ -105,10 +128,12 @@ OUTER:
 		}
 
 		log.Error("failed to pull image", map[string]interface{}{
-			"os":   os,
-			"id":   img.ID,
-			"urls": img.URLs,
+			log.FnError: lastErr,
+			"os":        os,
+			"id":        img.ID,
+			"urls":      img.URLs,
 		})
+		d.recordPullFailure(sabakan.ReplicaKindImage, os, img.ID, lastErr)
 	}
 
 	return nil
@@ -154,6 +179,7 @@ func (d *driver) updateImage(ctx context.Context) error {
 		}
 	}
 
+	d.notifyReport()
 	return nil
 }
 
@@ -0,0 +1,462 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/well"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+/*
+Sources of imports are fetched by the server that received the request,
+and stored temporarily in /var/lib/sabakan/imports.
+
+The status of a running import is bound to the lease of a session of the
+server, so it disappears if the server stops.  The status of a finished
+import is kept for importStatusTTL.
+
+An import is canceled by marking its status as failed.  The server running
+the import watches the status and aborts the fetch.
+*/
+
+var (
+	errImportCanceled = errors.New("import canceled")
+	errImportStalled  = errors.New("no data received from the source")
+	errImportTooLarge = fmt.Errorf("source is larger than %d bytes", sabakan.MaxAssetSize)
+)
+
+func keyAssetImport(name string) string {
+	return KeyImports + "assets/" + name
+}
+
+func keyImageImport(os, id string) string {
+	return KeyImports + "images/" + os + "/" + id
+}
+
+// importCommitFunc stores the fetched source as an asset or an image.
+// It returns the ID of the stored asset or image.
+type importCommitFunc func(ctx context.Context, r io.Reader, contentType string) (string, error)
+
+type importJob struct {
+	key    string
+	rev    int64
+	status *sabakan.ImportStatus
+	csum   []byte
+	sess   *concurrency.Session
+	commit importCommitFunc
+}
+
+func (d *driver) newImportStatus(kind, name, id, src string, csum []byte) *sabakan.ImportStatus {
+	now := time.Now().UTC()
+	return &sabakan.ImportStatus{
+		Kind:    kind,
+		Name:    name,
+		ID:      id,
+		Source:  src,
+		Sha256:  hex.EncodeToString(csum),
+		Server:  d.myURL(),
+		State:   sabakan.ImportRunning,
+		Started: now,
+		Updated: now,
+	}
+}
+
+// startImport records the status of a new import and passes it to the importer.
+func (d *driver) startImport(ctx context.Context, job *importJob) (*sabakan.ImportStatus, error) {
+	resp, err := d.client.Get(ctx, job.key)
+	if err != nil {
+		return nil, err
+	}
+
+	ifop := clientv3util.KeyMissing(job.key)
+	if resp.Count != 0 {
+		prev := new(sabakan.ImportStatus)
+		err = json.Unmarshal(resp.Kvs[0].Value, prev)
+		if err != nil {
+			return nil, err
+		}
+		if prev.State == sabakan.ImportRunning {
+			return nil, sabakan.ErrConflicted
+		}
+		ifop = clientv3.Compare(clientv3.ModRevision(job.key), "=", resp.Kvs[0].ModRevision)
+	}
+
+	data, err := json.Marshal(job.status)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := concurrency.NewSession(d.client, concurrency.WithTTL(importSessionTTL))
+	if err != nil {
+		return nil, err
+	}
+	tresp, err := d.client.Txn(ctx).
+		If(ifop).
+		Then(clientv3.OpPut(job.key, string(data), clientv3.WithLease(sess.Lease()))).
+		Commit()
+	if err != nil {
+		sess.Close()
+		return nil, err
+	}
+	if !tresp.Succeeded {
+		sess.Close()
+		return nil, sabakan.ErrConflicted
+	}
+	job.sess = sess
+	job.rev = tresp.Header.Revision
+	// the importer updates job.status concurrently
+	status := *job.status
+
+	select {
+	case d.importCh <- job:
+	case <-ctx.Done():
+		// closing the session removes the status
+		sess.Close()
+		return nil, ctx.Err()
+	}
+
+	category := sabakan.AuditAssets
+	if status.Kind == sabakan.ReplicaKindImage {
+		category = sabakan.AuditImage
+	}
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, category, status.Name, "import",
+		"from="+status.Source)
+
+	return &status, nil
+}
+
+func (d *driver) putImportStatus(ctx context.Context, key string, status *sabakan.ImportStatus, lease clientv3.LeaseID) error {
+	status.Updated = time.Now().UTC()
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.Put(ctx, key, string(data), clientv3.WithLease(lease))
+	return err
+}
+
+// importReader updates the progress of an import while reading the source.
+type importReader struct {
+	io.Reader
+	ctx  context.Context
+	d    *driver
+	job  *importJob
+	idle *time.Timer
+	last time.Time
+}
+
+func (r *importReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.job.status.Received += int64(n)
+	if n > 0 {
+		r.idle.Reset(r.d.importIdleTimeout)
+	}
+	if time.Since(r.last) < importProgressInterval {
+		return n, err
+	}
+
+	r.last = time.Now()
+	perr := r.d.putImportStatus(r.ctx, r.job.key, r.job.status, r.job.sess.Lease())
+	if perr != nil {
+		log.Warn("import: failed to update the progress", map[string]interface{}{
+			log.FnError: perr,
+			"key":       r.job.key,
+		})
+	}
+	return n, err
+}
+
+// fetchImport fetches the source and passes it to job.commit.
+// If no data is received for d.importIdleTimeout, the fetch is aborted
+// by calling cancel.
+func (d *driver) fetchImport(ctx context.Context, job *importJob, cancel context.CancelCauseFunc) (string, error) {
+	idle := time.AfterFunc(d.importIdleTimeout, func() {
+		cancel(errImportStalled)
+	})
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", job.status.Source, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := d.importClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from %s: %s", job.status.Source, resp.Status)
+	}
+	if resp.ContentLength > sabakan.MaxAssetSize {
+		return "", errImportTooLarge
+	}
+	if resp.ContentLength > 0 {
+		job.status.Size = resp.ContentLength
+	}
+
+	dir := filepath.Join(d.dataDir, "imports")
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, ".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), &importReader{
+		Reader: io.LimitReader(resp.Body, sabakan.MaxAssetSize+1),
+		ctx:    ctx,
+		d:      d,
+		job:    job,
+		idle:   idle,
+		last:   time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if job.status.Received > sabakan.MaxAssetSize {
+		return "", errImportTooLarge
+	}
+	// storing the fetched source may take long
+	idle.Stop()
+	hsum := h.Sum(nil)
+	if !bytes.Equal(hsum, job.csum) {
+		return "", fmt.Errorf("%w: got %s", sabakan.ErrChecksumMismatch, hex.EncodeToString(hsum))
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return job.commit(ctx, f, contentType)
+}
+
+func (d *driver) runImport(ctx context.Context, job *importJob) {
+	defer job.sess.Close()
+
+	st := job.status
+	fctx, cancel := context.WithCancelCause(ctx)
+	go d.watchImportCancel(fctx, job, cancel)
+	id, err := d.fetchImport(fctx, job, cancel)
+	if cause := context.Cause(fctx); err != nil && cause != nil {
+		err = cause
+	}
+	cancel(nil)
+
+	fields := map[string]interface{}{
+		"kind":   st.Kind,
+		"name":   st.Name,
+		"source": st.Source,
+	}
+	if err != nil {
+		st.State = sabakan.ImportFailed
+		st.Error = err.Error()
+		fields[log.FnError] = err
+		log.Error("import: failed", fields)
+	} else {
+		st.State = sabakan.ImportCompleted
+		st.ID = id
+		fields["id"] = id
+		log.Info("import: completed", fields)
+	}
+
+	// keep the result after the session is closed
+	lresp, err := d.client.Grant(ctx, importStatusTTL)
+	if err == nil {
+		err = d.putImportStatus(ctx, job.key, st, lresp.ID)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Error("import: failed to record the result", map[string]interface{}{
+			log.FnError: err,
+			"key":       job.key,
+		})
+	}
+}
+
+// watchImportCancel calls cancel when the status of the import is marked
+// as finished by cancelImport.
+func (d *driver) watchImportCancel(ctx context.Context, job *importJob, cancel context.CancelCauseFunc) {
+	ch := d.client.Watch(ctx, job.key, clientv3.WithRev(job.rev+1), clientv3.WithFilterDelete())
+	for resp := range ch {
+		for _, ev := range resp.Events {
+			st := new(sabakan.ImportStatus)
+			err := json.Unmarshal(ev.Kv.Value, st)
+			if err != nil {
+				continue
+			}
+			if st.State != sabakan.ImportRunning {
+				cancel(errImportCanceled)
+				return
+			}
+		}
+	}
+}
+
+func (d *driver) startImporter(ctx context.Context) error {
+	env := well.NewEnvironment(ctx)
+	for {
+		select {
+		case job := <-d.importCh:
+			env.Go(func(ctx context.Context) error {
+				d.runImport(ctx, job)
+				return nil
+			})
+		case <-ctx.Done():
+			env.Stop()
+			return env.Wait()
+		}
+	}
+}
+
+func (d *driver) getImport(ctx context.Context, key string) (*sabakan.ImportStatus, error) {
+	resp, err := d.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+
+	st := new(sabakan.ImportStatus)
+	err = json.Unmarshal(resp.Kvs[0].Value, st)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// cancelImport marks the running import as failed.
+func (d *driver) cancelImport(ctx context.Context, key string) error {
+	var lease clientv3.LeaseID
+
+RETRY:
+	resp, err := d.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if resp.Count == 0 {
+		return sabakan.ErrNotFound
+	}
+
+	st := new(sabakan.ImportStatus)
+	err = json.Unmarshal(resp.Kvs[0].Value, st)
+	if err != nil {
+		return err
+	}
+	if st.State != sabakan.ImportRunning {
+		return sabakan.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	st.State = sabakan.ImportFailed
+	st.Error = errImportCanceled.Error()
+	st.Updated = now
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	// keep the result after the session of the importer is closed
+	if lease == 0 {
+		lresp, err := d.client.Grant(ctx, importStatusTTL)
+		if err != nil {
+			return err
+		}
+		lease = lresp.ID
+	}
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+		Then(clientv3.OpPut(key, string(data), clientv3.WithLease(lease))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	category := sabakan.AuditAssets
+	if st.Kind == sabakan.ReplicaKindImage {
+		category = sabakan.AuditImage
+	}
+	d.addLog(ctx, now, tresp.Header.Revision, category, st.Name, "import-cancel", "")
+
+	return nil
+}
+
+func (d *driver) assetImport(ctx context.Context, name, src string, csum []byte, options map[string]string) (*sabakan.ImportStatus, error) {
+	return d.startImport(ctx, &importJob{
+		key:    keyAssetImport(name),
+		status: d.newImportStatus(sabakan.ReplicaKindAsset, name, "", src, csum),
+		csum:   csum,
+		commit: func(ctx context.Context, r io.Reader, contentType string) (string, error) {
+			status, err := d.assetPut(ctx, name, contentType, csum, options, r)
+			if err != nil {
+				return "", err
+			}
+			return strconv.Itoa(status.ID), nil
+		},
+	})
+}
+
+func (d *driver) imageImport(ctx context.Context, os, id, src string, csum []byte) (*sabakan.ImportStatus, error) {
+	return d.startImport(ctx, &importJob{
+		key:    keyImageImport(os, id),
+		status: d.newImportStatus(sabakan.ReplicaKindImage, os, id, src, csum),
+		csum:   csum,
+		commit: func(ctx context.Context, r io.Reader, contentType string) (string, error) {
+			return id, d.imageUpload(ctx, os, id, r)
+		},
+	})
+}
+
+func (d assetDriver) Import(ctx context.Context, name, src string, csum []byte, options map[string]string) (*sabakan.ImportStatus, error) {
+	return d.assetImport(ctx, name, src, csum, options)
+}
+
+func (d assetDriver) GetImport(ctx context.Context, name string) (*sabakan.ImportStatus, error) {
+	return d.getImport(ctx, keyAssetImport(name))
+}
+
+func (d assetDriver) CancelImport(ctx context.Context, name string) error {
+	return d.cancelImport(ctx, keyAssetImport(name))
+}
+
+func (d imageDriver) Import(ctx context.Context, os, id, src string, csum []byte) (*sabakan.ImportStatus, error) {
+	return d.imageImport(ctx, os, id, src, csum)
+}
+
+func (d imageDriver) GetImport(ctx context.Context, os, id string) (*sabakan.ImportStatus, error) {
+	return d.getImport(ctx, keyImageImport(os, id))
+}
+
+func (d imageDriver) CancelImport(ctx context.Context, os, id string) error {
+	return d.cancelImport(ctx, keyImageImport(os, id))
+}
@@ -0,0 +1,345 @@
+package etcd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testNewImportDriver(t *testing.T) *driver {
+	d := testNewAssetDriver(t)
+	d.importCh = make(chan *importJob)
+	d.importClient = &http.Client{}
+	d.importIdleTimeout = importIdleTimeout
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.startImporter(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d
+}
+
+func newTestSourceServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-test")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func waitImport(t *testing.T, d *driver, key string) *sabakan.ImportStatus {
+	for i := 0; i < 100; i++ {
+		st, err := d.getImport(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.State != sabakan.ImportRunning {
+			return st
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("import did not finish")
+	return nil
+}
+
+func testImportAsset(t *testing.T) {
+	t.Parallel()
+
+	d := testNewImportDriver(t)
+	srv := newTestSourceServer(t, map[string][]byte{"/foo": []byte("0123456789")})
+	ctx := context.Background()
+
+	_, err := assetDriver{d}.GetImport(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+
+	st, err := d.assetImport(ctx, "foo", srv.URL+"/foo", testSum("0123456789"), map[string]string{"version": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != sabakan.ImportRunning || st.Kind != sabakan.ReplicaKindAsset || st.Server != "http://localhost:10080" {
+		t.Error("wrong status:", st)
+	}
+
+	st = waitImport(t, d, keyAssetImport("foo"))
+	if st.State != sabakan.ImportCompleted || st.Received != 10 || st.Size != 10 || st.ID == "" {
+		t.Error("wrong status:", st)
+	}
+	asset, err := d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ContentType != "application/x-test" || asset.Size != 10 || asset.Options["version"] != "1" {
+		t.Error("wrong asset:", asset)
+	}
+	h := new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "0123456789" {
+		t.Error("wrong content:", string(h.content))
+	}
+
+	// checksum mismatch
+	_, err = d.assetImport(ctx, "bar", srv.URL+"/foo", testSum("abc"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = waitImport(t, d, keyAssetImport("bar"))
+	if st.State != sabakan.ImportFailed {
+		t.Error("import should fail:", st)
+	}
+	_, err = d.assetGetInfo(ctx, "bar")
+	if err != sabakan.ErrNotFound {
+		t.Error("failed import should not create the asset:", err)
+	}
+
+	// missing source
+	_, err = d.assetImport(ctx, "bar", srv.URL+"/bar", testSum("abc"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = waitImport(t, d, keyAssetImport("bar"))
+	if st.State != sabakan.ImportFailed || st.Error == "" {
+		t.Error("import should fail:", st)
+	}
+
+	fil, err := os.ReadDir(filepath.Join(d.dataDir, "imports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fil) != 0 {
+		t.Error("temporary files remain:", len(fil))
+	}
+}
+
+func testImportConflict(t *testing.T) {
+	t.Parallel()
+
+	d := testNewImportDriver(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("56789"))
+	}))
+	defer srv.Close()
+	defer close(release)
+	ctx := context.Background()
+
+	_, err := d.assetImport(ctx, "foo", srv.URL, testSum("0123456789"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.assetImport(ctx, "foo", srv.URL, testSum("0123456789"), nil)
+	if err != sabakan.ErrConflicted {
+		t.Error("err != sabakan.ErrConflicted:", err)
+	}
+}
+
+func testImportCancel(t *testing.T) {
+	t.Parallel()
+
+	d := testNewImportDriver(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	ctx := context.Background()
+
+	err := assetDriver{d}.CancelImport(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+
+	_, err = d.assetImport(ctx, "foo", srv.URL, testSum("0123456789"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = assetDriver{d}.CancelImport(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := d.getImport(ctx, keyAssetImport("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != sabakan.ImportFailed || st.Error != errImportCanceled.Error() {
+		t.Error("import should be canceled:", st)
+	}
+	err = assetDriver{d}.CancelImport(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("finished import should not be canceled:", err)
+	}
+
+	// the importer records its result
+	for i := 0; i < 100; i++ {
+		fil, err := os.ReadDir(filepath.Join(d.dataDir, "imports"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fil) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	st, err = d.getImport(ctx, keyAssetImport("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != sabakan.ImportFailed || st.Error != errImportCanceled.Error() {
+		t.Error("import should be canceled:", st)
+	}
+	_, err = d.assetGetInfo(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("canceled import should not create the asset:", err)
+	}
+}
+
+func testImportStalled(t *testing.T) {
+	t.Parallel()
+
+	d := testNewImportDriver(t)
+	d.importIdleTimeout = 500 * time.Millisecond
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Header().Set("Content-Length", strconv.FormatInt(sabakan.MaxAssetSize+1, 10))
+			return
+		}
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	ctx := context.Background()
+
+	_, err := d.assetImport(ctx, "foo", srv.URL, testSum("0123456789"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := waitImport(t, d, keyAssetImport("foo"))
+	if st.State != sabakan.ImportFailed || st.Error != errImportStalled.Error() || st.Received != 5 {
+		t.Error("stalled import should fail:", st)
+	}
+
+	_, err = d.assetImport(ctx, "bar", srv.URL+"/large", testSum("0123456789"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = waitImport(t, d, keyAssetImport("bar"))
+	if st.State != sabakan.ImportFailed || st.Error != errImportTooLarge.Error() {
+		t.Error("too large import should fail:", st)
+	}
+}
+
+func testImportImage(t *testing.T) {
+	t.Parallel()
+
+	d := testNewImportDriver(t)
+	archive, err := io.ReadAll(newTestImage("abcd", "efg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestSourceServer(t, map[string][]byte{"/image.tar": archive})
+	ctx := context.Background()
+
+	_, err = d.imageImport(ctx, "coreos", "1234.5", srv.URL+"/image.tar", testSum(string(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := waitImport(t, d, keyImageImport("coreos", "1234.5"))
+	if st.State != sabakan.ImportCompleted || st.ID != "1234.5" || st.Kind != sabakan.ReplicaKindImage {
+		t.Error("wrong status:", st)
+	}
+
+	index, err := d.imageGetIndex(ctx, "coreos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Find("1234.5") == nil {
+		t.Error("image was not imported:", index)
+	}
+	kernel, err := os.ReadFile(filepath.Join(d.getImageDir("coreos").Dir, "1234.5", sabakan.ImageKernelFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kernel) != "abcd" {
+		t.Error("wrong kernel:", string(kernel))
+	}
+}
+
+func testImportProxy(t *testing.T) {
+	t.Parallel()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("foo"))
+	}))
+	defer proxy.Close()
+
+	d := testNewImportDriver(t)
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithImportProxy(u)(d)
+
+	_, err = d.assetImport(context.Background(), "foo", "http://example.invalid/foo", testSum("foo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := waitImport(t, d, keyAssetImport("foo"))
+	if st.State != sabakan.ImportCompleted {
+		t.Error("import failed:", st)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://example.invalid/foo" {
+		t.Error("source was not fetched through the proxy:", proxied)
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Run("Asset", testImportAsset)
+	t.Run("Conflict", testImportConflict)
+	t.Run("Cancel", testImportCancel)
+	t.Run("Stalled", testImportStalled)
+	t.Run("Image", testImportImage)
+	t.Run("Proxy", testImportProxy)
+}
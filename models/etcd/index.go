@@ -17,25 +17,31 @@ const (
 
 // machinesIndex is on-memory index of the etcd values
 type machinesIndex struct {
-	mux     sync.RWMutex
-	Rack    map[string][]string
-	Role    map[string][]string
-	Labels  map[string][]string
-	IPv4    map[string]string
-	IPv6    map[string]string
-	BMCType map[string][]string
-	State   map[sabakan.MachineState][]string
+	mux       sync.RWMutex
+	Serials   map[string]struct{}
+	Rack      map[string][]string
+	Role      map[string][]string
+	Labels    map[string][]string
+	LabelKeys map[string][]string
+	IPv4      map[string]string
+	IPv6      map[string]string
+	MAC       map[string]string
+	BMCType   map[string][]string
+	State     map[sabakan.MachineState][]string
 }
 
 func newMachinesIndex() *machinesIndex {
 	return &machinesIndex{
-		Rack:    make(map[string][]string),
-		Role:    make(map[string][]string),
-		Labels:  make(map[string][]string),
-		IPv4:    make(map[string]string),
-		IPv6:    make(map[string]string),
-		BMCType: make(map[string][]string),
-		State:   make(map[sabakan.MachineState][]string),
+		Serials:   make(map[string]struct{}),
+		Rack:      make(map[string][]string),
+		Role:      make(map[string][]string),
+		Labels:    make(map[string][]string),
+		LabelKeys: make(map[string][]string),
+		IPv4:      make(map[string]string),
+		IPv6:      make(map[string]string),
+		MAC:       make(map[string]string),
+		BMCType:   make(map[string][]string),
+		State:     make(map[sabakan.MachineState][]string),
 	}
 }
 
@@ -74,6 +80,7 @@ func (mi *machinesIndex) AddIndex(m *sabakan.Machine) {
 
 func (mi *machinesIndex) addNoLock(m *sabakan.Machine) {
 	spec := &m.Spec
+	mi.Serials[spec.Serial] = struct{}{}
 	mcrack := fmt.Sprint(spec.Rack)
 	mi.Rack[mcrack] = append(mi.Rack[mcrack], spec.Serial)
 	mi.Role[spec.Role] = append(mi.Role[spec.Role], spec.Serial)
@@ -84,6 +91,9 @@ func (mi *machinesIndex) addNoLock(m *sabakan.Machine) {
 	for _, ip := range spec.IPv6 {
 		mi.IPv6[ip] = spec.Serial
 	}
+	for _, mac := range spec.MACs {
+		mi.MAC[mac] = spec.Serial
+	}
 	if len(spec.BMC.IPv4) > 0 {
 		mi.IPv4[spec.BMC.IPv4] = spec.Serial
 	}
@@ -94,6 +104,7 @@ func (mi *machinesIndex) addNoLock(m *sabakan.Machine) {
 	for k, v := range spec.Labels {
 		labelKey := k + labelSep + v
 		mi.Labels[labelKey] = append(mi.Labels[labelKey], spec.Serial)
+		mi.LabelKeys[k] = append(mi.LabelKeys[k], spec.Serial)
 	}
 }
 
@@ -114,6 +125,7 @@ func (mi *machinesIndex) DeleteIndex(m *sabakan.Machine) {
 
 func (mi *machinesIndex) deleteNoLock(m *sabakan.Machine) {
 	spec := &m.Spec
+	delete(mi.Serials, spec.Serial)
 	mcrack := fmt.Sprint(spec.Rack)
 	i := indexOf(mi.Rack[mcrack], spec.Serial)
 	mi.Rack[mcrack] = append(mi.Rack[mcrack][:i], mi.Rack[mcrack][i+1:]...)
@@ -127,6 +139,9 @@ func (mi *machinesIndex) deleteNoLock(m *sabakan.Machine) {
 	for _, ip := range spec.IPv6 {
 		delete(mi.IPv6, ip)
 	}
+	for _, mac := range spec.MACs {
+		delete(mi.MAC, mac)
+	}
 	delete(mi.IPv4, spec.BMC.IPv4)
 	delete(mi.IPv6, spec.BMC.IPv6)
 
@@ -136,6 +151,8 @@ func (mi *machinesIndex) deleteNoLock(m *sabakan.Machine) {
 		labelKey := k + labelSep + v
 		i = indexOf(mi.Labels[labelKey], spec.Serial)
 		mi.Labels[labelKey] = append(mi.Labels[labelKey][:i], mi.Labels[labelKey][i+1:]...)
+		i = indexOf(mi.LabelKeys[k], spec.Serial)
+		mi.LabelKeys[k] = append(mi.LabelKeys[k][:i], mi.LabelKeys[k][i+1:]...)
 	}
 }
 
@@ -147,18 +164,29 @@ func (mi *machinesIndex) UpdateIndex(prevM *sabakan.Machine, newM *sabakan.Machi
 	mi.mux.Unlock()
 }
 
-func (mi *machinesIndex) query(q sabakan.Query) []string {
+func (mi *machinesIndex) query(q sabakan.Query) ([]string, error) {
+	sel, err := q.LabelSelector()
+	if err != nil {
+		return nil, err
+	}
+
 	mi.mux.RLock()
 	defer mi.mux.RUnlock()
 
 	res := make(map[string]struct{})
 
 	for _, rack := range strings.Split(q.Rack(), ",") {
+		if len(rack) == 0 {
+			continue
+		}
 		for _, serial := range mi.Rack[rack] {
 			res[serial] = struct{}{}
 		}
 	}
 	for _, role := range strings.Split(q.Role(), ",") {
+		if len(role) == 0 {
+			continue
+		}
 		for _, serial := range mi.Role[role] {
 			res[serial] = struct{}{}
 		}
@@ -173,18 +201,36 @@ func (mi *machinesIndex) query(q sabakan.Query) []string {
 			res[serial] = struct{}{}
 		}
 	}
+	for _, mac := range strings.Split(q.MAC(), ",") {
+		if len(mac) == 0 {
+			continue
+		}
+		hwaddr, err := sabakan.NormalizeMAC(mac)
+		if err != nil {
+			return nil, err
+		}
+		if serial, ok := mi.MAC[hwaddr]; ok {
+			res[serial] = struct{}{}
+		}
+	}
 	for _, bmcType := range strings.Split(q.BMCType(), ",") {
+		if len(bmcType) == 0 {
+			continue
+		}
 		for _, serial := range mi.BMCType[bmcType] {
 			res[serial] = struct{}{}
 		}
 	}
 	for _, state := range strings.Split(q.State(), ",") {
+		if len(state) == 0 {
+			continue
+		}
 		for _, serial := range mi.State[sabakan.MachineState(state)] {
 			res[serial] = struct{}{}
 		}
 	}
-	for _, labelKey := range q.Labels() {
-		for _, serial := range mi.Labels[labelKey] {
+	if len(sel) > 0 {
+		for serial := range mi.selectLabels(sel) {
 			res[serial] = struct{}{}
 		}
 	}
@@ -193,7 +239,70 @@ func (mi *machinesIndex) query(q sabakan.Query) []string {
 	for serial := range res {
 		serials = append(serials, serial)
 	}
-	return serials
+	return serials, nil
+}
+
+// macOwner returns the serial of the machine to which mac is bound.
+func (mi *machinesIndex) macOwner(mac string) (string, bool) {
+	mi.mux.RLock()
+	defer mi.mux.RUnlock()
+
+	serial, ok := mi.MAC[mac]
+	return serial, ok
+}
+
+// selectLabels returns serials of machines whose labels satisfy sel.
+// The caller must hold mi.mux.
+func (mi *machinesIndex) selectLabels(sel sabakan.LabelSelector) map[string]struct{} {
+	var res map[string]struct{}
+	for _, r := range sel {
+		matched := make(map[string]struct{})
+		switch r.Operator {
+		case sabakan.LabelOpEquals, sabakan.LabelOpIn:
+			for _, v := range r.Values {
+				for _, serial := range mi.Labels[r.Key+labelSep+v] {
+					matched[serial] = struct{}{}
+				}
+			}
+		case sabakan.LabelOpExists:
+			for _, serial := range mi.LabelKeys[r.Key] {
+				matched[serial] = struct{}{}
+			}
+		case sabakan.LabelOpNotEquals, sabakan.LabelOpNotIn:
+			excluded := make(map[string]struct{})
+			for _, v := range r.Values {
+				for _, serial := range mi.Labels[r.Key+labelSep+v] {
+					excluded[serial] = struct{}{}
+				}
+			}
+			for serial := range mi.Serials {
+				if _, ok := excluded[serial]; !ok {
+					matched[serial] = struct{}{}
+				}
+			}
+		case sabakan.LabelOpDoesNotExist:
+			excluded := make(map[string]struct{})
+			for _, serial := range mi.LabelKeys[r.Key] {
+				excluded[serial] = struct{}{}
+			}
+			for serial := range mi.Serials {
+				if _, ok := excluded[serial]; !ok {
+					matched[serial] = struct{}{}
+				}
+			}
+		}
+
+		if res == nil {
+			res = matched
+			continue
+		}
+		for serial := range res {
+			if _, ok := matched[serial]; !ok {
+				delete(res, serial)
+			}
+		}
+	}
+	return res
 }
 
 func decodeMachine(val []byte) (*sabakan.Machine, error) {
@@ -1,6 +1,7 @@
 package etcd
 
 import (
+	"reflect"
 	"sort"
 	"testing"
 
@@ -22,7 +23,10 @@ func TestMachinesIndex(t *testing.T) {
 		mi.AddIndex(m)
 	}
 
-	serials := mi.query(sabakan.Query{"labels": "product=R730xd"})
+	serials, err := mi.query(sabakan.Query{"labels": "product=R730xd"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(serials) != 1 {
 		t.Fatal("wrong query count:", len(serials))
 	}
@@ -54,7 +58,10 @@ func TestMachinesIndex(t *testing.T) {
 
 	mi.UpdateIndex(prev, current)
 
-	serials = mi.query(sabakan.Query{"labels": "product=R730xd"})
+	serials, err = mi.query(sabakan.Query{"labels": "product=R730xd"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(serials) != 2 {
 		t.Fatal("wrong query count:", len(serials))
 	}
@@ -63,7 +70,10 @@ func TestMachinesIndex(t *testing.T) {
 		t.Error("wrong query serials:", serials)
 	}
 
-	serials = mi.query(sabakan.Query{"state": "retiring"})
+	serials, err = mi.query(sabakan.Query{"state": "retiring"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(serials) != 1 {
 		t.Fatal("wrong query count:", len(serials))
 	}
@@ -79,7 +89,10 @@ func TestMachinesIndex(t *testing.T) {
 			BMC:  sabakan.MachineBMC{Type: "IPMI-2.0"},
 		}))
 
-	serials = mi.query(sabakan.Query{"labels": "product=R730xd"})
+	serials, err = mi.query(sabakan.Query{"labels": "product=R730xd"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(serials) != 1 {
 		t.Fatal("wrong query count:", len(serials))
 	}
@@ -87,3 +100,63 @@ func TestMachinesIndex(t *testing.T) {
 		t.Error("wrong query serial:", serials[0])
 	}
 }
+
+func TestMachinesIndexLabelSelector(t *testing.T) {
+	t.Parallel()
+
+	mi := newMachinesIndex()
+
+	machines := []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1", Labels: map[string]string{"product": "R630", "datacenter": "ty3"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "2", Labels: map[string]string{"product": "R730xd", "datacenter": "ty3", "gpu": "a100"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "3", Labels: map[string]string{"product": "R740", "datacenter": "ty4"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "4"}),
+	}
+	for _, m := range machines {
+		mi.AddIndex(m)
+	}
+
+	cases := []struct {
+		labels  string
+		serials []string
+	}{
+		{"product=R630", []string{"1"}},
+		{"product!=R630", []string{"2", "3", "4"}},
+		{"product in (R630,R740)", []string{"1", "3"}},
+		{"product notin (R630,R740)", []string{"2", "4"}},
+		{"gpu", []string{"2"}},
+		{"!gpu", []string{"1", "3", "4"}},
+		{"datacenter=ty3,!gpu", []string{"1"}},
+		{"datacenter in (ty3,ty4),product!=R740", []string{"1", "2"}},
+		{"rack=1", nil},
+	}
+
+	for _, c := range cases {
+		serials, err := mi.query(sabakan.Query{"labels": c.labels})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(serials)
+		if len(serials) == 0 && len(c.serials) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(serials, c.serials) {
+			t.Errorf("wrong query serials for %s: %v", c.labels, serials)
+		}
+	}
+
+	mi.DeleteIndex(machines[1])
+	serials, err := mi.query(sabakan.Query{"labels": "!gpu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(serials)
+	if !reflect.DeepEqual(serials, []string{"1", "3", "4"}) {
+		t.Error("wrong query serials after delete:", serials)
+	}
+
+	_, err = mi.query(sabakan.Query{"labels": "product in (R630"})
+	if err == nil {
+		t.Error("invalid selector should be rejected")
+	}
+}
@@ -0,0 +1,183 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+func keyInventoryHistoryPrefix(serial string) string {
+	return KeyInventoryHistory + serial + "/"
+}
+
+func keyInventoryHistory(serial string, version int64) string {
+	return fmt.Sprintf("%s%020d", keyInventoryHistoryPrefix(serial), version)
+}
+
+func (d *driver) inventoryGetWithRev(ctx context.Context, serial string) (*sabakan.Inventory, int64, error) {
+	resp, err := d.client.Get(ctx, KeyInventories+serial)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Count == 0 {
+		return nil, 0, nil
+	}
+
+	inv := new(sabakan.Inventory)
+	err = json.Unmarshal(resp.Kvs[0].Value, inv)
+	if err != nil {
+		return nil, 0, err
+	}
+	return inv, resp.Kvs[0].ModRevision, nil
+}
+
+func (d *driver) inventoryPut(ctx context.Context, serial string, inv *sabakan.Inventory) (*sabakan.Inventory, error) {
+	key := KeyInventories + serial
+	machineKey := KeyMachines + serial
+
+RETRY:
+	prev, rev, err := d.inventoryGetWithRev(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := sabakan.DiffInventory(prev, inv)
+	if prev != nil && len(diffs) == 0 {
+		return prev, nil
+	}
+
+	newInv := *inv
+	newInv.Serial = serial
+	newInv.Version = 1
+	if prev != nil {
+		newInv.Version = prev.Version + 1
+	}
+	newInv.Timestamp = time.Now().UTC()
+
+	data, err := json.Marshal(newInv)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []clientv3.Op{
+		clientv3.OpPut(key, string(data)),
+		clientv3.OpPut(keyInventoryHistory(serial, newInv.Version), string(data)),
+	}
+	if newInv.Version > MaxInventories {
+		ops = append(ops, clientv3.OpDelete(keyInventoryHistory(serial, 0),
+			clientv3.WithRange(keyInventoryHistory(serial, newInv.Version-MaxInventories+1))))
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(
+			clientv3util.KeyExists(machineKey),
+			clientv3.Compare(clientv3.ModRevision(key), "=", rev),
+		).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !tresp.Succeeded {
+		resp, err := d.client.Get(ctx, machineKey, clientv3.WithCountOnly())
+		if err != nil {
+			return nil, err
+		}
+		if resp.Count == 0 {
+			return nil, sabakan.ErrNotFound
+		}
+		goto RETRY
+	}
+
+	action := "update"
+	if prev == nil {
+		action = "put"
+	}
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditInventory, serial,
+		action, strings.Join(diffs, "\n"))
+	return &newInv, nil
+}
+
+func (d *driver) inventoryGet(ctx context.Context, serial string) (*sabakan.Inventory, error) {
+	inv, _, err := d.inventoryGetWithRev(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil {
+		return nil, sabakan.ErrNotFound
+	}
+	return inv, nil
+}
+
+func (d *driver) inventoryGetHistory(ctx context.Context, serial string) ([]*sabakan.Inventory, error) {
+	resp, err := d.client.Get(ctx, keyInventoryHistoryPrefix(serial),
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+
+	history := make([]*sabakan.Inventory, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		inv := new(sabakan.Inventory)
+		err = json.Unmarshal(kv.Value, inv)
+		if err != nil {
+			return nil, err
+		}
+		history[i] = inv
+	}
+	return history, nil
+}
+
+func (d *driver) inventoryGetAll(ctx context.Context) ([]*sabakan.Inventory, error) {
+	resp, err := d.client.Get(ctx, KeyInventories,
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+
+	inventories := make([]*sabakan.Inventory, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		inv := new(sabakan.Inventory)
+		err = json.Unmarshal(kv.Value, inv)
+		if err != nil {
+			return nil, err
+		}
+		inventories[i] = inv
+	}
+	return inventories, nil
+}
+
+type inventoryDriver struct {
+	*driver
+}
+
+// Put implements sabakan.InventoryModel
+func (d inventoryDriver) Put(ctx context.Context, serial string, inv *sabakan.Inventory) (*sabakan.Inventory, error) {
+	return d.inventoryPut(ctx, serial, inv)
+}
+
+// Get implements sabakan.InventoryModel
+func (d inventoryDriver) Get(ctx context.Context, serial string) (*sabakan.Inventory, error) {
+	return d.inventoryGet(ctx, serial)
+}
+
+// GetHistory implements sabakan.InventoryModel
+func (d inventoryDriver) GetHistory(ctx context.Context, serial string) ([]*sabakan.Inventory, error) {
+	return d.inventoryGetHistory(ctx, serial)
+}
+
+// GetAll implements sabakan.InventoryModel
+func (d inventoryDriver) GetAll(ctx context.Context) ([]*sabakan.Inventory, error) {
+	return d.inventoryGetAll(ctx)
+}
@@ -0,0 +1,144 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func testInventoryPut(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	_, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = d.inventoryGet(ctx, "12345678")
+	if err != sabakan.ErrNotFound {
+		t.Error("unexpected error:", err)
+	}
+
+	inv := &sabakan.Inventory{
+		CPUs:   []sabakan.InventoryCPU{{Model: "Xeon", Cores: 20, Threads: 40}},
+		Memory: 192 << 30,
+		NICs:   []sabakan.InventoryNIC{{Name: "eno1", MAC: "0c:c4:7a:00:00:01", Speed: 25000}},
+	}
+	stored, err := d.inventoryPut(ctx, "12345678", inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Serial != "12345678" || stored.Version != 1 || stored.Timestamp.IsZero() {
+		t.Error("wrong meta data:", stored)
+	}
+
+	// the same inventory does not create a new version
+	stored, err = d.inventoryPut(ctx, "12345678", inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 1 {
+		t.Error("unchanged inventory should not be stored:", stored.Version)
+	}
+
+	inv.Memory = 384 << 30
+	stored, err = d.inventoryPut(ctx, "12345678", inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 2 {
+		t.Error("changed inventory should be stored:", stored.Version)
+	}
+
+	latest, err := d.inventoryGet(ctx, "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 2 || latest.Memory != 384<<30 {
+		t.Error("wrong latest inventory:", latest)
+	}
+
+	resp, err := d.client.Get(ctx, KeyAudit, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, kv := range resp.Kvs {
+		var a sabakan.AuditLog
+		err = json.Unmarshal(kv.Value, &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category == sabakan.AuditInventory && a.Action == "update" &&
+			a.Detail == "memory changed: 206158430208 -> 412316860416" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("inventory change was not audited")
+	}
+
+	_, err = d.inventoryPut(ctx, "1111", inv)
+	if err != sabakan.ErrNotFound {
+		t.Error("inventory of non-existing machine should not be stored:", err)
+	}
+}
+
+func testInventoryHistory(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	_, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i := 1; i <= MaxInventories+2; i++ {
+		_, err := d.inventoryPut(ctx, "12345678", &sabakan.Inventory{Memory: uint64(i) << 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = d.inventoryPut(ctx, "123456789", &sabakan.Inventory{Memory: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := d.inventoryGetHistory(ctx, "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != MaxInventories {
+		t.Fatal("wrong number of versions:", len(history))
+	}
+	if history[0].Version != 3 || history[len(history)-1].Version != MaxInventories+2 {
+		t.Error("wrong versions:", history[0].Version, history[len(history)-1].Version)
+	}
+
+	all, err := d.inventoryGetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatal("wrong number of inventories:", len(all))
+	}
+	if all[0].Serial != "12345678" || all[0].Version != MaxInventories+2 || all[1].Serial != "123456789" {
+		t.Error("wrong inventories:", all[0], all[1])
+	}
+
+	_, err = d.inventoryGetHistory(ctx, "12345679")
+	if err != sabakan.ErrNotFound {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	t.Run("Put", testInventoryPut)
+	t.Run("History", testInventoryHistory)
+}
@@ -6,20 +6,66 @@ import (
 	"errors"
 	"time"
 
+	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/sabakan/v3"
 	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.etcd.io/etcd/client/v3/clientv3util"
 )
 
-func (d *driver) putIPAMConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
+func (d *driver) putIPAMConfig(ctx context.Context, config *sabakan.IPAMConfig, force bool) error {
 	j, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
 
 	sj := string(j)
+	action := "put"
+	if force {
+		action = "force-put"
+	}
+
+RETRY:
+	// Forced updates skip the checks below, so they need no comparison.
+	var cmps []clientv3.Cmp
+	if !force {
+		machines, rev, err := d.ipamMachines(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Registered machines must keep their addresses.
+		for _, m := range machines {
+			if config.AddressChange(m) != nil {
+				log.Warn("etcd: IPAM config would change addresses of a machine", map[string]interface{}{
+					"serial": m.Spec.Serial,
+				})
+				return sabakan.ErrConflicted
+			}
+		}
+
+		conflicts, err := d.ipamConflicts(ctx, config, machines, rev)
+		if err != nil {
+			return err
+		}
+		for _, c := range conflicts {
+			log.Warn("etcd: IPAM config conflicts with machines or DHCP", map[string]interface{}{
+				"serial": c.Serial,
+				"reason": c.Reason,
+			})
+		}
+		if len(conflicts) > 0 {
+			return sabakan.ErrConflicted
+		}
+
+		// machines, leases and reservations were read at rev.
+		cmps = []clientv3.Cmp{
+			clientv3.Compare(clientv3.ModRevision(KeyMachines), "<", rev+1).WithPrefix(),
+			clientv3.Compare(clientv3.ModRevision(KeyLeaseUsages), "<", rev+1).WithPrefix(),
+			clientv3.Compare(clientv3.ModRevision(KeyReservations), "<", rev+1).WithPrefix(),
+		}
+	}
+
 	tresp, err := d.client.Txn(ctx).
-		If(clientv3util.KeyMissing(KeyMachines).WithPrefix()).
+		If(cmps...).
 		Then(clientv3.OpPut(KeyIPAM, sj)).
 		Else().
 		Commit()
@@ -28,14 +74,135 @@ func (d *driver) putIPAMConfig(ctx context.Context, config *sabakan.IPAMConfig)
 	}
 
 	if !tresp.Succeeded {
-		return errors.New("machines already exists")
+		log.Info("etcd: revision mismatch; retrying...", nil)
+		goto RETRY
 	}
 
-	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditIPAM, "config", "put", sj)
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditIPAM, "config", action, sj)
 
 	return nil
 }
 
+// ipamMachines returns all registered machines and the revision.
+func (d *driver) ipamMachines(ctx context.Context) ([]*sabakan.Machine, int64, error) {
+	resp, err := d.client.Get(ctx, KeyMachines, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	machines := make([]*sabakan.Machine, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		m := new(sabakan.Machine)
+		err = json.Unmarshal(kv.Value, m)
+		if err != nil {
+			return nil, 0, err
+		}
+		machines[i] = m
+	}
+	return machines, resp.Header.Revision, nil
+}
+
+// ipamConflicts returns reasons why config cannot be applied to machines,
+// DHCP leases and reservations without force.  Leases and reservations are
+// read at rev, the revision at which machines were read.
+func (d *driver) ipamConflicts(ctx context.Context, config *sabakan.IPAMConfig, machines []*sabakan.Machine, rev int64) ([]*sabakan.IPAMConflict, error) {
+	leases, err := d.dhcpGetLeases(ctx, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	reservations, err := d.dhcpGetReservations(ctx, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	return config.Conflicts(machines, leases, reservations), nil
+}
+
+func (d *driver) ipamAddressChanges(ctx context.Context, config *sabakan.IPAMConfig) ([]*sabakan.IPAMChange, error) {
+	machines, _, err := d.ipamMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*sabakan.IPAMChange{}
+	for _, m := range machines {
+		if c := config.AddressChange(m); c != nil {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (d *driver) ipamMigrate(ctx context.Context) ([]*sabakan.IPAMChange, error) {
+RETRY:
+	// read the configurations from etcd as the cache may be stale.
+	resp, err := d.client.Get(ctx, KeyIPAM)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, errors.New("IPAMConfig is not set")
+	}
+	config := new(sabakan.IPAMConfig)
+	err = json.Unmarshal(resp.Kvs[0].Value, config)
+	if err != nil {
+		return nil, err
+	}
+	configRev := resp.Kvs[0].ModRevision
+
+	machines, rev, err := d.ipamMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*sabakan.IPAMChange{}
+	var ops []clientv3.Op
+	for _, m := range machines {
+		c := config.AddressChange(m)
+		if c == nil {
+			continue
+		}
+		config.GenerateIP(m)
+		j, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+		ops = append(ops, clientv3.OpPut(KeyMachines+m.Spec.Serial, string(j)))
+	}
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.ModRevision(KeyIPAM), "=", configRev),
+			clientv3.Compare(clientv3.ModRevision(KeyMachines), "<", rev+1).WithPrefix(),
+		).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !tresp.Succeeded {
+		log.Info("etcd: revision mismatch; retrying...", nil)
+		goto RETRY
+	}
+
+	// record the old and new addresses of each machine.
+	for i, c := range changes {
+		j, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditIPAM, c.Serial, "migrate", string(j))
+			continue
+		}
+		d.addEventLog(ctx, sabakan.AuditIPAM, c.Serial, "migrate", string(j))
+	}
+	return changes, nil
+}
+
 func (d *driver) getIPAMConfig() (*sabakan.IPAMConfig, error) {
 	v := d.ipamConfig.Load()
 	if v == nil {
@@ -65,9 +232,29 @@ type ipamDriver struct {
 }
 
 func (d ipamDriver) PutConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
-	return d.putIPAMConfig(ctx, config)
+	return d.putIPAMConfig(ctx, config, false)
+}
+
+func (d ipamDriver) ForcePutConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
+	return d.putIPAMConfig(ctx, config, true)
 }
 
 func (d ipamDriver) GetConfig() (*sabakan.IPAMConfig, error) {
 	return d.getIPAMConfig()
 }
+
+func (d ipamDriver) AddressChanges(ctx context.Context, config *sabakan.IPAMConfig) ([]*sabakan.IPAMChange, error) {
+	return d.ipamAddressChanges(ctx, config)
+}
+
+func (d ipamDriver) Conflicts(ctx context.Context, config *sabakan.IPAMConfig) ([]*sabakan.IPAMConflict, error) {
+	machines, rev, err := d.ipamMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.ipamConflicts(ctx, config, machines, rev)
+}
+
+func (d ipamDriver) Migrate(ctx context.Context) ([]*sabakan.IPAMChange, error) {
+	return d.ipamMigrate(ctx)
+}
@@ -3,10 +3,13 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"reflect"
 	"testing"
 
 	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 var testIPAMConfig = sabakan.IPAMConfig{
@@ -30,7 +33,7 @@ func testIPAMPutConfig(t *testing.T) {
 
 	d, ch := testNewDriver(t)
 	config := &testIPAMConfig
-	err := d.putIPAMConfig(context.Background(), config)
+	err := d.putIPAMConfig(context.Background(), config, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,11 +64,52 @@ func testIPAMPutConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 	<-ch
-	err = d.putIPAMConfig(context.Background(), config)
-	if err == nil {
-		t.Error("should be failed, because some machine is already registered")
+
+	changed := *config
+	changed.NodeIPv4Offset = "0.0.1.0"
+	err = d.putIPAMConfig(context.Background(), &changed, false)
+	if err != sabakan.ErrConflicted {
+		t.Error("should be failed, because addresses of the registered machine would change:", err)
+	}
+
+	pooled := *config
+	pooled.Pools = map[string]*sabakan.IPAMPool{
+		"hall2": {
+			MaxNodesInRack:    60,
+			NodeIPv4Pool:      "10.80.0.0/16",
+			NodeRangeSize:     7,
+			NodeRangeMask:     25,
+			NodeIPPerNode:     2,
+			NodeIndexOffset:   10,
+			NodeGatewayOffset: 1,
+			BMCIPv4Pool:       "10.81.0.0/16",
+			BMCRangeSize:      7,
+			BMCRangeMask:      16,
+			BMCGatewayOffset:  1,
+		},
+	}
+	pooled.RackPools = []sabakan.RackPool{{Begin: 100, End: 119, Pool: "hall2"}}
+	err = d.putIPAMConfig(context.Background(), &pooled, false)
+	if err != nil {
+		t.Fatal(err)
 	}
 	<-ch
+
+	m := sabakan.NewMachine(sabakan.MachineSpec{Serial: "5678efgh", Rack: 101, Role: "boot"})
+	err = d.machineRegister(context.Background(), []*sabakan.Machine{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := d.machineGet(context.Background(), "5678efgh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Spec.IndexInRack != 10 {
+		t.Error("node index should be assigned from the pool:", saved.Spec.IndexInRack)
+	}
+	if !reflect.DeepEqual(saved.Spec.IPv4, []string{"10.80.1.10", "10.80.1.138"}) {
+		t.Error("addresses should be assigned from the pool:", saved.Spec.IPv4)
+	}
 }
 
 func testIPAMGetConfig(t *testing.T) {
@@ -94,7 +138,157 @@ func testIPAMGetConfig(t *testing.T) {
 	}
 }
 
+func testIPAMPutConflicts(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	ctx := context.Background()
+	config := testIPAMConfig
+	err := d.putIPAMConfig(ctx, &config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	// node indices up to 21 are assigned
+	var machines []*sabakan.Machine
+	for i := 0; i < 18; i++ {
+		machines = append(machines, sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: fmt.Sprintf("serial%02d", i),
+			Rack:   0,
+			Role:   "worker",
+		}))
+	}
+	err = d.machineRegister(ctx, machines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease range would cover the nodes at index 14 and above.
+	changed := testIPAMConfig
+	changed.MaxNodesInRack = 10
+	err = d.putIPAMConfig(ctx, &changed, false)
+	if err != sabakan.ErrConflicted {
+		t.Error("should be failed, because the node index is out of range:", err)
+	}
+
+	// the lease range would move.
+	d, ch = testNewDriver(t)
+	testSetupConfig(t, d, ch)
+	mac := net.HardwareAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	_, err = d.dhcpLease(ctx, net.ParseIP("10.69.0.1"), mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.putIPAMConfig(ctx, &changed, false)
+	if err != sabakan.ErrConflicted {
+		t.Error("should be failed, because the lease range would move:", err)
+	}
+
+	err = d.putIPAMConfig(ctx, &changed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testIPAMMigrate(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	ctx := context.Background()
+	config := testIPAMConfig
+	err := d.putIPAMConfig(ctx, &config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	err = d.machineRegister(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234abcd", Rack: 0, Role: "worker"}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "5678efgh", Rack: 1, Role: "worker"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := testIPAMConfig
+	changed.BMCIPv4Offset = "0.0.2.0"
+	changes, err := d.ipamAddressChanges(ctx, &changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Serial != "1234abcd" || changes[1].Serial != "5678efgh" {
+		t.Fatal("unexpected changes:", changes)
+	}
+	if changes[0].Old.BMCIPv4 != "10.72.17.4" || changes[0].New.BMCIPv4 != "10.72.18.4" {
+		t.Error("unexpected change of BMC address:", changes[0].Old.BMCIPv4, changes[0].New.BMCIPv4)
+	}
+
+	err = d.putIPAMConfig(ctx, &changed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	changes, err = d.ipamMigrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatal("unexpected changes:", changes)
+	}
+	m, err := d.machineGet(ctx, "5678efgh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Spec.BMC.IPv4 != "10.72.18.36" || m.Info.BMC.IPv4.Address != "10.72.18.36" {
+		t.Error("BMC address was not migrated:", m.Spec.BMC.IPv4, m.Info.BMC.IPv4.Address)
+	}
+
+	changes, err = d.ipamMigrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Error("no machines should be migrated twice:", changes)
+	}
+
+	resp, err := d.client.Get(ctx, KeyAudit, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make(map[string]int)
+	migrated := make(map[string]string)
+	for _, kv := range resp.Kvs {
+		var a sabakan.AuditLog
+		err = json.Unmarshal(kv.Value, &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Category != sabakan.AuditIPAM {
+			continue
+		}
+		actions[a.Action]++
+		if a.Action == "migrate" {
+			migrated[a.Instance] = a.Detail
+		}
+	}
+	if actions["force-put"] != 1 || actions["migrate"] != 2 {
+		t.Error("migration was not audited:", actions)
+	}
+	var c sabakan.IPAMChange
+	err = json.Unmarshal([]byte(migrated["5678efgh"]), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Old.BMCIPv4 != "10.72.17.36" || c.New.BMCIPv4 != "10.72.18.36" {
+		t.Error("unexpected audit of migration:", migrated["5678efgh"])
+	}
+}
+
 func TestIPAM(t *testing.T) {
 	t.Run("Put", testIPAMPutConfig)
 	t.Run("Get", testIPAMGetConfig)
+	t.Run("PutConflicts", testIPAMPutConflicts)
+	t.Run("Migrate", testIPAMMigrate)
 }
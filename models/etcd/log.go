@@ -47,6 +47,24 @@ func (d *driver) addLog(ctx context.Context, ts time.Time, rev int64, cat sabaka
 	})
 }
 
+// addEventLog records an audit log of an event that modifies nothing.
+// A revision for the log is obtained by updating KeyAuditSequence.
+func (d *driver) addEventLog(ctx context.Context, cat sabakan.AuditCategory,
+	instance, action, detail string) {
+
+	resp, err := d.client.Put(ctx, KeyAuditSequence, "")
+	if err != nil {
+		log.Error("etcd: addEventLog failed", map[string]interface{}{
+			log.FnError: err,
+			"category":  string(cat),
+			"instance":  instance,
+			"action":    action,
+		})
+		return
+	}
+	d.addLog(ctx, time.Now(), resp.Header.Revision, cat, instance, action, detail)
+}
+
 func (d *driver) logLastGCTime(ctx context.Context, nowData string) (t time.Time, rev int64, e error) {
 RETRY:
 	resp, err := d.client.Get(ctx, KeyAuditLastGC)
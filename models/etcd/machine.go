@@ -18,6 +18,10 @@ func (d *driver) machineRegister(ctx context.Context, machines []*sabakan.Machin
 	if err != nil {
 		return err
 	}
+	err = d.checkMACConflicts(machines)
+	if err != nil {
+		return err
+	}
 RETRY:
 	// Assign node indices and addresses temporarily
 	usageMap, err := d.assignNodeIndex(ctx, machines, cfg)
@@ -223,6 +227,168 @@ RETRY:
 	return nil
 }
 
+func (d *driver) machinePutAnnotation(ctx context.Context, serial string, name, value string) error {
+	key := KeyMachines + serial
+
+RETRY:
+	m, rev, err := d.machineGetWithRev(ctx, serial)
+	if err != nil {
+		return err
+	}
+
+	err = m.PutAnnotation(name, value)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	// values may be large, so only the name is recorded.
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditMachines, serial,
+		"put-annotation", name)
+	return nil
+}
+
+func (d *driver) machineDeleteAnnotation(ctx context.Context, serial string, name string) error {
+	key := KeyMachines + serial
+
+RETRY:
+	m, rev, err := d.machineGetWithRev(ctx, serial)
+	if err != nil {
+		return err
+	}
+
+	err = m.DeleteAnnotation(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditMachines, serial,
+		"delete-annotation", name)
+	return nil
+}
+
+// checkMACConflicts returns ErrConflicted if a MAC address of machines is
+// bound to another machine.
+func (d *driver) checkMACConflicts(machines []*sabakan.Machine) error {
+	owners := make(map[string]string)
+	for _, m := range machines {
+		for _, mac := range m.Spec.MACs {
+			if serial, ok := owners[mac]; ok && serial != m.Spec.Serial {
+				return sabakan.ErrConflicted
+			}
+			if serial, ok := d.mi.macOwner(mac); ok && serial != m.Spec.Serial {
+				return sabakan.ErrConflicted
+			}
+			owners[mac] = m.Spec.Serial
+		}
+	}
+	return nil
+}
+
+func (d *driver) machineAddMAC(ctx context.Context, serial string, mac string) error {
+	key := KeyMachines + serial
+
+RETRY:
+	m, rev, err := d.machineGetWithRev(ctx, serial)
+	if err != nil {
+		return err
+	}
+
+	if !m.AddMAC(mac) {
+		return nil
+	}
+	err = d.checkMACConflicts([]*sabakan.Machine{m})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditMachines, serial,
+		"add-mac", mac)
+	return nil
+}
+
+func (d *driver) machineRemoveMAC(ctx context.Context, serial string, mac string) error {
+	key := KeyMachines + serial
+
+RETRY:
+	m, rev, err := d.machineGetWithRev(ctx, serial)
+	if err != nil {
+		return err
+	}
+
+	err = m.RemoveMAC(mac)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !tresp.Succeeded {
+		goto RETRY
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditMachines, serial,
+		"remove-mac", mac)
+	return nil
+}
+
 func (d *driver) machineSetRetireDate(ctx context.Context, serial string, date time.Time) error {
 	key := KeyMachines + serial
 
@@ -271,7 +437,11 @@ func (d *driver) machineQuery(ctx context.Context, q sabakan.Query) ([]*sabakan.
 	case len(q.Serial()) > 0:
 		serials = strings.Split(q.Serial(), ",")
 	default:
-		serials = d.mi.query(q)
+		s, err := d.mi.query(q)
+		if err != nil {
+			return nil, err
+		}
+		serials = s
 	}
 
 	res := make([]*sabakan.Machine, 0, len(serials))
@@ -362,6 +532,10 @@ func (d *driver) machineDoDelete(ctx context.Context, machine *sabakan.Machine,
 		Then(
 			clientv3.OpDelete(machineKey),
 			clientv3.OpPut(indexKey, string(j)),
+			clientv3.OpDelete(KeyInventories+machine.Spec.Serial),
+			clientv3.OpDelete(keyInventoryHistoryPrefix(machine.Spec.Serial), clientv3.WithPrefix()),
+			clientv3.OpDelete(KeyMachineTokens+machine.Spec.Serial),
+			clientv3.OpDelete(keySecretPrefix(sabakan.SecretScopeSerial, machine.Spec.Serial), clientv3.WithPrefix()),
 		).
 		Commit()
 }
@@ -395,6 +569,26 @@ func (d machineDriver) DeleteLabel(ctx context.Context, serial string, label str
 	return d.machineDeleteLabel(ctx, serial, label)
 }
 
+// PutAnnotation implements sabakan.MachineModel
+func (d machineDriver) PutAnnotation(ctx context.Context, serial string, name, value string) error {
+	return d.machinePutAnnotation(ctx, serial, name, value)
+}
+
+// DeleteAnnotation implements sabakan.MachineModel
+func (d machineDriver) DeleteAnnotation(ctx context.Context, serial string, name string) error {
+	return d.machineDeleteAnnotation(ctx, serial, name)
+}
+
+// AddMAC implements sabakan.MachineModel
+func (d machineDriver) AddMAC(ctx context.Context, serial string, mac string) error {
+	return d.machineAddMAC(ctx, serial, mac)
+}
+
+// RemoveMAC implements sabakan.MachineModel
+func (d machineDriver) RemoveMAC(ctx context.Context, serial string, mac string) error {
+	return d.machineRemoveMAC(ctx, serial, mac)
+}
+
 func (d machineDriver) SetRetireDate(ctx context.Context, serial string, date time.Time) error {
 	return d.machineSetRetireDate(ctx, serial, date)
 }
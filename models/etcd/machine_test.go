@@ -3,6 +3,7 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -306,6 +307,147 @@ func testDeleteLabel(t *testing.T) {
 	}
 }
 
+func testPutAnnotation(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	_, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note := "交換予定: https://example.com/tickets/123"
+	err = d.machinePutAnnotation(context.Background(), "12345678", "note", note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := d.machineGet(context.Background(), "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.Spec.Annotations["note"]; !ok || v != note {
+		t.Error("wrong annotations:", m.Spec.Annotations)
+	}
+
+	err = d.machinePutAnnotation(context.Background(), "12345678", "huge", strings.Repeat("a", sabakan.MaxAnnotationsSize))
+	if err != sabakan.ErrAnnotationsTooLarge {
+		t.Error("PutAnnotation should fail for too large annotations:", err)
+	}
+
+	err = d.machinePutAnnotation(context.Background(), "1111", "note", note)
+	if err != sabakan.ErrNotFound {
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Error("PutAnnotation succeeded for non-existing machine")
+	}
+}
+
+func testDeleteAnnotation(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	_, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.machinePutAnnotation(context.Background(), "12345678", "note", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.machineDeleteAnnotation(context.Background(), "12345678", "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := d.machineGet(context.Background(), "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Spec.Annotations["note"]; ok {
+		t.Error("annotation was not deleted correctly:", m.Spec.Annotations)
+	}
+
+	err = d.machineDeleteAnnotation(context.Background(), "12345678", "note")
+	if err != sabakan.ErrNotFound {
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Error("DeleteAnnotation succeeded for non-existing annotation")
+	}
+}
+
+func testMACs(t *testing.T) {
+	t.Parallel()
+
+	d, ch := testNewDriver(t)
+	_, err := initializeTestData(d, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = d.machineAddMAC(ctx, "12345678", "0c:c4:7a:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	// adding a bound MAC address again is a no-op
+	err = d.machineAddMAC(ctx, "12345678", "0c:c4:7a:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.machineAddMAC(ctx, "12345679", "0c:c4:7a:00:00:01")
+	if err != sabakan.ErrConflicted {
+		t.Error("MAC address bound to another machine should be rejected:", err)
+	}
+	err = d.machineRegister(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: "abcdefgh",
+			Role:   "worker",
+			MACs:   []string{"0c:c4:7a:00:00:01"},
+		}),
+	})
+	if err != sabakan.ErrConflicted {
+		t.Error("machine having a bound MAC address should not be registered:", err)
+	}
+
+	resp, err := d.machineQuery(ctx, sabakan.Query{"mac": "0C:C4:7A:00:00:01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || resp[0].Spec.Serial != "12345678" {
+		t.Fatalf("unexpected query result: %#v", resp)
+	}
+
+	err = d.machineRemoveMAC(ctx, "12345678", "0c:c4:7a:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	resp, err = d.machineQuery(ctx, sabakan.Query{"mac": "0c:c4:7a:00:00:01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 0 {
+		t.Fatalf("unexpected query result: %#v", resp)
+	}
+
+	err = d.machineRemoveMAC(ctx, "12345678", "0c:c4:7a:00:00:01")
+	if err != sabakan.ErrNotFound {
+		t.Error("RemoveMAC succeeded for unbound MAC address:", err)
+	}
+	err = d.machineAddMAC(ctx, "1111", "0c:c4:7a:00:00:01")
+	if err != sabakan.ErrNotFound {
+		t.Error("AddMAC succeeded for non-existing machine:", err)
+	}
+}
+
 func testSetRetireDate(t *testing.T) {
 	t.Parallel()
 
@@ -452,6 +594,9 @@ func TestMachine(t *testing.T) {
 	t.Run("SetState", testSetState)
 	t.Run("PutLabel", testPutLabel)
 	t.Run("DeleteLabel", testDeleteLabel)
+	t.Run("PutAnnotation", testPutAnnotation)
+	t.Run("DeleteAnnotation", testDeleteAnnotation)
+	t.Run("MACs", testMACs)
 	t.Run("SetRetireDate", testSetRetireDate)
 	t.Run("Delete", testDelete)
 	t.Run("DeleteRace", testDeleteRace)
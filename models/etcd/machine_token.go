@@ -0,0 +1,81 @@
+package etcd
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (d *driver) machineIssueToken(ctx context.Context, serial string) (string, error) {
+	token, err := sabakan.NewMachineToken(serial)
+	if err != nil {
+		return "", err
+	}
+
+	tresp, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(KeyMachines+serial), ">", 0)).
+		Then(clientv3.OpPut(KeyMachineTokens+serial, sabakan.HashMachineToken(token))).
+		Commit()
+	if err != nil {
+		return "", err
+	}
+	if !tresp.Succeeded {
+		return "", sabakan.ErrNotFound
+	}
+
+	d.addLog(ctx, time.Now(), tresp.Header.Revision, sabakan.AuditMachines, serial, "issue-token", "")
+	return token, nil
+}
+
+func (d *driver) machineRevokeToken(ctx context.Context, serial string) error {
+	resp, err := d.client.Delete(ctx, KeyMachineTokens+serial)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return sabakan.ErrNotFound
+	}
+
+	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditMachines, serial, "revoke-token", "")
+	return nil
+}
+
+// machineVerifyToken returns the machine identified by token.
+// It returns ErrForbidden if token is not valid.
+func (d *driver) machineVerifyToken(ctx context.Context, token string) (*sabakan.Machine, error) {
+	serial, ok := sabakan.ParseMachineToken(token)
+	if !ok {
+		return nil, sabakan.ErrForbidden
+	}
+
+	resp, err := d.client.Get(ctx, KeyMachineTokens+serial)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return nil, sabakan.ErrForbidden
+	}
+	hash := sabakan.HashMachineToken(token)
+	if subtle.ConstantTimeCompare(resp.Kvs[0].Value, []byte(hash)) != 1 {
+		return nil, sabakan.ErrForbidden
+	}
+
+	m, err := d.machineGet(ctx, serial)
+	if err == sabakan.ErrNotFound {
+		return nil, sabakan.ErrForbidden
+	}
+	return m, err
+}
+
+// IssueToken implements sabakan.MachineModel
+func (d machineDriver) IssueToken(ctx context.Context, serial string) (string, error) {
+	return d.machineIssueToken(ctx, serial)
+}
+
+// RevokeToken implements sabakan.MachineModel
+func (d machineDriver) RevokeToken(ctx context.Context, serial string) error {
+	return d.machineRevokeToken(ctx, serial)
+}
@@ -85,7 +85,7 @@ func testNewDriver(t *testing.T) (*driver, <-chan struct{}) {
 func initializeTestData(d *driver, ch <-chan struct{}) ([]*sabakan.Machine, error) {
 	ctx := context.Background()
 	config := &testIPAMConfig
-	err := d.putIPAMConfig(ctx, config)
+	err := d.putIPAMConfig(ctx, config, false)
 	if err != nil {
 		return nil, err
 	}
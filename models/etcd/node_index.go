@@ -37,17 +37,18 @@ func (r *rackIndexUsage) UnmarshalJSON(data []byte) error {
 
 func (r *rackIndexUsage) assign(m *sabakan.Machine, c *sabakan.IPAMConfig) error {
 	var idx uint
+	p, _ := c.PoolForRack(m.Spec.Rack)
 
 OUT:
 	switch m.Spec.Role {
 	case "boot":
-		idx = c.NodeIndexOffset
+		idx = p.NodeIndexOffset
 		if r.indexMap[idx] {
 			return sabakan.ErrConflicted
 		}
 	default:
-		for i := uint(0); i < c.MaxNodesInRack; i++ {
-			idx = i + c.NodeIndexOffset + 1
+		for i := uint(0); i < p.MaxNodesInRack; i++ {
+			idx = i + p.NodeIndexOffset + 1
 			if !r.indexMap[idx] {
 				break OUT
 			}
@@ -0,0 +1,184 @@
+package etcd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+/*
+Objects in the object storage:
+
+<bucket>/
+    - assets/
+        - ID
+        - ...
+    - images/
+        - OS (coreos) /
+            - ID/
+                - kernel
+                - initrd.gz
+*/
+
+func assetObjectKey(id int) string {
+	return path.Join("assets", strconv.Itoa(id))
+}
+
+func imageObjectKey(os, id, filename string) string {
+	return path.Join("images", os, id, filename)
+}
+
+// putFile uploads a local file to the object storage.
+func (d *driver) putFile(ctx context.Context, key, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return d.store.Put(ctx, key, f, fi.Size())
+}
+
+// storeAsset uploads the local copy of an asset to the object storage.
+func (d *driver) storeAsset(ctx context.Context, id int) error {
+	return d.putFile(ctx, assetObjectKey(id), d.getAssetDir().Path(id))
+}
+
+// cacheAsset downloads an asset from the object storage to the local cache.
+func (d *driver) cacheAsset(ctx context.Context, a *sabakan.Asset) error {
+	csum, err := hex.DecodeString(a.Sha256)
+	if err != nil {
+		return err
+	}
+
+	r, err := d.store.Get(ctx, assetObjectKey(a.ID))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dir := d.getAssetDir()
+	_, err = dir.Save(a.ID, r, csum)
+	if err != nil {
+		return err
+	}
+	log.Info("asset: cached a local copy", map[string]interface{}{
+		"name": a.Name,
+		"id":   a.ID,
+	})
+
+	d.trimAssetCache(a.ID)
+	return nil
+}
+
+// trimAssetCache removes local copies of assets exceeding the cache size.
+func (d *driver) trimAssetCache(keep int) {
+	if d.store == nil || d.cacheSize == 0 {
+		return
+	}
+
+	err := d.getAssetDir().Trim(d.cacheSize, keep)
+	if err != nil {
+		log.Error("asset: failed to trim the cache", map[string]interface{}{
+			log.FnError: err,
+		})
+	}
+}
+
+// removeAssetObjects removes objects of asset versions.
+// Failures are only logged because etcd has already been updated.
+func (d *driver) removeAssetObjects(ctx context.Context, name string, ids []int) {
+	for _, id := range ids {
+		err := d.store.Delete(ctx, assetObjectKey(id))
+		if err != nil {
+			log.Error("asset: failed to remove an object", map[string]interface{}{
+				log.FnError: err,
+				"name":      name,
+				"id":        id,
+			})
+		}
+	}
+}
+
+// storeImage uploads the local copy of an image to the object storage.
+func (d *driver) storeImage(ctx context.Context, os, id string) error {
+	dir := d.getImageDir(os)
+	for _, m := range imageMembers[os] {
+		err := d.putFile(ctx, imageObjectKey(os, id, m), filepath.Join(dir.Dir, id, m))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pullImage downloads an image from the object storage.
+func (d *driver) pullImage(ctx context.Context, os, id string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(d.writeImageArchive(ctx, pw, os, id))
+	}()
+	defer pr.Close()
+
+	return d.getImageDir(os).Extract(pr, id, imageMembers[os])
+}
+
+// writeImageArchive writes image files in the object storage as a tar archive.
+func (d *driver) writeImageArchive(ctx context.Context, w io.Writer, os, id string) error {
+	tw := tar.NewWriter(w)
+	for _, m := range imageMembers[os] {
+		key := imageObjectKey(os, id, m)
+		size, err := d.store.Size(ctx, key)
+		if err != nil {
+			return err
+		}
+		r, err := d.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(&tar.Header{
+			Name: m,
+			Size: size,
+			Mode: 0644,
+		})
+		if err == nil {
+			_, err = io.Copy(tw, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// removeImageObjects removes objects of images.
+// Failures are only logged because etcd has already been updated.
+func (d *driver) removeImageObjects(ctx context.Context, os string, ids []string) {
+	for _, id := range ids {
+		for _, m := range imageMembers[os] {
+			err := d.store.Delete(ctx, imageObjectKey(os, id, m))
+			if err != nil {
+				log.Error("image: failed to remove an object", map[string]interface{}{
+					log.FnError: err,
+					"os":        os,
+					"id":        id,
+					"filename":  m,
+				})
+			}
+		}
+	}
+}
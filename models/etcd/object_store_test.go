@@ -0,0 +1,238 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/objstore"
+	"github.com/cybozu-go/sabakan/v3/objstore/objstoretest"
+)
+
+func testNewStoreDriver(t *testing.T, srv *objstoretest.Server) *driver {
+	d, _ := testNewDriver(t)
+
+	tempdir, err := os.MkdirTemp("", "sabakan-objstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempdir) })
+	d.dataDir = tempdir
+
+	store, err := objstore.NewClient(srv.Config(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithObjectStore(store, false, 0)(d)
+	return d
+}
+
+func testObjectStoreAsset(t *testing.T) {
+	t.Parallel()
+
+	srv := objstoretest.NewServer("sabakan")
+	defer srv.Close()
+	d := testNewStoreDriver(t, srv)
+	ctx := context.Background()
+
+	status, err := d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := srv.Object(assetObjectKey(status.ID))
+	if !ok || string(data) != "bar" {
+		t.Fatal("asset was not stored:", string(data))
+	}
+
+	// serve from the object storage through the cache
+	dir := d.getAssetDir()
+	err = dir.Remove(status.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := d.assetGetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.handleAssetAdd(ctx, asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Exists(status.ID) {
+		t.Error("asset should not be pulled in advance")
+	}
+
+	h := new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.calledServeContent || string(h.content) != "bar" {
+		t.Error("asset was not served:", string(h.content))
+	}
+	if !dir.Exists(status.ID) || srv.Gets() != 1 {
+		t.Error("asset was not cached:", srv.Gets())
+	}
+
+	h = new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Gets() != 1 {
+		t.Error("cached asset should be served locally:", srv.Gets())
+	}
+
+	// redirect to a presigned URL
+	d.storeRedirect = true
+	err = dir.Remove(status.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = new(mockHandler)
+	err = d.assetGet(ctx, "foo", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := srv.URL + "/sabakan/" + assetObjectKey(status.ID) + "?"
+	if !h.calledRedirect || !strings.HasPrefix(h.redirectURL, prefix) ||
+		!strings.Contains(h.redirectURL, "X-Amz-Signature=") {
+		t.Error("wrong redirect URL:", h.redirectURL)
+	}
+
+	// old versions are removed from the object storage
+	for i := 0; i < MaxAssetVersions; i++ {
+		_, err = d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := srv.Object(assetObjectKey(status.ID)); ok {
+		t.Error("evicted version was not removed")
+	}
+	if srv.Len() != MaxAssetVersions {
+		t.Error("wrong number of objects:", srv.Len())
+	}
+
+	err = d.assetDelete(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Len() != 0 {
+		t.Error("objects were not removed:", srv.Len())
+	}
+	err = d.assetDelete(ctx, "foo")
+	if err != sabakan.ErrNotFound {
+		t.Error("err != sabakan.ErrNotFound:", err)
+	}
+}
+
+func testObjectStoreAssetCache(t *testing.T) {
+	t.Parallel()
+
+	srv := objstoretest.NewServer("sabakan")
+	defer srv.Close()
+	d := testNewStoreDriver(t, srv)
+	d.cacheSize = 10
+	ctx := context.Background()
+	dir := d.getAssetDir()
+
+	var ids []int
+	for _, name := range []string{"a", "b", "c"} {
+		status, err := d.assetPut(ctx, name, "text/plain", nil, nil, strings.NewReader("01234"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, status.ID)
+
+		// make the order of access times stable
+		past := time.Now().Add(-time.Duration(10-len(ids)) * time.Minute)
+		err = os.Chtimes(dir.Path(status.ID), past, past)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if dir.Exists(ids[0]) || !dir.Exists(ids[1]) || !dir.Exists(ids[2]) {
+		t.Error("least recently used asset should be removed")
+	}
+
+	// access "b" then cache "a"
+	err := d.assetGet(ctx, "b", new(mockHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := new(mockHandler)
+	err = d.assetGet(ctx, "a", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h.content) != "01234" {
+		t.Error("wrong content:", string(h.content))
+	}
+	if !dir.Exists(ids[0]) || !dir.Exists(ids[1]) || dir.Exists(ids[2]) {
+		t.Error("wrong cache eviction")
+	}
+}
+
+func testObjectStoreImage(t *testing.T) {
+	t.Parallel()
+
+	srv := objstoretest.NewServer("sabakan")
+	defer srv.Close()
+	d := testNewStoreDriver(t, srv)
+	ctx := context.Background()
+
+	err := d.imageUpload(ctx, "coreos", "1234.5", newTestImage("abcd", "efg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := srv.Object(imageObjectKey("coreos", "1234.5", sabakan.ImageKernelFilename))
+	if !ok || string(data) != "abcd" {
+		t.Error("kernel was not stored:", string(data))
+	}
+	data, ok = srv.Object(imageObjectKey("coreos", "1234.5", sabakan.ImageInitrdFilename))
+	if !ok || string(data) != "efg" {
+		t.Error("initrd was not stored:", string(data))
+	}
+
+	// another server pulls the image from the object storage
+	dir := d.getImageDir("coreos")
+	err = os.RemoveAll(filepath.Join(dir.Dir, "1234.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := d.imageGetIndex(ctx, "coreos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.updateImageForOS(ctx, "coreos", updateData{index: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernel, err := os.ReadFile(filepath.Join(dir.Dir, "1234.5", sabakan.ImageKernelFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kernel) != "abcd" {
+		t.Error("wrong kernel:", string(kernel))
+	}
+
+	err = d.imageDelete(ctx, "coreos", "1234.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Len() != 0 {
+		t.Error("objects were not removed:", srv.Len())
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	t.Run("Asset", testObjectStoreAsset)
+	t.Run("AssetCache", testObjectStoreAssetCache)
+	t.Run("Image", testObjectStoreImage)
+}
@@ -0,0 +1,208 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+func pullFailureKey(kind, name, id string) string {
+	return kind + "/" + name + "/" + id
+}
+
+// recordPullFailure records the last error of a pull to be reported.
+func (d *driver) recordPullFailure(kind, name, id string, err error) {
+	d.pullMu.Lock()
+	defer d.pullMu.Unlock()
+
+	if d.pullFailures == nil {
+		d.pullFailures = make(map[string]*sabakan.PullFailure)
+	}
+	d.pullFailures[pullFailureKey(kind, name, id)] = &sabakan.PullFailure{
+		Kind:  kind,
+		Name:  name,
+		ID:    id,
+		Error: err.Error(),
+		Date:  time.Now().UTC(),
+	}
+}
+
+// clearPullFailure clears the failure of a pull that has succeeded.
+func (d *driver) clearPullFailure(kind, name, id string) {
+	d.pullMu.Lock()
+	defer d.pullMu.Unlock()
+
+	delete(d.pullFailures, pullFailureKey(kind, name, id))
+}
+
+// notifyReport requests the reporter to update the replica report.
+func (d *driver) notifyReport() {
+	select {
+	case d.reportCh <- struct{}{}:
+	default:
+	}
+}
+
+func (d *driver) getAssetsAndImages(ctx context.Context) ([]*sabakan.Asset, map[string]sabakan.ImageIndex, error) {
+	resp, err := d.client.Get(ctx, KeyAssets, clientv3.WithPrefix())
+	if err != nil {
+		return nil, nil, err
+	}
+	assets := make([]*sabakan.Asset, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		assets[i], err = decodeAsset(kv.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	images := make(map[string]sabakan.ImageIndex)
+	for os := range imageMembers {
+		index, err := d.imageGetIndex(ctx, os)
+		if err != nil {
+			return nil, nil, err
+		}
+		images[os] = index
+	}
+	return assets, images, nil
+}
+
+func (d *driver) replicaReport(ctx context.Context) (*sabakan.ReplicaReport, error) {
+	assets, images, err := d.getAssetsAndImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &sabakan.ReplicaReport{
+		URL:         d.myURL(),
+		Assets:      []int{},
+		Images:      make(map[string][]string),
+		ObjectStore: d.store != nil,
+		Failures:    []*sabakan.PullFailure{},
+		Date:        time.Now().UTC(),
+	}
+	dir := d.getAssetDir()
+	for _, a := range assets {
+		if dir.Exists(a.ID) {
+			report.Assets = append(report.Assets, a.ID)
+		}
+	}
+	for os, index := range images {
+		idir := d.getImageDir(os)
+		ids := []string{}
+		for _, img := range index {
+			if idir.Exists(img.ID) {
+				ids = append(ids, img.ID)
+			}
+		}
+		report.Images[os] = ids
+	}
+
+	d.pullMu.Lock()
+	for _, f := range d.pullFailures {
+		report.Failures = append(report.Failures, f)
+	}
+	d.pullMu.Unlock()
+	sort.Slice(report.Failures, func(i, j int) bool {
+		fi, fj := report.Failures[i], report.Failures[j]
+		return pullFailureKey(fi.Kind, fi.Name, fi.ID) < pullFailureKey(fj.Kind, fj.Name, fj.ID)
+	})
+
+	return report, nil
+}
+
+func (d *driver) putReplicaReport(ctx context.Context, lease clientv3.LeaseID) error {
+	report, err := d.replicaReport(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.Put(ctx, KeyReplicas+d.advertiseURL.Host, string(data), clientv3.WithLease(lease))
+	return err
+}
+
+// runReplicaReporter puts the replica report of this server bound to
+// a lease so that the report is removed when the server stops.
+func (d *driver) runReplicaReporter(ctx context.Context) error {
+	sess, err := concurrency.NewSession(d.client, concurrency.WithTTL(replicaReportTTL))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	for {
+		err = d.putReplicaReport(ctx, sess.Lease())
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-d.reportCh:
+		case <-sess.Done():
+			return errors.New("session for replica report has expired")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (d *driver) startReplicaReporter(ctx context.Context) error {
+	for {
+		err := d.runReplicaReporter(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Error("replica reporter: failed to report", map[string]interface{}{
+			log.FnError: err,
+		})
+
+		select {
+		case <-time.After(replicaReportRetryInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (d *driver) replicationGetStatus(ctx context.Context) (*sabakan.ReplicationStatus, error) {
+	resp, err := d.client.Get(ctx, KeyReplicas, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*sabakan.ReplicaReport, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		reports[i] = new(sabakan.ReplicaReport)
+		err = json.Unmarshal(kv.Value, reports[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].URL < reports[j].URL
+	})
+
+	assets, images, err := d.getAssetsAndImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sabakan.NewReplicationStatus(reports, assets, images), nil
+}
+
+type replicationDriver struct {
+	*driver
+}
+
+func (d replicationDriver) GetStatus(ctx context.Context) (*sabakan.ReplicationStatus, error) {
+	return d.replicationGetStatus(ctx)
+}
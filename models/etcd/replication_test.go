@@ -0,0 +1,154 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testReplicationStatus(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	d2, _ := testNewDriver(t)
+	for _, drv := range []*driver{d, d2} {
+		tempdir, err := os.MkdirTemp("", "sabakan-replication-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tempdir)
+		drv.dataDir = tempdir
+	}
+	u, err := url.Parse("http://10.0.0.2:10080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2.advertiseURL = u
+	ctx := context.Background()
+
+	status, err := d.assetPut(ctx, "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.imageUpload(ctx, "coreos", "1234.5", newTestImage("abcd", "efg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2.recordPullFailure(sabakan.ReplicaKindAsset, "foo", "1", errors.New("connection refused"))
+
+	lease, err := d.client.Grant(ctx, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, drv := range []*driver{d, d2} {
+		err = drv.putReplicaReport(ctx, lease.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := d.replicationGetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Servers) != 2 || st.Servers[0] != "http://10.0.0.2:10080" || st.Servers[1] != "http://localhost:10080" {
+		t.Fatal("wrong servers:", st.Servers)
+	}
+	foo := st.FindAsset("foo")
+	if foo == nil || foo.ID != "1" || status.ID != 1 {
+		t.Fatal("wrong asset:", foo)
+	}
+	if len(foo.Servers) != 1 || foo.Servers[0] != "http://localhost:10080" {
+		t.Error("wrong servers of foo:", foo.Servers)
+	}
+	if len(foo.Failed) != 1 || foo.Failed[0].Server != "http://10.0.0.2:10080" || foo.Failed[0].Error != "connection refused" {
+		t.Error("wrong failures of foo:", foo.Failed)
+	}
+	if len(st.Images) != 1 || len(st.Images[0].Servers) != 1 || len(st.Images[0].Pending) != 1 {
+		t.Error("wrong images:", st.Images)
+	}
+
+	// a successful pull clears the failure
+	d2.clearPullFailure(sabakan.ReplicaKindAsset, "foo", "1")
+	err = d2.putReplicaReport(ctx, lease.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err = d.replicationGetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo = st.FindAsset("foo")
+	if len(foo.Failed) != 0 || len(foo.Pending) != 1 {
+		t.Error("wrong status of foo:", foo)
+	}
+}
+
+func testReplicaReporter(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	tempdir, err := os.MkdirTemp("", "sabakan-replication-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+	d.dataDir = tempdir
+	d.reportCh = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.runReplicaReporter(ctx)
+	}()
+
+	getReport := func() []byte {
+		resp, err := d.client.Get(context.Background(), KeyReplicas+"localhost:10080")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Count == 0 {
+			return nil
+		}
+		return resp.Kvs[0].Value
+	}
+	waitFor := func(cond func(data []byte) bool) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if cond(getReport()) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatal("timed out")
+	}
+
+	waitFor(func(data []byte) bool { return data != nil })
+
+	_, err = d.assetPut(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.notifyReport()
+	waitFor(func(data []byte) bool { return strings.Contains(string(data), `"assets":[1]`) })
+
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getReport() != nil {
+		t.Error("report was not removed")
+	}
+}
+
+func TestReplication(t *testing.T) {
+	t.Run("Status", testReplicationStatus)
+	t.Run("Reporter", testReplicaReporter)
+}
@@ -0,0 +1,186 @@
+package etcd
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*
+Secrets are sealed by AES-256-GCM with the server key.  The key of
+a secret in etcd is used as the additional data so that sealed values
+cannot be moved to other secrets.
+*/
+
+// secretValue is the value stored in etcd for a secret.
+type secretValue struct {
+	Date time.Time `json:"date"`
+
+	// Data is the nonce followed by the sealed value.
+	Data []byte `json:"data"`
+}
+
+func keySecretPrefix(scope sabakan.SecretScope, target string) string {
+	if scope == sabakan.SecretScopeGlobal {
+		return KeySecrets + string(scope) + "/"
+	}
+	return KeySecrets + string(scope) + "/" + target + "/"
+}
+
+func keySecret(scope sabakan.SecretScope, target, name string) string {
+	return keySecretPrefix(scope, target) + name
+}
+
+func (d *driver) secretAEAD() (cipher.AEAD, error) {
+	if d.secretKey == nil {
+		return nil, sabakan.ErrNoSecretKey
+	}
+	block, err := aes.NewCipher(d.secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (d *driver) secretPut(ctx context.Context, scope sabakan.SecretScope, target, name string, value []byte) error {
+	aead, err := d.secretAEAD()
+	if err != nil {
+		return err
+	}
+
+	key := keySecret(scope, target, name)
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(secretValue{
+		Date: time.Now().UTC(),
+		Data: aead.Seal(nonce, nonce, value, []byte(key)),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Put(ctx, key, string(data))
+	if err != nil {
+		return err
+	}
+
+	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditSecrets,
+		strings.TrimPrefix(key, KeySecrets), "put", "")
+	return nil
+}
+
+func (d *driver) secretList(ctx context.Context) ([]*sabakan.SecretInfo, error) {
+	resp, err := d.client.Get(ctx, KeySecrets, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*sabakan.SecretInfo, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		fields := strings.Split(string(kv.Key[len(KeySecrets):]), "/")
+		info := &sabakan.SecretInfo{Scope: sabakan.SecretScope(fields[0])}
+		switch {
+		case info.Scope == sabakan.SecretScopeGlobal && len(fields) == 2:
+			info.Name = fields[1]
+		case len(fields) == 3:
+			info.Target = fields[1]
+			info.Name = fields[2]
+		default:
+			continue
+		}
+
+		var v secretValue
+		err := json.Unmarshal(kv.Value, &v)
+		if err != nil {
+			return nil, err
+		}
+		info.Date = v.Date
+		secrets = append(secrets, info)
+	}
+	return secrets, nil
+}
+
+func (d *driver) secretDelete(ctx context.Context, scope sabakan.SecretScope, target, name string) error {
+	key := keySecret(scope, target, name)
+	resp, err := d.client.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return sabakan.ErrNotFound
+	}
+
+	d.addLog(ctx, time.Now(), resp.Header.Revision, sabakan.AuditSecrets,
+		strings.TrimPrefix(key, KeySecrets), "delete", "")
+	return nil
+}
+
+func (d *driver) secretResolve(ctx context.Context, m *sabakan.Machine, name string) ([]byte, error) {
+	keys := []string{
+		keySecret(sabakan.SecretScopeSerial, m.Spec.Serial, name),
+		keySecret(sabakan.SecretScopeRole, m.Spec.Role, name),
+		keySecret(sabakan.SecretScopeGlobal, "", name),
+	}
+	ops := make([]clientv3.Op, len(keys))
+	for i, key := range keys {
+		ops[i] = clientv3.OpGet(key)
+	}
+	resp, err := d.client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range resp.Responses {
+		kvs := r.GetResponseRange().Kvs
+		if len(kvs) == 0 {
+			continue
+		}
+
+		aead, err := d.secretAEAD()
+		if err != nil {
+			return nil, err
+		}
+		var v secretValue
+		err = json.Unmarshal(kvs[0].Value, &v)
+		if err != nil {
+			return nil, err
+		}
+		if len(v.Data) < aead.NonceSize() {
+			return nil, errors.New("broken secret: " + keys[i])
+		}
+		nonce, sealed := v.Data[:aead.NonceSize()], v.Data[aead.NonceSize():]
+		return aead.Open(nil, nonce, sealed, []byte(keys[i]))
+	}
+	return nil, sabakan.ErrNotFound
+}
+
+type secretDriver struct {
+	*driver
+}
+
+func (d secretDriver) Put(ctx context.Context, scope sabakan.SecretScope, target, name string, value []byte) error {
+	return d.secretPut(ctx, scope, target, name, value)
+}
+
+func (d secretDriver) List(ctx context.Context) ([]*sabakan.SecretInfo, error) {
+	return d.secretList(ctx)
+}
+
+func (d secretDriver) Delete(ctx context.Context, scope sabakan.SecretScope, target, name string) error {
+	return d.secretDelete(ctx, scope, target, name)
+}
+
+func (d secretDriver) Resolve(ctx context.Context, m *sabakan.Machine, name string) ([]byte, error) {
+	return d.secretResolve(ctx, m, name)
+}
@@ -0,0 +1,151 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func testSecretNoKey(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	err := d.secretPut(context.Background(), sabakan.SecretScopeGlobal, "", "foo", []byte("bar"))
+	if err != sabakan.ErrNoSecretKey {
+		t.Error("secrets should not be stored without key:", err)
+	}
+}
+
+func testSecretResolve(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	d.secretKey = bytes.Repeat([]byte{1}, 32)
+	ctx := context.Background()
+
+	puts := []struct {
+		scope  sabakan.SecretScope
+		target string
+		name   string
+		value  string
+	}{
+		{sabakan.SecretScopeGlobal, "", "token", "global-token"},
+		{sabakan.SecretScopeRole, "worker", "token", "worker-token"},
+		{sabakan.SecretScopeSerial, "1234", "token", "1234-token"},
+		{sabakan.SecretScopeGlobal, "", "password", "global-password"},
+	}
+	for _, p := range puts {
+		err := d.secretPut(ctx, p.scope, p.target, p.name, []byte(p.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// values are encrypted at rest
+	resp, err := d.client.Get(ctx, keySecret(sabakan.SecretScopeRole, "worker", "token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || strings.Contains(string(resp.Kvs[0].Value), "worker-token") {
+		t.Error("secret is not encrypted:", resp.Kvs)
+	}
+
+	cases := []struct {
+		serial   string
+		role     string
+		name     string
+		expected string
+	}{
+		{"1234", "worker", "token", "1234-token"},
+		{"5678", "worker", "token", "worker-token"},
+		{"5678", "boot", "token", "global-token"},
+		{"1234", "worker", "password", "global-password"},
+	}
+	for _, c := range cases {
+		m := sabakan.NewMachine(sabakan.MachineSpec{Serial: c.serial, Role: c.role})
+		val, err := d.secretResolve(ctx, m, c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != c.expected {
+			t.Errorf("wrong secret for %s/%s/%s: %s", c.serial, c.role, c.name, val)
+		}
+	}
+	m := sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234", Role: "worker"})
+	_, err = d.secretResolve(ctx, m, "missing")
+	if err != sabakan.ErrNotFound {
+		t.Error("missing secret should not be found:", err)
+	}
+
+	// sealed values cannot be moved to other secrets
+	_, err = d.client.Put(ctx, keySecret(sabakan.SecretScopeSerial, "5678", "token"), string(resp.Kvs[0].Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = sabakan.NewMachine(sabakan.MachineSpec{Serial: "5678", Role: "boot"})
+	_, err = d.secretResolve(ctx, m, "token")
+	if err == nil {
+		t.Error("moved secret should not be decrypted")
+	}
+
+	// a wrong key cannot decrypt secrets
+	d.secretKey = bytes.Repeat([]byte{2}, 32)
+	m = sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234", Role: "worker"})
+	_, err = d.secretResolve(ctx, m, "token")
+	if err == nil {
+		t.Error("secret should not be decrypted by a wrong key")
+	}
+}
+
+func testSecretListDelete(t *testing.T) {
+	t.Parallel()
+
+	d, _ := testNewDriver(t)
+	d.secretKey = bytes.Repeat([]byte{1}, 32)
+	ctx := context.Background()
+
+	err := d.secretPut(ctx, sabakan.SecretScopeGlobal, "", "foo", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.secretPut(ctx, sabakan.SecretScopeRole, "worker", "bar", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := d.secretList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 ||
+		secrets[0].Scope != sabakan.SecretScopeGlobal || secrets[0].Name != "foo" || secrets[0].Target != "" ||
+		secrets[1].Scope != sabakan.SecretScopeRole || secrets[1].Name != "bar" || secrets[1].Target != "worker" ||
+		secrets[0].Date.IsZero() {
+		t.Error("wrong secrets:", secrets)
+	}
+
+	err = d.secretDelete(ctx, sabakan.SecretScopeRole, "worker", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.secretDelete(ctx, sabakan.SecretScopeRole, "worker", "bar")
+	if err != sabakan.ErrNotFound {
+		t.Error("deleted secret should not be found:", err)
+	}
+	secrets, err = d.secretList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 {
+		t.Error("secret was not deleted:", secrets)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	t.Run("NoKey", testSecretNoKey)
+	t.Run("Resolve", testSecretResolve)
+	t.Run("ListDelete", testSecretListDelete)
+}
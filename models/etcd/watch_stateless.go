@@ -75,6 +75,11 @@ func (d *driver) initStateless(ctx context.Context, ch chan<- struct{}) (int64,
 		return 0, err
 	}
 
+	err = d.reservations.init(ctx, d.client)
+	if err != nil {
+		return 0, err
+	}
+
 	return rev, nil
 }
 
@@ -107,6 +112,8 @@ func (d *driver) startStatelessWatcher(ctx context.Context, ch, indexCh chan<- s
 				err = d.handleDHCPConfig(ev)
 			case key == KeyIPAM:
 				err = d.handleIPAMConfig(ev)
+			case strings.HasPrefix(key, KeyReservations):
+				err = d.handleReservation(ev)
 			case strings.HasPrefix(key, KeyImages):
 				select {
 				case indexCh <- struct{}{}:
@@ -9,23 +9,45 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
 
+// maxAssetVersions is the same as MaxAssetVersions of etcd driver.
+const maxAssetVersions = 5
+
 type assetDriver struct {
-	mu     sync.Mutex
-	assets map[string]*sabakan.Asset
-	data   map[string][]byte
-	lastID int
+	mu          sync.Mutex
+	assets      map[string]*sabakan.Asset
+	data        map[string][]byte
+	history     map[string][]*sabakan.Asset
+	historyData map[int][]byte
+	lastID      int
+	uploads     map[string]*mockUpload
+	lastUpload  int
+	ignition    *ignitionDriver
+	imports     *importRecorder
+	machines    *driver
+}
+
+type mockUpload struct {
+	upload *sabakan.AssetUpload
+	parts  map[int][]byte
 }
 
-func newAssetDriver() *assetDriver {
+func newAssetDriver(ignition *ignitionDriver, machines *driver) *assetDriver {
 	return &assetDriver{
-		assets: make(map[string]*sabakan.Asset),
-		data:   make(map[string][]byte),
+		ignition:    ignition,
+		machines:    machines,
+		imports:     newImportRecorder(),
+		assets:      make(map[string]*sabakan.Asset),
+		data:        make(map[string][]byte),
+		history:     make(map[string][]*sabakan.Asset),
+		historyData: make(map[int][]byte),
+		uploads:     make(map[string]*mockUpload),
 	}
 }
 
@@ -101,12 +123,111 @@ func (d *assetDriver) Delete(ctx context.Context, name string) error {
 		return sabakan.ErrNotFound
 	}
 
+	d.delete(name)
+	return nil
+}
+
+func (d *assetDriver) delete(name string) {
 	delete(d.assets, name)
 	delete(d.data, name)
+	for _, a := range d.history[name] {
+		delete(d.historyData, a.ID)
+	}
+	delete(d.history, name)
+}
+
+func (d *assetDriver) GetHistory(ctx context.Context, name string) ([]*sabakan.Asset, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	history, ok := d.history[name]
+	if !ok {
+		return nil, sabakan.ErrNotFound
+	}
+
+	ret := make([]*sabakan.Asset, len(history))
+	for i, a := range history {
+		copied := *a
+		ret[i] = &copied
+	}
+	return ret, nil
+}
 
+func (d *assetDriver) findVersion(name string, id int) *sabakan.Asset {
+	for _, a := range d.history[name] {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (d *assetDriver) GetVersion(ctx context.Context, name string, id int, h sabakan.AssetHandler) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a := d.findVersion(name, id)
+	if a == nil {
+		return sabakan.ErrNotFound
+	}
+
+	h.ServeContent(a, bytes.NewReader(d.historyData[id]))
 	return nil
 }
 
+func (d *assetDriver) Rollback(ctx context.Context, name string, id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur, ok := d.assets[name]
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	if cur.ID == id {
+		return nil
+	}
+	a := d.findVersion(name, id)
+	if a == nil {
+		return sabakan.ErrNotFound
+	}
+
+	rolled := *a
+	rolled.History = []int{cur.ID}
+	for _, old := range cur.History {
+		if old != id {
+			rolled.History = append(rolled.History, old)
+		}
+	}
+	d.assets[name] = &rolled
+	d.data[name] = d.historyData[id]
+	return nil
+}
+
+// addVersion records the current version of the asset in the history.
+func (d *assetDriver) addVersion(asset *sabakan.Asset, data []byte) {
+	copied := *asset
+	copied.History = nil
+	history := append(d.history[asset.Name], &copied)
+	d.historyData[asset.ID] = data
+
+	if len(history) > maxAssetVersions {
+		kept := map[int]bool{asset.ID: true}
+		for _, id := range asset.History {
+			kept[id] = true
+		}
+		var trimmed []*sabakan.Asset
+		for _, a := range history {
+			if kept[a.ID] {
+				trimmed = append(trimmed, a)
+				continue
+			}
+			delete(d.historyData, a.ID)
+		}
+		history = trimmed
+	}
+	d.history[asset.Name] = history
+}
+
 func (d *assetDriver) newAsset(ctx context.Context, name, contentType string,
 	csum []byte, options map[string]string, r io.Reader) (*sabakan.AssetStatus, error) {
 
@@ -139,6 +260,7 @@ func (d *assetDriver) newAsset(ctx context.Context, name, contentType string,
 
 	d.assets[name] = asset
 	d.data[name] = data
+	d.addVersion(asset, data)
 
 	status := &sabakan.AssetStatus{
 		Status: http.StatusCreated,
@@ -166,6 +288,11 @@ func (d *assetDriver) updateAsset(ctx context.Context, asset *sabakan.Asset,
 		return nil, errors.New("checksum mismatch")
 	}
 
+	history := append([]int{asset.ID}, asset.History...)
+	if len(history) > maxAssetVersions-1 {
+		history = history[:maxAssetVersions-1]
+	}
+	asset.History = history
 	asset.ID = id
 	asset.ContentType = contentType
 	asset.Date = time.Now().UTC()
@@ -173,6 +300,7 @@ func (d *assetDriver) updateAsset(ctx context.Context, asset *sabakan.Asset,
 	asset.Options = options
 
 	d.data[asset.Name] = data
+	d.addVersion(asset, data)
 
 	status := &sabakan.AssetStatus{
 		Status: http.StatusOK,
@@ -181,3 +309,225 @@ func (d *assetDriver) updateAsset(ctx context.Context, asset *sabakan.Asset,
 
 	return status, nil
 }
+
+func (d *assetDriver) CreateUpload(ctx context.Context, upload *sabakan.AssetUpload) (*sabakan.AssetUpload, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastUpload++
+	u := *upload
+	u.ID = strconv.Itoa(d.lastUpload)
+	u.Date = time.Now().UTC()
+	u.Parts = nil
+	d.uploads[u.ID] = &mockUpload{
+		upload: &u,
+		parts:  make(map[int][]byte),
+	}
+
+	ret := u
+	return &ret, nil
+}
+
+func (u *mockUpload) get() *sabakan.AssetUpload {
+	ret := *u.upload
+	ret.Parts = nil
+	for n, data := range u.parts {
+		sum := sha256.Sum256(data)
+		ret.Parts = append(ret.Parts, sabakan.AssetUploadPart{
+			Number: n,
+			Size:   int64(len(data)),
+			Sha256: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(ret.Parts, func(i, j int) bool {
+		return ret.Parts[i].Number < ret.Parts[j].Number
+	})
+	return &ret
+}
+
+func (d *assetDriver) getUpload(name, id string) (*mockUpload, error) {
+	u, ok := d.uploads[id]
+	if !ok || u.upload.Name != name {
+		return nil, sabakan.ErrNotFound
+	}
+	return u, nil
+}
+
+func (d *assetDriver) GetUploads(ctx context.Context, name string) ([]*sabakan.AssetUpload, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	uploads := []*sabakan.AssetUpload{}
+	for _, u := range d.uploads {
+		if u.upload.Name == name {
+			uploads = append(uploads, u.get())
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Date.Before(uploads[j].Date)
+	})
+	return uploads, nil
+}
+
+func (d *assetDriver) GetUpload(ctx context.Context, name, id string) (*sabakan.AssetUpload, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	u, err := d.getUpload(name, id)
+	if err != nil {
+		return nil, err
+	}
+	return u.get(), nil
+}
+
+func (d *assetDriver) PutUploadPart(ctx context.Context, name, id string, number int, csum []byte, r io.Reader) (*sabakan.AssetUploadPart, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	hsum := sha256.Sum256(data)
+	if !bytes.Equal(csum, hsum[:]) {
+		return nil, sabakan.ErrChecksumMismatch
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	u, err := d.getUpload(name, id)
+	if err != nil {
+		return nil, err
+	}
+	u.parts[number] = data
+
+	return &sabakan.AssetUploadPart{
+		Number: number,
+		Size:   int64(len(data)),
+		Sha256: hex.EncodeToString(hsum[:]),
+	}, nil
+}
+
+func (d *assetDriver) CommitUpload(ctx context.Context, name, id string) (*sabakan.AssetStatus, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	mu, err := d.getUpload(name, id)
+	if err != nil {
+		return nil, err
+	}
+	u := mu.get()
+	err = u.VerifyParts()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, p := range u.Parts {
+		buf.Write(mu.parts[p.Number])
+	}
+	hsum := sha256.Sum256(buf.Bytes())
+	if hex.EncodeToString(hsum[:]) != u.Sha256 {
+		return nil, sabakan.ErrChecksumMismatch
+	}
+
+	var status *sabakan.AssetStatus
+	asset, ok := d.assets[name]
+	if ok {
+		status, err = d.updateAsset(ctx, asset, u.ContentType, hsum[:], u.Options, &buf)
+	} else {
+		status, err = d.newAsset(ctx, name, u.ContentType, hsum[:], u.Options, &buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	delete(d.uploads, id)
+	return status, nil
+}
+
+func (d *assetDriver) AbortUpload(ctx context.Context, name, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.getUpload(name, id)
+	if err != nil {
+		return err
+	}
+	delete(d.uploads, id)
+	return nil
+}
+
+func (d *assetDriver) GC(ctx context.Context, dryRun bool) (*sabakan.AssetGCReport, error) {
+	refs := make(map[string]bool)
+	d.ignition.mu.Lock()
+	for _, templates := range d.ignition.ignitions {
+		for _, tmpl := range templates {
+			names, err := tmpl.ReferencedAssets()
+			if err != nil {
+				d.ignition.mu.Unlock()
+				return nil, err
+			}
+			for _, name := range names {
+				refs[name] = true
+			}
+		}
+	}
+	d.ignition.mu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	assets := make([]*sabakan.Asset, 0, len(d.assets))
+	for _, a := range d.assets {
+		assets = append(assets, a)
+	}
+	report := sabakan.NewAssetGCReport(assets, refs)
+	if dryRun {
+		return report, nil
+	}
+
+	for _, a := range report.Unreferenced {
+		d.delete(a.Name)
+	}
+	report.Removed = true
+	return report, nil
+}
+
+func (d *assetDriver) CheckAccess(ctx context.Context, name string, id int, req *sabakan.AssetRequester) error {
+	d.mu.Lock()
+	asset, ok := d.assets[name]
+	if ok && id != 0 {
+		asset = d.findVersion(name, id)
+		ok = asset != nil
+	}
+	d.mu.Unlock()
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	if !asset.IsRestricted() || req.Trusted {
+		return nil
+	}
+
+	var m *sabakan.Machine
+	if len(req.Token) > 0 {
+		var err error
+		m, err = d.machines.machineVerifyToken(ctx, req.Token)
+		if err != nil {
+			return err
+		}
+	} else if req.IP != nil {
+		q := sabakan.Query{"ipv4": req.IP.String()}
+		if req.IP.To4() == nil {
+			q = sabakan.Query{"ipv6": req.IP.String()}
+		}
+		machines, err := d.machines.machineQuery(ctx, q)
+		if err != nil {
+			return err
+		}
+		if len(machines) > 0 {
+			m = machines[0]
+		}
+	}
+	if m == nil || !asset.AllowsMachine(m) {
+		return sabakan.ErrForbidden
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package mock
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"strings"
 	"sync"
 
 	"github.com/cybozu-go/sabakan/v3"
@@ -13,15 +15,16 @@ type leaseUsage struct {
 	leaseRange *sabakan.LeaseRange
 	macMap     map[string]int // MAC address to index-in-range
 	usageMap   map[int]bool
+	declinedBy map[int]string // index-in-range to MAC address
 }
 
-func (l *leaseUsage) lease(mac net.HardwareAddr) (net.IP, error) {
+func (l *leaseUsage) lease(mac net.HardwareAddr, reserved map[int]bool) (net.IP, error) {
 	if idx, ok := l.macMap[mac.String()]; ok {
 		return l.leaseRange.IP(idx), nil
 	}
 
 	for i := 0; i < l.leaseRange.Count; i++ {
-		if l.usageMap[i] {
+		if l.usageMap[i] || reserved[i] {
 			continue
 		}
 		l.usageMap[i] = true
@@ -29,7 +32,7 @@ func (l *leaseUsage) lease(mac net.HardwareAddr) (net.IP, error) {
 		return l.leaseRange.IP(i), nil
 	}
 
-	return nil, errors.New("no leasable IP address found from " + l.leaseRange.Key())
+	return nil, fmt.Errorf("%w from %s", sabakan.ErrNoLeasableAddress, l.leaseRange.Key())
 }
 
 func (l *leaseUsage) renew(mac net.HardwareAddr) error {
@@ -62,6 +65,7 @@ func (l *leaseUsage) decline(mac net.HardwareAddr) {
 
 	declineKey := generateDummyMAC(idx).String()
 	l.macMap[declineKey] = idx
+	l.declinedBy[idx] = key
 	delete(l.macMap, key)
 }
 
@@ -81,20 +85,23 @@ func newLeaseUsage(lr *sabakan.LeaseRange) *leaseUsage {
 		leaseRange: lr,
 		macMap:     make(map[string]int),
 		usageMap:   make(map[int]bool),
+		declinedBy: make(map[int]string),
 	}
 }
 
 type dhcpDriver struct {
-	mu     sync.Mutex
-	driver *driver
-	dhcp   *sabakan.DHCPConfig
-	leases map[string]*leaseUsage
+	mu           sync.Mutex
+	driver       *driver
+	dhcp         *sabakan.DHCPConfig
+	leases       map[string]*leaseUsage
+	reservations map[string]*sabakan.DHCPReservation
 }
 
 func newDHCPDriver(d *driver) *dhcpDriver {
 	return &dhcpDriver{
-		driver: d,
-		leases: make(map[string]*leaseUsage),
+		driver:       d,
+		leases:       make(map[string]*leaseUsage),
+		reservations: make(map[string]*sabakan.DHCPReservation),
 	}
 }
 
@@ -130,6 +137,18 @@ func (d *dhcpDriver) Lease(ctx context.Context, ifaddr net.IP, mac net.HardwareA
 		return nil, errors.New("invalid ifaddr: " + ifaddr.String())
 	}
 
+	reserved := make(map[int]bool)
+	for _, r := range d.reservations {
+		idx, ok := lr.Index(net.ParseIP(r.IP))
+		if !ok {
+			continue
+		}
+		if r.MAC == mac.String() {
+			return net.ParseIP(r.IP).To4(), nil
+		}
+		reserved[idx] = true
+	}
+
 	key := lr.Key()
 	lu := d.leases[key]
 	if lu == nil {
@@ -137,7 +156,13 @@ func (d *dhcpDriver) Lease(ctx context.Context, ifaddr net.IP, mac net.HardwareA
 		d.leases[key] = lu
 	}
 
-	return lu.lease(mac)
+	return lu.lease(mac, reserved)
+}
+
+// isReserved returns true if ip is reserved for mac.
+func (d *dhcpDriver) isReserved(ip net.IP, mac net.HardwareAddr) bool {
+	r, ok := d.reservations[mac.String()]
+	return ok && ip.Equal(net.ParseIP(r.IP))
 }
 
 func (d *dhcpDriver) Renew(ctx context.Context, ciaddr net.IP, mac net.HardwareAddr) error {
@@ -154,6 +179,10 @@ func (d *dhcpDriver) Renew(ctx context.Context, ciaddr net.IP, mac net.HardwareA
 		return errors.New("invalid ciaddr: " + ciaddr.String())
 	}
 
+	if d.isReserved(ciaddr, mac) {
+		return nil
+	}
+
 	key := lr.Key()
 	lu := d.leases[key]
 	if lu == nil {
@@ -198,6 +227,10 @@ func (d *dhcpDriver) Decline(ctx context.Context, ciaddr net.IP, mac net.Hardwar
 		return errors.New("invalid ciaddr: " + ciaddr.String())
 	}
 
+	if d.isReserved(ciaddr, mac) {
+		return nil
+	}
+
 	key := lr.Key()
 	lu := d.leases[key]
 	if lu != nil {
@@ -205,3 +238,164 @@ func (d *dhcpDriver) Decline(ctx context.Context, ciaddr net.IP, mac net.Hardwar
 	}
 	return nil
 }
+
+func (d *dhcpDriver) GetLeases(ctx context.Context) ([]*sabakan.DHCPLease, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	leases := make([]*sabakan.DHCPLease, 0)
+	for key, lu := range d.leases {
+		for mac, idx := range lu.macMap {
+			lease := &sabakan.DHCPLease{
+				Range: key,
+				IP:    lu.leaseRange.IP(idx).String(),
+			}
+			if strings.HasPrefix(mac, "ff:00:") {
+				lease.Declined = true
+				lease.DeclinedBy = lu.declinedBy[idx]
+			} else {
+				lease.MAC = mac
+			}
+			leases = append(leases, lease)
+		}
+	}
+	sabakan.SortDHCPLeases(leases)
+	return leases, nil
+}
+
+func (d *dhcpDriver) GetLease(ctx context.Context, ip net.IP) (*sabakan.DHCPLease, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ipam, err := d.driver.getIPAMConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return nil, sabakan.ErrNotFound
+	}
+	lu := d.leases[lr.Key()]
+	if lu == nil {
+		return nil, sabakan.ErrNotFound
+	}
+	for mac, idx := range lu.macMap {
+		if !lu.leaseRange.IP(idx).Equal(ip) {
+			continue
+		}
+		lease := &sabakan.DHCPLease{
+			Range: lr.Key(),
+			IP:    ip.String(),
+		}
+		if strings.HasPrefix(mac, "ff:00:") {
+			lease.Declined = true
+			lease.DeclinedBy = lu.declinedBy[idx]
+		} else {
+			lease.MAC = mac
+		}
+		return lease, nil
+	}
+	return nil, sabakan.ErrNotFound
+}
+
+func (d *dhcpDriver) DeleteLease(ctx context.Context, ip net.IP) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ipam, err := d.driver.getIPAMConfig()
+	if err != nil {
+		return err
+	}
+
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return sabakan.ErrNotFound
+	}
+	lu := d.leases[lr.Key()]
+	if lu == nil {
+		return sabakan.ErrNotFound
+	}
+	for mac, idx := range lu.macMap {
+		if lu.leaseRange.IP(idx).Equal(ip) {
+			delete(lu.macMap, mac)
+			delete(lu.usageMap, idx)
+			delete(lu.declinedBy, idx)
+			return nil
+		}
+	}
+	return sabakan.ErrNotFound
+}
+
+func (d *dhcpDriver) PutReservation(ctx context.Context, r *sabakan.DHCPReservation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ipam, err := d.driver.getIPAMConfig()
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(r.IP)
+	lr := ipam.LeaseRange(ip)
+	if lr == nil {
+		return errors.New("IP address is not in any lease range: " + r.IP)
+	}
+	idx, ok := lr.Index(ip)
+	if !ok {
+		return errors.New("IP address is not in any lease range: " + r.IP)
+	}
+
+	for _, other := range d.reservations {
+		if other.MAC != r.MAC && ip.Equal(net.ParseIP(other.IP)) {
+			return sabakan.ErrConflicted
+		}
+	}
+	if lu := d.leases[lr.Key()]; lu != nil {
+		for mac, i := range lu.macMap {
+			if i == idx && mac != r.MAC {
+				return sabakan.ErrConflicted
+			}
+		}
+	}
+
+	copied := *r
+	d.reservations[r.MAC] = &copied
+	return nil
+}
+
+func (d *dhcpDriver) GetReservation(ctx context.Context, mac net.HardwareAddr) (*sabakan.DHCPReservation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r, ok := d.reservations[mac.String()]
+	if !ok {
+		return nil, sabakan.ErrNotFound
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (d *dhcpDriver) GetReservations(ctx context.Context) ([]*sabakan.DHCPReservation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reservations := make([]*sabakan.DHCPReservation, 0, len(d.reservations))
+	for _, r := range d.reservations {
+		copied := *r
+		reservations = append(reservations, &copied)
+	}
+	sabakan.SortDHCPReservations(reservations)
+	return reservations, nil
+}
+
+func (d *dhcpDriver) DeleteReservation(ctx context.Context, mac net.HardwareAddr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.reservations[mac.String()]; !ok {
+		return sabakan.ErrNotFound
+	}
+	delete(d.reservations, mac.String())
+	return nil
+}
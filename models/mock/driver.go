@@ -10,32 +10,43 @@ import (
 
 // driver implements all interfaces for sabakan model.
 type driver struct {
-	mu       sync.Mutex
-	ipam     *sabakan.IPAMConfig
-	machines map[string]*sabakan.Machine
-	storage  map[string][]byte
-	log      *sabakan.AuditLog
+	mu          sync.Mutex
+	ipam        *sabakan.IPAMConfig
+	machines    map[string]*sabakan.Machine
+	storage     map[string][]byte
+	inventories map[string][]*sabakan.Inventory
+	tokens      map[string]string
+	log         *sabakan.AuditLog
 }
 
 // NewModel returns sabakan.Model
 func NewModel() sabakan.Model {
 	d := &driver{
-		machines: make(map[string]*sabakan.Machine),
-		storage:  make(map[string][]byte),
+		machines:    make(map[string]*sabakan.Machine),
+		storage:     make(map[string][]byte),
+		inventories: make(map[string][]*sabakan.Inventory),
+		tokens:      make(map[string]string),
 	}
+	ignition := newIgnitionDriver()
+	asset := newAssetDriver(ignition, d)
+	image := newImageDriver()
+	dhcp := newDHCPDriver(d)
 	return sabakan.Model{
 		Runner:       d,
-		IPAM:         ipamDriver{d},
+		IPAM:         ipamDriver{d, dhcp},
 		Machine:      machineDriver{d},
 		Storage:      d,
-		DHCP:         newDHCPDriver(d),
-		Image:        newImageDriver(),
-		Asset:        newAssetDriver(),
-		Ignition:     newIgnitionDriver(),
+		DHCP:         dhcp,
+		Image:        image,
+		Asset:        asset,
+		Ignition:     ignition,
+		Inventory:    inventoryDriver{d},
 		Log:          logDriver{d},
 		KernelParams: newKernelParamsDriver(),
+		Replication:  replicationDriver{asset, image},
 		Health:       newHealthDriver(),
 		Schema:       d,
+		Secret:       newSecretDriver(),
 	}
 }
 
@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"sync"
@@ -18,14 +20,16 @@ type imageData struct {
 }
 
 type imageDriver struct {
-	mu     sync.Mutex
-	index  sabakan.ImageIndex
-	images map[string]imageData
+	mu      sync.Mutex
+	index   sabakan.ImageIndex
+	images  map[string]imageData
+	imports *importRecorder
 }
 
 func newImageDriver() *imageDriver {
 	return &imageDriver{
-		images: make(map[string]imageData),
+		images:  make(map[string]imageData),
+		imports: newImportRecorder(),
 	}
 }
 
@@ -110,11 +114,20 @@ func (d *imageDriver) Upload(ctx context.Context, os, id string, r io.Reader) er
 		ID:   id,
 		Date: time.Now().UTC(),
 		Size: int64(len(kernel) + len(initrd)),
+		Sha256: map[string]string{
+			sabakan.ImageKernelFilename: checksum(kernel),
+			sabakan.ImageInitrdFilename: checksum(initrd),
+		},
 	})
 
 	return nil
 }
 
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (d *imageDriver) Download(ctx context.Context, os, id string, out io.Writer) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -183,7 +196,7 @@ func (d *imageDriver) Delete(ctx context.Context, os, id string) error {
 }
 
 func (d *imageDriver) ServeFile(ctx context.Context, os, filename string,
-	f func(modtime time.Time, content io.ReadSeeker)) error {
+	f func(modtime time.Time, sha256 string, content io.ReadSeeker)) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -201,9 +214,9 @@ func (d *imageDriver) ServeFile(ctx context.Context, os, filename string,
 
 	switch filename {
 	case sabakan.ImageKernelFilename:
-		f(img.Date, bytes.NewReader(data.kernel))
+		f(img.Date, img.Sha256[filename], bytes.NewReader(data.kernel))
 	case sabakan.ImageInitrdFilename:
-		f(img.Date, bytes.NewReader(data.initrd))
+		f(img.Date, img.Sha256[filename], bytes.NewReader(data.initrd))
 	default:
 		return sabakan.ErrNotFound
 	}
@@ -0,0 +1,162 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// importRecorder records statuses of imports.
+// Unlike the etcd driver, imports are done synchronously.
+type importRecorder struct {
+	mu       sync.Mutex
+	statuses map[string]*sabakan.ImportStatus
+}
+
+func newImportRecorder() *importRecorder {
+	return &importRecorder{
+		statuses: make(map[string]*sabakan.ImportStatus),
+	}
+}
+
+func fetch(ctx context.Context, src string, csum []byte) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status from %s: %s", src, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, sabakan.MaxAssetSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > sabakan.MaxAssetSize {
+		return nil, "", fmt.Errorf("source is larger than %d bytes", sabakan.MaxAssetSize)
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], csum) {
+		return nil, "", sabakan.ErrChecksumMismatch
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return data, contentType, nil
+}
+
+func (i *importRecorder) run(ctx context.Context, key string, status *sabakan.ImportStatus, csum []byte,
+	commit func(data []byte, contentType string) (string, error)) (*sabakan.ImportStatus, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	status.Sha256 = hex.EncodeToString(csum)
+	status.Server = "http://localhost:10080"
+	status.Started = time.Now().UTC()
+
+	data, contentType, err := fetch(ctx, status.Source, csum)
+	if err == nil {
+		status.Received = int64(len(data))
+		status.Size = int64(len(data))
+		var id string
+		id, err = commit(data, contentType)
+		status.ID = id
+	}
+	if err != nil {
+		status.State = sabakan.ImportFailed
+		status.Error = err.Error()
+	} else {
+		status.State = sabakan.ImportCompleted
+	}
+	status.Updated = time.Now().UTC()
+
+	i.statuses[key] = status
+	copied := *status
+	return &copied, nil
+}
+
+func (i *importRecorder) get(key string) (*sabakan.ImportStatus, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	status, ok := i.statuses[key]
+	if !ok {
+		return nil, sabakan.ErrNotFound
+	}
+	copied := *status
+	return &copied, nil
+}
+
+// cancel marks the running import as failed.
+func (i *importRecorder) cancel(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	status, ok := i.statuses[key]
+	if !ok || status.State != sabakan.ImportRunning {
+		return sabakan.ErrNotFound
+	}
+	status.State = sabakan.ImportFailed
+	status.Error = "import canceled"
+	status.Updated = time.Now().UTC()
+	return nil
+}
+
+func (d *assetDriver) Import(ctx context.Context, name, src string, csum []byte, options map[string]string) (*sabakan.ImportStatus, error) {
+	status := &sabakan.ImportStatus{
+		Kind:   sabakan.ReplicaKindAsset,
+		Name:   name,
+		Source: src,
+	}
+	return d.imports.run(ctx, name, status, csum, func(data []byte, contentType string) (string, error) {
+		st, err := d.Put(ctx, name, contentType, csum, options, bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(st.ID), nil
+	})
+}
+
+func (d *assetDriver) GetImport(ctx context.Context, name string) (*sabakan.ImportStatus, error) {
+	return d.imports.get(name)
+}
+
+func (d *assetDriver) CancelImport(ctx context.Context, name string) error {
+	return d.imports.cancel(name)
+}
+
+func (d *imageDriver) Import(ctx context.Context, os, id, src string, csum []byte) (*sabakan.ImportStatus, error) {
+	status := &sabakan.ImportStatus{
+		Kind:   sabakan.ReplicaKindImage,
+		Name:   os,
+		ID:     id,
+		Source: src,
+	}
+	return d.imports.run(ctx, os+"/"+id, status, csum, func(data []byte, contentType string) (string, error) {
+		return id, d.Upload(ctx, os, id, bytes.NewReader(data))
+	})
+}
+
+func (d *imageDriver) GetImport(ctx context.Context, os, id string) (*sabakan.ImportStatus, error) {
+	return d.imports.get(os + "/" + id)
+}
+
+func (d *imageDriver) CancelImport(ctx context.Context, os, id string) error {
+	return d.imports.cancel(os + "/" + id)
+}
@@ -0,0 +1,86 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+const maxInventories = 10
+
+type inventoryDriver struct {
+	*driver
+}
+
+func (d inventoryDriver) Put(ctx context.Context, serial string, inv *sabakan.Inventory) (*sabakan.Inventory, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.machines[serial]; !ok {
+		return nil, sabakan.ErrNotFound
+	}
+
+	history := d.inventories[serial]
+	var prev *sabakan.Inventory
+	if len(history) > 0 {
+		prev = history[len(history)-1]
+		if len(sabakan.DiffInventory(prev, inv)) == 0 {
+			return prev, nil
+		}
+	}
+
+	newInv := *inv
+	newInv.Serial = serial
+	newInv.Version = 1
+	if prev != nil {
+		newInv.Version = prev.Version + 1
+	}
+	newInv.Timestamp = time.Now().UTC()
+
+	history = append(history, &newInv)
+	if len(history) > maxInventories {
+		history = history[len(history)-maxInventories:]
+	}
+	d.inventories[serial] = history
+	return &newInv, nil
+}
+
+func (d inventoryDriver) Get(ctx context.Context, serial string) (*sabakan.Inventory, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	history := d.inventories[serial]
+	if len(history) == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+	return history[len(history)-1], nil
+}
+
+func (d inventoryDriver) GetHistory(ctx context.Context, serial string) ([]*sabakan.Inventory, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	history := d.inventories[serial]
+	if len(history) == 0 {
+		return nil, sabakan.ErrNotFound
+	}
+	return append([]*sabakan.Inventory(nil), history...), nil
+}
+
+func (d inventoryDriver) GetAll(ctx context.Context) ([]*sabakan.Inventory, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inventories := make([]*sabakan.Inventory, 0, len(d.inventories))
+	for _, history := range d.inventories {
+		if len(history) > 0 {
+			inventories = append(inventories, history[len(history)-1])
+		}
+	}
+	sort.Slice(inventories, func(i, j int) bool {
+		return inventories[i].Serial < inventories[j].Serial
+	})
+	return inventories, nil
+}
@@ -3,17 +3,38 @@ package mock
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
 
-func (d *driver) putIPAMConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
+func (d *driver) putIPAMConfig(ctx context.Context, config *sabakan.IPAMConfig, force bool, dhcp *dhcpDriver) error {
+	// read DHCP leases and reservations before locking d.mu
+	// because dhcpDriver may lock it.
+	leases, err := dhcp.GetLeases(ctx)
+	if err != nil {
+		return err
+	}
+	reservations, err := dhcp.GetReservations(ctx)
+	if err != nil {
+		return err
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if len(d.machines) > 0 {
-		return errors.New("machines already exist")
+	if !force {
+		machines := make([]*sabakan.Machine, 0, len(d.machines))
+		for _, m := range d.machines {
+			if config.AddressChange(m) != nil {
+				return sabakan.ErrConflicted
+			}
+			machines = append(machines, m)
+		}
+		if len(config.Conflicts(machines, leases, reservations)) > 0 {
+			return sabakan.ErrConflicted
+		}
 	}
 	copied := *config
 	d.ipam = &copied
@@ -33,14 +54,78 @@ func (d *driver) getIPAMConfig() (*sabakan.IPAMConfig, error) {
 	return &copied, nil
 }
 
+func (d *driver) ipamAddressChanges(config *sabakan.IPAMConfig) []*sabakan.IPAMChange {
+	changes := []*sabakan.IPAMChange{}
+	for _, m := range d.machines {
+		if c := config.AddressChange(m); c != nil {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Serial < changes[j].Serial
+	})
+	return changes
+}
+
+func (d *driver) ipamMigrate(ctx context.Context) ([]*sabakan.IPAMChange, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ipam == nil {
+		return nil, errors.New("IPAMConfig is not set")
+	}
+
+	changes := d.ipamAddressChanges(d.ipam)
+	for _, c := range changes {
+		d.ipam.GenerateIP(d.machines[c.Serial])
+	}
+	return changes, nil
+}
+
 type ipamDriver struct {
 	*driver
+	dhcp *dhcpDriver
 }
 
 func (d ipamDriver) PutConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
-	return d.putIPAMConfig(ctx, config)
+	return d.putIPAMConfig(ctx, config, false, d.dhcp)
+}
+
+func (d ipamDriver) ForcePutConfig(ctx context.Context, config *sabakan.IPAMConfig) error {
+	return d.putIPAMConfig(ctx, config, true, d.dhcp)
 }
 
 func (d ipamDriver) GetConfig() (*sabakan.IPAMConfig, error) {
 	return d.getIPAMConfig()
 }
+
+func (d ipamDriver) AddressChanges(ctx context.Context, config *sabakan.IPAMConfig) ([]*sabakan.IPAMChange, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ipamAddressChanges(config), nil
+}
+
+func (d ipamDriver) Conflicts(ctx context.Context, config *sabakan.IPAMConfig) ([]*sabakan.IPAMConflict, error) {
+	leases, err := d.dhcp.GetLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reservations, err := d.dhcp.GetReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	machines := make([]*sabakan.Machine, 0, len(d.machines))
+	for _, m := range d.machines {
+		machines = append(machines, m)
+	}
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].Spec.Serial < machines[j].Spec.Serial
+	})
+	return config.Conflicts(machines, leases, reservations), nil
+}
+
+func (d ipamDriver) Migrate(ctx context.Context) ([]*sabakan.IPAMChange, error) {
+	return d.ipamMigrate(ctx)
+}
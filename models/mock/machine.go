@@ -18,12 +18,32 @@ func (d *driver) machineRegister(ctx context.Context, machines []*sabakan.Machin
 			return sabakan.ErrConflicted
 		}
 	}
+	owners := make(map[string]string)
+	for _, m := range machines {
+		for _, mac := range m.Spec.MACs {
+			if _, ok := owners[mac]; ok || d.macOwner(mac) != "" {
+				return sabakan.ErrConflicted
+			}
+			owners[mac] = m.Spec.Serial
+		}
+	}
 	for _, m := range machines {
 		d.machines[m.Spec.Serial] = m
 	}
 	return nil
 }
 
+// macOwner returns the serial of the machine to which mac is bound.
+// The caller must hold d.mu.
+func (d *driver) macOwner(mac string) string {
+	for serial, m := range d.machines {
+		if m.HasMAC(mac) {
+			return serial
+		}
+	}
+	return ""
+}
+
 func (d *driver) machineGet(ctx context.Context, serial string) (*sabakan.Machine, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -79,6 +99,54 @@ func (d *driver) machineDeleteLabel(ctx context.Context, serial string, label st
 	return m.DeleteLabel(label)
 }
 
+func (d *driver) machinePutAnnotation(ctx context.Context, serial string, name, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m, ok := d.machines[serial]
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	return m.PutAnnotation(name, value)
+}
+
+func (d *driver) machineDeleteAnnotation(ctx context.Context, serial string, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m, ok := d.machines[serial]
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	return m.DeleteAnnotation(name)
+}
+
+func (d *driver) machineAddMAC(ctx context.Context, serial string, mac string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m, ok := d.machines[serial]
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	if owner := d.macOwner(mac); owner != "" && owner != serial {
+		return sabakan.ErrConflicted
+	}
+	m.AddMAC(mac)
+	return nil
+}
+
+func (d *driver) machineRemoveMAC(ctx context.Context, serial string, mac string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m, ok := d.machines[serial]
+	if !ok {
+		return sabakan.ErrNotFound
+	}
+	return m.RemoveMAC(mac)
+}
+
 func (d *driver) machineSetRetireDate(ctx context.Context, serial string, date time.Time) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -122,9 +190,52 @@ func (d *driver) machineDelete(ctx context.Context, serial string) error {
 	}
 
 	delete(d.machines, serial)
+	delete(d.inventories, serial)
+	delete(d.tokens, serial)
 	return nil
 }
 
+func (d *driver) machineIssueToken(ctx context.Context, serial string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.machines[serial]; !ok {
+		return "", sabakan.ErrNotFound
+	}
+	token, err := sabakan.NewMachineToken(serial)
+	if err != nil {
+		return "", err
+	}
+	d.tokens[serial] = sabakan.HashMachineToken(token)
+	return token, nil
+}
+
+func (d *driver) machineRevokeToken(ctx context.Context, serial string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.tokens[serial]; !ok {
+		return sabakan.ErrNotFound
+	}
+	delete(d.tokens, serial)
+	return nil
+}
+
+func (d *driver) machineVerifyToken(ctx context.Context, token string) (*sabakan.Machine, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	serial, ok := sabakan.ParseMachineToken(token)
+	if !ok || d.tokens[serial] != sabakan.HashMachineToken(token) {
+		return nil, sabakan.ErrForbidden
+	}
+	m, ok := d.machines[serial]
+	if !ok {
+		return nil, sabakan.ErrForbidden
+	}
+	return m, nil
+}
+
 type machineDriver struct {
 	*driver
 }
@@ -149,6 +260,22 @@ func (d machineDriver) DeleteLabel(ctx context.Context, serial string, label str
 	return d.machineDeleteLabel(ctx, serial, label)
 }
 
+func (d machineDriver) PutAnnotation(ctx context.Context, serial string, name, value string) error {
+	return d.machinePutAnnotation(ctx, serial, name, value)
+}
+
+func (d machineDriver) DeleteAnnotation(ctx context.Context, serial string, name string) error {
+	return d.machineDeleteAnnotation(ctx, serial, name)
+}
+
+func (d machineDriver) AddMAC(ctx context.Context, serial string, mac string) error {
+	return d.machineAddMAC(ctx, serial, mac)
+}
+
+func (d machineDriver) RemoveMAC(ctx context.Context, serial string, mac string) error {
+	return d.machineRemoveMAC(ctx, serial, mac)
+}
+
 func (d machineDriver) SetRetireDate(ctx context.Context, serial string, date time.Time) error {
 	return d.machineSetRetireDate(ctx, serial, date)
 }
@@ -160,3 +287,11 @@ func (d machineDriver) Query(ctx context.Context, query sabakan.Query) ([]*sabak
 func (d machineDriver) Delete(ctx context.Context, serial string) error {
 	return d.machineDelete(ctx, serial)
 }
+
+func (d machineDriver) IssueToken(ctx context.Context, serial string) (string, error) {
+	return d.machineIssueToken(ctx, serial)
+}
+
+func (d machineDriver) RevokeToken(ctx context.Context, serial string) error {
+	return d.machineRevokeToken(ctx, serial)
+}
@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// mockServerURL is the URL of the only server in the mock.
+const mockServerURL = "http://localhost:10080"
+
+type replicationDriver struct {
+	asset *assetDriver
+	image *imageDriver
+}
+
+func (d replicationDriver) GetStatus(ctx context.Context) (*sabakan.ReplicationStatus, error) {
+	assets, err := d.asset.GetInfoAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+	index, err := d.image.GetIndex(ctx, "coreos")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &sabakan.ReplicaReport{
+		URL:    mockServerURL,
+		Images: map[string][]string{"coreos": {}},
+		Date:   time.Now().UTC(),
+	}
+	for _, a := range assets {
+		report.Assets = append(report.Assets, a.ID)
+	}
+	for _, img := range index {
+		report.Images["coreos"] = append(report.Images["coreos"], img.ID)
+	}
+
+	images := map[string]sabakan.ImageIndex{"coreos": index}
+	return sabakan.NewReplicationStatus([]*sabakan.ReplicaReport{report}, assets, images), nil
+}
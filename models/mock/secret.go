@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+type secretKey struct {
+	scope  sabakan.SecretScope
+	target string
+	name   string
+}
+
+type mockSecret struct {
+	info  *sabakan.SecretInfo
+	value []byte
+}
+
+type secretDriver struct {
+	mu      sync.Mutex
+	secrets map[secretKey]*mockSecret
+}
+
+func newSecretDriver() *secretDriver {
+	return &secretDriver{
+		secrets: make(map[secretKey]*mockSecret),
+	}
+}
+
+func (d *secretDriver) Put(ctx context.Context, scope sabakan.SecretScope, target, name string, value []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.secrets[secretKey{scope, target, name}] = &mockSecret{
+		info: &sabakan.SecretInfo{
+			Name:   name,
+			Scope:  scope,
+			Target: target,
+			Date:   time.Now().UTC(),
+		},
+		value: append([]byte(nil), value...),
+	}
+	return nil
+}
+
+func (d *secretDriver) List(ctx context.Context) ([]*sabakan.SecretInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	secrets := make([]*sabakan.SecretInfo, 0, len(d.secrets))
+	for _, s := range d.secrets {
+		info := *s.info
+		secrets = append(secrets, &info)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		a, b := secrets[i], secrets[j]
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Name < b.Name
+	})
+	return secrets, nil
+}
+
+func (d *secretDriver) Delete(ctx context.Context, scope sabakan.SecretScope, target, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := secretKey{scope, target, name}
+	if _, ok := d.secrets[key]; !ok {
+		return sabakan.ErrNotFound
+	}
+	delete(d.secrets, key)
+	return nil
+}
+
+func (d *secretDriver) Resolve(ctx context.Context, m *sabakan.Machine, name string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := []secretKey{
+		{sabakan.SecretScopeSerial, m.Spec.Serial, name},
+		{sabakan.SecretScopeRole, m.Spec.Role, name},
+		{sabakan.SecretScopeGlobal, "", name},
+	}
+	for _, key := range keys {
+		if s, ok := d.secrets[key]; ok {
+			return s.value, nil
+		}
+	}
+	return nil, sabakan.ErrNotFound
+}
@@ -0,0 +1,155 @@
+// Package objstore implements a minimal client for S3-compatible object storages.
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRegion = "us-east-1"
+
+// ErrNotFound is returned when the object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Config is the configuration of an S3-compatible object storage.
+//
+// Objects are accessed by path-style URLs like "<endpoint>/<bucket>/<key>".
+type Config struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access-key"`
+	SecretKey string `json:"secret-key"`
+}
+
+// Client is a client of an S3-compatible object storage.
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+	now       func() time.Time
+}
+
+// NewClient returns a new client.
+func NewClient(cfg Config, client *http.Client) (*Client, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket must be specified")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("invalid endpoint: " + cfg.Endpoint)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{
+		endpoint:  u,
+		region:    region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		http:      client,
+		now:       time.Now,
+	}, nil
+}
+
+func (c *Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = ""
+	return &u
+}
+
+func (c *Client) do(ctx context.Context, method, key string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	c.sign(req, unsignedPayload, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Put stores an object of size bytes read from r.
+func (c *Client) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := c.do(ctx, http.MethodPut, key, r, size)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// Get returns the contents of an object.
+// Callers must close the returned reader.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Size returns the size of an object.
+func (c *Client) Size(ctx context.Context, key string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+}
+
+// Delete removes an object.  Removing a missing object is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, 0)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// PresignGet returns a URL to download an object without credentials.
+// The URL is valid for expires.
+func (c *Client) PresignGet(key string, expires time.Duration) string {
+	return c.presign(http.MethodGet, c.objectURL(key), expires, c.now())
+}
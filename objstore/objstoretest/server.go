@@ -0,0 +1,132 @@
+// Package objstoretest provides an in-memory S3-compatible server for tests.
+package objstoretest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cybozu-go/sabakan/v3/objstore"
+)
+
+// Test credentials accepted by Server.
+const (
+	AccessKey = "sabakan-test"
+	SecretKey = "sabakan-test-secret"
+)
+
+// Server is an in-memory stand-in of MinIO.
+// It supports PUT, GET, HEAD and DELETE of objects with path-style URLs,
+// and accepts requests signed by the test credentials.
+type Server struct {
+	*httptest.Server
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    int
+}
+
+// NewServer starts a new server with a bucket.
+// Callers should call Close when finished.
+func NewServer(bucket string) *Server {
+	s := &Server{
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Config returns a configuration to access the server.
+func (s *Server) Config() objstore.Config {
+	return objstore.Config{
+		Endpoint:  s.URL,
+		Bucket:    s.bucket,
+		AccessKey: AccessKey,
+		SecretKey: SecretKey,
+	}
+}
+
+// Object returns the contents of an object.
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+// Len returns the number of objects.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.objects)
+}
+
+// Gets returns how many times objects have been downloaded.
+func (s *Server) Gets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gets
+}
+
+func authorized(r *http.Request) bool {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		for _, f := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			if strings.HasPrefix(f, "Credential=") {
+				credential = f[len("Credential="):]
+			}
+		}
+	}
+	return strings.HasPrefix(credential, AccessKey+"/")
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	prefix := "/" + s.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := r.URL.Path[len(prefix):]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			s.gets++
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -3,12 +3,38 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 
+	"github.com/cybozu-go/sabakan/v3"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 )
 
-var assetsUploadMeta map[string]string
+var (
+	assetsUploadMeta      map[string]string
+	assetsUploadResumable bool
+	assetsUploadPartSize  int64
+	assetsWaitReplicas    int
+	assetsGCDryRun        bool
+	assetsAllowRoles      string
+	assetsAllowLabels     string
+)
+
+// assetOptions adds the options given by --allow-roles and --allow-labels to meta.
+// They cannot be given by --meta because their values contain commas.
+func assetOptions(meta map[string]string) map[string]string {
+	options := make(map[string]string)
+	for k, v := range meta {
+		options[k] = v
+	}
+	if len(assetsAllowRoles) > 0 {
+		options[sabakan.AssetOptionAllowRoles] = assetsAllowRoles
+	}
+	if len(assetsAllowLabels) > 0 {
+		options[sabakan.AssetOptionAllowLabels] = assetsAllowLabels
+	}
+	return options
+}
 
 var assetsCmd = &cobra.Command{
 	Use:   "assets",
@@ -63,13 +89,22 @@ var assetsInfoCmd = &cobra.Command{
 var assetsUploadCmd = &cobra.Command{
 	Use:   "upload NAME FILE",
 	Short: "add a new asset or update current asset",
-	Long:  `Add a new asset or update current asset to sabakan.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Add a new asset or update current asset to sabakan.
+
+With --resumable, FILE is uploaded in parts.  If the upload is interrupted,
+running this again with the same FILE resumes it.`,
+	Args: cobra.ExactArgs(2),
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name, path := args[0], args[1]
 		well.Go(func(ctx context.Context) error {
-			st, err := httpApi.AssetsUpload(ctx, name, path, assetsUploadMeta)
+			var st *sabakan.AssetStatus
+			var err error
+			if assetsUploadResumable {
+				st, err = httpApi.AssetsUploadResumable(ctx, name, path, assetOptions(assetsUploadMeta), assetsUploadPartSize)
+			} else {
+				st, err = httpApi.AssetsUpload(ctx, name, path, assetOptions(assetsUploadMeta))
+			}
 			if err != nil {
 				return err
 			}
@@ -83,6 +118,24 @@ var assetsUploadCmd = &cobra.Command{
 	},
 }
 
+var assetsDownloadCmd = &cobra.Command{
+	Use:   "download NAME FILE",
+	Short: "download the asset",
+	Long: `Download the asset to FILE.
+
+If the download is interrupted, running this again resumes it.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, path := args[0], args[1]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.AssetsDownload(ctx, name, path)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 var assetsDeleteCmd = &cobra.Command{
 	Use:   "delete NAME",
 	Short: "delete the registered asset",
@@ -99,12 +152,125 @@ var assetsDeleteCmd = &cobra.Command{
 	},
 }
 
+var assetsHistoryCmd = &cobra.Command{
+	Use:   "history NAME",
+	Short: "list versions of the asset",
+	Long:  `List metadata of the versions of the asset kept for rollback.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		well.Go(func(ctx context.Context) error {
+			history, err := httpApi.AssetsHistory(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(history)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var assetsRollbackCmd = &cobra.Command{
+	Use:   "rollback NAME ID",
+	Short: "roll back the asset to a previous version",
+	Long:  `Make the version of ID the current version of the asset.`,
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		well.Go(func(ctx context.Context) error {
+			st, err := httpApi.AssetsRollback(ctx, name, id)
+			if err != nil {
+				return err
+			}
+
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(st)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var assetsWaitCmd = &cobra.Command{
+	Use:   "wait NAME",
+	Short: "wait for the asset to be replicated",
+	Long: `Wait until the current version of the asset is held by sabakan servers.
+
+By default, this waits for all running servers.`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		well.Go(func(ctx context.Context) error {
+			rs, err := httpApi.AssetsWait(ctx, name, assetsWaitReplicas)
+			if err != nil {
+				return err
+			}
+
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(rs)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var assetsGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "remove assets no ignition template references",
+	Long: `Remove assets that are not referenced by URLs of remote files in
+any ignition template, and show the removed assets.
+
+With --dry-run, this only shows the assets to be removed.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			report, err := httpApi.AssetsGC(ctx, assetsGCDryRun)
+			if err != nil {
+				return err
+			}
+
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(report)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 func init() {
 	assetsUploadCmd.Flags().StringToStringVar(&assetsUploadMeta, "meta", nil, "Additional metadata for the assets as <KEY1>=<VALUE1>,<KEY2>=<VALUE2>,...")
+	for _, c := range []*cobra.Command{assetsUploadCmd, assetsImportCmd} {
+		c.Flags().StringVar(&assetsAllowRoles, "allow-roles", "", "Restrict downloads to machines of the comma-separated roles")
+		c.Flags().StringVar(&assetsAllowLabels, "allow-labels", "", "Restrict downloads to machines that match the label selector")
+	}
+	assetsUploadCmd.Flags().BoolVar(&assetsUploadResumable, "resumable", false, "Upload in parts so that an interrupted upload can be resumed")
+	assetsUploadCmd.Flags().Int64Var(&assetsUploadPartSize, "part-size", 64<<20, "Size of each part in bytes for --resumable")
+	assetsGCCmd.Flags().BoolVar(&assetsGCDryRun, "dry-run", false, "Only show the assets to be removed")
+	assetsWaitCmd.Flags().IntVar(&assetsWaitReplicas, "replicas", 0, "The number of servers to hold the asset; 0 means all servers")
 
 	assetsCmd.AddCommand(assetsIndexCmd)
 	assetsCmd.AddCommand(assetsInfoCmd)
 	assetsCmd.AddCommand(assetsUploadCmd)
+	assetsCmd.AddCommand(assetsDownloadCmd)
 	assetsCmd.AddCommand(assetsDeleteCmd)
+	assetsCmd.AddCommand(assetsHistoryCmd)
+	assetsCmd.AddCommand(assetsRollbackCmd)
+	assetsCmd.AddCommand(assetsWaitCmd)
+	assetsCmd.AddCommand(assetsGCCmd)
 	rootCmd.AddCommand(assetsCmd)
 }
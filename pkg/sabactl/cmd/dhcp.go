@@ -10,7 +10,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var dhcpConfigFile string
+var (
+	dhcpConfigFile string
+	dhcpLeasesIP   string
+	dhcpLeasesMAC  string
+
+	dhcpLeasesDeclined bool
+
+	dhcpReservationHostname string
+	dhcpReservationBootURL  string
+)
 
 var dhcpCmd = &cobra.Command{
 	Use:   "dhcp",
@@ -64,11 +73,169 @@ var dhcpSetCmd = &cobra.Command{
 	},
 }
 
+var dhcpLeasesCmd = &cobra.Command{
+	Use:   "leases",
+	Short: "manage DHCP leases",
+	Long:  `List and delete DHCP leases in sabakan.`,
+	RunE:  dummyRunFunc,
+}
+
+var dhcpLeasesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list active DHCP leases",
+	Long: `List active DHCP leases in sabakan.
+Leases can be filtered by --ip and/or --mac.
+With --declined, only quarantined addresses declined by clients are listed.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := make(map[string]string)
+		if len(dhcpLeasesIP) > 0 {
+			params["ip"] = dhcpLeasesIP
+		}
+		if len(dhcpLeasesMAC) > 0 {
+			params["mac"] = dhcpLeasesMAC
+		}
+		if dhcpLeasesDeclined {
+			params["declined"] = "true"
+		}
+
+		well.Go(func(ctx context.Context) error {
+			leases, err := httpApi.DHCPLeasesList(ctx, params)
+			if err != nil {
+				return err
+			}
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(leases)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var dhcpLeasesDeleteCmd = &cobra.Command{
+	Use:   "delete IP",
+	Short: "delete a DHCP lease",
+	Long: `Delete the DHCP lease of IP forcibly.
+The address becomes available for other clients immediately.`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return httpApi.DHCPLeaseDelete(ctx, args[0])
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var dhcpReservationsCmd = &cobra.Command{
+	Use:   "reservations",
+	Short: "manage static DHCP reservations",
+	Long:  `List, set and delete static DHCP reservations in sabakan.`,
+	RunE:  dummyRunFunc,
+}
+
+var dhcpReservationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list static DHCP reservations",
+	Long:  `List static DHCP reservations in sabakan.`,
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			reservations, err := httpApi.DHCPReservationsList(ctx)
+			if err != nil {
+				return err
+			}
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(reservations)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var dhcpReservationsGetCmd = &cobra.Command{
+	Use:   "get MAC",
+	Short: "show a static DHCP reservation",
+	Long:  `Show the static DHCP reservation of MAC.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			r, err := httpApi.DHCPReservationGet(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(r)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var dhcpReservationsSetCmd = &cobra.Command{
+	Use:   "set MAC IP",
+	Short: "add or update a static DHCP reservation",
+	Long: `Reserve IP for MAC.
+IP must be in a DHCP lease range, i.e. not a node or BMC address.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := &sabakan.DHCPReservation{
+			MAC:      args[0],
+			IP:       args[1],
+			Hostname: dhcpReservationHostname,
+			BootURL:  dhcpReservationBootURL,
+		}
+		well.Go(func(ctx context.Context) error {
+			return httpApi.DHCPReservationSet(ctx, r)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var dhcpReservationsDeleteCmd = &cobra.Command{
+	Use:   "delete MAC",
+	Short: "delete a static DHCP reservation",
+	Long:  `Delete the static DHCP reservation of MAC.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return httpApi.DHCPReservationDelete(ctx, args[0])
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 func init() {
 	dhcpSetCmd.Flags().StringVarP(&dhcpConfigFile, "file", "f", "", "DHCP configuration in json")
 	dhcpSetCmd.MarkFlagRequired("file")
 
 	dhcpCmd.AddCommand(dhcpGetCmd)
 	dhcpCmd.AddCommand(dhcpSetCmd)
+
+	dhcpLeasesListCmd.Flags().StringVar(&dhcpLeasesIP, "ip", "", "show only the lease of this IP address")
+	dhcpLeasesListCmd.Flags().StringVar(&dhcpLeasesMAC, "mac", "", "show only the lease of this MAC address")
+	dhcpLeasesListCmd.Flags().BoolVar(&dhcpLeasesDeclined, "declined", false, "show only declined addresses")
+	dhcpLeasesCmd.AddCommand(dhcpLeasesListCmd)
+	dhcpLeasesCmd.AddCommand(dhcpLeasesDeleteCmd)
+	dhcpCmd.AddCommand(dhcpLeasesCmd)
+
+	dhcpReservationsSetCmd.Flags().StringVar(&dhcpReservationHostname, "hostname", "", "host name sent to the client")
+	dhcpReservationsSetCmd.Flags().StringVar(&dhcpReservationBootURL, "boot-url", "", "boot file URL sent to the client")
+	dhcpReservationsCmd.AddCommand(dhcpReservationsListCmd)
+	dhcpReservationsCmd.AddCommand(dhcpReservationsGetCmd)
+	dhcpReservationsCmd.AddCommand(dhcpReservationsSetCmd)
+	dhcpReservationsCmd.AddCommand(dhcpReservationsDeleteCmd)
+	dhcpCmd.AddCommand(dhcpReservationsCmd)
 	rootCmd.AddCommand(dhcpCmd)
 }
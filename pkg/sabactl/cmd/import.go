@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importSha256 string
+	importWait   bool
+	importMeta   map[string]string
+)
+
+// printImportProgress returns a function to print the progress of an import to stderr.
+func printImportProgress(cmd *cobra.Command) func(*sabakan.ImportStatus) {
+	return func(st *sabakan.ImportStatus) {
+		if st.State != sabakan.ImportRunning {
+			return
+		}
+		if st.Size > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: received %d / %d bytes (%d%%)\n",
+				st.Name, st.Received, st.Size, st.Received*100/st.Size)
+			return
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: received %d bytes\n", st.Name, st.Received)
+	}
+}
+
+// runImport starts an import by start, and waits for it by wait if --wait is given.
+func runImport(cmd *cobra.Command,
+	start func(ctx context.Context) (*sabakan.ImportStatus, error),
+	wait func(ctx context.Context, f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error)) error {
+	well.Go(func(ctx context.Context) error {
+		st, err := start(ctx)
+		if err != nil {
+			return err
+		}
+		if importWait {
+			st, err = wait(ctx, printImportProgress(cmd))
+		}
+
+		e := json.NewEncoder(cmd.OutOrStdout())
+		e.SetIndent("", "  ")
+		if eerr := e.Encode(st); eerr != nil {
+			return eerr
+		}
+		return err
+	})
+	well.Stop()
+	return well.Wait()
+}
+
+func showImportStatus(cmd *cobra.Command, get func(ctx context.Context) (*sabakan.ImportStatus, error)) error {
+	well.Go(func(ctx context.Context) error {
+		st, err := get(ctx)
+		if err != nil {
+			return err
+		}
+
+		e := json.NewEncoder(cmd.OutOrStdout())
+		e.SetIndent("", "  ")
+		return e.Encode(st)
+	})
+	well.Stop()
+	return well.Wait()
+}
+
+var assetsImportCmd = &cobra.Command{
+	Use:   "import NAME URL",
+	Short: "make sabakan fetch an asset from URL",
+	Long: `Make sabakan fetch an asset from URL, and verify it by the SHA256
+checksum given by --sha256.
+
+The asset is fetched in background.  With --wait, this shows the progress
+and waits for the import to finish.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, src := args[0], args[1]
+		return runImport(cmd, func(ctx context.Context) (*sabakan.ImportStatus, error) {
+			return httpApi.AssetsImport(ctx, name, src, importSha256, assetOptions(importMeta))
+		}, func(ctx context.Context, f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error) {
+			return httpApi.AssetsImportWait(ctx, name, f)
+		})
+	},
+}
+
+var assetsImportStatusCmd = &cobra.Command{
+	Use:   "import-status NAME",
+	Short: "show the status of the last import of an asset",
+	Long:  `Show the status of the last import of an asset.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		return showImportStatus(cmd, func(ctx context.Context) (*sabakan.ImportStatus, error) {
+			return httpApi.AssetsImportStatus(ctx, name)
+		})
+	},
+}
+
+var assetsImportCancelCmd = &cobra.Command{
+	Use:   "import-cancel NAME",
+	Short: "cancel the running import of an asset",
+	Long:  `Cancel the running import of an asset.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.AssetsImportCancel(ctx, name)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var imagesImportCmd = &cobra.Command{
+	Use:   "import ID URL",
+	Short: "make sabakan fetch an image archive from URL",
+	Long: `Make sabakan fetch a tar archive of an image from URL, and verify it
+by the SHA256 checksum given by --sha256.  The archive should contain
+the kernel and the initrd as "sabactl images upload" creates.
+
+The image is fetched in background.  With --wait, this shows the progress
+and waits for the import to finish.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, src := args[0], args[1]
+		return runImport(cmd, func(ctx context.Context) (*sabakan.ImportStatus, error) {
+			return httpApi.ImagesImport(ctx, imagesOS, id, src, importSha256)
+		}, func(ctx context.Context, f func(*sabakan.ImportStatus)) (*sabakan.ImportStatus, error) {
+			return httpApi.ImagesImportWait(ctx, imagesOS, id, f)
+		})
+	},
+}
+
+var imagesImportStatusCmd = &cobra.Command{
+	Use:   "import-status ID",
+	Short: "show the status of the last import of an image",
+	Long:  `Show the status of the last import of an image.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		return showImportStatus(cmd, func(ctx context.Context) (*sabakan.ImportStatus, error) {
+			return httpApi.ImagesImportStatus(ctx, imagesOS, id)
+		})
+	},
+}
+
+var imagesImportCancelCmd = &cobra.Command{
+	Use:   "import-cancel ID",
+	Short: "cancel the running import of an image",
+	Long:  `Cancel the running import of an image.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.ImagesImportCancel(ctx, imagesOS, id)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{assetsImportCmd, imagesImportCmd} {
+		c.Flags().StringVar(&importSha256, "sha256", "", "Hex-encoded SHA256 checksum of the source")
+		c.Flags().BoolVar(&importWait, "wait", false, "Wait for the import to finish")
+		c.MarkFlagRequired("sha256")
+	}
+	assetsImportCmd.Flags().StringToStringVar(&importMeta, "meta", nil, "Additional metadata for the asset as <KEY1>=<VALUE1>,<KEY2>=<VALUE2>,...")
+
+	assetsCmd.AddCommand(assetsImportCmd)
+	assetsCmd.AddCommand(assetsImportStatusCmd)
+	assetsCmd.AddCommand(assetsImportCancelCmd)
+	imagesCmd.AddCommand(imagesImportCmd)
+	imagesCmd.AddCommand(imagesImportStatusCmd)
+	imagesCmd.AddCommand(imagesImportCancelCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inventoriesGetFilter string
+	inventoriesPutFile   string
+)
+
+var inventoriesCmd = &cobra.Command{
+	Use:   "inventories",
+	Short: "manage hardware inventories",
+	Long:  `Get and upload hardware inventories of machines in sabakan.`,
+	RunE:  dummyRunFunc,
+}
+
+var inventoriesGetCmd = &cobra.Command{
+	Use:   "get [SERIAL]",
+	Short: "get hardware inventories",
+	Long: `If SERIAL is not given, this command retrieves the latest inventories
+of all machines that match --filter.
+If SERIAL is given, this command retrieves the latest inventory of the machine.`,
+	Args: cobra.MaximumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			var data interface{}
+			if len(args) == 0 {
+				inventories, err := httpApi.InventoriesList(ctx, inventoriesGetFilter)
+				if err != nil {
+					return err
+				}
+				data = inventories
+			} else {
+				inv, err := httpApi.InventoriesGet(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				data = inv
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(data)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var inventoriesHistoryCmd = &cobra.Command{
+	Use:   "history SERIAL",
+	Short: "get stored versions of the hardware inventory",
+	Long:  `Get stored versions of the hardware inventory of the machine, oldest first.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			history, err := httpApi.InventoriesHistory(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(history)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var inventoriesPutCmd = &cobra.Command{
+	Use:   "put -f FILE SERIAL",
+	Short: "upload a hardware inventory",
+	Long:  `Upload a hardware inventory of the machine to sabakan TLS server.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(inventoriesPutFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		inv := new(sabakan.Inventory)
+		err = json.NewDecoder(f).Decode(inv)
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return httpsApi.InventoriesPut(ctx, args[0], inv)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	inventoriesGetCmd.Flags().StringVar(&inventoriesGetFilter, "filter", "", "Inventory filter (--filter 'memory<256GiB,cores>=32')")
+	inventoriesPutCmd.Flags().StringVarP(&inventoriesPutFile, "file", "f", "", "inventory in json")
+	inventoriesPutCmd.MarkFlagRequired("file")
+
+	inventoriesCmd.AddCommand(inventoriesGetCmd)
+	inventoriesCmd.AddCommand(inventoriesHistoryCmd)
+	inventoriesCmd.AddCommand(inventoriesPutCmd)
+	rootCmd.AddCommand(inventoriesCmd)
+}
@@ -10,7 +10,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var ipamConfigFile string
+var (
+	ipamConfigFile string
+	ipamDryRun     bool
+	ipamForce      bool
+	ipamMigrateDry bool
+)
 
 var ipamCmd = &cobra.Command{
 	Use:   "ipam",
@@ -42,7 +47,12 @@ var ipamGetCmd = &cobra.Command{
 var ipamSetCmd = &cobra.Command{
 	Use:   "set -f FILE",
 	Short: "update IPAM configuration",
-	Long:  `Update IPAM configuration from FILE.`,
+	Long: `Update IPAM configuration from FILE.
+
+Configurations that would change addresses of registered machines, or
+whose DHCP lease ranges would conflict with them, DHCP leases or reservations,
+are refused unless --force is given.  With --dry-run, changes of addresses
+and conflicts are shown without updating the configuration.`,
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		f, err := os.Open(ipamConfigFile)
@@ -58,6 +68,18 @@ var ipamSetCmd = &cobra.Command{
 		}
 
 		well.Go(func(ctx context.Context) error {
+			switch {
+			case ipamDryRun:
+				result, err := httpApi.IPAMConfigDryRun(ctx, &conf)
+				if err != nil {
+					return err
+				}
+				e := json.NewEncoder(cmd.OutOrStdout())
+				e.SetIndent("", "  ")
+				return e.Encode(result)
+			case ipamForce:
+				return httpApi.IPAMConfigForceSet(ctx, &conf)
+			}
 			return httpApi.IPAMConfigSet(ctx, &conf)
 		})
 		well.Stop()
@@ -65,11 +87,37 @@ var ipamSetCmd = &cobra.Command{
 	},
 }
 
+var ipamMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "rewrite addresses of machines",
+	Long: `Rewrite addresses of registered machines with the current IPAM configuration.
+
+Changes of addresses are shown.  With --dry-run, machines are not updated.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			changes, err := httpApi.IPAMMigrate(ctx, ipamMigrateDry)
+			if err != nil {
+				return err
+			}
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(changes)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 func init() {
 	ipamSetCmd.Flags().StringVarP(&ipamConfigFile, "file", "f", "", "IPAM configuration in json")
 	ipamSetCmd.MarkFlagRequired("file")
+	ipamSetCmd.Flags().BoolVar(&ipamDryRun, "dry-run", false, "show changes of addresses and conflicts without updating")
+	ipamSetCmd.Flags().BoolVar(&ipamForce, "force", false, "update even if addresses of machines would change or conflict")
+	ipamMigrateCmd.Flags().BoolVar(&ipamMigrateDry, "dry-run", false, "show changes of addresses without updating machines")
 
 	ipamCmd.AddCommand(ipamGetCmd)
 	ipamCmd.AddCommand(ipamSetCmd)
+	ipamCmd.AddCommand(ipamMigrateCmd)
 	rootCmd.AddCommand(ipamCmd)
 }
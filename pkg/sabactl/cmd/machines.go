@@ -3,7 +3,9 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
@@ -18,6 +20,8 @@ var (
 	machinesGetParams  = make(map[string]*string)
 	machinesGetOutput  string
 	machinesCreateFile string
+
+	machinesSetAnnotationFile string
 )
 
 var machinesCmd = &cobra.Command{
@@ -187,6 +191,93 @@ var machinesRemoveLabelCmd = &cobra.Command{
 	},
 }
 
+var machinesSetAnnotationCmd = &cobra.Command{
+	Use:   "set-annotation SERIAL NAME [VALUE]",
+	Short: "add or update an annotation for the machine",
+	Long: `Add or update an annotation named NAME for the machine.
+
+VALUE can be any UTF-8 string.  If --file is given instead of VALUE,
+the value is read from the file.  "-" means the standard input.`,
+	Args: cobra.RangeArgs(2, 3),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial, name := args[0], args[1]
+		var value string
+		switch {
+		case len(args) == 3 && len(machinesSetAnnotationFile) == 0:
+			value = args[2]
+		case len(args) == 2 && machinesSetAnnotationFile == "-":
+			data, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		case len(args) == 2 && len(machinesSetAnnotationFile) > 0:
+			data, err := os.ReadFile(machinesSetAnnotationFile)
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		default:
+			return errors.New("specify either VALUE or --file")
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return httpApi.MachinesSetAnnotation(ctx, serial, name, value)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var machinesRemoveAnnotationCmd = &cobra.Command{
+	Use:   "remove-annotation SERIAL NAME",
+	Short: "remove an annotation from the machine",
+	Long:  `Remove an annotation named NAME from the machine.`,
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial, name := args[0], args[1]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.MachinesRemoveAnnotation(ctx, serial, name)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var machinesAddMACCmd = &cobra.Command{
+	Use:   "add-mac SERIAL MAC",
+	Short: "bind a MAC address to the machine",
+	Long:  `Bind a MAC address of a NIC to the machine.`,
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial, mac := args[0], args[1]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.MachinesAddMAC(ctx, serial, mac)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var machinesRemoveMACCmd = &cobra.Command{
+	Use:   "remove-mac SERIAL MAC",
+	Short: "unbind a MAC address from the machine",
+	Long:  `Unbind a MAC address of a NIC from the machine.`,
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial, mac := args[0], args[1]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.MachinesRemoveMAC(ctx, serial, mac)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 var machinesSetRetireDateCmd = &cobra.Command{
 	Use:   "set-retire-date SERIAL YYYY-MM-DD",
 	Short: "set the retire date of the machine",
@@ -207,24 +298,67 @@ var machinesSetRetireDateCmd = &cobra.Command{
 	},
 }
 
+var machinesIssueTokenCmd = &cobra.Command{
+	Use:   "issue-token SERIAL",
+	Short: "issue a token for the machine",
+	Long: `Issue a new token for the machine by SERIAL, and revoke the previous one.
+
+The machine can send the token in X-Sabakan-Machine-Token header to
+identify itself when it downloads restricted assets.`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial := args[0]
+		well.Go(func(ctx context.Context) error {
+			token, err := httpApi.MachinesIssueToken(ctx, serial)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), token)
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var machinesRevokeTokenCmd = &cobra.Command{
+	Use:   "revoke-token SERIAL",
+	Short: "revoke the token of the machine",
+	Long:  `Revoke the token of the machine by SERIAL.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serial := args[0]
+		well.Go(func(ctx context.Context) error {
+			return httpApi.MachinesRevokeToken(ctx, serial)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
 func init() {
 	getOpts := map[string]string{
 		"serial":           "Serial name(s) (--serial 001,002,003...)",
 		"rack":             "Rack name(s) (--rack 1,2,3...)",
 		"role":             "Role name(s) (--role boot,worker...)",
-		"labels":           "Label name and value (--labels key=val,...)",
+		"labels":           "Label selector (--labels 'key=val,key!=val,key in (v1,v2),key notin (v1,v2),key,!key')",
 		"ipv4":             "IPv4 address(s) (--ipv4 10.0.0.1,10.0.0.2,10.0.0.3...)",
 		"ipv6":             "IPv6 address(s) (--ipv6 aa::ff,bb::ff,cc::ff...)",
+		"mac":              "MAC address(es) (--mac 0c:c4:7a:00:00:01,0c:c4:7a:00:00:02...)",
 		"bmc-type":         "BMC type(s) (--bmc-type iDRAC-9,IPMI-2.0...)",
 		"state":            "State(s) (--state retiring,uninitialized...)",
 		"without-serial":   "without Serial name",
 		"without-rack":     "without Rack name",
 		"without-role":     "without Role name",
-		"without-labels":   "without Label name and value (--labels key=val,...)",
+		"without-labels":   "without Label selector (--without-labels key=val,...)",
 		"without-ipv4":     "without IPv4 address",
 		"without-ipv6":     "without IPv6 address",
 		"without-bmc-type": "without BMC type",
 		"without-state":    "without State",
+		"sort":             "Sort key [serial,rack,index,state,register-date,retire-date]; prefix '-' for descending order",
+		"limit":            "Number of machines retrieved in a request",
 	}
 	for k, v := range getOpts {
 		val := new(string)
@@ -234,6 +368,7 @@ func init() {
 	machinesGetCmd.Flags().StringVarP(&machinesGetOutput, "output", "o", "json", "Output format [json,simple]")
 	machinesCreateCmd.Flags().StringVarP(&machinesCreateFile, "file", "f", "", "machiens in json")
 	machinesCreateCmd.MarkFlagRequired("file")
+	machinesSetAnnotationCmd.Flags().StringVarP(&machinesSetAnnotationFile, "file", "f", "", "read the value from the file (\"-\" for stdin)")
 
 	machinesCmd.AddCommand(machinesGetCmd)
 	machinesCmd.AddCommand(machinesCreateCmd)
@@ -242,6 +377,12 @@ func init() {
 	machinesCmd.AddCommand(machinesSetStateCmd)
 	machinesCmd.AddCommand(machinesSetLabelCmd)
 	machinesCmd.AddCommand(machinesRemoveLabelCmd)
+	machinesCmd.AddCommand(machinesSetAnnotationCmd)
+	machinesCmd.AddCommand(machinesRemoveAnnotationCmd)
+	machinesCmd.AddCommand(machinesAddMACCmd)
+	machinesCmd.AddCommand(machinesRemoveMACCmd)
 	machinesCmd.AddCommand(machinesSetRetireDateCmd)
+	machinesCmd.AddCommand(machinesIssueTokenCmd)
+	machinesCmd.AddCommand(machinesRevokeTokenCmd)
 	rootCmd.AddCommand(machinesCmd)
 }
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var replicationCmd = &cobra.Command{
+	Use:   "replication",
+	Short: "show replication status of assets and images",
+	Long: `Show which sabakan servers hold the current versions of assets and images,
+and which servers are pending or have failed to pull them.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			status, err := httpApi.ReplicationStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(status)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replicationCmd)
+}
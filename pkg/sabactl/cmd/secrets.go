@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretsRole   string
+	secretsSerial string
+)
+
+// secretScope returns the scope and the target given by --role or --serial.
+func secretScope() (sabakan.SecretScope, string, error) {
+	switch {
+	case len(secretsRole) > 0 && len(secretsSerial) > 0:
+		return "", "", errors.New("--role and --serial are exclusive")
+	case len(secretsRole) > 0:
+		return sabakan.SecretScopeRole, secretsRole, nil
+	case len(secretsSerial) > 0:
+		return sabakan.SecretScopeSerial, secretsSerial, nil
+	}
+	return sabakan.SecretScopeGlobal, "", nil
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "manage secrets",
+	Long:  `Manage secrets injected into ignition configurations.`,
+	RunE:  dummyRunFunc,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list secrets",
+	Long:  `List the meta data of secrets.  Values are never shown.`,
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			secrets, err := httpApi.SecretsList(ctx)
+			if err != nil {
+				return err
+			}
+			e := json.NewEncoder(cmd.OutOrStdout())
+			e.SetIndent("", "  ")
+			return e.Encode(secrets)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set [--role ROLE | --serial SERIAL] NAME FILE",
+	Short: "add or update a secret",
+	Long: `Add or update a secret whose value is read from FILE ("-" for stdin).
+
+Without --role or --serial, the secret is given to all machines.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, file := args[0], args[1]
+		scope, target, err := secretScope()
+		if err != nil {
+			return err
+		}
+
+		var value []byte
+		if file == "-" {
+			value, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			value, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return httpApi.SecretsPut(ctx, scope, target, name, value)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:   "delete [--role ROLE | --serial SERIAL] NAME",
+	Short: "delete a secret",
+	Long:  `Delete a secret.`,
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		scope, target, err := secretScope()
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return httpApi.SecretsDelete(ctx, scope, target, name)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{secretsSetCmd, secretsDeleteCmd} {
+		c.Flags().StringVar(&secretsRole, "role", "", "Role of machines to which the secret is given")
+		c.Flags().StringVar(&secretsSerial, "serial", "", "Serial of the machine to which the secret is given")
+	}
+
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -1,6 +1,9 @@
 package main
 
-import "github.com/cybozu-go/etcdutil"
+import (
+	"github.com/cybozu-go/etcdutil"
+	"github.com/cybozu-go/sabakan/v3/objstore"
+)
 
 const (
 	defaultListenHTTP     = "0.0.0.0:10080"
@@ -9,6 +12,7 @@ const (
 	defaultEtcdPrefix     = "/sabakan/"
 	defaultDHCPBind       = "0.0.0.0:10067"
 	defaultIPXEPath       = "/usr/lib/ipxe/ipxe.efi"
+	defaultUndionlyPath   = "/usr/lib/ipxe/undionly.kpxe"
 	defaultDataDir        = "/var/lib/sabakan"
 	defaultServerCertFile = "/etc/sabakan/server.crt"
 	defaultServerKeyFile  = "/etc/sabakan/server.key"
@@ -25,6 +29,7 @@ func newConfig() *config {
 		ListenMetrics:  defaultListenMetrics,
 		DHCPBind:       defaultDHCPBind,
 		IPXEPath:       defaultIPXEPath,
+		UndionlyPath:   defaultUndionlyPath,
 		DataDir:        defaultDataDir,
 		AllowIPs:       defaultAllowIPs,
 		Etcd:           etcdutil.NewConfig(defaultEtcdPrefix),
@@ -38,14 +43,30 @@ type config struct {
 	ListenHTTPS       string `json:"https"`
 	ListenMetrics     string `json:"metrics"`
 	DHCPBind          string `json:"dhcp-bind"`
+	DHCP6Bind         string `json:"dhcp6-bind"`
+	TFTPBind          string `json:"tftp-bind"`
 	IPXEPath          string `json:"ipxe-efi-path"`
+	UndionlyPath      string `json:"undionly-kpxe-path"`
 	DataDir           string `json:"data-dir"`
 	AdvertiseURL      string `json:"advertise-url"`
 	AdvertiseURLHTTPS string `json:"advertise-url-https"`
+	AdvertiseURLV6    string `json:"advertise-url-v6"`
 
 	AllowIPs       []string         `json:"allow-ips"`
 	Playground     bool             `json:"enable-playground"`
 	Etcd           *etcdutil.Config `json:"etcd"`
 	ServerCertFile string           `json:"server-cert"`
 	ServerKeyFile  string           `json:"server-key"`
+
+	ObjectStore *objectStoreConfig `json:"object-store"`
+	AssetQuota  int64              `json:"asset-quota"`
+	ImportProxy string             `json:"import-proxy"`
+
+	SecretKeyFile string `json:"secret-key-file"`
+}
+
+type objectStoreConfig struct {
+	objstore.Config
+	Redirect  bool  `json:"redirect"`
+	CacheSize int64 `json:"cache-size"`
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"net"
@@ -18,6 +19,8 @@ import (
 	"github.com/cybozu-go/sabakan/v3/dhcpd"
 	"github.com/cybozu-go/sabakan/v3/metrics"
 	"github.com/cybozu-go/sabakan/v3/models/etcd"
+	"github.com/cybozu-go/sabakan/v3/objstore"
+	"github.com/cybozu-go/sabakan/v3/tftpd"
 	"github.com/cybozu-go/sabakan/v3/web"
 	"github.com/cybozu-go/well"
 	"go.universe.tf/netboot/dhcp4"
@@ -33,12 +36,19 @@ var (
 	flagHTTPS             = flag.String("https", defaultListenHTTPS, "<Listen IP>:<Port number>")
 	flagMetrics           = flag.String("metrics", defaultListenMetrics, "<Listen IP>:<Port number>")
 	flagDHCPBind          = flag.String("dhcp-bind", defaultDHCPBind, "bound ip addresses and port for dhcp server")
+	flagDHCP6Bind         = flag.String("dhcp6-bind", "", "bound ip addresses and port for dhcpv6 server; disabled if empty")
+	flagTFTPBind          = flag.String("tftp-bind", "", "bound ip address and port for tftp server; disabled if empty")
 	flagIPXEPath          = flag.String("ipxe-efi-path", defaultIPXEPath, "path to ipxe.efi")
+	flagUndionlyPath      = flag.String("undionly-kpxe-path", defaultUndionlyPath, "path to undionly.kpxe")
 	flagDataDir           = flag.String("data-dir", defaultDataDir, "directory to store files")
 	flagAdvertiseURL      = flag.String("advertise-url", "", "public URL of this server")
 	flagAdvertiseURLHTTPS = flag.String("advertise-url-https", "", "public URL of this server(https)")
+	flagAdvertiseURLV6    = flag.String("advertise-url-v6", "", "public URL of this server for DHCPv6 clients")
 	flagAllowIPs          = flag.String("allow-ips", strings.Join(defaultAllowIPs, ","), "comma-separated IPs allowed to change resources")
 	flagPlayground        = flag.Bool("enable-playground", false, "enable GraphQL playground")
+	flagAssetQuota        = flag.Int64("asset-quota", 0, "maximum total size of assets in bytes; 0 means unlimited")
+	flagImportProxy       = flag.String("import-proxy", "", "URL of HTTP proxy to import assets and images; environment variables are used if empty")
+	flagSecretKeyFile     = flag.String("secret-key-file", "", "path to a file of hex-encoded 32-byte key to encrypt secrets")
 
 	flagEtcdEndpoints  = flag.String("etcd-endpoints", strings.Join(etcdutil.DefaultEndpoints, ","), "comma-separated URLs of the backend etcd endpoints")
 	flagEtcdPrefix     = flag.String("etcd-prefix", defaultEtcdPrefix, "etcd prefix")
@@ -87,14 +97,21 @@ func subMain(ctx context.Context) error {
 	if *flagConfigFile == "" {
 		cfg.AdvertiseURL = *flagAdvertiseURL
 		cfg.AdvertiseURLHTTPS = *flagAdvertiseURLHTTPS
+		cfg.AdvertiseURLV6 = *flagAdvertiseURLV6
 		cfg.AllowIPs = strings.Split(*flagAllowIPs, ",")
 		cfg.DHCPBind = *flagDHCPBind
+		cfg.DHCP6Bind = *flagDHCP6Bind
 		cfg.DataDir = *flagDataDir
 		cfg.IPXEPath = *flagIPXEPath
+		cfg.UndionlyPath = *flagUndionlyPath
+		cfg.TFTPBind = *flagTFTPBind
 		cfg.ListenHTTP = *flagHTTP
 		cfg.ListenHTTPS = *flagHTTPS
 		cfg.Playground = *flagPlayground
 		cfg.ListenMetrics = *flagMetrics
+		cfg.AssetQuota = *flagAssetQuota
+		cfg.ImportProxy = *flagImportProxy
+		cfg.SecretKeyFile = *flagSecretKeyFile
 
 		cfg.Etcd.Endpoints = strings.Split(*flagEtcdEndpoints, ",")
 		cfg.Etcd.Prefix = *flagEtcdPrefix
@@ -134,6 +151,13 @@ func subMain(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	var advertiseURLV6 *url.URL
+	if cfg.AdvertiseURLV6 != "" {
+		advertiseURLV6, err = url.Parse(cfg.AdvertiseURLV6)
+		if err != nil {
+			return err
+		}
+	}
 
 	c, err := etcdutil.NewClient(cfg.Etcd)
 	if err != nil {
@@ -141,7 +165,43 @@ func subMain(ctx context.Context) error {
 	}
 	defer c.Close()
 
-	model := etcd.NewModel(c, cfg.DataDir, advertiseURL)
+	var opts []etcd.Option
+	if cfg.ObjectStore != nil {
+		store, err := objstore.NewClient(cfg.ObjectStore.Config, nil)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, etcd.WithObjectStore(store, cfg.ObjectStore.Redirect, cfg.ObjectStore.CacheSize))
+	}
+
+	if cfg.AssetQuota < 0 {
+		return errors.New("asset-quota must not be negative")
+	}
+	if cfg.AssetQuota > 0 {
+		opts = append(opts, etcd.WithAssetQuota(cfg.AssetQuota))
+	}
+
+	if cfg.ImportProxy != "" {
+		proxy, err := url.Parse(cfg.ImportProxy)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, etcd.WithImportProxy(proxy))
+	}
+
+	if cfg.SecretKeyFile != "" {
+		data, err := os.ReadFile(cfg.SecretKeyFile)
+		if err != nil {
+			return err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return errors.New("secret key must be 32 bytes encoded in hex")
+		}
+		opts = append(opts, etcd.WithSecretKey(key))
+	}
+
+	model := etcd.NewModel(c, cfg.DataDir, advertiseURL, opts...)
 
 	// update schema
 	sv, err := model.Schema.Version(ctx)
@@ -169,12 +229,45 @@ func subMain(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	dhcpCounter := metrics.NewDHCPCounter()
+	dhcpHandler := dhcpd.DHCPHandler{Model: model, MyURL: advertiseURL, MyURL6: advertiseURLV6, TFTP: cfg.TFTPBind != "", Counter: dhcpCounter}
 	dhcpServer := dhcpd.Server{
-		Handler: dhcpd.DHCPHandler{Model: model, MyURL: advertiseURL},
+		Handler: dhcpHandler,
 		Conn:    conn,
+		Counter: dhcpCounter,
 	}
 	env.Go(dhcpServer.Serve)
 
+	// DHCPv6
+	if cfg.DHCP6Bind != "" {
+		conn6, err := dhcpd.NewConn6(cfg.DHCP6Bind)
+		if err != nil {
+			return err
+		}
+		dhcp6Server := dhcpd.Server6{
+			Handler: dhcpHandler,
+			Conn:    conn6,
+			Counter: dhcpCounter,
+		}
+		env.Go(dhcp6Server.Serve)
+	}
+
+	// TFTP
+	if cfg.TFTPBind != "" {
+		tftpConn, err := net.ListenPacket("udp4", cfg.TFTPBind)
+		if err != nil {
+			return err
+		}
+		tftpServer := tftpd.Server{
+			Files: map[string]string{
+				"undionly.kpxe": cfg.UndionlyPath,
+				"ipxe.efi":      cfg.IPXEPath,
+			},
+			Conn: tftpConn,
+		}
+		env.Go(tftpServer.Serve)
+	}
+
 	// Web
 	cryptsetupPath := findCryptSetup()
 	allowedIPs, err := parseAllowIPs(cfg.AllowIPs)
@@ -53,23 +53,30 @@ func (q Query) Match(m *Machine) (bool, error) {
 			return false, nil
 		}
 	}
-	if labels := q["labels"]; len(labels) > 0 {
-		queries := strings.Split(labels, ",")
-		for _, query := range queries {
-			kv := strings.SplitN(query, "=", 2)
-			if len(kv) != 2 {
-				return false, fmt.Errorf("invalid query in labels: %s", query)
+	if mac := q["mac"]; len(mac) > 0 {
+		match := false
+		for _, s := range strings.Split(mac, ",") {
+			hwaddr, err := NormalizeMAC(s)
+			if err != nil {
+				return false, fmt.Errorf("invalid query in mac: %v", err)
 			}
-			queryKey := kv[0]
-			queryValue := kv[1]
-			if value, exists := m.Spec.Labels[queryKey]; exists {
-				if value != queryValue {
-					return false, nil
-				}
-			} else {
-				return false, nil
+			if m.HasMAC(hwaddr) {
+				match = true
+				break
 			}
 		}
+		if !match {
+			return false, nil
+		}
+	}
+	if labels := q["labels"]; len(labels) > 0 {
+		sel, err := ParseLabelSelector(labels)
+		if err != nil {
+			return false, fmt.Errorf("invalid query in labels: %v", err)
+		}
+		if !sel.Matches(m.Spec.Labels) {
+			return false, nil
+		}
 	}
 	if rack := q["rack"]; len(rack) > 0 {
 		racks := strings.Split(rack, ",")
@@ -152,26 +159,11 @@ func (q Query) Match(m *Machine) (bool, error) {
 		}
 	}
 	if withoutLabels := q["without-labels"]; len(withoutLabels) > 0 {
-		queries := strings.Split(withoutLabels, ",")
-		excluded := true
-		for _, query := range queries {
-			kv := strings.SplitN(query, "=", 2)
-			if len(kv) != 2 {
-				return false, fmt.Errorf("invalid query in without-labels: %s", query)
-			}
-			queryKey := kv[0]
-			queryValue := kv[1]
-			if value, exists := m.Spec.Labels[queryKey]; exists {
-				if value != queryValue {
-					excluded = false
-					break
-				}
-			} else {
-				excluded = false
-				break
-			}
+		sel, err := ParseLabelSelector(withoutLabels)
+		if err != nil {
+			return false, fmt.Errorf("invalid query in without-labels: %v", err)
 		}
-		if excluded {
+		if len(sel) > 0 && sel.Matches(m.Spec.Labels) {
 			return false, nil
 		}
 	}
@@ -226,19 +218,27 @@ func (q Query) IPv4() string { return q["ipv4"] }
 // IPv6 returns value of ipv6 in the query
 func (q Query) IPv6() string { return q["ipv6"] }
 
+// MAC returns value of mac in the query
+func (q Query) MAC() string { return q["mac"] }
+
 // BMCType returns value of bmc-type in the query
 func (q Query) BMCType() string { return q["bmc-type"] }
 
 // State returns value of state the query
 func (q Query) State() string { return q["state"] }
 
-// Labels return label's key and value combined with '='
+// Labels returns label requirements in the query without parsing them.
 func (q Query) Labels() []string {
-	queries := strings.Split(q["labels"], ",")
-	for idx, rawQuery := range queries {
-		queries[idx] = strings.TrimSpace(rawQuery)
+	terms, err := splitLabelSelector(q["labels"])
+	if err != nil {
+		return []string{q["labels"]}
 	}
-	return queries
+	return terms
+}
+
+// LabelSelector returns the parsed label selector in the query.
+func (q Query) LabelSelector() (LabelSelector, error) {
+	return ParseLabelSelector(q["labels"])
 }
 
 // IsEmpty returns true if query is empty or no values are presented
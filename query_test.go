@@ -23,6 +23,10 @@ func TestMatch(t *testing.T) {
 		{Query{"ipv6": "aa::ff,bb::ff"}, NewMachine(MachineSpec{IPv6: []string{"aa::ff", "bb::ff"}}), true},
 		{Query{"labels": "product=R630,datacenter=us"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R630", "datacenter": "us"}}), true},
 		{Query{"state": "uninitialized"}, NewMachine(MachineSpec{}), true},
+		{Query{"mac": "0C:C4:7A:00:00:01"}, NewMachine(MachineSpec{MACs: []string{"0c:c4:7a:00:00:01"}}), true},
+		{Query{"mac": "0c:c4:7a:00:00:02,0c:c4:7a:00:00:01"}, NewMachine(MachineSpec{MACs: []string{"0c:c4:7a:00:00:01"}}), true},
+		{Query{"mac": "0c:c4:7a:00:00:02"}, NewMachine(MachineSpec{MACs: []string{"0c:c4:7a:00:00:01"}}), false},
+		{Query{"mac": "0c:c4:7a:00:00:01"}, NewMachine(MachineSpec{}), false},
 		{Query{"bmc-type": "iDRAC-9"}, NewMachine(MachineSpec{BMC: MachineBMC{Type: "iDRAC-9"}}), true},
 		{Query{"bmc-type": "iDRAC-9,IPMI-1.0"}, NewMachine(MachineSpec{BMC: MachineBMC{Type: "iDRAC-9"}}), true},
 		{Query{"labels": "product=R630"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R630", "datacenter": "jp"}}), true},
@@ -62,6 +66,20 @@ func TestMatch(t *testing.T) {
 		{Query{"without-ipv6": "aa::ff"}, NewMachine(MachineSpec{}), true},
 		{Query{"without-state": "unreachable"}, NewMachine(MachineSpec{}), true},
 		{Query{"without-bmc-type": "IPMI-1.0"}, NewMachine(MachineSpec{BMC: MachineBMC{Type: "iDRAC-9"}}), true},
+		{Query{"labels": "product!=R630"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R730"}}), true},
+		{Query{"labels": "product!=R630"}, NewMachine(MachineSpec{}), true},
+		{Query{"labels": "product!=R630"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R630"}}), false},
+		{Query{"labels": "product in (R630,R730)"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R730"}}), true},
+		{Query{"labels": "product in (R630,R730)"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R740"}}), false},
+		{Query{"labels": "product notin (R630,R730)"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R740"}}), true},
+		{Query{"labels": "product notin (R630,R730)"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R630"}}), false},
+		{Query{"labels": "gpu"}, NewMachine(MachineSpec{Labels: map[string]string{"gpu": "a100"}}), true},
+		{Query{"labels": "gpu"}, NewMachine(MachineSpec{}), false},
+		{Query{"labels": "!gpu"}, NewMachine(MachineSpec{}), true},
+		{Query{"labels": "!gpu"}, NewMachine(MachineSpec{Labels: map[string]string{"gpu": "a100"}}), false},
+		{Query{"labels": "product in (R630,R730),!gpu,datacenter=us"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R630", "datacenter": "us"}}), true},
+		{Query{"without-labels": "gpu"}, NewMachine(MachineSpec{Labels: map[string]string{"gpu": "a100"}}), false},
+		{Query{"without-labels": "product in (R630,R730)"}, NewMachine(MachineSpec{Labels: map[string]string{"product": "R740"}}), true},
 	}
 
 	for _, c := range cases {
@@ -74,6 +92,23 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestMatchInvalidLabels(t *testing.T) {
+	t.Parallel()
+
+	queries := []Query{
+		{"labels": "product in (R630"},
+		{"labels": "product=R630)"},
+		{"labels": "invalid key=value"},
+		{"without-labels": "product between (R630,R730)"},
+	}
+	for _, q := range queries {
+		_, err := q.Match(NewMachine(MachineSpec{}))
+		if err == nil {
+			t.Errorf("error should be returned for %#v", q)
+		}
+	}
+}
+
 func TestIsEmpty(t *testing.T) {
 	blanks := []Query{{}, {"serial": "", "role": ""}}
 	for _, q := range blanks {
@@ -0,0 +1,153 @@
+package sabakan
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kinds of replicated objects.
+const (
+	ReplicaKindAsset = "asset"
+	ReplicaKindImage = "image"
+)
+
+// ReplicaReport is a report of local copies of assets and images
+// held by a sabakan server.
+type ReplicaReport struct {
+	// URL is the advertise URL of the server.
+	URL string `json:"url"`
+
+	// Assets is a list of IDs of assets that the server has local copies.
+	Assets []int `json:"assets"`
+
+	// Images is a map from OS to IDs of images that the server has local copies.
+	Images map[string][]string `json:"images"`
+
+	// ObjectStore is true if the server serves assets from the object storage.
+	ObjectStore bool `json:"object-store"`
+
+	// Failures is a list of pulls that have failed.
+	Failures []*PullFailure `json:"failures"`
+
+	Date time.Time `json:"date"`
+}
+
+// PullFailure represents a failed pull of an asset or an image.
+type PullFailure struct {
+	// Kind is ReplicaKindAsset or ReplicaKindImage.
+	Kind string `json:"kind"`
+
+	// Name is the name of an asset or the OS of an image.
+	Name string `json:"name"`
+
+	ID    string    `json:"id"`
+	Error string    `json:"error"`
+	Date  time.Time `json:"date"`
+}
+
+// ReplicationStatus is the replication status of assets and images
+// among sabakan servers.
+type ReplicationStatus struct {
+	// Servers is a list of URLs of the running servers.
+	Servers []string         `json:"servers"`
+	Assets  []*ReplicaStatus `json:"assets"`
+	Images  []*ReplicaStatus `json:"images"`
+}
+
+// ReplicaStatus is the replication status of the current version of
+// an asset or an image.
+type ReplicaStatus struct {
+	// Name is the name of an asset or the OS of an image.
+	Name string `json:"name"`
+	ID   string `json:"id"`
+
+	// Servers is a list of servers that hold the copy.
+	Servers []string `json:"servers"`
+
+	// Pending is a list of servers that have not pulled the copy yet.
+	Pending []string `json:"pending"`
+
+	// Failed is a list of servers that failed to pull the copy.
+	Failed []*ReplicaFailure `json:"failed"`
+}
+
+// ReplicaFailure is the last error of a server that failed to pull a copy.
+type ReplicaFailure struct {
+	Server string    `json:"server"`
+	Error  string    `json:"error"`
+	Date   time.Time `json:"date"`
+}
+
+// NewReplicationStatus aggregates reports of servers.
+// assets is the current versions of assets.  images is a map from OS to its index.
+func NewReplicationStatus(reports []*ReplicaReport, assets []*Asset, images map[string]ImageIndex) *ReplicationStatus {
+	st := &ReplicationStatus{
+		Servers: []string{},
+		Assets:  []*ReplicaStatus{},
+		Images:  []*ReplicaStatus{},
+	}
+	for _, r := range reports {
+		st.Servers = append(st.Servers, r.URL)
+	}
+
+	newStatus := func(kind, name, id string, holds func(r *ReplicaReport) bool) *ReplicaStatus {
+		rs := &ReplicaStatus{
+			Name:    name,
+			ID:      id,
+			Servers: []string{},
+			Pending: []string{},
+			Failed:  []*ReplicaFailure{},
+		}
+	OUTER:
+		for _, r := range reports {
+			if holds(r) {
+				rs.Servers = append(rs.Servers, r.URL)
+				continue
+			}
+			for _, f := range r.Failures {
+				if f.Kind == kind && f.Name == name && f.ID == id {
+					rs.Failed = append(rs.Failed, &ReplicaFailure{
+						Server: r.URL,
+						Error:  f.Error,
+						Date:   f.Date,
+					})
+					continue OUTER
+				}
+			}
+			rs.Pending = append(rs.Pending, r.URL)
+		}
+		return rs
+	}
+
+	for _, a := range assets {
+		st.Assets = append(st.Assets, newStatus(ReplicaKindAsset, a.Name, strconv.Itoa(a.ID), func(r *ReplicaReport) bool {
+			return r.ObjectStore || slices.Contains(r.Assets, a.ID)
+		}))
+	}
+
+	oses := make([]string, 0, len(images))
+	for os := range images {
+		oses = append(oses, os)
+	}
+	sort.Strings(oses)
+	for _, os := range oses {
+		for _, img := range images[os] {
+			st.Images = append(st.Images, newStatus(ReplicaKindImage, os, img.ID, func(r *ReplicaReport) bool {
+				return slices.Contains(r.Images[os], img.ID)
+			}))
+		}
+	}
+	return st
+}
+
+// FindAsset returns the replication status of the named asset, or nil if not found.
+func (s *ReplicationStatus) FindAsset(name string) *ReplicaStatus {
+	for _, a := range s.Assets {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
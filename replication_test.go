@@ -0,0 +1,78 @@
+package sabakan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewReplicationStatus(t *testing.T) {
+	t.Parallel()
+
+	reports := []*ReplicaReport{
+		{
+			URL:    "http://10.0.0.1:10080",
+			Assets: []int{1, 3},
+			Images: map[string][]string{"coreos": {"100.0"}},
+		},
+		{
+			URL:    "http://10.0.0.2:10080",
+			Assets: []int{1},
+			Images: map[string][]string{"coreos": {}},
+			Failures: []*PullFailure{
+				{Kind: ReplicaKindAsset, Name: "bar", ID: "3", Error: "connection refused"},
+				{Kind: ReplicaKindAsset, Name: "bar", ID: "2", Error: "old version"},
+				{Kind: ReplicaKindImage, Name: "coreos", ID: "100.0", Error: "not found"},
+			},
+		},
+		{
+			URL:         "http://10.0.0.3:10080",
+			ObjectStore: true,
+		},
+	}
+	assets := []*Asset{
+		{Name: "bar", ID: 3},
+		{Name: "foo", ID: 1},
+	}
+	images := map[string]ImageIndex{
+		"coreos": {{ID: "100.0"}},
+	}
+
+	st := NewReplicationStatus(reports, assets, images)
+	servers := []string{"http://10.0.0.1:10080", "http://10.0.0.2:10080", "http://10.0.0.3:10080"}
+	if !reflect.DeepEqual(st.Servers, servers) {
+		t.Error("wrong servers:", st.Servers)
+	}
+
+	bar := st.FindAsset("bar")
+	if bar == nil {
+		t.Fatal("bar was not found")
+	}
+	if bar.ID != "3" || !reflect.DeepEqual(bar.Servers, []string{servers[0], servers[2]}) || len(bar.Pending) != 0 {
+		t.Error("wrong status of bar:", bar)
+	}
+	if len(bar.Failed) != 1 || bar.Failed[0].Server != servers[1] || bar.Failed[0].Error != "connection refused" {
+		t.Error("wrong failures of bar:", bar.Failed)
+	}
+
+	foo := st.FindAsset("foo")
+	if foo == nil {
+		t.Fatal("foo was not found")
+	}
+	if len(foo.Servers) != 3 || len(foo.Failed) != 0 {
+		t.Error("wrong status of foo:", foo)
+	}
+	if st.FindAsset("baz") != nil {
+		t.Error("baz should not be found")
+	}
+
+	if len(st.Images) != 1 {
+		t.Fatal("wrong images:", st.Images)
+	}
+	img := st.Images[0]
+	if img.Name != "coreos" || img.ID != "100.0" ||
+		!reflect.DeepEqual(img.Servers, []string{servers[0]}) ||
+		!reflect.DeepEqual(img.Pending, []string{servers[2]}) ||
+		len(img.Failed) != 1 || img.Failed[0].Error != "not found" {
+		t.Error("wrong status of image:", img)
+	}
+}
@@ -0,0 +1,60 @@
+package sabakan
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxSecretSize is the maximum size of a secret value.
+const MaxSecretSize = 64 << 10
+
+// ErrNoSecretKey is returned when the server has no key to encrypt secrets.
+var ErrNoSecretKey = errors.New("secret key is not configured")
+
+// SecretScope is the scope of machines to which a secret is given.
+type SecretScope string
+
+// Secret scopes.  For the same name, a secret of a narrower scope takes precedence.
+const (
+	SecretScopeGlobal = SecretScope("global")
+	SecretScopeRole   = SecretScope("role")
+	SecretScopeSerial = SecretScope("serial")
+)
+
+// SecretInfo is the meta data of a secret.  Values of secrets are never shown.
+type SecretInfo struct {
+	Name  string      `json:"name"`
+	Scope SecretScope `json:"scope"`
+
+	// Target is the role or the serial of the scope.  Empty for SecretScopeGlobal.
+	Target string `json:"target,omitempty"`
+
+	Date time.Time `json:"date"`
+}
+
+// IsValidSecretName returns true if name is valid as a secret name.
+func IsValidSecretName(name string) bool {
+	return reValidLabelName.MatchString(name)
+}
+
+// ValidateSecretScope validates the scope and the target of a secret.
+func ValidateSecretScope(scope SecretScope, target string) error {
+	switch scope {
+	case SecretScopeGlobal:
+		if len(target) != 0 {
+			return errors.New("global secrets cannot have a target")
+		}
+	case SecretScopeRole:
+		if !IsValidRole(target) {
+			return errors.New("invalid role: " + target)
+		}
+	case SecretScopeSerial:
+		if len(target) == 0 || strings.ContainsAny(target, "/") {
+			return errors.New("invalid serial: " + target)
+		}
+	default:
+		return errors.New("invalid scope: " + string(scope))
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package sabakan
+
+import "testing"
+
+func TestValidateSecretScope(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		scope  SecretScope
+		target string
+		valid  bool
+	}{
+		{SecretScopeGlobal, "", true},
+		{SecretScopeGlobal, "worker", false},
+		{SecretScopeRole, "worker", true},
+		{SecretScopeRole, "", false},
+		{SecretScopeSerial, "1234abcd", true},
+		{SecretScopeSerial, "1234/abcd", false},
+		{SecretScope("rack"), "1", false},
+	}
+	for _, c := range cases {
+		err := ValidateSecretScope(c.scope, c.target)
+		if (err == nil) != c.valid {
+			t.Errorf("%s/%s: unexpected result: %v", c.scope, c.target, err)
+		}
+	}
+}
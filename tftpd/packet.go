@@ -0,0 +1,112 @@
+package tftpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// TFTP opcodes (RFC 1350 and RFC 2347).
+const (
+	opRRQ   uint16 = 1
+	opWRQ   uint16 = 2
+	opDATA  uint16 = 3
+	opACK   uint16 = 4
+	opERROR uint16 = 5
+	opOACK  uint16 = 6
+)
+
+// TFTP error codes.
+const (
+	errCodeNotDefined       uint16 = 0
+	errCodeFileNotFound     uint16 = 1
+	errCodeAccessViolation  uint16 = 2
+	errCodeIllegalOperation uint16 = 4
+	errCodeUnknownTID       uint16 = 5
+	errCodeBadOption        uint16 = 8
+)
+
+// request is a read or write request.
+type request struct {
+	Op       uint16
+	Filename string
+	Mode     string
+
+	// Options are options of RFC 2347 with lower-cased names.
+	Options map[string]string
+}
+
+func parseRequest(data []byte) (*request, error) {
+	if len(data) < 2 {
+		return nil, errors.New("too short packet")
+	}
+	req := &request{
+		Op:      binary.BigEndian.Uint16(data[0:2]),
+		Options: make(map[string]string),
+	}
+	if req.Op != opRRQ && req.Op != opWRQ {
+		return nil, errors.New("not a request")
+	}
+
+	fields := bytes.Split(data[2:], []byte{0})
+	// the last element is empty as the packet ends with NUL
+	if len(fields) < 3 || len(fields[len(fields)-1]) != 0 {
+		return nil, errors.New("malformed request")
+	}
+	fields = fields[:len(fields)-1]
+	if len(fields)%2 != 0 {
+		return nil, errors.New("malformed request options")
+	}
+
+	req.Filename = string(fields[0])
+	req.Mode = strings.ToLower(string(fields[1]))
+	for i := 2; i < len(fields); i += 2 {
+		req.Options[strings.ToLower(string(fields[i]))] = string(fields[i+1])
+	}
+	return req, nil
+}
+
+func makeData(block uint16, data []byte) []byte {
+	buf := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint16(buf[0:2], opDATA)
+	binary.BigEndian.PutUint16(buf[2:4], block)
+	return append(buf, data...)
+}
+
+func makeError(code uint16, msg string) []byte {
+	buf := make([]byte, 4, 5+len(msg))
+	binary.BigEndian.PutUint16(buf[0:2], opERROR)
+	binary.BigEndian.PutUint16(buf[2:4], code)
+	buf = append(buf, msg...)
+	return append(buf, 0)
+}
+
+// makeOACK returns an OACK packet.  keys specifies the order of options.
+func makeOACK(keys []string, opts map[string]string) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, opOACK)
+	for _, k := range keys {
+		buf = append(buf, k...)
+		buf = append(buf, 0)
+		buf = append(buf, opts[k]...)
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// parseACK returns the block number of an ACK packet.
+func parseACK(data []byte) (uint16, bool) {
+	if len(data) < 4 || binary.BigEndian.Uint16(data[0:2]) != opACK {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(data[2:4]), true
+}
+
+// parseError returns the message of an ERROR packet.
+func parseError(data []byte) (string, bool) {
+	if len(data) < 4 || binary.BigEndian.Uint16(data[0:2]) != opERROR {
+		return "", false
+	}
+	return strings.TrimRight(string(data[4:]), "\x00"), true
+}
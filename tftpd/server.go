@@ -0,0 +1,264 @@
+package tftpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/well"
+)
+
+const (
+	defaultBlockSize = 512
+	minBlockSize     = 8
+	maxBlockSize     = 65464
+
+	defaultTimeout = 3 * time.Second
+	maxRetries     = 5
+)
+
+// Server is a read-only TFTP server.
+//
+// It implements RFC 1350 in octet mode with blksize (RFC 2348),
+// tsize and timeout (RFC 2349) options.
+type Server struct {
+	// Files maps file names that clients request to local file paths.
+	// Other files cannot be read.
+	Files map[string]string
+
+	Conn net.PacketConn
+}
+
+// Serve runs until context is canceled.
+//
+// Once ctx is canceled, s.Conn will be closed.
+func (s Server) Serve(ctx context.Context) error {
+	env := well.NewEnvironment(ctx)
+	env.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return s.Conn.Close()
+	})
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.Conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != context.Canceled {
+				log.Error("tftp: ReadFrom returns an error, exiting", map[string]interface{}{
+					log.FnError: err.Error(),
+				})
+			}
+			break
+		}
+
+		req, err := parseRequest(buf[:n])
+		if err != nil {
+			log.Warn("tftp: invalid request", map[string]interface{}{
+				"remote":    addr.String(),
+				log.FnError: err.Error(),
+			})
+			s.Conn.WriteTo(makeError(errCodeIllegalOperation, err.Error()), addr)
+			continue
+		}
+
+		env.Go(func(ctx context.Context) error {
+			s.handleRequest(ctx, req, addr)
+			return nil
+		})
+	}
+
+	env.Stop()
+	return env.Wait()
+}
+
+func (s Server) handleRequest(ctx context.Context, req *request, addr net.Addr) {
+	fields := map[string]interface{}{
+		"remote":   addr.String(),
+		"filename": req.Filename,
+	}
+
+	// each transfer uses a new port as transfer ID
+	laddr := &net.UDPAddr{}
+	if a, ok := s.Conn.LocalAddr().(*net.UDPAddr); ok {
+		laddr.IP = a.IP
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		fields[log.FnError] = err.Error()
+		log.Error("tftp: failed to listen", fields)
+		return
+	}
+	defer conn.Close()
+
+	if req.Op != opRRQ {
+		log.Warn("tftp: refused write request", fields)
+		conn.WriteTo(makeError(errCodeAccessViolation, "read only"), addr)
+		return
+	}
+	if req.Mode != "octet" {
+		log.Warn("tftp: unsupported mode", fields)
+		conn.WriteTo(makeError(errCodeNotDefined, "only octet mode is supported"), addr)
+		return
+	}
+
+	p, ok := s.Files[strings.TrimPrefix(req.Filename, "/")]
+	if !ok {
+		log.Warn("tftp: file not found", fields)
+		conn.WriteTo(makeError(errCodeFileNotFound, "file not found"), addr)
+		return
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		fields[log.FnError] = err.Error()
+		log.Error("tftp: failed to open file", fields)
+		conn.WriteTo(makeError(errCodeFileNotFound, "file not found"), addr)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		fields[log.FnError] = err.Error()
+		log.Error("tftp: failed to stat file", fields)
+		conn.WriteTo(makeError(errCodeNotDefined, "internal error"), addr)
+		return
+	}
+
+	t := &transfer{
+		conn:      conn,
+		addr:      addr,
+		blockSize: defaultBlockSize,
+		timeout:   defaultTimeout,
+	}
+	err = t.negotiate(req, fi.Size())
+	if err == nil {
+		log.Info("tftp: sending file", fields)
+		err = t.send(ctx, f)
+	}
+	if err != nil {
+		fields[log.FnError] = err.Error()
+		log.Error("tftp: transfer failed", fields)
+		return
+	}
+	log.Info("tftp: sent file", fields)
+}
+
+type transfer struct {
+	conn      net.PacketConn
+	addr      net.Addr
+	blockSize int
+	timeout   time.Duration
+}
+
+// negotiate handles options in req and sends OACK if necessary.
+func (t *transfer) negotiate(req *request, size int64) error {
+	var keys []string
+	opts := make(map[string]string)
+
+	if v, ok := req.Options["blksize"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minBlockSize {
+			t.conn.WriteTo(makeError(errCodeBadOption, "invalid blksize"), t.addr)
+			return fmt.Errorf("invalid blksize: %s", v)
+		}
+		if n > maxBlockSize {
+			n = maxBlockSize
+		}
+		t.blockSize = n
+		keys = append(keys, "blksize")
+		opts["blksize"] = strconv.Itoa(n)
+	}
+	if v, ok := req.Options["timeout"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 255 {
+			t.conn.WriteTo(makeError(errCodeBadOption, "invalid timeout"), t.addr)
+			return fmt.Errorf("invalid timeout: %s", v)
+		}
+		t.timeout = time.Duration(n) * time.Second
+		keys = append(keys, "timeout")
+		opts["timeout"] = v
+	}
+	if _, ok := req.Options["tsize"]; ok {
+		keys = append(keys, "tsize")
+		opts["tsize"] = strconv.FormatInt(size, 10)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	return t.sendAndWait(makeOACK(keys, opts), 0)
+}
+
+// send sends the content of r block by block.
+func (t *transfer) send(ctx context.Context, r io.Reader) error {
+	buf := make([]byte, t.blockSize)
+	var block uint16
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.conn.WriteTo(makeError(errCodeNotDefined, "read error"), t.addr)
+			return err
+		}
+
+		// block numbers wrap around for large files
+		block++
+		err = t.sendAndWait(makeData(block, buf[:n]), block)
+		if err != nil {
+			return err
+		}
+		if n < t.blockSize {
+			return nil
+		}
+	}
+}
+
+// sendAndWait sends pkt and waits for the ACK for block.
+// pkt is retransmitted on timeout.
+func (t *transfer) sendAndWait(pkt []byte, block uint16) error {
+	buf := make([]byte, 1500)
+	for i := 0; i < maxRetries; i++ {
+		_, err := t.conn.WriteTo(pkt, t.addr)
+		if err != nil {
+			return err
+		}
+
+		deadline := time.Now().Add(t.timeout)
+		for {
+			err = t.conn.SetReadDeadline(deadline)
+			if err != nil {
+				return err
+			}
+			n, addr, err := t.conn.ReadFrom(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return err
+			}
+			if addr.String() != t.addr.String() {
+				// RFC 1350: packets from unknown transfer ID
+				t.conn.WriteTo(makeError(errCodeUnknownTID, "unknown transfer ID"), addr)
+				continue
+			}
+			if msg, ok := parseError(buf[:n]); ok {
+				return errors.New("client error: " + msg)
+			}
+			if ack, ok := parseACK(buf[:n]); ok && ack == block {
+				return nil
+			}
+			// ignore duplicate ACKs for previous blocks
+		}
+	}
+	return fmt.Errorf("timed out waiting for ACK of block %d", block)
+}
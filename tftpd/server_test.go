@@ -0,0 +1,223 @@
+package tftpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testStartServer(t *testing.T) (net.Addr, []byte) {
+	t.Helper()
+
+	content := make([]byte, 512*3+100)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	dir := t.TempDir()
+	p := filepath.Join(dir, "undionly.kpxe")
+	err := os.WriteFile(p, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// exact multiple of the block size
+	err = os.WriteFile(filepath.Join(dir, "ipxe.efi"), content[:1024], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Server{
+		Files: map[string]string{
+			"undionly.kpxe": p,
+			"ipxe.efi":      filepath.Join(dir, "ipxe.efi"),
+			"missing.efi":   filepath.Join(dir, "missing.efi"),
+		},
+		Conn: conn,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return conn.LocalAddr(), content
+}
+
+func testMakeRequest(op uint16, filename string, opts ...string) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, op)
+	for _, s := range append([]string{filename, "octet"}, opts...) {
+		buf = append(buf, s...)
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// testRead reads a file from the server.
+// It returns the content and options in OACK, or the error packet.
+func testRead(t *testing.T, server net.Addr, req []byte) ([]byte, map[string]string, []byte) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.WriteTo(req, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content []byte
+	opts := make(map[string]string)
+	blockSize := 512
+	buf := make([]byte, 65536)
+	var expected uint16 = 1
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkt := buf[:n]
+
+		switch binary.BigEndian.Uint16(pkt[0:2]) {
+		case opERROR:
+			return nil, nil, append([]byte(nil), pkt...)
+		case opOACK:
+			fields := bytes.Split(pkt[2:n-1], []byte{0})
+			for i := 0; i+1 < len(fields); i += 2 {
+				opts[string(fields[i])] = string(fields[i+1])
+			}
+			if v, ok := opts["blksize"]; ok {
+				blockSize, err = strconv.Atoi(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			conn.WriteTo([]byte{0, byte(opACK), 0, 0}, addr)
+		case opDATA:
+			block := binary.BigEndian.Uint16(pkt[2:4])
+			if block != expected {
+				t.Fatal("unexpected block:", block, expected)
+			}
+			expected++
+			content = append(content, pkt[4:]...)
+			ack := []byte{0, byte(opACK), 0, 0}
+			binary.BigEndian.PutUint16(ack[2:4], block)
+			conn.WriteTo(ack, addr)
+			if n-4 < blockSize {
+				return content, opts, nil
+			}
+		default:
+			t.Fatal("unexpected packet:", pkt)
+		}
+	}
+}
+
+func testServerRead(t *testing.T) {
+	addr, content := testStartServer(t)
+
+	data, opts, errPkt := testRead(t, addr, testMakeRequest(opRRQ, "undionly.kpxe"))
+	if errPkt != nil {
+		t.Fatal("unexpected error:", errPkt)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("wrong content:", len(data))
+	}
+	if len(opts) != 0 {
+		t.Error("unexpected OACK:", opts)
+	}
+
+	data, opts, errPkt = testRead(t, addr, testMakeRequest(opRRQ, "/undionly.kpxe", "blksize", "1432", "tsize", "0"))
+	if errPkt != nil {
+		t.Fatal("unexpected error:", errPkt)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("wrong content:", len(data))
+	}
+	if opts["blksize"] != "1432" || opts["tsize"] != "1636" {
+		t.Error("wrong OACK:", opts)
+	}
+
+	data, _, errPkt = testRead(t, addr, testMakeRequest(opRRQ, "ipxe.efi"))
+	if errPkt != nil {
+		t.Fatal("unexpected error:", errPkt)
+	}
+	if !bytes.Equal(data, content[:1024]) {
+		t.Error("wrong content:", len(data))
+	}
+}
+
+func testServerErrors(t *testing.T) {
+	addr, _ := testStartServer(t)
+
+	testCases := []struct {
+		req  []byte
+		code uint16
+	}{
+		{testMakeRequest(opRRQ, "../etc/passwd"), errCodeFileNotFound},
+		{testMakeRequest(opRRQ, "missing.efi"), errCodeFileNotFound},
+		{testMakeRequest(opWRQ, "ipxe.efi"), errCodeAccessViolation},
+		{testMakeRequest(opRRQ, "ipxe.efi", "blksize", "4"), errCodeBadOption},
+		{[]byte{0, byte(opACK), 0, 1}, errCodeIllegalOperation},
+	}
+	for _, c := range testCases {
+		_, _, errPkt := testRead(t, addr, c.req)
+		if errPkt == nil {
+			t.Error("error is expected:", c.req)
+			continue
+		}
+		if code := binary.BigEndian.Uint16(errPkt[2:4]); code != c.code {
+			t.Error("wrong error code:", code, c.code)
+		}
+	}
+}
+
+func testParseRequest(t *testing.T) {
+	t.Parallel()
+
+	req, err := parseRequest(testMakeRequest(opRRQ, "ipxe.efi", "BLKSIZE", "1468"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Op != opRRQ || req.Filename != "ipxe.efi" || req.Mode != "octet" {
+		t.Error("wrong request:", req)
+	}
+	if req.Options["blksize"] != "1468" {
+		t.Error("wrong options:", req.Options)
+	}
+
+	badCases := [][]byte{
+		{0},
+		{0, byte(opRRQ), 'a', 0},
+		{0, byte(opRRQ), 'a', 0, 'o', 'c', 't', 'e', 't'},
+		{0, byte(opRRQ), 'a', 0, 'o', 0, 'b', 0},
+	}
+	for _, c := range badCases {
+		_, err := parseRequest(c)
+		if err == nil {
+			t.Error("request should be invalid:", c)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Run("Read", testServerRead)
+	t.Run("Errors", testServerErrors)
+	t.Run("ParseRequest", testParseRequest)
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func (s Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	args := strings.SplitN(r.URL.Path[len("/api/v1/annotations/"):], "/", 2)
+	if len(args) != 2 {
+		renderError(r.Context(), w, APIErrBadRequest)
+		return
+	}
+	if !sabakan.IsValidAnnotationName(args[1]) {
+		renderError(r.Context(), w, BadRequest("invalid annotation name"))
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		s.handleAnnotationsPut(w, r, args[0], args[1])
+		return
+	case "DELETE":
+		s.handleAnnotationsDelete(w, r, args[0], args[1])
+		return
+	}
+
+	renderError(r.Context(), w, APIErrBadMethod)
+}
+
+func (s Server) handleAnnotationsPut(w http.ResponseWriter, r *http.Request, serial, name string) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, sabakan.MaxAnnotationsSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			renderError(r.Context(), w, APIErrTooLargeAnnotations)
+			return
+		}
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	if !sabakan.IsValidAnnotationValue(string(value)) {
+		renderError(r.Context(), w, BadRequest("annotation value is not valid UTF-8"))
+		return
+	}
+
+	err = s.Model.Machine.PutAnnotation(r.Context(), serial, name, string(value))
+	switch err {
+	case nil:
+	case sabakan.ErrNotFound:
+		renderError(r.Context(), w, APIErrNotFound)
+	case sabakan.ErrAnnotationsTooLarge:
+		renderError(r.Context(), w, APIErrTooLargeAnnotations)
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
+
+func (s Server) handleAnnotationsDelete(w http.ResponseWriter, r *http.Request, serial, name string) {
+	err := s.Model.Machine.DeleteAnnotation(r.Context(), serial, name)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
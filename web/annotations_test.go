@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func testAnnotationsPut(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	m.Machine.Register(context.Background(), []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: "1234abcd",
+			Rack:   1,
+			Role:   "worker",
+			BMC:    sabakan.MachineBMC{Type: "IPMI-2.0"},
+		}),
+	})
+
+	note := "Replace the fan.\nSee https://example.com/tickets/123"
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/annotations/1234abcd/note", strings.NewReader(note))
+	handler.ServeHTTP(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Error("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+
+	stored, err := m.Machine.Get(context.Background(), "1234abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored.Spec.Annotations, map[string]string{"note": note}) {
+		t.Error("stored annotations are wrong:", stored.Spec.Annotations)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/annotations/1234abcd/"+strings.Repeat("too-long", 8), strings.NewReader("value"))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("resp.StatusCode != http.StatusBadRequest:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/annotations/1234abcd/binary", strings.NewReader("\xff\xfe"))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("resp.StatusCode != http.StatusBadRequest:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/annotations/1234abcd/huge", strings.NewReader(strings.Repeat("a", sabakan.MaxAnnotationsSize+1)))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Error("resp.StatusCode != http.StatusRequestEntityTooLarge:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/annotations/1234abcd/huge", strings.NewReader(strings.Repeat("a", sabakan.MaxAnnotationsSize)))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Error("resp.StatusCode != http.StatusRequestEntityTooLarge:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/annotations/5678efgh/note", strings.NewReader(note))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("resp.StatusCode != http.StatusNotFound:", resp.StatusCode)
+	}
+}
+
+func testAnnotationsDelete(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	m.Machine.Register(context.Background(), []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial:      "1234abcd",
+			Annotations: map[string]string{"note": "hello"},
+			Rack:        1,
+			Role:        "worker",
+			BMC:         sabakan.MachineBMC{Type: "IPMI-2.0"},
+		}),
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/api/v1/annotations/1234abcd/note", nil)
+	handler.ServeHTTP(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+
+	stored, err := m.Machine.Get(context.Background(), "1234abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Spec.Annotations["note"]; ok {
+		t.Error("annotation was not deleted correctly:", stored.Spec.Annotations)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/annotations/1234abcd/note", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("resp.StatusCode != http.StatusNotFound:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/annotations/5678efgh/note", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("resp.StatusCode != http.StatusNotFound:", resp.StatusCode)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	t.Run("Put", testAnnotationsPut)
+	t.Run("Delete", testAnnotationsDelete)
+}
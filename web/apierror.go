@@ -32,13 +32,21 @@ func BadRequest(reason string) APIError {
 	return APIError{http.StatusBadRequest, "invalid request: " + reason, nil}
 }
 
+// Conflict creates an APIError that describes what was conflicted.
+func Conflict(reason string) APIError {
+	return APIError{http.StatusConflict, "conflicted: " + reason, nil}
+}
+
 // Common API errors
 var (
-	APIErrBadRequest     = APIError{http.StatusBadRequest, "invalid request", nil}
-	APIErrForbidden      = APIError{http.StatusForbidden, "forbidden", nil}
-	APIErrNotFound       = APIError{http.StatusNotFound, "requested resource is not found", nil}
-	APIErrBadMethod      = APIError{http.StatusMethodNotAllowed, "method not allowed", nil}
-	APIErrConflict       = APIError{http.StatusConflict, "conflicted", nil}
-	APIErrLengthRequired = APIError{http.StatusLengthRequired, "content-length is required", nil}
-	APIErrTooLargeAsset  = APIError{http.StatusRequestEntityTooLarge, "too large asset", nil}
+	APIErrBadRequest          = APIError{http.StatusBadRequest, "invalid request", nil}
+	APIErrForbidden           = APIError{http.StatusForbidden, "forbidden", nil}
+	APIErrNotFound            = APIError{http.StatusNotFound, "requested resource is not found", nil}
+	APIErrBadMethod           = APIError{http.StatusMethodNotAllowed, "method not allowed", nil}
+	APIErrConflict            = APIError{http.StatusConflict, "conflicted", nil}
+	APIErrLengthRequired      = APIError{http.StatusLengthRequired, "content-length is required", nil}
+	APIErrTooLargeAsset       = APIError{http.StatusRequestEntityTooLarge, "too large asset", nil}
+	APIErrTooLargeAnnotations = APIError{http.StatusRequestEntityTooLarge, "too large annotations", nil}
+	APIErrAssetQuotaExceeded  = APIError{http.StatusRequestEntityTooLarge, "asset quota exceeded", nil}
+	APIErrTooLargeSecret      = APIError{http.StatusRequestEntityTooLarge, "too large secret", nil}
 )
@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -11,7 +12,7 @@ import (
 )
 
 const (
-	maxAssetSize = 2 << 31
+	maxAssetSize = sabakan.MaxAssetSize
 )
 
 func (s Server) handleAssets(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +24,11 @@ func (s Server) handleAssets(w http.ResponseWriter, r *http.Request) {
 	params := strings.Split(r.URL.Path[len("/api/v1/assets/"):], "/")
 	name := params[0]
 
+	if len(params) >= 2 && params[1] == "uploads" {
+		s.handleAssetsUploads(w, r, name, params[2:])
+		return
+	}
+
 	switch r.Method {
 	case "GET", "HEAD":
 		switch len(params) {
@@ -30,15 +36,36 @@ func (s Server) handleAssets(w http.ResponseWriter, r *http.Request) {
 			s.handleAssetsGet(w, r, name)
 			return
 		case 2:
-			if params[1] == "meta" {
+			switch params[1] {
+			case "meta":
 				s.handleAssetsInfo(w, r, name)
 				return
+			case "history":
+				s.handleAssetsHistory(w, r, name)
+				return
+			case "import":
+				s.handleAssetsImportStatus(w, r, name)
+				return
 			}
 		}
 		renderError(r.Context(), w, APIErrBadRequest)
+	case "POST":
+		if len(params) == 1 {
+			s.handleAssetsImport(w, r, name)
+			return
+		}
+		if len(params) == 2 && params[1] == "rollback" {
+			s.handleAssetsRollback(w, r, name)
+			return
+		}
+		renderError(r.Context(), w, APIErrBadRequest)
 	case "PUT":
 		s.handleAssetsPut(w, r, name)
 	case "DELETE":
+		if len(params) == 2 && params[1] == "import" {
+			s.handleAssetsImportCancel(w, r, name)
+			return
+		}
 		s.handleAssetsDelete(w, r, name)
 	default:
 		renderError(r.Context(), w, APIErrBadMethod)
@@ -65,6 +92,7 @@ func (h assetHandler) ServeContent(asset *sabakan.Asset, content io.ReadSeeker)
 	header.Set("content-type", asset.ContentType)
 	header.Set("X-Sabakan-Asset-ID", strconv.Itoa(asset.ID))
 	header.Set("X-Sabakan-Asset-SHA256", asset.Sha256)
+	header.Set("ETag", strconv.Quote(asset.Sha256))
 	http.ServeContent(h.w, h.r, asset.Name, asset.Date, content)
 }
 
@@ -73,7 +101,39 @@ func (h assetHandler) Redirect(u string) {
 }
 
 func (s Server) handleAssetsGet(w http.ResponseWriter, r *http.Request, name string) {
-	err := s.Model.Asset.Get(r.Context(), name, assetHandler{w, r})
+	var id int
+	if v := r.URL.Query().Get("id"); len(v) > 0 {
+		var err error
+		id, err = strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			renderError(r.Context(), w, BadRequest("invalid id: "+v))
+			return
+		}
+	}
+
+	err := s.Model.Asset.CheckAccess(r.Context(), name, id, &sabakan.AssetRequester{
+		IP:      remoteIP(r),
+		Token:   r.Header.Get(sabakan.HeaderMachineToken),
+		Trusted: s.isAllowedRemote(r),
+	})
+	switch err {
+	case nil:
+	case sabakan.ErrNotFound:
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	case sabakan.ErrForbidden:
+		renderError(r.Context(), w, APIErrForbidden)
+		return
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	if id != 0 {
+		err = s.Model.Asset.GetVersion(r.Context(), name, id, assetHandler{w, r})
+	} else {
+		err = s.Model.Asset.Get(r.Context(), name, assetHandler{w, r})
+	}
 	if err == sabakan.ErrNotFound {
 		renderError(r.Context(), w, APIErrNotFound)
 		return
@@ -123,6 +183,30 @@ func (s Server) handleAssetsPut(w http.ResponseWriter, r *http.Request, name str
 		csum = c
 	}
 
+	options, err := assetOptions(r)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	status, err := s.Model.Asset.Put(r.Context(), name, contentType, csum, options, r.Body)
+	if err == sabakan.ErrConflicted {
+		renderError(r.Context(), w, APIErrConflict)
+		return
+	}
+	if errors.Is(err, sabakan.ErrQuotaExceeded) {
+		renderError(r.Context(), w, APIErrAssetQuotaExceeded)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, status, status.Status)
+}
+
+// assetOptions returns options given by X-Sabakan-Asset-Options-<KEY> headers.
+func assetOptions(r *http.Request) (map[string]string, error) {
 	options := make(map[string]string)
 	for k, v := range r.Header {
 		optionHeaderPrefix := "x-sabakan-asset-options-"
@@ -131,37 +215,79 @@ func (s Server) handleAssetsPut(w http.ResponseWriter, r *http.Request, name str
 			if len(key) == 0 {
 				continue
 			}
-			if !sabakan.IsValidLabelName(key) {
-				renderError(r.Context(), w, BadRequest("invalid option key"+key))
-				return
-			}
-			if !sabakan.IsValidLabelValue(v[0]) {
-				renderError(r.Context(), w, BadRequest("invalid option value"+v[0]))
-				return
-			}
 			options[key] = v[0]
 		}
 	}
+	return options, sabakan.ValidateAssetOptions(options)
+}
 
-	status, err := s.Model.Asset.Put(r.Context(), name, contentType, csum, options, r.Body)
-	if err == sabakan.ErrConflicted {
-		renderError(r.Context(), w, APIErrConflict)
+func (s Server) handleAssetsDelete(w http.ResponseWriter, r *http.Request, name string) {
+	err := s.Model.Asset.Delete(r.Context(), name)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
 		return
 	}
 	if err != nil {
 		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
+
+func (s Server) handleAssetsHistory(w http.ResponseWriter, r *http.Request, name string) {
+	history, err := s.Model.Asset.GetHistory(r.Context(), name)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
 		return
 	}
-	renderJSON(w, status, status.Status)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, history, http.StatusOK)
 }
 
-func (s Server) handleAssetsDelete(w http.ResponseWriter, r *http.Request, name string) {
-	err := s.Model.Asset.Delete(r.Context(), name)
+func (s Server) handleAssetsRollback(w http.ResponseWriter, r *http.Request, name string) {
+	v := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest("invalid id: "+v))
+		return
+	}
+
+	err = s.Model.Asset.Rollback(r.Context(), name, id)
 	if err == sabakan.ErrNotFound {
 		renderError(r.Context(), w, APIErrNotFound)
 		return
 	}
 	if err != nil {
 		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, &sabakan.AssetStatus{Status: http.StatusOK, ID: id}, http.StatusOK)
+}
+
+func (s Server) handleGCAssets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "POST" {
+		renderError(ctx, w, APIErrBadMethod)
+		return
+	}
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dry-run"); len(v) > 0 {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest("invalid dry-run: "+v))
+			return
+		}
+	}
+
+	report, err := s.Model.Asset.GC(ctx, dryRun)
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
 	}
+	renderJSON(w, report, http.StatusOK)
 }
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cybozu-go/sabakan/v3"
 	"github.com/cybozu-go/sabakan/v3/models/mock"
@@ -167,6 +168,71 @@ func testHandleAssetsGet(t *testing.T) {
 	}
 }
 
+func testHandleAssetsGetRange(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	_, err := m.Asset.Put(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := m.Asset.GetInfo(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := `"` + asset.Sha256 + `"`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("ETag") != etag {
+		t.Error("wrong ETag:", w.Header().Get("ETag"))
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	r.Header.Set("If-None-Match", etag)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Error("w.Code != http.StatusNotModified:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	r.Header.Set("If-Modified-Since", asset.Date.Add(time.Second).Format(http.TimeFormat))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Error("w.Code != http.StatusNotModified:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	r.Header.Set("Range", "bytes=4-")
+	r.Header.Set("If-Range", etag)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent {
+		t.Fatal("w.Code != http.StatusPartialContent:", w.Code)
+	}
+	if w.Body.String() != "456789" {
+		t.Error("wrong partial content:", w.Body.String())
+	}
+
+	// the asset has been updated
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	r.Header.Set("Range", "bytes=4-")
+	r.Header.Set("If-Range", `"0000"`)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	if w.Body.String() != "0123456789" {
+		t.Error("wrong content:", w.Body.String())
+	}
+}
+
 func testHandleAssetsPut(t *testing.T) {
 	t.Parallel()
 	m := mock.NewModel()
@@ -290,6 +356,19 @@ func testHandleAssetsPut(t *testing.T) {
 	if resp.StatusCode == http.StatusOK {
 		t.Error("resp.StatusCode == http.StatusOK")
 	}
+
+	// invalid ttl
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/assets/foo", strings.NewReader("bar"))
+	r.Header.Set("content-length", "3")
+	r.Header.Set("content-type", "text/plain")
+	r.Header.Set("X-Sabakan-Asset-Options-TTL", "-1h")
+	handler.ServeHTTP(w, r)
+
+	resp = w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("resp.StatusCode != http.StatusBadRequest:", resp.StatusCode)
+	}
 }
 
 func testHandleAssetsDelete(t *testing.T) {
@@ -322,10 +401,277 @@ func testHandleAssetsDelete(t *testing.T) {
 	}
 }
 
+func testHandleAssetsHistory(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/assets/foo/history", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	var ids []int
+	for _, data := range []string{"v1", "v2", "v3"} {
+		status, err := m.Asset.Put(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, status.ID)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo/history", nil)
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	var history []*sabakan.Asset
+	err := json.NewDecoder(resp.Body).Decode(&history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].ID != ids[0] || history[2].ID != ids[2] {
+		t.Error("wrong history:", history)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo?id="+strconv.Itoa(ids[0]), nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	if w.Body.String() != "v1" {
+		t.Error("wrong content:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo?id=100", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo?id=abc", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+}
+
+func testHandleAssetsRollback(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	var ids []int
+	for _, data := range []string{"v1", "v2"} {
+		status, err := m.Asset.Put(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, status.ID)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/assets/foo/rollback", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/assets/foo/rollback?id=100", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/assets/foo/rollback?id="+strconv.Itoa(ids[0]), nil)
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	var status sabakan.AssetStatus
+	err := json.NewDecoder(resp.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ID != ids[0] {
+		t.Error("wrong id:", status.ID)
+	}
+
+	asset, err := m.Asset.GetInfo(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != ids[0] || !reflect.DeepEqual(asset.History, []int{ids[1]}) {
+		t.Error("asset was not rolled back:", asset.ID, asset.History)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "v1" {
+		t.Error("wrong content:", w.Body.String())
+	}
+}
+
+func testHandleGCAssets(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	ctx := context.Background()
+
+	err := m.Ignition.PutTemplate(ctx, "cs", "1.0.0", &sabakan.IgnitionTemplate{
+		Version:  sabakan.Ignition2_3,
+		Template: json.RawMessage(`{"storage": {"files": [{"contents": {"source": "{{ MyURL }}/api/v1/assets/foo"}}]}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		_, err = m.Asset.Put(ctx, name, "text/plain", nil, nil, strings.NewReader(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/gc/assets", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("w.Code != http.StatusMethodNotAllowed:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/gc/assets?dry-run=maybe", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("POST", "/api/v1/gc/assets?dry-run="+strconv.FormatBool(dryRun), nil)
+		handler.ServeHTTP(w, r)
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+		}
+		var report sabakan.AssetGCReport
+		err = json.NewDecoder(resp.Body).Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Unreferenced) != 1 || report.Unreferenced[0].Name != "bar" || report.Removed == dryRun {
+			t.Error("wrong report:", report)
+		}
+	}
+
+	index, err := m.Asset.GetIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(index, []string{"foo"}) {
+		t.Error("unreferenced asset was not removed:", index)
+	}
+}
+
+func testHandleAssetsGetRestricted(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	ctx := context.Background()
+
+	err := m.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1", Role: "boot", IPv4: []string{"10.69.0.3"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "2", Role: "worker", IPv4: []string{"10.69.0.4"}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Asset.Put(ctx, "foo", "text/plain", nil, map[string]string{"allow-roles": "boot"}, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remote string
+		status int
+	}{
+		{"10.69.0.3:1234", http.StatusOK},
+		{"10.69.0.4:1234", http.StatusForbidden},
+		{"10.69.0.5:1234", http.StatusForbidden},
+		// allowed remotes can download any asset
+		{"192.0.2.1:1234", http.StatusOK},
+	}
+	for _, c := range cases {
+		for _, method := range []string{"GET", "HEAD"} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(method, "/api/v1/assets/foo", nil)
+			r.RemoteAddr = c.remote
+			handler.ServeHTTP(w, r)
+
+			resp := w.Result()
+			if resp.StatusCode != c.status {
+				t.Errorf("%s from %s: unexpected status: %d", method, c.remote, resp.StatusCode)
+			}
+		}
+	}
+
+	// versions are checked by their own options
+	status, err := m.Asset.Put(ctx, "foo", "text/plain", nil, nil, strings.NewReader("baz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := []struct {
+		id     int
+		status int
+	}{
+		{status.ID, http.StatusOK},
+		{status.ID - 1, http.StatusForbidden},
+	}
+	for _, v := range versions {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/assets/foo?id="+strconv.Itoa(v.id), nil)
+		r.RemoteAddr = "10.69.0.4:1234"
+		handler.ServeHTTP(w, r)
+		if w.Result().StatusCode != v.status {
+			t.Errorf("version %d: unexpected status: %d", v.id, w.Result().StatusCode)
+		}
+	}
+
+	// meta data is not restricted
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/assets/foo/meta", nil)
+	r.RemoteAddr = "10.69.0.4:1234"
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Error("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+}
+
 func TestHandleAssets(t *testing.T) {
 	t.Run("GetIndex", testHandleAssetsGetIndex)
 	t.Run("GetInfo", testHandleAssetsGetInfo)
 	t.Run("Get", testHandleAssetsGet)
+	t.Run("GetRange", testHandleAssetsGetRange)
+	t.Run("GetRestricted", testHandleAssetsGetRestricted)
 	t.Run("Put", testHandleAssetsPut)
 	t.Run("Delete", testHandleAssetsDelete)
+	t.Run("History", testHandleAssetsHistory)
+	t.Run("Rollback", testHandleAssetsRollback)
+	t.Run("GC", testHandleGCAssets)
 }
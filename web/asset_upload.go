@@ -0,0 +1,167 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+const (
+	maxAssetUploadSize = maxAssetSize * 64
+)
+
+// handleAssetsUploads handles /api/v1/assets/<name>/uploads[/...].
+func (s Server) handleAssetsUploads(w http.ResponseWriter, r *http.Request, name string, params []string) {
+	switch len(params) {
+	case 0:
+		switch r.Method {
+		case "GET", "HEAD":
+			s.handleAssetsUploadsGet(w, r, name)
+		case "POST":
+			s.handleAssetsUploadsCreate(w, r, name)
+		default:
+			renderError(r.Context(), w, APIErrBadMethod)
+		}
+		return
+	case 1:
+		switch r.Method {
+		case "GET", "HEAD":
+			s.handleAssetsUploadGet(w, r, name, params[0])
+		case "DELETE":
+			s.handleAssetsUploadAbort(w, r, name, params[0])
+		default:
+			renderError(r.Context(), w, APIErrBadMethod)
+		}
+		return
+	case 2:
+		if params[1] == "commit" {
+			if r.Method != "POST" {
+				renderError(r.Context(), w, APIErrBadMethod)
+				return
+			}
+			s.handleAssetsUploadCommit(w, r, name, params[0])
+			return
+		}
+		if r.Method != "PUT" {
+			renderError(r.Context(), w, APIErrBadMethod)
+			return
+		}
+		s.handleAssetsUploadPart(w, r, name, params[0], params[1])
+		return
+	}
+	renderError(r.Context(), w, APIErrBadRequest)
+}
+
+func renderUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case err == sabakan.ErrNotFound:
+		renderError(r.Context(), w, APIErrNotFound)
+	case err == sabakan.ErrConflicted:
+		renderError(r.Context(), w, APIErrConflict)
+	case errors.Is(err, sabakan.ErrQuotaExceeded):
+		renderError(r.Context(), w, APIErrAssetQuotaExceeded)
+	case errors.Is(err, sabakan.ErrChecksumMismatch), errors.Is(err, sabakan.ErrIncompleteUpload):
+		renderError(r.Context(), w, BadRequest(err.Error()))
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
+
+func (s Server) handleAssetsUploadsGet(w http.ResponseWriter, r *http.Request, name string) {
+	uploads, err := s.Model.Asset.GetUploads(r.Context(), name)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	renderJSON(w, uploads, http.StatusOK)
+}
+
+func (s Server) handleAssetsUploadsCreate(w http.ResponseWriter, r *http.Request, name string) {
+	upload := new(sabakan.AssetUpload)
+	err := json.NewDecoder(r.Body).Decode(upload)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	upload.Name = name
+	err = upload.Validate()
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if upload.Size > maxAssetUploadSize {
+		renderError(r.Context(), w, APIErrTooLargeAsset)
+		return
+	}
+
+	upload, err = s.Model.Asset.CreateUpload(r.Context(), upload)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	renderJSON(w, upload, http.StatusCreated)
+}
+
+func (s Server) handleAssetsUploadGet(w http.ResponseWriter, r *http.Request, name, id string) {
+	upload, err := s.Model.Asset.GetUpload(r.Context(), name, id)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	renderJSON(w, upload, http.StatusOK)
+}
+
+func (s Server) handleAssetsUploadPart(w http.ResponseWriter, r *http.Request, name, id, number string) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > sabakan.MaxAssetUploadParts {
+		renderError(r.Context(), w, BadRequest("invalid part number: "+number))
+		return
+	}
+	if r.ContentLength < 0 {
+		renderError(r.Context(), w, APIErrLengthRequired)
+		return
+	}
+	if r.ContentLength > maxAssetSize {
+		renderError(r.Context(), w, APIErrTooLargeAsset)
+		return
+	}
+
+	sum := r.Header.Get("X-Sabakan-Part-SHA256")
+	csum, err := hex.DecodeString(sum)
+	if err != nil || len(csum) != 32 {
+		renderError(r.Context(), w, BadRequest("bad checksum: "+sum))
+		return
+	}
+
+	part, err := s.Model.Asset.PutUploadPart(r.Context(), name, id, n, csum, r.Body)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	renderJSON(w, part, http.StatusOK)
+}
+
+func (s Server) handleAssetsUploadCommit(w http.ResponseWriter, r *http.Request, name, id string) {
+	status, err := s.Model.Asset.CommitUpload(r.Context(), name, id)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	renderJSON(w, status, status.Status)
+}
+
+func (s Server) handleAssetsUploadAbort(w http.ResponseWriter, r *http.Request, name, id string) {
+	err := s.Model.Asset.AbortUpload(r.Context(), name, id)
+	if err != nil {
+		renderUploadError(w, r, err)
+	}
+}
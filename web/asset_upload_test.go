@@ -0,0 +1,193 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func testSha256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func testHandleAssetsUploadsCreate(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"content-type": "text/plain", "size": 4, "sha256": "` + testSha256("0123") + `"}`, http.StatusCreated},
+		{`{"size": 4, "sha256": "` + testSha256("0123") + `"}`, http.StatusBadRequest},
+		{`{"content-type": "text/plain", "size": 4, "sha256": "abcd"}`, http.StatusBadRequest},
+		{`{"content-type": "text/plain", "size": 4, "sha256": "` + testSha256("0123") + `", "options": {"@": "1"}}`, http.StatusBadRequest},
+		{`{`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/v1/assets/foo/uploads", strings.NewReader(c.body))
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Error("wrong status for", c.body, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/assets/foo/uploads", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	var uploads []*sabakan.AssetUpload
+	err := json.NewDecoder(w.Body).Decode(&uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Name != "foo" || uploads[0].Size != 4 {
+		t.Error("wrong uploads:", uploads)
+	}
+}
+
+func testHandleAssetsUploadsCommit(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	body := `{"content-type": "text/plain", "size": 6, "sha256": "` + testSha256("012345") + `"}`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/assets/foo/uploads", strings.NewReader(body))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatal("w.Code != http.StatusCreated:", w.Code)
+	}
+	var upload sabakan.AssetUpload
+	err := json.NewDecoder(w.Body).Decode(&upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "/api/v1/assets/foo/uploads/" + upload.ID
+
+	putPart := func(number, data, sum string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", prefix+"/"+number, bytes.NewBufferString(data))
+		if sum != "" {
+			r.Header.Set("X-Sabakan-Part-SHA256", sum)
+		}
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := putPart("1", "012", ""); code != http.StatusBadRequest {
+		t.Error("checksum should be required:", code)
+	}
+	if code := putPart("1", "012", testSha256("abc")); code != http.StatusBadRequest {
+		t.Error("broken part should be rejected:", code)
+	}
+	if code := putPart("0", "012", testSha256("012")); code != http.StatusBadRequest {
+		t.Error("invalid part number should be rejected:", code)
+	}
+	if code := putPart("1", "012", testSha256("012")); code != http.StatusOK {
+		t.Error("w.Code != http.StatusOK:", code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", prefix+"/commit", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("incomplete upload should not be committed:", w.Code)
+	}
+
+	if code := putPart("2", "345", testSha256("345")); code != http.StatusOK {
+		t.Error("w.Code != http.StatusOK:", code)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", prefix, nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	err = json.NewDecoder(w.Body).Decode(&upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upload.Parts) != 2 || upload.Parts[1].Sha256 != testSha256("345") {
+		t.Error("wrong parts:", upload.Parts)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", prefix+"/commit", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatal("w.Code != http.StatusCreated:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "012345" {
+		t.Error("wrong content:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", prefix, nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("committed upload should be removed:", w.Code)
+	}
+}
+
+func testHandleAssetsUploadsAbort(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	body := `{"content-type": "text/plain", "size": 6, "sha256": "` + testSha256("012345") + `"}`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/assets/foo/uploads", strings.NewReader(body))
+	handler.ServeHTTP(w, r)
+	var upload sabakan.AssetUpload
+	err := json.NewDecoder(w.Body).Decode(&upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/assets/bar/uploads/"+upload.ID, nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/assets/foo/uploads/"+upload.ID, nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Error("w.Code != http.StatusOK:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/assets/foo/uploads/"+upload.ID+"/commit", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("aborted upload should not be found:", w.Code)
+	}
+}
+
+func TestHandleAssetsUploads(t *testing.T) {
+	t.Run("Create", testHandleAssetsUploadsCreate)
+	t.Run("Commit", testHandleAssetsUploadsCommit)
+	t.Run("Abort", testHandleAssetsUploadsAbort)
+}
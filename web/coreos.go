@@ -3,18 +3,21 @@ package web
 import (
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/sabakan/v3"
 )
 
 const (
 	// iPXE script specs can be found at http://ipxe.org/cfg and http://ipxe.org/cmd
 	redirectiPXETemplate = `#!ipxe
-chain %s/${serial}
+chain %s/${serial}?mac=${mac:hexhyp}
 `
 
 	coreOSiPXETemplate = `#!ipxe
@@ -67,6 +70,10 @@ func (s Server) handleCoreOSiPXEWithSerial(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if mac := r.URL.Query().Get("mac"); len(mac) > 0 {
+		s.learnMAC(r, m, mac)
+	}
+
 	role := m.Spec.Role
 	ids, err := s.Model.Ignition.GetTemplateIDs(r.Context(), role)
 	if err != nil {
@@ -92,27 +99,85 @@ func (s Server) handleCoreOSiPXEWithSerial(w http.ResponseWriter, r *http.Reques
 	w.Write([]byte(ipxe))
 }
 
-func (s Server) handleCoreOSKernel(w http.ResponseWriter, r *http.Request) {
-	f := func(modtime time.Time, content io.ReadSeeker) {
-		http.ServeContent(w, r, sabakan.ImageKernelFilename, modtime, content)
+// learnMAC binds the MAC address of the NIC used for network boot to the machine.
+// MAC addresses are not learned when the DHCP server refuses unknown MAC addresses;
+// they must be registered by administrators in that case.
+//
+// To prevent arbitrary clients from binding MAC addresses, the MAC address is
+// learned only when the request comes from the address leased or reserved to it.
+func (s Server) learnMAC(r *http.Request, m *sabakan.Machine, mac string) {
+	hwaddr, err := sabakan.NormalizeMAC(mac)
+	if err != nil || m.HasMAC(hwaddr) {
+		return
 	}
-	w.Header().Set("content-type", "application/octet-stream")
-	err := s.Model.Image.ServeFile(r.Context(), "coreos", sabakan.ImageKernelFilename, f)
-	if err == sabakan.ErrNotFound {
-		renderError(r.Context(), w, APIErrNotFound)
+	config, err := s.Model.DHCP.GetConfig()
+	if err != nil || config.DenyUnknownMAC {
 		return
 	}
+	if !s.isAssignedTo(r, hwaddr) {
+		log.Warn("refused to learn MAC address from unleased address", map[string]interface{}{
+			"serial":    m.Spec.Serial,
+			"mac":       hwaddr,
+			"remote_ip": r.RemoteAddr,
+		})
+		return
+	}
+
+	err = s.Model.Machine.AddMAC(r.Context(), m.Spec.Serial, hwaddr)
 	if err != nil {
-		renderError(r.Context(), w, InternalServerError(err))
+		log.Warn("failed to learn MAC address", map[string]interface{}{
+			log.FnError: err.Error(),
+			"serial":    m.Spec.Serial,
+			"mac":       hwaddr,
+		})
+		return
+	}
+	log.Info("learned MAC address", map[string]interface{}{
+		"serial": m.Spec.Serial,
+		"mac":    hwaddr,
+	})
+}
+
+// isAssignedTo returns true if the source address of r is currently leased
+// or reserved to the NIC of MAC address mac by the DHCP server.
+func (s Server) isAssignedTo(r *http.Request, mac string) bool {
+	ip := remoteIP(r)
+	if ip == nil {
+		return false
+	}
+
+	lease, err := s.Model.DHCP.GetLease(r.Context(), ip)
+	if err == nil && lease.MAC == mac {
+		return true
+	}
+
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return false
 	}
+	rsv, err := s.Model.DHCP.GetReservation(r.Context(), hwaddr)
+	return err == nil && ip.Equal(net.ParseIP(rsv.IP))
+}
+
+func (s Server) handleCoreOSKernel(w http.ResponseWriter, r *http.Request) {
+	s.serveImageFile(w, r, "coreos", sabakan.ImageKernelFilename)
 }
 
 func (s Server) handleCoreOSInitRD(w http.ResponseWriter, r *http.Request) {
-	f := func(modtime time.Time, content io.ReadSeeker) {
-		http.ServeContent(w, r, sabakan.ImageInitrdFilename, modtime, content)
+	s.serveImageFile(w, r, "coreos", sabakan.ImageInitrdFilename)
+}
+
+// serveImageFile serves a file of the newest image.
+// Range and conditional requests are handled by http.ServeContent.
+func (s Server) serveImageFile(w http.ResponseWriter, r *http.Request, os, filename string) {
+	f := func(modtime time.Time, sha256 string, content io.ReadSeeker) {
+		if sha256 != "" {
+			w.Header().Set("ETag", strconv.Quote(sha256))
+		}
+		http.ServeContent(w, r, filename, modtime, content)
 	}
 	w.Header().Set("content-type", "application/octet-stream")
-	err := s.Model.Image.ServeFile(r.Context(), "coreos", sabakan.ImageInitrdFilename, f)
+	err := s.Model.Image.ServeFile(r.Context(), os, filename, f)
 	if err == sabakan.ErrNotFound {
 		renderError(r.Context(), w, APIErrNotFound)
 		return
@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -107,6 +108,92 @@ func testHandleiPXEWithSerial(t *testing.T) {
 	}
 }
 
+func testHandleiPXELearnMAC(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	ctx := context.Background()
+	testWithIPAM(t, m)
+
+	err := m.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "2222abcd", Role: "cs"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Ignition.PutTemplate(ctx, "cs", "1.0.0", &sabakan.IgnitionTemplate{Version: sabakan.Ignition2_3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &sabakan.DHCPConfig{DenyUnknownMAC: true}
+	err = m.DHCP.PutConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hwaddr, _ := net.ParseMAC("0c:c4:7a:00:00:01")
+	leased, err := m.DHCP.Lease(ctx, net.ParseIP("10.69.0.1"), hwaddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boot := func(mac string, from net.IP) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/boot/coreos/ipxe/2222abcd?mac="+mac, nil)
+		if from != nil {
+			r.RemoteAddr = net.JoinHostPort(from.String(), "1234")
+		}
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatal("w.Code != http.StatusOK:", w.Code)
+		}
+	}
+	macs := func() []string {
+		machine, err := m.Machine.Get(ctx, "2222abcd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return machine.Spec.MACs
+	}
+
+	// MAC addresses are not learned if unknown MAC addresses are refused.
+	boot("0c-c4-7a-00-00-01", leased)
+	if len(macs()) != 0 {
+		t.Error("MAC address should not be learned:", macs())
+	}
+
+	config.DenyUnknownMAC = false
+	err = m.DHCP.PutConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MAC addresses are not learned from addresses not leased to them.
+	boot("0c-c4-7a-00-00-01", nil)
+	boot("0c-c4-7a-00-00-02", leased)
+	if len(macs()) != 0 {
+		t.Error("MAC address should not be learned:", macs())
+	}
+
+	boot("0c-c4-7a-00-00-01", leased)
+	boot("0c-c4-7a-00-00-01", leased)
+	boot("invalid", leased)
+	if ms := macs(); len(ms) != 1 || ms[0] != "0c:c4:7a:00:00:01" {
+		t.Error("MAC address was not learned:", ms)
+	}
+
+	// reserved addresses are also accepted.
+	err = m.DHCP.PutReservation(ctx, &sabakan.DHCPReservation{MAC: "0c:c4:7a:00:00:03", IP: "10.69.0.40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	boot("0c-c4-7a-00-00-03", net.ParseIP("10.69.0.40"))
+	if ms := macs(); len(ms) != 2 || ms[1] != "0c:c4:7a:00:00:03" {
+		t.Error("MAC address was not learned:", ms)
+	}
+}
+
 func testHandleCoreOSKernel(t *testing.T) {
 	t.Parallel()
 
@@ -158,6 +245,31 @@ func testHandleCoreOSKernel(t *testing.T) {
 	if string(data) != "opqr" {
 		t.Error("wrong content")
 	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag is not set")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/boot/coreos/kernel", nil)
+	r.Header.Set("If-None-Match", etag)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Error("w.Code != http.StatusNotModified:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/boot/coreos/kernel", nil)
+	r.Header.Set("Range", "bytes=2-")
+	r.Header.Set("If-Range", etag)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent {
+		t.Fatal("w.Code != http.StatusPartialContent:", w.Code)
+	}
+	if w.Body.String() != "qr" {
+		t.Error("wrong partial content:", w.Body.String())
+	}
 }
 
 func testHandleCoreOSInitRD(t *testing.T) {
@@ -216,6 +328,7 @@ func testHandleCoreOSInitRD(t *testing.T) {
 func TestHandleCoreOS(t *testing.T) {
 	t.Run("iPXE", testHandleiPXE)
 	t.Run("iPXEWithSerial", testHandleiPXEWithSerial)
+	t.Run("iPXELearnMAC", testHandleiPXELearnMAC)
 	t.Run("kernel", testHandleCoreOSKernel)
 	t.Run("initrd", testHandleCoreOSInitRD)
 }
@@ -2,7 +2,10 @@ package web
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
@@ -52,3 +55,204 @@ func (s Server) handleConfigDHCPPut(w http.ResponseWriter, r *http.Request) {
 	}
 	renderJSON(w, nil, http.StatusOK)
 }
+
+func (s Server) handleDHCPLeases(w http.ResponseWriter, r *http.Request) {
+	addr := strings.TrimPrefix(r.URL.Path[len("/api/v1/dhcp/leases"):], "/")
+	switch {
+	case len(addr) == 0 && r.Method == "GET":
+		s.handleDHCPLeasesGet(w, r)
+	case len(addr) > 0 && r.Method == "DELETE":
+		s.handleDHCPLeasesDelete(w, r, addr)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleDHCPLeasesGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var ip net.IP
+	if v := r.URL.Query().Get("ip"); len(v) > 0 {
+		ip = net.ParseIP(v)
+		if ip == nil {
+			renderError(ctx, w, BadRequest("invalid IP address: "+v))
+			return
+		}
+	}
+	var mac string
+	if v := r.URL.Query().Get("mac"); len(v) > 0 {
+		var err error
+		mac, err = sabakan.NormalizeMAC(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest(err.Error()))
+			return
+		}
+	}
+
+	var declined *bool
+	if v := r.URL.Query().Get("declined"); len(v) > 0 {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest("invalid declined: "+v))
+			return
+		}
+		declined = &b
+	}
+
+	all, err := s.Model.DHCP.GetLeases(ctx)
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	leases := make([]*sabakan.DHCPLease, 0, len(all))
+	for _, l := range all {
+		if ip != nil && !ip.Equal(net.ParseIP(l.IP)) {
+			continue
+		}
+		if len(mac) > 0 && mac != l.MAC {
+			continue
+		}
+		if declined != nil && *declined != l.Declined {
+			continue
+		}
+		leases = append(leases, l)
+	}
+
+	renderJSON(w, leases, http.StatusOK)
+}
+
+func (s Server) handleDHCPLeasesDelete(w http.ResponseWriter, r *http.Request, addr string) {
+	ctx := r.Context()
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		renderError(ctx, w, BadRequest("invalid IP address: "+addr))
+		return
+	}
+
+	err := s.Model.DHCP.DeleteLease(ctx, ip)
+	if err == sabakan.ErrNotFound {
+		renderError(ctx, w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, nil, http.StatusOK)
+}
+
+func (s Server) handleDHCPReservations(w http.ResponseWriter, r *http.Request) {
+	mac := strings.TrimPrefix(r.URL.Path[len("/api/v1/dhcp/reservations"):], "/")
+	switch {
+	case len(mac) == 0 && r.Method == "GET":
+		s.handleDHCPReservationsGetAll(w, r)
+	case len(mac) > 0 && r.Method == "GET":
+		s.handleDHCPReservationsGet(w, r, mac)
+	case len(mac) > 0 && r.Method == "PUT":
+		s.handleDHCPReservationsPut(w, r, mac)
+	case len(mac) > 0 && r.Method == "DELETE":
+		s.handleDHCPReservationsDelete(w, r, mac)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleDHCPReservationsGetAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	reservations, err := s.Model.DHCP.GetReservations(ctx)
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, reservations, http.StatusOK)
+}
+
+func (s Server) handleDHCPReservationsGet(w http.ResponseWriter, r *http.Request, addr string) {
+	ctx := r.Context()
+
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		renderError(ctx, w, BadRequest("invalid MAC address: "+addr))
+		return
+	}
+
+	reservation, err := s.Model.DHCP.GetReservation(ctx, mac)
+	if err == sabakan.ErrNotFound {
+		renderError(ctx, w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, reservation, http.StatusOK)
+}
+
+func (s Server) handleDHCPReservationsPut(w http.ResponseWriter, r *http.Request, addr string) {
+	ctx := r.Context()
+
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		renderError(ctx, w, BadRequest("invalid MAC address: "+addr))
+		return
+	}
+
+	var reservation sabakan.DHCPReservation
+	err = json.NewDecoder(r.Body).Decode(&reservation)
+	if err != nil {
+		renderError(ctx, w, APIErrBadRequest)
+		return
+	}
+	reservation.MAC = mac.String()
+
+	ipam, err := s.Model.IPAM.GetConfig()
+	if err != nil {
+		renderError(ctx, w, BadRequest("IPAM configuration is not set"))
+		return
+	}
+	err = reservation.Validate(ipam)
+	if err != nil {
+		renderError(ctx, w, BadRequest(err.Error()))
+		return
+	}
+
+	err = s.Model.DHCP.PutReservation(ctx, &reservation)
+	if err == sabakan.ErrConflicted {
+		renderError(ctx, w, APIErrConflict)
+		return
+	}
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, nil, http.StatusOK)
+}
+
+func (s Server) handleDHCPReservationsDelete(w http.ResponseWriter, r *http.Request, addr string) {
+	ctx := r.Context()
+
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		renderError(ctx, w, BadRequest("invalid MAC address: "+addr))
+		return
+	}
+
+	err = s.Model.DHCP.DeleteReservation(ctx, mac)
+	if err == sabakan.ErrNotFound {
+		renderError(ctx, w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, nil, http.StatusOK)
+}
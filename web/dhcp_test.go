@@ -3,8 +3,10 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -109,3 +111,313 @@ func TestConfigDHCP(t *testing.T) {
 	t.Run("Get", testConfigDHCPGet)
 	t.Run("Put", testConfigDHCPPut)
 }
+
+func TestDHCPLeases(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	testWithIPAM(t, m)
+
+	ctx := context.Background()
+	ifaddr := net.ParseIP("10.69.0.1")
+	mac1, _ := net.ParseMAC("00:11:22:33:44:55")
+	mac2, _ := net.ParseMAC("00:11:22:33:44:66")
+	mac3, _ := net.ParseMAC("00:11:22:33:44:77")
+	for _, mac := range []net.HardwareAddr{mac1, mac2, mac3} {
+		_, err := m.DHCP.Lease(ctx, ifaddr, mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := m.DHCP.Decline(ctx, net.ParseIP("10.69.0.33"), mac2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getLeases := func(query string) []*sabakan.DHCPLease {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/dhcp/leases"+query, nil)
+		handler.ServeHTTP(w, r)
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+		}
+		var leases []*sabakan.DHCPLease
+		err := json.NewDecoder(resp.Body).Decode(&leases)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leases
+	}
+
+	leases := getLeases("")
+	if len(leases) != 3 {
+		t.Fatal("wrong number of leases:", len(leases))
+	}
+	expected := []sabakan.DHCPLease{
+		{Range: "10.69.0.32", IP: "10.69.0.32", MAC: "00:11:22:33:44:55"},
+		{Range: "10.69.0.32", IP: "10.69.0.33", Declined: true, DeclinedBy: "00:11:22:33:44:66"},
+		{Range: "10.69.0.32", IP: "10.69.0.34", MAC: "00:11:22:33:44:77"},
+	}
+	for i, l := range leases {
+		if !reflect.DeepEqual(*l, expected[i]) {
+			t.Errorf("unexpected lease #%d: %#v", i, l)
+		}
+	}
+
+	leases = getLeases("?ip=10.69.0.34")
+	if len(leases) != 1 || leases[0].MAC != "00:11:22:33:44:77" {
+		t.Error("wrong leases filtered by ip:", leases)
+	}
+	leases = getLeases("?mac=00-11-22-33-44-55")
+	if len(leases) != 1 || leases[0].IP != "10.69.0.32" {
+		t.Error("wrong leases filtered by mac:", leases)
+	}
+
+	leases = getLeases("?declined=true")
+	if len(leases) != 1 || leases[0].IP != "10.69.0.33" || leases[0].DeclinedBy != "00:11:22:33:44:66" {
+		t.Error("wrong declined leases:", leases)
+	}
+	leases = getLeases("?declined=false")
+	if len(leases) != 2 {
+		t.Error("wrong leases filtered by declined=false:", leases)
+	}
+
+	for _, query := range []string{"?ip=10.69.0", "?mac=foo", "?declined=foo"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/dhcp/leases"+query, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Error("resp.StatusCode != http.StatusBadRequest:", query, w.Code)
+		}
+	}
+
+	testCases := []struct {
+		addr   string
+		status int
+	}{
+		{"10.69.0.33", http.StatusOK},
+		{"10.69.0.33", http.StatusNotFound},
+		{"10.69.0.40", http.StatusNotFound},
+		{"10.69.0", http.StatusBadRequest},
+	}
+	for _, c := range testCases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", "/api/v1/dhcp/leases/"+c.addr, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("unexpected status for %s: %d", c.addr, w.Code)
+		}
+	}
+
+	leases = getLeases("")
+	if len(leases) != 2 {
+		t.Error("lease was not deleted:", leases)
+	}
+}
+
+func TestDHCPReservations(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	put := func(mac, body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/api/v1/dhcp/reservations/"+mac, strings.NewReader(body))
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// IPAM is not configured
+	if code := put("00:11:22:33:44:55", `{"ip": "10.69.0.40"}`); code != http.StatusBadRequest {
+		t.Error("unexpected status without IPAM:", code)
+	}
+	testWithIPAM(t, m)
+
+	mac3, _ := net.ParseMAC("00:11:22:33:44:77")
+	_, err := m.DHCP.Lease(context.Background(), net.ParseIP("10.69.0.1"), mac3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		mac    string
+		body   string
+		status int
+	}{
+		{"00-11-22-33-44-55", `{"ip": "10.69.0.40", "hostname": "sw-mgmt"}`, http.StatusOK},
+		{"00:11:22:33:44:66", `{"ip": "10.69.1.62", "boot-url": "http://10.0.0.1/boot.ipxe"}`, http.StatusOK},
+		// reserved for another MAC
+		{"00:11:22:33:44:88", `{"ip": "10.69.0.40"}`, http.StatusConflict},
+		// leased to another MAC
+		{"00:11:22:33:44:88", `{"ip": "10.69.0.32"}`, http.StatusConflict},
+		// node address
+		{"00:11:22:33:44:88", `{"ip": "10.69.0.3"}`, http.StatusBadRequest},
+		// BMC address
+		{"00:11:22:33:44:88", `{"ip": "10.72.17.3"}`, http.StatusBadRequest},
+		{"00:11:22:33:44:88", `{"ip": "10.69.0.41", "hostname": "a b"}`, http.StatusBadRequest},
+		{"00:11:22:33:44", `{"ip": "10.69.0.41"}`, http.StatusBadRequest},
+		{"00:11:22:33:44:88", `{`, http.StatusBadRequest},
+	}
+	for _, c := range testCases {
+		if code := put(c.mac, c.body); code != c.status {
+			t.Errorf("unexpected status for %s %s: %d", c.mac, c.body, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/dhcp/reservations", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", w.Code)
+	}
+	var reservations []*sabakan.DHCPReservation
+	err = json.NewDecoder(w.Body).Decode(&reservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*sabakan.DHCPReservation{
+		{MAC: "00:11:22:33:44:55", IP: "10.69.0.40", Hostname: "sw-mgmt"},
+		{MAC: "00:11:22:33:44:66", IP: "10.69.1.62", BootURL: "http://10.0.0.1/boot.ipxe"},
+	}
+	if !reflect.DeepEqual(reservations, expected) {
+		t.Error("unexpected reservations:", reservations)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/dhcp/reservations/00:11:22:33:44:66", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", w.Code)
+	}
+	var reservation sabakan.DHCPReservation
+	err = json.NewDecoder(w.Body).Decode(&reservation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation != *expected[1] {
+		t.Error("unexpected reservation:", reservation)
+	}
+
+	deleteCases := []struct {
+		mac    string
+		status int
+	}{
+		{"00:11:22:33:44:55", http.StatusOK},
+		{"00:11:22:33:44:55", http.StatusNotFound},
+		{"foo", http.StatusBadRequest},
+	}
+	for _, c := range deleteCases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", "/api/v1/dhcp/reservations/"+c.mac, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("unexpected status for %s: %d", c.mac, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/dhcp/reservations/00:11:22:33:44:55", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("reservation was not deleted:", w.Code)
+	}
+}
+
+func TestDHCPLeasesGraphQL(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	testWithIPAM(t, m)
+
+	ctx := context.Background()
+	err := m.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: "1234abcd",
+			MACs:   []string{"00:11:22:33:44:55"},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifaddr := net.ParseIP("10.69.0.1")
+	mac1, _ := net.ParseMAC("00:11:22:33:44:55")
+	mac2, _ := net.ParseMAC("00:11:22:33:44:66")
+	for _, mac := range []net.HardwareAddr{mac1, mac2} {
+		_, err := m.DHCP.Lease(ctx, ifaddr, mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := url.Values{}
+	v.Set("query", `{dhcpLeases { ip mac declined machine { spec { serial } } } }`)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+v.Encode(), nil))
+
+	var gqlResponse struct {
+		Errors []interface{} `json:"errors"`
+		Data   struct {
+			DHCPLeases []struct {
+				IP       string  `json:"ip"`
+				MAC      *string `json:"mac"`
+				Declined bool    `json:"declined"`
+				Machine  *struct {
+					Spec struct {
+						Serial string `json:"serial"`
+					} `json:"spec"`
+				} `json:"machine"`
+			} `json:"dhcpLeases"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(w.Result().Body).Decode(&gqlResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gqlResponse.Errors) > 0 {
+		t.Fatal(gqlResponse.Errors)
+	}
+	leases := gqlResponse.Data.DHCPLeases
+	if len(leases) != 2 {
+		t.Fatal("wrong number of leases:", leases)
+	}
+	if leases[0].IP != "10.69.0.32" || leases[0].Machine == nil || leases[0].Machine.Spec.Serial != "1234abcd" {
+		t.Error("wrong lease #0:", leases[0])
+	}
+	if leases[1].IP != "10.69.0.33" || leases[1].Machine != nil {
+		t.Error("wrong lease #1:", leases[1])
+	}
+
+	mutation := `{"query": "mutation { deleteDHCPLease(ip: \"10.69.0.33\") }"}`
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/graphql", strings.NewReader(mutation))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+
+	var mutResponse struct {
+		Errors []interface{} `json:"errors"`
+		Data   struct {
+			DeleteDHCPLease bool `json:"deleteDHCPLease"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(w.Result().Body).Decode(&mutResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mutResponse.Errors) > 0 || !mutResponse.Data.DeleteDHCPLease {
+		t.Error("deleteDHCPLease failed:", mutResponse)
+	}
+
+	all, err := m.DHCP.GetLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].IP != "10.69.0.32" {
+		t.Error("lease was not deleted:", all)
+	}
+}
@@ -2,10 +2,14 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -17,6 +21,72 @@ import (
 
 type renderFunc func(name, tmpl string) (string, error)
 
+// templateLookup looks up data in sabakan for template functions.
+type templateLookup interface {
+	// Secret returns the value of the named secret for the machine.
+	Secret(name string) (string, error)
+
+	// Asset returns the named asset.
+	Asset(name string) (*sabakan.Asset, error)
+
+	// Machines returns machines matching q.
+	Machines(q sabakan.Query) ([]*sabakan.Machine, error)
+}
+
+// templateAsset is returned by `Asset` template function.
+type templateAsset struct {
+	URL    string
+	Sha256 string
+}
+
+// modelLookup is a templateLookup to render templates for a machine.
+type modelLookup struct {
+	ctx   context.Context
+	model sabakan.Model
+	m     *sabakan.Machine
+	ip    net.IP
+}
+
+func (l modelLookup) Secret(name string) (string, error) {
+	// secrets are served only to the machine itself
+	if !isMachineAddress(l.m, l.ip) {
+		return "", sabakan.ErrForbidden
+	}
+	val, err := l.model.Secret.Resolve(l.ctx, l.m, name)
+	if err == sabakan.ErrNotFound {
+		return "", errors.New("no such secret: " + name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+func (l modelLookup) Asset(name string) (*sabakan.Asset, error) {
+	a, err := l.model.Asset.GetInfo(l.ctx, name)
+	if err == sabakan.ErrNotFound {
+		return nil, errors.New("no such asset: " + name)
+	}
+	return a, err
+}
+
+func (l modelLookup) Machines(q sabakan.Query) ([]*sabakan.Machine, error) {
+	return l.model.Machine.Query(l.ctx, q)
+}
+
+// isMachineAddress returns true if ip is one of the addresses of m.
+func isMachineAddress(m *sabakan.Machine, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, a := range append(m.Spec.IPv4, m.Spec.IPv6...) {
+		if ip.Equal(net.ParseIP(a)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s Server) handleIgnitions(w http.ResponseWriter, r *http.Request) {
 	params := strings.Split(r.URL.Path[len("/api/v1/boot/ignitions/"):], "/")
 	if len(params) != 2 {
@@ -48,7 +118,17 @@ func (s Server) handleIgnitions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ign, err := s.renderIgnition(tmpl, m)
+	lookup := modelLookup{
+		ctx:   r.Context(),
+		model: s.Model,
+		m:     m,
+		ip:    remoteIP(r),
+	}
+	ign, err := s.renderIgnition(tmpl, m, lookup)
+	if errors.Is(err, sabakan.ErrForbidden) {
+		renderError(r.Context(), w, APIErrForbidden)
+		return
+	}
 	if err != nil {
 		renderError(r.Context(), w, InternalServerError(err))
 		return
@@ -57,7 +137,7 @@ func (s Server) handleIgnitions(w http.ResponseWriter, r *http.Request) {
 	renderJSON(w, ign, http.StatusOK)
 }
 
-func (s Server) renderIgnition(tmpl *sabakan.IgnitionTemplate, m *sabakan.Machine) (interface{}, error) {
+func (s Server) renderIgnition(tmpl *sabakan.IgnitionTemplate, m *sabakan.Machine, lookup templateLookup) (interface{}, error) {
 	myURL := s.MyURL.String()
 	myURLHTTPS := s.MyURLHTTPS.String()
 
@@ -71,11 +151,48 @@ func (s Server) renderIgnition(tmpl *sabakan.IgnitionTemplate, m *sabakan.Machin
 			}
 			return val, nil
 		},
-		"json": jsonFunc,
-		"add":  addFunc,
-		"sub":  subFunc,
-		"mul":  mulFunc,
-		"div":  divFunc,
+		"Label": func(key string) string {
+			return m.Spec.Labels[key]
+		},
+		"Secret": lookup.Secret,
+		"Asset": func(name string) (*templateAsset, error) {
+			a, err := lookup.Asset(name)
+			if err != nil {
+				return nil, err
+			}
+			return &templateAsset{
+				URL:    myURL + "/api/v1/assets/" + url.PathEscape(name),
+				Sha256: a.Sha256,
+			}, nil
+		},
+		"Machines": func(kvs ...interface{}) ([]*sabakan.Machine, error) {
+			q, err := templateQuery(kvs)
+			if err != nil {
+				return nil, err
+			}
+			machines, err := lookup.Machines(q)
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(machines, func(i, j int) bool {
+				return machines[i].Spec.Serial < machines[j].Spec.Serial
+			})
+			return machines, nil
+		},
+		"json":        jsonFunc,
+		"add":         addFunc,
+		"sub":         subFunc,
+		"mul":         mulFunc,
+		"div":         divFunc,
+		"ipAdd":       ipAddFunc,
+		"cidrHost":    cidrHostFunc,
+		"cidrNetmask": cidrNetmaskFunc,
+		"ipToInt":     ipToIntFunc,
+		"join":        joinFunc,
+		"split":       splitFunc,
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+		"default":     defaultFunc,
 	}
 	render := func(name, tmpl string) (string, error) {
 		buf := &bytes.Buffer{}
@@ -100,6 +217,32 @@ func (s Server) renderIgnition(tmpl *sabakan.IgnitionTemplate, m *sabakan.Machin
 	return nil, errors.New("unsupported ignition version: " + string(tmpl.Version))
 }
 
+// templateQuery builds a machine query from pairs of keys and values
+// given to `Machines` template function.
+func templateQuery(kvs []interface{}) (sabakan.Query, error) {
+	if len(kvs)%2 != 0 {
+		return nil, errors.New("odd number of arguments for query")
+	}
+
+	q := sabakan.Query{}
+	for i := 0; i < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("query key must be a string: %v", kvs[i])
+		}
+		q[key] = fmt.Sprint(kvs[i+1])
+	}
+	if !q.Valid() {
+		return nil, errors.New("'with' and 'without' options about the same things are specified")
+	}
+	for _, k := range []string{"labels", "without-labels"} {
+		if _, err := sabakan.ParseLabelSelector(q[k]); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
 func renderIgnition2_2(tmpl *sabakan.IgnitionTemplate, render renderFunc) (interface{}, error) {
 	ign := new(ign22.Config)
 	err := json.Unmarshal([]byte(tmpl.Template), ign)
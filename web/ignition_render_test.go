@@ -64,6 +64,71 @@ func TestIgnitions(t *testing.T) {
 		t.Error(`ign.Ignition.Version != "2.3.0"`)
 	}
 }
+func TestIgnitionsSecret(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	ctx := context.Background()
+	handler := newTestServer(m)
+
+	mc := sabakan.NewMachine(sabakan.MachineSpec{
+		Serial: "abc",
+		Role:   "cs",
+		IPv4:   []string{"10.69.0.4"},
+	})
+	err := m.Machine.Register(ctx, []*sabakan.Machine{mc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &sabakan.IgnitionTemplate{
+		Version: sabakan.Ignition2_3,
+		Template: json.RawMessage(`{
+  "systemd": {
+    "units": [{"name": "foo.service", "contents": "TOKEN={{ Secret \"token\" }}"}]
+  }
+}`),
+	}
+	err = m.Ignition.PutTemplate(ctx, "cs", "1.0.0", tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(remote string) *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/boot/ignitions/abc/1.0.0", nil)
+		r.RemoteAddr = remote
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	resp := get("10.69.0.4:1234")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Error("missing secret should be an error:", resp.StatusCode)
+	}
+
+	err = m.Secret.Put(ctx, sabakan.SecretScopeRole, "cs", "token", []byte("cs-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = get("10.69.0.4:1234")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	ign := new(ign23.Config)
+	if err := json.NewDecoder(resp.Body).Decode(ign); err != nil {
+		t.Fatal(err)
+	}
+	if len(ign.Systemd.Units) != 1 || ign.Systemd.Units[0].Contents != "TOKEN=cs-token" {
+		t.Error("secret is not rendered:", ign.Systemd.Units)
+	}
+
+	// not even allowed remotes can see secrets
+	resp = get("192.0.2.1:1234")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Error("resp.StatusCode != http.StatusForbidden:", resp.StatusCode)
+	}
+}
+
 func TestRenderIgnition(t *testing.T) {
 	t.Run("2.3", testRenderIgnition2_3)
 }
@@ -78,6 +143,7 @@ func testRenderIgnition2_3(t *testing.T) {
 		Role:        "cs",
 		Rack:        1,
 		IndexInRack: 4,
+		Annotations: map[string]string{"description": "Foo service for ラック1"},
 	})
 	ipam.GenerateIP(mc)
 	strPtr := func(s string) *string { return &s }
@@ -133,6 +199,7 @@ Gateway={{ (index .Info.Network.IPv4 0).Gateway }}
 				{
 					Name: "foo.service",
 					Contents: `[Unit]
+Description={{ index .Spec.Annotations "description" }}
 Wants=var-lib-foo.mount
 After=var-lib-foo.mount
 
@@ -168,7 +235,7 @@ ExecStart=/bin/echo {{ add .Spec.Rack 10 }}
 	}
 
 	s := newTestServer(m)
-	rendered, err := s.renderIgnition(tmpl, mc)
+	rendered, err := s.renderIgnition(tmpl, mc, validationLookup{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -230,6 +297,7 @@ Gateway=10.69.0.193
 		{
 			Name: "foo.service",
 			Contents: `[Unit]
+Description=Foo service for ラック1
 Wants=var-lib-foo.mount
 After=var-lib-foo.mount
 
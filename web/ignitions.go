@@ -116,6 +116,22 @@ func (s Server) validateIgnitionTemplate(tmpl *sabakan.IgnitionTemplate) error {
 	})
 	ipam.GenerateIP(mc)
 
-	_, err = s.renderIgnition(tmpl, mc)
+	_, err = s.renderIgnition(tmpl, mc, validationLookup{})
 	return err
 }
+
+// validationLookup is a templateLookup to validate templates.
+// It does not require secrets, assets, or machines to exist.
+type validationLookup struct{}
+
+func (validationLookup) Secret(name string) (string, error) {
+	return "", nil
+}
+
+func (validationLookup) Asset(name string) (*sabakan.Asset, error) {
+	return &sabakan.Asset{Name: name}, nil
+}
+
+func (validationLookup) Machines(q sabakan.Query) ([]*sabakan.Machine, error) {
+	return nil, nil
+}
@@ -26,7 +26,7 @@ func (s Server) handleImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(params) != 2 {
+	if len(params) != 2 && !(len(params) == 3 && params[2] == "import") {
 		renderError(r.Context(), w, APIErrBadRequest)
 		return
 	}
@@ -36,6 +36,18 @@ func (s Server) handleImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(params) == 3 {
+		switch r.Method {
+		case "GET":
+			s.handleImagesImportStatus(w, r, os, id)
+		case "DELETE":
+			s.handleImagesImportCancel(w, r, os, id)
+		default:
+			renderError(r.Context(), w, APIErrBadMethod)
+		}
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		s.handleImagesGet(w, r, os, id)
@@ -43,6 +55,9 @@ func (s Server) handleImages(w http.ResponseWriter, r *http.Request) {
 	case "PUT":
 		s.handleImagesPut(w, r, os, id)
 		return
+	case "POST":
+		s.handleImagesImport(w, r, os, id)
+		return
 	case "DELETE":
 		s.handleImagesDelete(w, r, os, id)
 		return
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// importSource returns the source URL and the checksum given by query parameters.
+func importSource(r *http.Request) (string, []byte, error) {
+	q := r.URL.Query()
+	src := q.Get("from")
+	csum, err := sabakan.ValidateImportSource(src, q.Get("sha256"))
+	if err != nil {
+		return "", nil, err
+	}
+	return src, csum, nil
+}
+
+func renderImportStatus(w http.ResponseWriter, r *http.Request, status *sabakan.ImportStatus, err error) {
+	switch err {
+	case nil:
+		renderJSON(w, status, http.StatusAccepted)
+	case sabakan.ErrConflicted:
+		renderError(r.Context(), w, APIErrConflict)
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
+
+func (s Server) handleAssetsImport(w http.ResponseWriter, r *http.Request, name string) {
+	src, csum, err := importSource(r)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	options, err := assetOptions(r)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	status, err := s.Model.Asset.Import(r.Context(), name, src, csum, options)
+	renderImportStatus(w, r, status, err)
+}
+
+func (s Server) handleAssetsImportStatus(w http.ResponseWriter, r *http.Request, name string) {
+	status, err := s.Model.Asset.GetImport(r.Context(), name)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, status, http.StatusOK)
+}
+
+func (s Server) handleAssetsImportCancel(w http.ResponseWriter, r *http.Request, name string) {
+	err := s.Model.Asset.CancelImport(r.Context(), name)
+	renderImportCancel(w, r, err)
+}
+
+func (s Server) handleImagesImport(w http.ResponseWriter, r *http.Request, os, id string) {
+	src, csum, err := importSource(r)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	status, err := s.Model.Image.Import(r.Context(), os, id, src, csum)
+	renderImportStatus(w, r, status, err)
+}
+
+func (s Server) handleImagesImportStatus(w http.ResponseWriter, r *http.Request, os, id string) {
+	status, err := s.Model.Image.GetImport(r.Context(), os, id)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, status, http.StatusOK)
+}
+
+func (s Server) handleImagesImportCancel(w http.ResponseWriter, r *http.Request, os, id string) {
+	err := s.Model.Image.CancelImport(r.Context(), os, id)
+	renderImportCancel(w, r, err)
+}
+
+func renderImportCancel(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case nil:
+	case sabakan.ErrNotFound:
+		renderError(r.Context(), w, APIErrNotFound)
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func importURL(p, src, sum string) string {
+	return p + "?" + url.Values{"from": {src}, "sha256": {sum}}.Encode()
+}
+
+func testHandleAssetsImport(t *testing.T) {
+	t.Parallel()
+
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("bar"))
+	}))
+	defer src.Close()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	sum := testSha256("bar")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/assets/foo/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	badRequests := []string{
+		importURL("/api/v1/assets/foo", "", sum),
+		importURL("/api/v1/assets/foo", "ftp://example.com/foo", sum),
+		importURL("/api/v1/assets/foo", src.URL, ""),
+		importURL("/api/v1/assets/foo", src.URL, "abcd"),
+	}
+	for _, u := range badRequests {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("POST", u, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Error("w.Code != http.StatusBadRequest:", u, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", importURL("/api/v1/assets/foo", src.URL, sum), nil)
+	r.Header.Set("X-Sabakan-Asset-Options-Version", "1.0")
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatal("resp.StatusCode != http.StatusAccepted:", resp.StatusCode)
+	}
+	var status sabakan.ImportStatus
+	err := json.NewDecoder(resp.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Source != src.URL || status.Sha256 != sum {
+		t.Error("wrong status:", status)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/assets/foo/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	err = json.NewDecoder(w.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != sabakan.ImportCompleted || status.Received != 3 {
+		t.Error("wrong status:", status)
+	}
+
+	asset, err := m.Asset.GetInfo(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ContentType != "text/plain" || asset.Options["version"] != "1.0" {
+		t.Error("wrong asset:", asset)
+	}
+
+	// the import has already finished
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/assets/foo/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+	_, err = m.Asset.GetInfo(context.Background(), "foo")
+	if err != nil {
+		t.Error("canceling an import should not delete the asset:", err)
+	}
+}
+
+func testHandleImagesImport(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, f := range []string{sabakan.ImageKernelFilename, sabakan.ImageInitrdFilename} {
+		err := tw.WriteHeader(&tar.Header{Name: f, Mode: 0644, Size: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("data"))
+	}
+	tw.Close()
+	archive := buf.Bytes()
+
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer src.Close()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", importURL("/api/v1/images/coreos/1234.5", src.URL, testSha256(string(archive))), nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatal("w.Code != http.StatusAccepted:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/images/coreos/1234.5/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	var status sabakan.ImportStatus
+	err := json.NewDecoder(w.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != sabakan.ImportCompleted || status.ID != "1234.5" {
+		t.Error("wrong status:", status)
+	}
+
+	index, err := m.Image.GetIndex(context.Background(), "coreos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Find("1234.5") == nil {
+		t.Error("image was not imported")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/images/coreos/1234.5/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/images/coreos/1234.5/import", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("w.Code != http.StatusMethodNotAllowed:", w.Code)
+	}
+}
+
+func TestHandleImport(t *testing.T) {
+	t.Run("Assets", testHandleAssetsImport)
+	t.Run("Images", testHandleImagesImport)
+}
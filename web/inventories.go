@@ -0,0 +1,126 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+// maxInventorySize is the maximum size of an inventory document.
+const maxInventorySize = 1 << 20
+
+// handleInventories handles GET requests for the HTTP server.
+func (s Server) handleInventories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		renderError(r.Context(), w, APIErrBadMethod)
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path[len("/api/v1/"):], "inventories")
+	if len(p) == 0 {
+		s.handleInventoriesList(w, r)
+		return
+	}
+
+	params := strings.Split(p[1:], "/")
+	switch {
+	case len(params) == 1 && len(params[0]) > 0:
+		s.handleInventoriesGet(w, r, params[0])
+	case len(params) == 2 && len(params[0]) > 0 && params[1] == "history":
+		s.handleInventoriesHistory(w, r, params[0])
+	default:
+		renderError(r.Context(), w, APIErrNotFound)
+	}
+}
+
+// handleInventoriesHTTPS handles requests for the HTTPS server.
+// Machines upload their inventories to the HTTPS server.
+func (s Server) handleInventoriesHTTPS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		s.handleInventories(w, r)
+		return
+	}
+
+	serial := r.URL.Path[len("/api/v1/inventories/"):]
+	if len(serial) == 0 || strings.Contains(serial, "/") {
+		renderError(r.Context(), w, APIErrBadRequest)
+		return
+	}
+
+	inv := new(sabakan.Inventory)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInventorySize)).Decode(inv)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	err = inv.Validate()
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	stored, err := s.Model.Inventory.Put(r.Context(), serial, inv)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, stored, http.StatusOK)
+}
+
+func (s Server) handleInventoriesList(w http.ResponseWriter, r *http.Request) {
+	filter, err := sabakan.ParseInventoryFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	all, err := s.Model.Inventory.GetAll(r.Context())
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	inventories := make([]*sabakan.Inventory, 0, len(all))
+	for _, inv := range all {
+		if filter.Matches(inv) {
+			inventories = append(inventories, inv)
+		}
+	}
+
+	renderJSON(w, inventories, http.StatusOK)
+}
+
+func (s Server) handleInventoriesGet(w http.ResponseWriter, r *http.Request, serial string) {
+	inv, err := s.Model.Inventory.Get(r.Context(), serial)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, inv, http.StatusOK)
+}
+
+func (s Server) handleInventoriesHistory(w http.ResponseWriter, r *http.Request, serial string) {
+	history, err := s.Model.Inventory.GetHistory(r.Context(), serial)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, history, http.StatusOK)
+}
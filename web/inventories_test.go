@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+const testInventoryJSON = `{
+  "cpus": [{"model": "Xeon Gold 6230", "cores": 20, "threads": 40}],
+  "memory": 206158430208,
+  "disks": [{"path": "pci-0000:00:1f.2-ata-1", "model": "SSD1", "serial": "S1", "size": 480000000000}],
+  "nics": [{"name": "eno1", "mac": "0c:c4:7a:00:00:01", "speed": 25000}]
+}`
+
+func testInventoriesPut(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	err := m.Machine.Register(context.Background(), []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234abcd"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the HTTP server does not accept uploads
+	handler := newTestServer(m)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/inventories/1234abcd", strings.NewReader(testInventoryJSON))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("w.Code != http.StatusMethodNotAllowed:", w.Code)
+	}
+
+	// any host can upload inventories to the HTTPS server
+	handler = newTestServer(m)
+	handler.TLSServer = true
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/inventories/1234abcd", strings.NewReader(testInventoryJSON))
+	r.RemoteAddr = "10.0.0.1:5678"
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code, w.Body.String())
+	}
+	var stored sabakan.Inventory
+	err = json.NewDecoder(w.Body).Decode(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Serial != "1234abcd" || stored.Version != 1 || stored.Memory != 192<<30 {
+		t.Error("wrong inventory:", stored)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/inventories/1234abcd", strings.NewReader(`{"nics": [{"name": "eno1", "mac": "foo"}]}`))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/inventories/5678efgh", strings.NewReader(testInventoryJSON))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+}
+
+func testInventoriesGet(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	ctx := context.Background()
+
+	err := m.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1"}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "2"}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "3"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range []struct {
+		serial string
+		memory uint64
+	}{{"1", 128 << 30}, {"1", 384 << 30}, {"2", 256 << 30}} {
+		_, err = m.Inventory.Put(ctx, inv.serial, &sabakan.Inventory{Memory: inv.memory})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/inventories/1", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	var inv sabakan.Inventory
+	err = json.NewDecoder(w.Body).Decode(&inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Version != 2 || inv.Memory != 384<<30 {
+		t.Error("wrong inventory:", inv)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/inventories/1/history", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	var history []sabakan.Inventory
+	err = json.NewDecoder(w.Body).Decode(&history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Memory != 128<<30 {
+		t.Error("wrong history:", history)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/inventories/3", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Error("w.Code != http.StatusNotFound:", w.Code)
+	}
+
+	cases := []struct {
+		filter  string
+		serials []string
+	}{
+		{"", []string{"1", "2"}},
+		{"memory<256GiB", nil},
+		{"memory>=256GiB", []string{"1", "2"}},
+		{"memory>256GiB", []string{"1"}},
+	}
+	for _, c := range cases {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/api/v1/inventories?filter="+url.QueryEscape(c.filter), nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatal("w.Code != http.StatusOK:", w.Code)
+		}
+		var inventories []sabakan.Inventory
+		err = json.NewDecoder(w.Body).Decode(&inventories)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var serials []string
+		for _, inv := range inventories {
+			serials = append(serials, inv.Serial)
+		}
+		if strings.Join(serials, ",") != strings.Join(c.serials, ",") {
+			t.Errorf("wrong inventories for %q: %v", c.filter, serials)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/inventories?filter=foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+}
+
+func TestInventories(t *testing.T) {
+	t.Run("Put", testInventoriesPut)
+	t.Run("Get", testInventoriesGet)
+}
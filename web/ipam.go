@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/cybozu-go/sabakan/v3"
 )
@@ -33,8 +34,25 @@ func (s Server) handleConfigIPAMGet(w http.ResponseWriter, r *http.Request) {
 
 func (s Server) handleConfigIPAMPut(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	var dryRun, force bool
+	var err error
+	if v := r.URL.Query().Get("dry-run"); len(v) > 0 {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest("invalid dry-run: "+v))
+			return
+		}
+	}
+	if v := r.URL.Query().Get("force"); len(v) > 0 {
+		force, err = strconv.ParseBool(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest("invalid force: "+v))
+			return
+		}
+	}
+
 	var sc sabakan.IPAMConfig
-	err := json.NewDecoder(r.Body).Decode(&sc)
+	err = json.NewDecoder(r.Body).Decode(&sc)
 	if err != nil {
 		renderError(ctx, w, BadRequest(err.Error()))
 		return
@@ -45,10 +63,69 @@ func (s Server) handleConfigIPAMPut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.Model.IPAM.PutConfig(ctx, &sc)
+	if dryRun {
+		changes, err := s.Model.IPAM.AddressChanges(ctx, &sc)
+		if err != nil {
+			renderError(ctx, w, InternalServerError(err))
+			return
+		}
+		conflicts, err := s.Model.IPAM.Conflicts(ctx, &sc)
+		if err != nil {
+			renderError(ctx, w, InternalServerError(err))
+			return
+		}
+		renderJSON(w, &sabakan.IPAMDryRun{Changes: changes, Conflicts: conflicts}, http.StatusOK)
+		return
+	}
+
+	if force {
+		err = s.Model.IPAM.ForcePutConfig(ctx, &sc)
+	} else {
+		err = s.Model.IPAM.PutConfig(ctx, &sc)
+	}
+	if err == sabakan.ErrConflicted {
+		renderError(ctx, w, Conflict("addresses of registered machines would change, or DHCP lease ranges would conflict"))
+		return
+	}
 	if err != nil {
 		renderError(ctx, w, InternalServerError(err))
 		return
 	}
 	renderJSON(w, nil, http.StatusOK)
 }
+
+func (s Server) handleConfigIPAMMigrate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "POST" {
+		renderError(ctx, w, APIErrBadMethod)
+		return
+	}
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dry-run"); len(v) > 0 {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			renderError(ctx, w, BadRequest("invalid dry-run: "+v))
+			return
+		}
+	}
+
+	config, err := s.Model.IPAM.GetConfig()
+	if err != nil {
+		renderError(ctx, w, APIErrNotFound)
+		return
+	}
+
+	var changes []*sabakan.IPAMChange
+	if dryRun {
+		changes, err = s.Model.IPAM.AddressChanges(ctx, config)
+	} else {
+		changes, err = s.Model.IPAM.Migrate(ctx)
+	}
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, changes, http.StatusOK)
+}
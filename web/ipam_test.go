@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -114,8 +115,11 @@ func testConfigIPAMPut(t *testing.T) {
 	}
 
 	machine := sabakan.NewMachine(sabakan.MachineSpec{
-		Serial: "1234",
+		Serial:      "1234",
+		Rack:        1,
+		IndexInRack: 4,
 	})
+	conf.GenerateIP(machine)
 	err = m.Machine.Register(context.Background(), []*sabakan.Machine{machine})
 	if err != nil {
 		t.Fatal(err)
@@ -126,12 +130,155 @@ func testConfigIPAMPut(t *testing.T) {
 	handler.ServeHTTP(w, r)
 
 	resp = w.Result()
-	if resp.StatusCode == http.StatusOK {
-		t.Error("request must not succeed")
+	if resp.StatusCode != http.StatusOK {
+		t.Error("request failed with " + http.StatusText(resp.StatusCode))
+	}
+
+	changed := strings.Replace(good, `"node-ipv4-offset": "0.0.0.0"`, `"node-ipv4-offset": "0.0.1.0"`, 1)
+	r = httptest.NewRequest("PUT", "/api/v1/config/ipam", strings.NewReader(changed))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	resp = w.Result()
+	if resp.StatusCode != http.StatusConflict {
+		t.Error("resp.StatusCode != http.StatusConflict:", resp.StatusCode)
+	}
+}
+
+func testConfigIPAMMigrate(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	config := testWithIPAM(t, m)
+	handler := newTestServer(m)
+
+	machine := sabakan.NewMachine(sabakan.MachineSpec{
+		Serial:      "1234",
+		Rack:        1,
+		IndexInRack: 4,
+	})
+	config.GenerateIP(machine)
+	err := m.Machine.Register(context.Background(), []*sabakan.Machine{machine})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := *config
+	changed.BMCIPv4Offset = "0.0.2.0"
+	body, err := json.Marshal(&changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dry-run
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/config/ipam?dry-run=true", strings.NewReader(string(body)))
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	var result sabakan.IPAMDryRun
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := result.Changes
+	if len(changes) != 1 || changes[0].Old.BMCIPv4 != "10.72.17.36" || changes[0].New.BMCIPv4 != "10.72.18.36" {
+		t.Error("unexpected changes:", changes)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Error("unexpected conflicts:", result.Conflicts)
+	}
+	conf, err := m.IPAM.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.BMCIPv4Offset != "0.0.1.0" {
+		t.Error("config should not be updated by dry-run")
+	}
+
+	// conflicts are reported by dry-run
+	conflicting := *config
+	conflicting.NodeIndexOffset = 5
+	cbody, err := json.Marshal(&conflicting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/config/ipam?dry-run=true", strings.NewReader(string(cbody)))
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	result = sabakan.IPAMDryRun{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Serial != "1234" {
+		t.Error("unexpected conflicts:", result.Conflicts)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/config/ipam", strings.NewReader(string(cbody)))
+	handler.ServeHTTP(w, r)
+	if w.Result().StatusCode != http.StatusConflict {
+		t.Error("resp.StatusCode != http.StatusConflict:", w.Result().StatusCode)
+	}
+
+	// refused without force
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/config/ipam", strings.NewReader(string(body)))
+	handler.ServeHTTP(w, r)
+	if w.Result().StatusCode != http.StatusConflict {
+		t.Error("resp.StatusCode != http.StatusConflict:", w.Result().StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/config/ipam?force=true", strings.NewReader(string(body)))
+	handler.ServeHTTP(w, r)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", w.Result().StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/config/ipam/migrate", nil)
+	handler.ServeHTTP(w, r)
+	if w.Result().StatusCode != http.StatusMethodNotAllowed {
+		t.Error("resp.StatusCode != http.StatusMethodNotAllowed:", w.Result().StatusCode)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("POST", "/api/v1/config/ipam/migrate?dry-run="+strconv.FormatBool(dryRun), nil)
+		handler.ServeHTTP(w, r)
+		resp = w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+		}
+		changes = nil
+		err = json.NewDecoder(resp.Body).Decode(&changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 || changes[0].Serial != "1234" {
+			t.Error("unexpected changes:", dryRun, changes)
+		}
+	}
+
+	machine, err = m.Machine.Get(context.Background(), "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machine.Spec.BMC.IPv4 != "10.72.18.36" {
+		t.Error("machine was not migrated:", machine.Spec.BMC.IPv4)
 	}
 }
 
 func TestConfigIPAM(t *testing.T) {
 	t.Run("Get", testConfigIPAMGet)
 	t.Run("Put", testConfigIPAMPut)
+	t.Run("Migrate", testConfigIPAMMigrate)
 }
@@ -2,7 +2,9 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -53,6 +55,10 @@ func (s Server) handleMachinesPost(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+		if err := sabakan.ValidateAnnotations(m.Annotations); err != nil {
+			renderError(r.Context(), w, BadRequest(err.Error()))
+			return
+		}
 		if m.BMC.Type == "" {
 			renderError(r.Context(), w, BadRequest("BMC type is empty"))
 			return
@@ -61,6 +67,12 @@ func (s Server) handleMachinesPost(w http.ResponseWriter, r *http.Request) {
 			renderError(r.Context(), w, BadRequest("BMC type contains invalid character"))
 			return
 		}
+		macs, err := sabakan.NormalizeMACs(m.MACs)
+		if err != nil {
+			renderError(r.Context(), w, BadRequest(err.Error()))
+			return
+		}
+		m.MACs = macs
 		m.IPv4 = nil
 		m.IPv6 = nil
 	}
@@ -97,13 +109,156 @@ func getQueryMap(r *http.Request) sabakan.Query {
 	return q
 }
 
+// Query parameters for GET /api/v1/machines that are not machine conditions.
+const (
+	machinesParamLimit    = "limit"
+	machinesParamContinue = "continue"
+	machinesParamSort     = "sort"
+	machinesParamFields   = "fields"
+)
+
+// getMachineListOptions removes pagination parameters from q and returns them.
+func getMachineListOptions(q sabakan.Query) (sabakan.MachineListOptions, []string, error) {
+	var opts sabakan.MachineListOptions
+	defer func() {
+		delete(q, machinesParamLimit)
+		delete(q, machinesParamContinue)
+		delete(q, machinesParamSort)
+		delete(q, machinesParamFields)
+	}()
+
+	if limit := q[machinesParamLimit]; len(limit) > 0 {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return opts, nil, errors.New("limit must be a positive integer")
+		}
+		opts.Limit = n
+	}
+	opts.Continue = q[machinesParamContinue]
+
+	key, desc, err := sabakan.ParseMachineSort(q[machinesParamSort])
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.SortBy = key
+	opts.Descending = desc
+
+	var fields []string
+	if f := q[machinesParamFields]; len(f) > 0 {
+		for _, field := range strings.Split(f, ",") {
+			field = strings.TrimSpace(field)
+			if len(field) == 0 {
+				continue
+			}
+			if _, err := projectJSON(machineTemplate, field); err != nil {
+				return opts, nil, err
+			}
+			fields = append(fields, field)
+		}
+	}
+	return opts, fields, nil
+}
+
+// machineTemplate is a JSON representation of a zero Machine
+// used to validate field names for projection.
+var machineTemplate = func() map[string]interface{} {
+	data, err := json.Marshal(sabakan.Machine{})
+	if err != nil {
+		panic(err)
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}()
+
+// projectJSON returns the value at a dot-separated path in obj.
+// A path that goes through a JSON object of arbitrary keys, such as
+// labels, is not an error even if the key is missing; nil is returned then.
+func projectJSON(obj map[string]interface{}, path string) (interface{}, error) {
+	var cur interface{} = obj
+	for _, name := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[name]
+			if !ok {
+				return nil, errors.New("unknown field: " + path)
+			}
+			cur = next
+		case nil:
+			return nil, nil
+		default:
+			return nil, errors.New("unknown field: " + path)
+		}
+	}
+	return cur, nil
+}
+
+// projectMachine returns a JSON object that contains only fields of m.
+func projectMachine(m *sabakan.Machine, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{})
+	for _, field := range fields {
+		v, err := projectJSON(obj, field)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+
+		names := strings.Split(field, ".")
+		dst := res
+		for _, name := range names[:len(names)-1] {
+			child, ok := dst[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				dst[name] = child
+			}
+			dst = child
+		}
+		dst[names[len(names)-1]] = v
+	}
+	return res, nil
+}
+
 func (s Server) handleMachinesGet(w http.ResponseWriter, r *http.Request) {
 	q := getQueryMap(r)
 
+	opts, fields, err := getMachineListOptions(q)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
 	if !q.Valid() {
 		renderError(r.Context(), w, BadRequest("'with' and 'without' options about the same things are specified."))
 		return
 	}
+	for _, k := range []string{"labels", "without-labels"} {
+		if _, err := sabakan.ParseLabelSelector(q[k]); err != nil {
+			renderError(r.Context(), w, BadRequest(err.Error()))
+			return
+		}
+	}
+	if len(q.MAC()) > 0 {
+		for _, mac := range strings.Split(q.MAC(), ",") {
+			if _, err := sabakan.NormalizeMAC(mac); err != nil {
+				renderError(r.Context(), w, BadRequest(err.Error()))
+				return
+			}
+		}
+	}
 	machines, err := s.Model.Machine.Query(r.Context(), q)
 	if err != nil {
 		renderError(r.Context(), w, InternalServerError(err))
@@ -115,13 +270,33 @@ func (s Server) handleMachinesGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	j := make([]*sabakan.Machine, len(machines))
+	machines, next, err := sabakan.PaginateMachines(machines, opts)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if len(next) > 0 {
+		w.Header().Set(HeaderContinue, next)
+	}
+
 	now := time.Now()
-	for i, m := range machines {
+	for _, m := range machines {
 		m.Status.Duration = now.Sub(m.Status.Timestamp).Seconds()
-		j[i] = m
 	}
 
+	if len(fields) == 0 {
+		renderJSON(w, machines, http.StatusOK)
+		return
+	}
+
+	j := make([]map[string]interface{}, len(machines))
+	for i, m := range machines {
+		j[i], err = projectMachine(m, fields)
+		if err != nil {
+			renderError(r.Context(), w, InternalServerError(err))
+			return
+		}
+	}
 	renderJSON(w, j, http.StatusOK)
 }
 
@@ -125,6 +125,24 @@ func testMachinesPost(t *testing.T) {
   "rack": 1,
   "role": "invalid/Role",
   "bmc": {"type": "iDRAC-9"}
+}]`, http.StatusBadRequest},
+		{`[{
+  "serial": "4444abcd",
+  "annotations": {
+	  "note": "ファン交換済み\nhttps://example.com/tickets/1"
+  },
+  "rack": 1,
+  "role": "boot",
+  "bmc": {"type": "iDRAC-9"}
+}]`, http.StatusCreated},
+		{`[{
+  "serial": "2222abcd",
+  "annotations": {
+	  "invalid/name": "value"
+  },
+  "rack": 1,
+  "role": "boot",
+  "bmc": {"type": "iDRAC-9"}
 }]`, http.StatusBadRequest},
 		{`[{
   "serial": "2222abcd",
@@ -145,6 +163,27 @@ func testMachinesPost(t *testing.T) {
   "bmc": {"type": "iDRAC-9"}
 }]`, http.StatusBadRequest},
 		{`[{
+  "serial": "5555abcd",
+  "macs": ["0C:C4:7A:00:00:01", "0c:c4:7a:00:00:02"],
+  "rack": 1,
+  "role": "boot",
+  "bmc": {"type": "iDRAC-9"}
+}]`, http.StatusCreated},
+		{`[{
+  "serial": "2222abcd",
+  "macs": ["0c:c4:7a:00:00:03", "0C:C4:7A:00:00:03"],
+  "rack": 1,
+  "role": "boot",
+  "bmc": {"type": "iDRAC-9"}
+}]`, http.StatusBadRequest},
+		{`[{
+  "serial": "2222abcd",
+  "macs": ["0c:c4:7a:00:00:01"],
+  "rack": 1,
+  "role": "boot",
+  "bmc": {"type": "iDRAC-9"}
+}]`, http.StatusConflict},
+		{`[{
   "serial": "3333abcd",
   "labels": {},
   "rack": 1,
@@ -191,6 +230,10 @@ func testMachinesGet(t *testing.T) {
 				"product":    "R630",
 				"datacenter": "ty3",
 			},
+			Annotations: map[string]string{
+				"note":   "ファン交換済み",
+				"ticket": "https://example.com/tickets/1",
+			},
 			Rack: 1,
 			Role: "boot",
 			BMC:  sabakan.MachineBMC{Type: "iDRAC-9"},
@@ -328,6 +371,31 @@ func testMachinesGet(t *testing.T) {
 			status:   http.StatusOK,
 			expected: map[string]bool{"5678abcd": true},
 		},
+		{
+			query:    map[string][]string{"labels": {"product in (R630,R740),datacenter!=ty4"}},
+			status:   http.StatusOK,
+			expected: map[string]bool{"1234abcd": true, "5678abcd": true, "1234efgh": true},
+		},
+		{
+			query:    map[string][]string{"labels": {"product notin (R630)"}},
+			status:   http.StatusOK,
+			expected: map[string]bool{"5678abcd": true},
+		},
+		{
+			query:    map[string][]string{"labels": {"!product"}},
+			status:   http.StatusNotFound,
+			expected: nil,
+		},
+		{
+			query:    map[string][]string{"labels": {"product in (R630"}},
+			status:   http.StatusBadRequest,
+			expected: nil,
+		},
+		{
+			query:    map[string][]string{"without-labels": {"product=R630="}},
+			status:   http.StatusBadRequest,
+			expected: nil,
+		},
 	}
 	for _, c := range cases {
 		w := httptest.NewRecorder()
@@ -363,6 +431,95 @@ func testMachinesGet(t *testing.T) {
 	}
 }
 
+func testMachinesGetPage(t *testing.T) {
+	m := mock.NewModel()
+	handler := Server{Model: m}
+
+	var machines []*sabakan.Machine
+	for i, serial := range []string{"5", "3", "1", "4", "2"} {
+		machines = append(machines, sabakan.NewMachine(sabakan.MachineSpec{
+			Serial: serial,
+			Rack:   uint(i % 2),
+			Role:   "worker",
+			BMC:    sabakan.MachineBMC{Type: "iDRAC-9"},
+		}))
+	}
+	m.Machine.Register(context.Background(), machines)
+
+	get := func(query url.Values) *http.Response {
+		w := httptest.NewRecorder()
+		u := url.URL{Path: "/api/v1/machines", RawQuery: query.Encode()}
+		handler.ServeHTTP(w, httptest.NewRequest("GET", u.String(), nil))
+		return w.Result()
+	}
+
+	query := url.Values{"limit": {"2"}, "sort": {"-serial"}, "role": {"worker"}}
+	var serials []string
+	for i := 0; ; i++ {
+		if i > 3 {
+			t.Fatal("too many pages")
+		}
+		resp := get(query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("wrong status code:", resp.StatusCode)
+		}
+		var page []*sabakan.Machine
+		err := json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 2 {
+			t.Fatal("too many machines in a page:", len(page))
+		}
+		for _, m := range page {
+			serials = append(serials, m.Spec.Serial)
+		}
+		next := resp.Header.Get(HeaderContinue)
+		if len(next) == 0 {
+			break
+		}
+		query.Set("continue", next)
+	}
+	if !reflect.DeepEqual(serials, []string{"5", "4", "3", "2", "1"}) {
+		t.Error("wrong pages:", serials)
+	}
+
+	resp := get(url.Values{"sort": {"rack"}, "fields": {"spec.serial,spec.rack,status.state"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("wrong status code:", resp.StatusCode)
+	}
+	var projected []map[string]interface{}
+	err := json.NewDecoder(resp.Body).Decode(&projected)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"spec":   map[string]interface{}{"serial": "1", "rack": float64(0)},
+		"status": map[string]interface{}{"state": "uninitialized"},
+	}
+	if len(projected) != 5 || !reflect.DeepEqual(projected[0], expected) {
+		t.Error("wrong projection:", projected)
+	}
+
+	badQueries := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"a"}},
+		{"sort": {"labels"}},
+		{"fields": {"spec.unknown"}},
+		{"fields": {"spec.serial.foo"}},
+		{"continue": {"broken"}},
+	}
+	for _, q := range badQueries {
+		resp := get(q)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Error("wrong status code for", q, resp.StatusCode)
+		}
+	}
+}
+
 func testMachinesDelete(t *testing.T) {
 	m := mock.NewModel()
 	handler := newTestServer(m)
@@ -434,6 +591,10 @@ func testMachinesGraphQL(t *testing.T) {
 				"product":    "R630",
 				"datacenter": "ty3",
 			},
+			Annotations: map[string]string{
+				"note":   "ファン交換済み",
+				"ticket": "https://example.com/tickets/1",
+			},
 			Rack: 1,
 			Role: "boot",
 			BMC:  sabakan.MachineBMC{Type: "iDRAC-9"},
@@ -487,6 +648,158 @@ func testMachinesGraphQL(t *testing.T) {
 		t.Error(`len(gqlResponse.Data.SearchMachines) != 3`, gqlResponse)
 	}
 
+	// Test for searchMachinesConnection
+	var serials []string
+	after := ""
+	for i := 0; ; i++ {
+		if i > 3 {
+			t.Fatal("too many pages")
+		}
+		v := url.Values{}
+		v.Set("query", `query search($after: String) {
+  searchMachinesConnection(orderBy: {field: RACK, direction: DESC}, first: 2, after: $after) {
+    edges { cursor node { spec { serial } } }
+    pageInfo { hasNextPage endCursor }
+    totalCount
+  }
+}`)
+		if len(after) > 0 {
+			v.Set("variables", `{"after": "`+after+`"}`)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+v.Encode(), nil))
+		resp := w.Result()
+
+		var connResponse struct {
+			Errors []interface{} `json:"errors"`
+			Data   struct {
+				SearchMachinesConnection struct {
+					Edges []struct {
+						Cursor string `json:"cursor"`
+						Node   struct {
+							Spec struct {
+								Serial string `json:"serial"`
+							} `json:"spec"`
+						} `json:"node"`
+					} `json:"edges"`
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					TotalCount int `json:"totalCount"`
+				} `json:"searchMachinesConnection"`
+			} `json:"data"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&connResponse)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(connResponse.Errors) > 0 {
+			t.Fatal(connResponse.Errors)
+		}
+		conn := connResponse.Data.SearchMachinesConnection
+		if conn.TotalCount != 3 {
+			t.Error("wrong totalCount:", conn.TotalCount)
+		}
+		for _, e := range conn.Edges {
+			serials = append(serials, e.Node.Spec.Serial)
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		after = conn.PageInfo.EndCursor
+	}
+	if !reflect.DeepEqual(serials, []string{"1234efgh", "5678abcd", "1234abcd"}) {
+		t.Error("wrong pages:", serials)
+	}
+
+	// Test for annotations
+	v = url.Values{}
+	v.Set("query", `{machine(serial: "1234abcd") { spec { annotations { name value } note: annotation(name: "note") missing: annotation(name: "missing") } } }`)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+v.Encode(), nil))
+	resp = w.Result()
+
+	var annotationsResponse struct {
+		Errors []interface{} `json:"errors"`
+		Data   struct {
+			Machine struct {
+				Spec struct {
+					Annotations []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"annotations"`
+					Note    *string `json:"note"`
+					Missing *string `json:"missing"`
+				} `json:"spec"`
+			} `json:"machine"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&annotationsResponse)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotationsResponse.Errors) > 0 {
+		t.Fatal(annotationsResponse.Errors)
+	}
+	spec := annotationsResponse.Data.Machine.Spec
+	if len(spec.Annotations) != 2 || spec.Annotations[0].Name != "note" || spec.Annotations[1].Value != "https://example.com/tickets/1" {
+		t.Error("wrong annotations:", spec.Annotations)
+	}
+	if spec.Note == nil || *spec.Note != "ファン交換済み" {
+		t.Error("wrong annotation:", spec.Note)
+	}
+	if spec.Missing != nil {
+		t.Error("missing annotation should be null:", *spec.Missing)
+	}
+
+	// Test for inventories
+	_, err = m.Inventory.Put(context.Background(), "1234abcd", &sabakan.Inventory{Memory: 192 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Inventory.Put(context.Background(), "5678abcd", &sabakan.Inventory{Memory: 384 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = url.Values{}
+	v.Set("query", `{searchMachines(having: {inventoryFilter: "memory<256GiB"}) { spec { serial } inventory { version memory } } }`)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?"+v.Encode(), nil))
+	resp = w.Result()
+
+	var inventoryResponse struct {
+		Errors []interface{} `json:"errors"`
+		Data   struct {
+			SearchMachines []struct {
+				Spec struct {
+					Serial string `json:"serial"`
+				} `json:"spec"`
+				Inventory *struct {
+					Version int    `json:"version"`
+					Memory  uint64 `json:"memory"`
+				} `json:"inventory"`
+			} `json:"searchMachines"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&inventoryResponse)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inventoryResponse.Errors) > 0 {
+		t.Fatal(inventoryResponse.Errors)
+	}
+	found := inventoryResponse.Data.SearchMachines
+	if len(found) != 1 || found[0].Spec.Serial != "1234abcd" {
+		t.Fatal("wrong machines:", found)
+	}
+	if found[0].Inventory == nil || found[0].Inventory.Version != 1 || found[0].Inventory.Memory != 192<<30 {
+		t.Error("wrong inventory:", found[0].Inventory)
+	}
+
 	// Test for mutation SetMachineState()
 	_, err = setMachineState("UNINITIALIZED", handler, t)
 	if err != nil {
@@ -526,6 +839,7 @@ func setMachineStateRequest(state string, handler *Server) *http.Response {
 
 func TestMachines(t *testing.T) {
 	t.Run("Get", testMachinesGet)
+	t.Run("GetPage", testMachinesGetPage)
 	t.Run("Post", testMachinesPost)
 	t.Run("Delete", testMachinesDelete)
 	t.Run("GraphQL", testMachinesGraphQL)
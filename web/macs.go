@@ -0,0 +1,41 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func (s Server) handleMACs(w http.ResponseWriter, r *http.Request) {
+	args := strings.SplitN(r.URL.Path[len("/api/v1/macs/"):], "/", 2)
+	if len(args) != 2 || len(args[0]) == 0 {
+		renderError(r.Context(), w, APIErrBadRequest)
+		return
+	}
+	mac, err := sabakan.NormalizeMAC(args[1])
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		err = s.Model.Machine.AddMAC(r.Context(), args[0], mac)
+	case "DELETE":
+		err = s.Model.Machine.RemoveMAC(r.Context(), args[0], mac)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+		return
+	}
+
+	switch err {
+	case nil:
+	case sabakan.ErrNotFound:
+		renderError(r.Context(), w, APIErrNotFound)
+	case sabakan.ErrConflicted:
+		renderError(r.Context(), w, APIErrConflict)
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func TestMACs(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	err := m.Machine.Register(context.Background(), []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234abcd"}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "5678efgh", MACs: []string{"0c:c4:7a:00:00:02"}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"PUT", "/api/v1/macs/1234abcd/0C-C4-7A-00-00-01", http.StatusOK},
+		{"PUT", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:01", http.StatusOK},
+		{"PUT", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:02", http.StatusConflict},
+		{"PUT", "/api/v1/macs/1234abcd/foo", http.StatusBadRequest},
+		{"PUT", "/api/v1/macs/0000/0c:c4:7a:00:00:03", http.StatusNotFound},
+		{"PUT", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:03", http.StatusOK},
+		{"DELETE", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:03", http.StatusOK},
+		{"DELETE", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:03", http.StatusNotFound},
+		{"GET", "/api/v1/macs/1234abcd/0c:c4:7a:00:00:01", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, c.path, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s %s: unexpected status: %d", c.method, c.path, w.Code)
+		}
+	}
+
+	stored, err := m.Machine.Get(context.Background(), "1234abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored.Spec.MACs, []string{"0c:c4:7a:00:00:01"}) {
+		t.Error("stored MAC addresses are wrong:", stored.Spec.MACs)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/machines?mac=0C:C4:7A:00:00:01", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/machines?mac=foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Error("w.Code != http.StatusBadRequest:", w.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"net/http"
+)
+
+func (s Server) handleReplication(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		renderError(r.Context(), w, APIErrBadMethod)
+		return
+	}
+
+	status, err := s.Model.Replication.GetStatus(r.Context())
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, status, http.StatusOK)
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func TestHandleReplication(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	_, err := m.Asset.Put(context.Background(), "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/replication", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatal("w.Code != http.StatusOK:", w.Code)
+	}
+	var status sabakan.ReplicationStatus
+	err = json.NewDecoder(w.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Servers) != 1 {
+		t.Error("wrong servers:", status.Servers)
+	}
+	foo := status.FindAsset("foo")
+	if foo == nil || len(foo.Servers) != 1 || len(foo.Pending) != 0 {
+		t.Error("wrong status of foo:", foo)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/replication", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("w.Code != http.StatusMethodNotAllowed:", w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func (s Server) handleSecrets(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/secrets" {
+		if r.Method != http.MethodGet {
+			renderError(r.Context(), w, APIErrBadMethod)
+			return
+		}
+		s.handleSecretsList(w, r)
+		return
+	}
+
+	params := strings.Split(r.URL.Path[len("/api/v1/secrets/"):], "/")
+	scope := sabakan.SecretScope(params[0])
+	var target, name string
+	switch {
+	case scope == sabakan.SecretScopeGlobal && len(params) == 2:
+		name = params[1]
+	case scope != sabakan.SecretScopeGlobal && len(params) == 3:
+		target, name = params[1], params[2]
+	default:
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	err := sabakan.ValidateSecretScope(scope, target)
+	if err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if !sabakan.IsValidSecretName(name) {
+		renderError(r.Context(), w, BadRequest("invalid secret name: "+name))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.handleSecretsPut(w, r, scope, target, name)
+	case http.MethodDelete:
+		s.handleSecretsDelete(w, r, scope, target, name)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleSecretsList(w http.ResponseWriter, r *http.Request) {
+	secrets, err := s.Model.Secret.List(r.Context())
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, secrets, http.StatusOK)
+}
+
+func (s Server) handleSecretsPut(w http.ResponseWriter, r *http.Request, scope sabakan.SecretScope, target, name string) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, sabakan.MaxSecretSize))
+	if err != nil {
+		renderError(r.Context(), w, APIErrTooLargeSecret)
+		return
+	}
+
+	err = s.Model.Secret.Put(r.Context(), scope, target, name, value)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s Server) handleSecretsDelete(w http.ResponseWriter, r *http.Request, scope sabakan.SecretScope, target, name string) {
+	err := s.Model.Secret.Delete(r.Context(), scope, target, name)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func TestSecrets(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"PUT", "/api/v1/secrets/global/token", "global", http.StatusCreated},
+		{"PUT", "/api/v1/secrets/role/worker/token", "worker", http.StatusCreated},
+		{"PUT", "/api/v1/secrets/serial/1234abcd/token", "1234abcd", http.StatusCreated},
+		{"PUT", "/api/v1/secrets/global/worker/token", "", http.StatusNotFound},
+		{"PUT", "/api/v1/secrets/role/token", "", http.StatusNotFound},
+		{"PUT", "/api/v1/secrets/rack/1/token", "", http.StatusBadRequest},
+		{"PUT", "/api/v1/secrets/global/to%20ken", "", http.StatusBadRequest},
+		{"PUT", "/api/v1/secrets/global/big", strings.Repeat("a", sabakan.MaxSecretSize+1), http.StatusRequestEntityTooLarge},
+		{"GET", "/api/v1/secrets/global/token", "", http.StatusMethodNotAllowed},
+		{"DELETE", "/api/v1/secrets/serial/1234abcd/token", "", http.StatusOK},
+		{"DELETE", "/api/v1/secrets/serial/1234abcd/token", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		handler.ServeHTTP(w, r)
+		resp := w.Result()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s: unexpected status: %d", c.method, c.path, resp.StatusCode)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/secrets", nil)
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	var secrets []*sabakan.SecretInfo
+	err := json.NewDecoder(resp.Body).Decode(&secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 || secrets[0].Scope != sabakan.SecretScopeGlobal ||
+		secrets[1].Scope != sabakan.SecretScopeRole || secrets[1].Target != "worker" {
+		t.Error("wrong secrets:", secrets)
+	}
+
+	mc := sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234abcd", Role: "worker"})
+	val, err := m.Secret.Resolve(context.Background(), mc, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "worker" {
+		t.Error("wrong secret:", string(val))
+	}
+
+	// only allowed remotes can change secrets
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/secrets/global/token", strings.NewReader("x"))
+	r.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Error("resp.StatusCode != http.StatusForbidden:", resp.StatusCode)
+	}
+}
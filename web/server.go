@@ -24,6 +24,9 @@ import (
 const (
 	// HeaderSabactlUser is the HTTP header name to tell which user run sabactl.
 	HeaderSabactlUser = "X-Sabakan-User"
+
+	// HeaderContinue is the HTTP header name to return the continue token for the next page.
+	HeaderContinue = "X-Sabakan-Continue"
 )
 
 var (
@@ -189,26 +192,46 @@ func (s Server) handleAPIV1(w http.ResponseWriter, r *http.Request) {
 		s.handleCoreOS(w, r)
 	case strings.HasPrefix(p, "boot/ignitions/"):
 		s.handleIgnitions(w, r)
+	case p == "dhcp/leases" || strings.HasPrefix(p, "dhcp/leases/"):
+		s.handleDHCPLeases(w, r)
+	case p == "dhcp/reservations" || strings.HasPrefix(p, "dhcp/reservations/"):
+		s.handleDHCPReservations(w, r)
 	case p == "config/dhcp":
 		s.handleConfigDHCP(w, r)
 	case p == "config/ipam":
 		s.handleConfigIPAM(w, r)
+	case p == "config/ipam/migrate":
+		s.handleConfigIPAMMigrate(w, r)
 	case p == "cryptsetup":
 		s.handleCryptSetup(w, r)
+	case p == "gc/assets":
+		s.handleGCAssets(w, r)
 	case strings.HasPrefix(p, "ignitions/"):
 		s.handleIgnitionTemplates(w, r)
 	case p == "images/coreos" || strings.HasPrefix(p, "images/coreos/"):
 		s.handleImages(w, r)
+	case p == "inventories" || strings.HasPrefix(p, "inventories/"):
+		s.handleInventories(w, r)
 	case p == "logs":
 		s.handleLogs(w, r)
 	case strings.HasPrefix(p, "machines"):
 		s.handleMachines(w, r)
+	case p == "replication":
+		s.handleReplication(w, r)
+	case p == "secrets" || strings.HasPrefix(p, "secrets/"):
+		s.handleSecrets(w, r)
 	case strings.HasPrefix(p, "state/"):
 		s.handleState(w, r)
 	case strings.HasPrefix(p, "labels/"):
 		s.handleLabels(w, r)
+	case strings.HasPrefix(p, "annotations/"):
+		s.handleAnnotations(w, r)
+	case strings.HasPrefix(p, "macs/"):
+		s.handleMACs(w, r)
 	case strings.HasPrefix(p, "retire-date/"):
 		s.handleRetireDate(w, r)
+	case strings.HasPrefix(p, "tokens/"):
+		s.handleTokens(w, r)
 	case strings.HasPrefix(p, "kernel_params/"):
 		s.handleKernelParams(w, r)
 	default:
@@ -229,6 +252,8 @@ func (s Server) handleAPIV1HTTPS(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case strings.HasPrefix(p, "crypts/"):
 		s.handleCrypts(w, r)
+	case strings.HasPrefix(p, "inventories/"):
+		s.handleInventoriesHTTPS(w, r)
 	default:
 		renderError(r.Context(), w, APIErrNotFound)
 	}
@@ -243,14 +268,30 @@ func (s Server) hasPermission(r *http.Request) bool {
 	if strings.HasPrefix(p, "crypts/") && r.Method != http.MethodDelete {
 		return true
 	}
-	rhost, _, err := net.SplitHostPort(r.RemoteAddr)
-	if rhost == "" || err != nil {
+	if strings.HasPrefix(p, "inventories/") && r.Method == http.MethodPut {
+		return true
+	}
+	return s.isAllowedRemote(r)
+}
+
+// isAllowedRemote returns true if the request comes from allowed remotes.
+func (s Server) isAllowedRemote(r *http.Request) bool {
+	ip := remoteIP(r)
+	if ip == nil {
 		return false
 	}
 	for _, allowed := range s.AllowedRemotes {
-		if allowed.Contains(net.ParseIP(rhost)) {
+		if allowed.Contains(ip) {
 			return true
 		}
 	}
 	return false
 }
+
+func remoteIP(r *http.Request) net.IP {
+	rhost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(rhost)
+}
@@ -1,14 +1,23 @@
 package web
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
 )
 
 var (
 	errNotInt       = errors.New("not an integer")
 	errNotFloat     = errors.New("not a float")
 	errZeroDivision = errors.New("zero division")
+	errNotIPv4      = errors.New("not an IPv4 address")
+	errIPOverflow   = errors.New("IP address out of range")
+	errNotList      = errors.New("not a list")
 )
 
 func jsonFunc(i interface{}) (string, error) {
@@ -144,3 +153,126 @@ func divFunc(a, b interface{}) (interface{}, error) {
 	}
 	return nil, err
 }
+
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errors.New("invalid IP address: " + s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return ip, nil
+}
+
+// addIP returns ip + n.  ip must be 4 or 16 bytes long.
+func addIP(ip net.IP, n *big.Int) (net.IP, error) {
+	v := new(big.Int).SetBytes(ip)
+	v.Add(v, n)
+	if v.Sign() < 0 || v.BitLen() > len(ip)*8 {
+		return nil, errIPOverflow
+	}
+	return net.IP(v.FillBytes(make([]byte, len(ip)))), nil
+}
+
+func ipAddFunc(ip string, n interface{}) (string, error) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return "", err
+	}
+	i, err := getAsInt64(n)
+	if err != nil {
+		return "", err
+	}
+	res, err := addIP(addr, big.NewInt(i))
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+func cidrHostFunc(cidr string, n interface{}) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	i, err := getAsInt64(n)
+	if err != nil {
+		return "", err
+	}
+
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	num := big.NewInt(i)
+	if i < 0 {
+		// count from the end of the network
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("host number %d is out of %s", i, cidr)
+	}
+
+	res, err := addIP(network.IP, num)
+	if err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+func cidrNetmaskFunc(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	if len(network.Mask) != net.IPv4len {
+		return "", errNotIPv4
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+func ipToIntFunc(ip string) (int64, error) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return 0, err
+	}
+	if len(addr) != net.IPv4len {
+		return 0, errNotIPv4
+	}
+	return int64(binary.BigEndian.Uint32(addr)), nil
+}
+
+func joinFunc(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", errNotList
+	}
+	strs := make([]string, v.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func splitFunc(sep, s string) []string {
+	return strings.Split(s, sep)
+}
+
+// defaultFunc returns val unless it is nil, a zero value, or an empty
+// string, slice, or map.  Otherwise, it returns def.
+func defaultFunc(def, val interface{}) interface{} {
+	if val == nil {
+		return def
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		if v.Len() == 0 {
+			return def
+		}
+	default:
+		if v.IsZero() {
+			return def
+		}
+	}
+	return val
+}
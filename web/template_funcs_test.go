@@ -1,9 +1,15 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
 	"math"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -84,6 +90,222 @@ var testCmp = cmp.Comparer(func(a, b float64) bool {
 	return math.Abs(a-b) < 0.0001
 })
 
+func testNetworkFuncs(t *testing.T) {
+	cases := []struct {
+		name    string
+		f       func() (interface{}, error)
+		expect  interface{}
+		wantErr bool
+	}{
+		{"ipAddIPv4", func() (interface{}, error) { return ipAddFunc("10.0.0.250", 10) }, "10.0.1.4", false},
+		{"ipAddIPv4Negative", func() (interface{}, error) { return ipAddFunc("10.0.1.0", int64(-1)) }, "10.0.0.255", false},
+		{"ipAddIPv6", func() (interface{}, error) { return ipAddFunc("fd00::ffff", 1) }, "fd00::1:0", false},
+		{"ipAddOverflow", func() (interface{}, error) { return ipAddFunc("255.255.255.255", 1) }, nil, true},
+		{"ipAddUnderflow", func() (interface{}, error) { return ipAddFunc("0.0.0.0", -1) }, nil, true},
+		{"ipAddInvalid", func() (interface{}, error) { return ipAddFunc("10.0.0", 1) }, nil, true},
+		{"ipAddString", func() (interface{}, error) { return ipAddFunc("10.0.0.1", "1") }, nil, true},
+		{"cidrHost", func() (interface{}, error) { return cidrHostFunc("10.69.0.0/26", 5) }, "10.69.0.5", false},
+		{"cidrHostNotNetwork", func() (interface{}, error) { return cidrHostFunc("10.69.0.3/26", 5) }, "10.69.0.5", false},
+		{"cidrHostLast", func() (interface{}, error) { return cidrHostFunc("10.69.0.0/26", -1) }, "10.69.0.63", false},
+		{"cidrHostIPv6", func() (interface{}, error) { return cidrHostFunc("fd00::/64", 256) }, "fd00::100", false},
+		{"cidrHostOutOfRange", func() (interface{}, error) { return cidrHostFunc("10.69.0.0/26", 64) }, nil, true},
+		{"cidrHostOutOfRangeNegative", func() (interface{}, error) { return cidrHostFunc("10.69.0.0/26", -65) }, nil, true},
+		{"cidrHostInvalid", func() (interface{}, error) { return cidrHostFunc("10.69.0.0", 1) }, nil, true},
+		{"cidrNetmask", func() (interface{}, error) { return cidrNetmaskFunc("10.69.0.0/26") }, "255.255.255.192", false},
+		{"cidrNetmaskIPv6", func() (interface{}, error) { return cidrNetmaskFunc("fd00::/64") }, nil, true},
+		{"ipToInt", func() (interface{}, error) { return ipToIntFunc("10.0.1.2") }, int64(0x0a000102), false},
+		{"ipToIntMax", func() (interface{}, error) { return ipToIntFunc("255.255.255.255") }, int64(0xffffffff), false},
+		{"ipToIntIPv6", func() (interface{}, error) { return ipToIntFunc("fd00::1") }, nil, true},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := tt.f()
+			if err != nil {
+				if !tt.wantErr {
+					t.Error("unexpected error:", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("error is expected, but got", actual)
+			}
+
+			if !cmp.Equal(tt.expect, actual) {
+				t.Error("unexpected result:", cmp.Diff(tt.expect, actual))
+			}
+		})
+	}
+}
+
+func testStringFuncs(t *testing.T) {
+	t.Parallel()
+
+	s, err := joinFunc(",", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "a,b" {
+		t.Error("unexpected join result:", s)
+	}
+	s, err = joinFunc(" ", []interface{}{1, "x", 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "1 x 2.5" {
+		t.Error("unexpected join result:", s)
+	}
+	_, err = joinFunc(",", "abc")
+	if err == nil {
+		t.Error("join should fail for a non-list")
+	}
+
+	if l := splitFunc(",", "a,b,,c"); !cmp.Equal(l, []string{"a", "b", "", "c"}) {
+		t.Error("unexpected split result:", l)
+	}
+
+	defaultCases := []struct {
+		val    interface{}
+		expect interface{}
+	}{
+		{nil, "def"},
+		{"", "def"},
+		{0, "def"},
+		{false, "def"},
+		{[]string{}, "def"},
+		{map[string]string{}, "def"},
+		{"abc", "abc"},
+		{3, 3},
+		{true, true},
+		{[]string{"a"}, []string{"a"}},
+	}
+	for _, c := range defaultCases {
+		actual := defaultFunc("def", c.val)
+		if !cmp.Equal(c.expect, actual) {
+			t.Errorf("unexpected default for %#v: %#v", c.val, actual)
+		}
+	}
+}
+
+func testFuncsInTemplate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := mock.NewModel()
+	testWithIPAM(t, m)
+
+	mc := sabakan.NewMachine(sabakan.MachineSpec{
+		Serial:      "1234abcd",
+		Rack:        1,
+		IndexInRack: 1,
+		Role:        "worker",
+		IPv4:        []string{"10.69.0.4"},
+		Labels:      map[string]string{"datacenter": "dc1"},
+	})
+	peers := []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "2222", Rack: 1, Role: "boot", IPv4: []string{"10.69.0.3"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1111", Rack: 1, Role: "boot", IPv4: []string{"10.69.0.2"}}),
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "3333", Rack: 2, Role: "boot", IPv4: []string{"10.69.0.66"}}),
+	}
+	err := m.Machine.Register(ctx, append(peers, mc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Asset.Put(ctx, "foo", "text/plain", nil, nil, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := m.Asset.GetInfo(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	units := []struct {
+		tmpl   string
+		expect string
+	}{
+		{`{{ ipAdd (index .Spec.IPv4 0) 1 }}`, "10.69.0.5"},
+		{`{{ cidrHost "10.69.0.0/26" -2 }}/{{ cidrNetmask "10.69.0.0/26" }}`, "10.69.0.62/255.255.255.192"},
+		{`{{ ipToInt "0.0.1.0" | add 1 }}`, "257"},
+		{`{{ .Spec.Role | upper }} {{ "DC" | lower }} {{ printf "%03d" .Spec.Rack }}`, "WORKER dc 001"},
+		{`{{ split "," "a,b" | join "-" }}`, "a-b"},
+		{`{{ Label "datacenter" }} {{ Label "none" | default "unknown" }}`, "dc1 unknown"},
+		{`{{ (Asset "foo").URL }} {{ (Asset "foo").Sha256 }}`, testMyURL + "/api/v1/assets/foo " + asset.Sha256},
+		{`{{ range Machines "rack" .Spec.Rack "role" "boot" }}{{ index .Spec.IPv4 0 }} {{ end }}`, "10.69.0.2 10.69.0.3 "},
+		{`{{ len (Machines "labels" "datacenter=dc1") }}`, "1"},
+	}
+
+	var tmplUnits []map[string]interface{}
+	for i, u := range units {
+		tmplUnits = append(tmplUnits, map[string]interface{}{
+			"name":     string(rune('a'+i)) + ".service",
+			"contents": u.tmpl,
+		})
+	}
+	tmplData, err := json.Marshal(map[string]interface{}{
+		"systemd": map[string]interface{}{"units": tmplUnits},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(m)
+	lookup := modelLookup{ctx: ctx, model: m, m: mc}
+	for _, ver := range []sabakan.IgnitionVersion{sabakan.Ignition2_2, sabakan.Ignition2_3} {
+		tmpl := &sabakan.IgnitionTemplate{
+			Version:  ver,
+			Template: json.RawMessage(tmplData),
+		}
+		rendered, err := s.renderIgnition(tmpl, mc, lookup)
+		if err != nil {
+			t.Fatal(ver, err)
+		}
+
+		data, err := json.Marshal(rendered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			Systemd struct {
+				Units []struct {
+					Contents string `json:"contents"`
+				} `json:"units"`
+			} `json:"systemd"`
+		}
+		err = json.Unmarshal(data, &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, u := range units {
+			if actual := result.Systemd.Units[i].Contents; actual != u.expect {
+				t.Errorf("%s: unexpected result of %s: %s", ver, u.tmpl, actual)
+			}
+		}
+	}
+
+	for _, bad := range []string{
+		`{{ Asset "none" }}`,
+		`{{ Machines "rack" }}`,
+		`{{ Machines 1 2 }}`,
+		`{{ Machines "labels" "=" }}`,
+		`{{ Machines "role" "boot" "without-role" "boot" }}`,
+	} {
+		tmpl := &sabakan.IgnitionTemplate{
+			Version:  sabakan.Ignition2_3,
+			Template: json.RawMessage(`{"systemd": {"units": [{"name": "a.service", "contents": ` + strconv.Quote(bad) + `}]}}`),
+		}
+		_, err := s.renderIgnition(tmpl, mc, lookup)
+		if err == nil {
+			t.Error("rendering should fail:", bad)
+		}
+	}
+}
+
 func TestTemplateFuncs(t *testing.T) {
 	t.Run("arithmetic", testArithmeticFuncs)
+	t.Run("network", testNetworkFuncs)
+	t.Run("string", testStringFuncs)
+	t.Run("template", testFuncsInTemplate)
 }
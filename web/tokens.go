@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/cybozu-go/sabakan/v3"
+)
+
+func (s Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	serial := r.URL.Path[len("/api/v1/tokens/"):]
+	if len(serial) == 0 {
+		renderError(r.Context(), w, APIErrBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handleTokensPost(w, r, serial)
+	case http.MethodDelete:
+		s.handleTokensDelete(w, r, serial)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleTokensPost(w http.ResponseWriter, r *http.Request, serial string) {
+	token, err := s.Model.Machine.IssueToken(r.Context(), serial)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	renderJSON(w, map[string]string{"token": token}, http.StatusCreated)
+}
+
+func (s Server) handleTokensDelete(w http.ResponseWriter, r *http.Request, serial string) {
+	err := s.Model.Machine.RevokeToken(r.Context(), serial)
+	if err == sabakan.ErrNotFound {
+		renderError(r.Context(), w, APIErrNotFound)
+		return
+	}
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+	}
+}
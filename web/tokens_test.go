@@ -0,0 +1,109 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/sabakan/v3"
+	"github.com/cybozu-go/sabakan/v3/models/mock"
+)
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+
+	m := mock.NewModel()
+	handler := newTestServer(m)
+	ctx := context.Background()
+
+	err := m.Machine.Register(ctx, []*sabakan.Machine{
+		sabakan.NewMachine(sabakan.MachineSpec{Serial: "1234abcd", Role: "boot"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Asset.Put(ctx, "foo", "text/plain", nil, map[string]string{"allow-roles": "boot"}, strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getAsset := func(token string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/assets/foo", nil)
+		r.RemoteAddr = "10.69.0.3:1234"
+		if len(token) > 0 {
+			r.Header.Set(sabakan.HeaderMachineToken, token)
+		}
+		handler.ServeHTTP(w, r)
+		return w.Result().StatusCode
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/tokens/ufuf", nil)
+	handler.ServeHTTP(w, r)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatal("resp.StatusCode != http.StatusNotFound:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/tokens/1234abcd", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal("resp.StatusCode != http.StatusMethodNotAllowed:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/tokens/1234abcd", nil)
+	r.RemoteAddr = "10.69.0.3:1234"
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("tokens should be issued only for allowed remotes:", resp.StatusCode)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/tokens/1234abcd", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatal("resp.StatusCode != http.StatusCreated:", resp.StatusCode)
+	}
+	var result struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := getAsset(""); status != http.StatusForbidden {
+		t.Error("unknown machine should be denied:", status)
+	}
+	if status := getAsset(result.Token); status != http.StatusOK {
+		t.Error("machine identified by token should be allowed:", status)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/tokens/1234abcd", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("resp.StatusCode != http.StatusOK:", resp.StatusCode)
+	}
+	if status := getAsset(result.Token); status != http.StatusForbidden {
+		t.Error("revoked token should be denied:", status)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/tokens/1234abcd", nil)
+	handler.ServeHTTP(w, r)
+	resp = w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatal("resp.StatusCode != http.StatusNotFound:", resp.StatusCode)
+	}
+}